@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/jo-hoe/go-mail-service/internal/compose"
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
+	"github.com/jo-hoe/go-mail-service/internal/templates"
+	"github.com/labstack/echo/v4"
+)
+
+// maxBatchSize is the most mails accepted in a single batch request.
+const maxBatchSize = 1000
+
+// sendBatchRequest holds the mails of a batch. Each is decoded on its own so
+// that a malformed mail only fails itself.
+type sendBatchRequest struct {
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// batchResult is the outcome of one mail of a batch, in the order of the request.
+type batchResult struct {
+	ID     string        `json:"id,omitempty"`
+	Status outbox.Status `json:"status,omitempty"`
+	// Code is the HTTP status /v1/sendmail would have answered the mail with.
+	Code  int    `json:"code"`
+	Error string `json:"error,omitempty"`
+}
+
+type sendBatchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// sendBatchHandler validates each mail of a batch on its own and sends the
+// valid ones together, so that providers with a batch API get them in as few
+// requests as possible. When the queue is enabled, they are stored as a
+// batch for background delivery instead. The response holds one result per mail.
+func sendBatchHandler(box *outbox.Outbox, library *templates.Library, composer *compose.Composer, cfg *config.Config) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		req := new(sendBatchRequest)
+		if err := ctx.Bind(req); err != nil {
+			slog.Error("failed to bind batch", "error", err)
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if len(req.Messages) == 0 || len(req.Messages) > maxBatchSize {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("a batch must hold between 1 and %d messages", maxBatchSize))
+		}
+
+		results := make([]batchResult, len(req.Messages))
+		var valid []int
+		var mails []mail.MailAttributes
+		for i, raw := range req.Messages {
+			var req sendMailRequest
+			if err := json.Unmarshal(raw, &req); err != nil {
+				results[i] = batchResult{Code: http.StatusBadRequest, Error: err.Error()}
+				continue
+			}
+			if err := readMail(ctx, cfg, library, composer, &req); err != nil {
+				results[i] = newBatchError(err)
+				continue
+			}
+			valid = append(valid, i)
+			mails = append(mails, req.MailAttributes)
+		}
+
+		slog.Info("received batch mail request", "messages", len(req.Messages), "valid", len(mails))
+		if box.Queued() {
+			msgs, errs := box.EnqueueBatch(mails)
+			for j, i := range valid {
+				if err := errs[j]; err != nil {
+					slog.Error("failed to queue mail", "error", err)
+					results[i] = batchResult{Code: http.StatusInternalServerError, Error: err.Error()}
+					continue
+				}
+				results[i] = batchResult{ID: msgs[j].ID, Status: msgs[j].Status, Code: http.StatusAccepted}
+			}
+			return ctx.JSON(http.StatusAccepted, sendBatchResponse{Results: results})
+		}
+
+		msgs, errs := box.SendBatch(ctx.Request().Context(), mails)
+		for j, i := range valid {
+			results[i] = batchResult{ID: msgs[j].ID, Status: msgs[j].Status, Code: http.StatusOK}
+			if err := errs[j]; err != nil {
+				slog.Error("failed to send mail of batch", "id", msgs[j].ID, "error", err)
+				results[i].Code = sendErrorStatus(err)
+				results[i].Error = err.Error()
+			}
+		}
+		return ctx.JSON(http.StatusOK, sendBatchResponse{Results: results})
+	}
+}
+
+// newBatchError reports a mail rejected with err, as returned by validateMail.
+func newBatchError(err error) batchResult {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return batchResult{Code: httpErr.Code, Error: httpErrorMessage(err)}
+	}
+	return batchResult{Code: http.StatusBadRequest, Error: err.Error()}
+}
+
+// sendErrorStatus is the HTTP status of a mail that could not be sent.
+func sendErrorStatus(err error) int {
+	if errors.Is(err, mail.ErrRateLimited) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
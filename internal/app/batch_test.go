@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/noop"
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
+	"github.com/jo-hoe/go-mail-service/internal/templates"
+)
+
+// subjectFailingService fails the mails with the subject "fail".
+type subjectFailingService struct {
+	sent int
+}
+
+func (s *subjectFailingService) SendMail(_ context.Context, attributes mail.MailAttributes) error {
+	if attributes.Subject == "fail" {
+		return mail.NewStatusError("mailjet", http.StatusBadRequest, "", errors.New("invalid recipient"))
+	}
+	s.sent++
+	return nil
+}
+
+// recordingService records the mails it sends.
+type recordingService struct {
+	sent []mail.MailAttributes
+}
+
+func (s *recordingService) SendMail(_ context.Context, attributes mail.MailAttributes) error {
+	s.sent = append(s.sent, attributes)
+	return nil
+}
+
+func decodeBatchResponse(t *testing.T, rec *httptest.ResponseRecorder) sendBatchResponse {
+	t.Helper()
+	var resp sendBatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return resp
+}
+
+func Test_sendBatchHandler(t *testing.T) {
+	cfg := testConfig()
+	svc := &subjectFailingService{}
+	ctx := newContextWithBody(`{"messages": [
+		{"to": "a@example.com", "subject": "Hello", "content": "Body"},
+		{"to": "b@example.com", "cc": ["not-an-address"], "subject": "Hello", "content": "Body"},
+		{"to": "b@example.com", "subject": 42},
+		{"to": "c@example.com", "subject": "Hello", "content": "Body", "from": "ceo@evil.com"},
+		{"to": "d@example.com", "subject": "fail", "content": "Body"},
+		{"to": "e@example.com", "subject": "Hello", "content": "Body"}
+	]}`)
+
+	if err := sendBatchHandler(outbox.New(outbox.NewMemoryStore(), svc, cfg), testLibrary(), testComposer(t), cfg)(ctx); err != nil {
+		t.Fatalf("sendBatchHandler() error = %v", err)
+	}
+	if status := ctx.Response().Status; status != http.StatusOK {
+		t.Errorf("sendBatchHandler() status = %d, want %d", status, http.StatusOK)
+	}
+
+	resp := decodeBatchResponse(t, ctx.Response().Writer.(*httptest.ResponseRecorder))
+	wantCodes := []int{http.StatusOK, http.StatusBadRequest, http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError, http.StatusOK}
+	if len(resp.Results) != len(wantCodes) {
+		t.Fatalf("got %d results, want %d", len(resp.Results), len(wantCodes))
+	}
+	for i, result := range resp.Results {
+		if result.Code != wantCodes[i] {
+			t.Errorf("results[%d] = %+v, want code %d", i, result, wantCodes[i])
+		}
+	}
+	if r := resp.Results[0]; r.ID == "" || r.Status != outbox.StatusSent || r.Error != "" {
+		t.Errorf("results[0] = %+v, want sent", r)
+	}
+	if r := resp.Results[4]; r.ID == "" || r.Status != outbox.StatusFailed || !strings.Contains(r.Error, "invalid recipient") {
+		t.Errorf("results[4] = %+v, want failed with the provider error", r)
+	}
+	if r := resp.Results[3]; r.ID != "" || !strings.Contains(r.Error, "not allowed") {
+		t.Errorf("results[3] = %+v, want rejected without an ID", r)
+	}
+	if svc.sent != 2 {
+		t.Errorf("sent %d mails, want 2", svc.sent)
+	}
+}
+
+func Test_sendBatchHandler_TemplatePerRecipient(t *testing.T) {
+	cfg := testConfig()
+	library := testLibrary()
+	if _, err := library.Create("welcome", templates.Definition{Content: templates.Content{
+		Subject:     "Welcome {{.name}}",
+		HtmlContent: "<p>Hi {{.name}}, your plan is {{.plan}}</p>",
+	}}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	svc := &recordingService{}
+	ctx := newContextWithBody(`{"messages": [
+		{"to": "ann@example.com", "templateId": "welcome", "data": {"name": "Ann", "plan": "Pro"}},
+		{"to": "bob@example.com", "templateId": "welcome", "data": {"name": "Bob", "plan": "Free"}}
+	]}`)
+
+	if err := sendBatchHandler(outbox.New(outbox.NewMemoryStore(), svc, cfg), library, testComposer(t), cfg)(ctx); err != nil {
+		t.Fatalf("sendBatchHandler() error = %v", err)
+	}
+	if len(svc.sent) != 2 {
+		t.Fatalf("sent %d mails, want 2", len(svc.sent))
+	}
+	want := []struct{ to, subject, content string }{
+		{to: "ann@example.com", subject: "Welcome Ann", content: "Hi Ann, your plan is Pro"},
+		{to: "bob@example.com", subject: "Welcome Bob", content: "Hi Bob, your plan is Free"},
+	}
+	for i, w := range want {
+		got := svc.sent[i]
+		if got.To != w.to || got.Subject != w.subject || !strings.Contains(got.HtmlContent, w.content) {
+			t.Errorf("sent[%d] = to %q, subject %q, content %q, want %q, %q with %q", i, got.To, got.Subject, got.HtmlContent, w.to, w.subject, w.content)
+		}
+	}
+}
+
+func Test_sendBatchHandler_Queued(t *testing.T) {
+	cfg := testConfig()
+	cfg.Queue = config.QueueConfig{Enabled: true, Workers: 1, MaxAttempts: 1}
+	// the outbox is not started, so nothing is sent by the handler
+	queue := outbox.New(outbox.NewMemoryStore(), &errorMailService{}, cfg)
+	ctx := newContextWithBody(`{"messages": [
+		{"to": "a@example.com", "subject": "Hello", "content": "Body"},
+		{"to": "b@example.com"}
+	]}`)
+
+	if err := sendBatchHandler(queue, testLibrary(), testComposer(t), cfg)(ctx); err != nil {
+		t.Fatalf("sendBatchHandler() error = %v", err)
+	}
+	if status := ctx.Response().Status; status != http.StatusAccepted {
+		t.Errorf("sendBatchHandler() status = %d, want %d", status, http.StatusAccepted)
+	}
+	resp := decodeBatchResponse(t, ctx.Response().Writer.(*httptest.ResponseRecorder))
+	if len(resp.Results) != 2 || resp.Results[0].Code != http.StatusAccepted || resp.Results[1].Code != http.StatusBadRequest {
+		t.Fatalf("results = %+v, want the first accepted and the second rejected", resp.Results)
+	}
+	msg, err := queue.Get(resp.Results[0].ID)
+	if err != nil || msg.Status != outbox.StatusAccepted {
+		t.Errorf("Get() = %+v, %v, want the queued message", msg, err)
+	}
+}
+
+func Test_sendBatchHandler_Size(t *testing.T) {
+	item := `{"to": "a@example.com", "subject": "Hello", "content": "Body"}`
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "empty", body: `{"messages": []}`},
+		{name: "too large", body: `{"messages": [` + strings.Repeat(item+",", maxBatchSize) + item + `]}`},
+		{name: "invalid json", body: `[`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			ctx := newContextWithBody(tt.body)
+			err := sendBatchHandler(outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg), testLibrary(), testComposer(t), cfg)(ctx)
+			if status := responseStatus(ctx, err); status != http.StatusBadRequest {
+				t.Errorf("sendBatchHandler() status = %d, want %d", status, http.StatusBadRequest)
+			}
+		})
+	}
+}
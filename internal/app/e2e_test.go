@@ -0,0 +1,489 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail/breaker"
+	"github.com/jo-hoe/go-mail-service/internal/mail/fakeesp"
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
+)
+
+// e2eService is the service wired up from a config file the way main does,
+// with Mailjet and SendGrid pointed at fake API servers.
+type e2eService struct {
+	url      string
+	mailjet  *fakeesp.MailjetServer
+	sendgrid *fakeesp.SendGridServer
+}
+
+// newE2EService starts the service; routes is inserted into the provider
+// section of the config. With sendgridAccounts, SendGrid is configured with
+// one account per name, whose API key is "<name>-key", instead of a single key.
+func newE2EService(t *testing.T, routes string, sendgridAccounts ...string) *e2eService {
+	t.Helper()
+	mailjet := fakeesp.NewMailjetServer("mj-public", "mj-private")
+	t.Cleanup(mailjet.Close)
+
+	dir := t.TempDir()
+	secret := func(name, value string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return filepath.ToSlash(path)
+	}
+
+	sendgridKeys := []string{"sg-key"}
+	sendgridAuth := `    apiKeyFile: "` + secret("sg-key", "sg-key") + `"`
+	if len(sendgridAccounts) > 0 {
+		sendgridKeys = nil
+		sendgridAuth = "    accounts:"
+		for _, name := range sendgridAccounts {
+			sendgridKeys = append(sendgridKeys, name+"-key")
+			sendgridAuth += `
+      - name: "` + name + `"
+        apiKeyFile: "` + secret(name+"-key", name+"-key") + `"`
+		}
+	}
+	sendgrid := fakeesp.NewSendGridServer(sendgridKeys...)
+	t.Cleanup(sendgrid.Close)
+
+	content := `sender:
+  address: "noreply@example.com"
+  name: "Example"
+http:
+  port: 8080
+smtp:
+  port: 587
+  domain: "mail.example.com"
+retry:
+  maxAttempts: 2
+  initialBackoff: "1ms"
+  maxBackoff: "5ms"
+circuitBreaker:
+  failureThreshold: 3
+  openDuration: "1m"
+provider:
+` + routes + `
+  mailjet:
+    enabled: true
+    apiKeyPublicFile: "` + secret("mj-public", "mj-public") + `"
+    apiKeyPrivateFile: "` + secret("mj-private", "mj-private") + `"
+    baseURL: "` + mailjet.URL() + `"
+    timeout: "5s"
+  sendgrid:
+    enabled: true
+` + sendgridAuth + `
+    baseURL: "` + sendgrid.URL() + `"
+    timeout: "5s"
+`
+	cfg, err := config.Load(secret("config.yaml", content))
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+
+	svc, breakers, err := resolveMailService(cfg)
+	if err != nil {
+		t.Fatalf("resolveMailService() error = %v", err)
+	}
+	box := outbox.New(outbox.NewMemoryStore(), svc, cfg)
+	server := httptest.NewServer(buildHTTPServer(cfg, box, testLibrary(), testComposer(t), breakers))
+	t.Cleanup(server.Close)
+
+	return &e2eService{url: server.URL, mailjet: mailjet, sendgrid: sendgrid}
+}
+
+// send posts a mail to /v1/sendmail and returns the status code and the message ID.
+func (s *e2eService) send(t *testing.T, body string) (int, string) {
+	t.Helper()
+	return s.sendAs(t, "", body)
+}
+
+// sendAs posts a mail on behalf of the API client clientID.
+func (s *e2eService) sendAs(t *testing.T, clientID, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, s.url+"/v1/sendmail", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if clientID != "" {
+		req.Header.Set(clientIDHeader, clientID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /v1/sendmail error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var sent sendMailResponse
+	_ = json.NewDecoder(resp.Body).Decode(&sent)
+	return resp.StatusCode, sent.ID
+}
+
+// message looks up a message via /v1/messages/{id}.
+func (s *e2eService) message(t *testing.T, id string) messageResponse {
+	t.Helper()
+	resp, err := http.Get(s.url + "/v1/messages/" + id)
+	if err != nil {
+		t.Fatalf("GET /v1/messages error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var message messageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		t.Fatalf("decoding message: %v", err)
+	}
+	return message
+}
+
+const e2eMail = `{"to":"a@example.com","cc":["c@example.com"],"subject":"Invoice","content":"<p>Your invoice</p>"}`
+
+func TestE2E_SendViaMailjet(t *testing.T) {
+	service := newE2EService(t, "")
+
+	status, id := service.send(t, e2eMail)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	messages := service.mailjet.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("mailjet received %d messages, want 1", len(messages))
+	}
+	got := messages[0]
+	if got.From.Email != "noreply@example.com" || got.From.Name != "Example" || got.Subject != "Invoice" {
+		t.Errorf("mailjet message = %+v", got)
+	}
+	if len(got.To) != 1 || got.To[0].Email != "a@example.com" || len(got.Cc) != 1 || got.TextPart != "Your invoice" {
+		t.Errorf("mailjet message = %+v", got)
+	}
+	if len(service.sendgrid.Mails()) != 0 {
+		t.Errorf("sendgrid received %d mails, want 0", len(service.sendgrid.Mails()))
+	}
+
+	message := service.message(t, id)
+	if message.Status != outbox.StatusSent || message.Provider != config.ProviderMailjet {
+		t.Errorf("message status = %q via %q, want sent via mailjet", message.Status, message.Provider)
+	}
+	if len(message.ProviderMessageIDs) != 1 || message.ProviderMessageIDs[0] != "mailjet-1" {
+		t.Errorf("provider message IDs = %v, want [mailjet-1]", message.ProviderMessageIDs)
+	}
+}
+
+func TestE2E_RetriesRateLimitedMailjet(t *testing.T) {
+	service := newE2EService(t, "")
+	service.mailjet.FailNext(fakeesp.TooManyRequests("0"))
+
+	status, id := service.send(t, e2eMail)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if n := len(service.mailjet.Requests()); n != 2 {
+		t.Errorf("mailjet received %d requests, want 2", n)
+	}
+	if message := service.message(t, id); message.Provider != config.ProviderMailjet {
+		t.Errorf("delivered via %q, want mailjet", message.Provider)
+	}
+}
+
+func TestE2E_FailsOverToSendGrid(t *testing.T) {
+	service := newE2EService(t, "")
+	unavailable := fakeesp.Response{StatusCode: http.StatusServiceUnavailable, Body: "maintenance"}
+	service.mailjet.FailNext(unavailable, unavailable)
+
+	status, id := service.send(t, e2eMail)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	mails := service.sendgrid.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("sendgrid received %d mails, want 1", len(mails))
+	}
+	if p := mails[0].Personalizations; len(p) != 1 || p[0].Subject != "Invoice" || len(p[0].Cc) != 1 {
+		t.Errorf("sendgrid personalizations = %+v", p)
+	}
+
+	message := service.message(t, id)
+	if message.Provider != config.ProviderSendGrid || len(message.ProviderMessageIDs) != 1 || message.ProviderMessageIDs[0] != "sendgrid-1" {
+		t.Errorf("delivered via %q with IDs %v, want sendgrid with sendgrid-1", message.Provider, message.ProviderMessageIDs)
+	}
+}
+
+func TestE2E_PermanentMailjetErrorIsNotFailedOver(t *testing.T) {
+	service := newE2EService(t, "")
+	service.mailjet.FailNext(fakeesp.MailjetErrors(http.StatusBadRequest, fakeesp.MailjetError{
+		ErrorCode:    "mj-0013",
+		StatusCode:   http.StatusBadRequest,
+		ErrorMessage: `"a@example.com" is an invalid email address.`,
+	}))
+
+	status, _ := service.send(t, e2eMail)
+	if status != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", status, http.StatusInternalServerError)
+	}
+	if n := len(service.mailjet.Requests()); n != 1 {
+		t.Errorf("mailjet received %d requests, want 1", n)
+	}
+	if n := len(service.sendgrid.Mails()); n != 0 {
+		t.Errorf("sendgrid received %d mails, want 0", n)
+	}
+
+	resp, err := http.Get(service.url + "/v1/messages?status=failed")
+	if err != nil {
+		t.Fatalf("GET /v1/messages error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var failed listMessagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&failed); err != nil {
+		t.Fatalf("decoding messages: %v", err)
+	}
+	if len(failed.Messages) != 1 || !strings.Contains(failed.Messages[0].LastError, "mj-0013") {
+		t.Errorf("failed messages = %+v, want one with the Mailjet error", failed.Messages)
+	}
+}
+
+const e2eRoutes = `  routes:
+    - name: "internal"
+      match:
+        recipientDomains: ["corp.example.com"]
+      providers: ["sendgrid"]
+    - name: "crm"
+      match:
+        clients: ["crm"]
+      providers: ["sendgrid"]`
+
+func TestE2E_RoutesByRecipientDomain(t *testing.T) {
+	service := newE2EService(t, e2eRoutes)
+
+	status, id := service.send(t, `{"to":"a@corp.example.com, b@example.org","subject":"Split","content":"<p>Hi</p>"}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	mails := service.sendgrid.Mails()
+	if len(mails) != 1 || len(mails[0].Personalizations[0].To) != 1 || mails[0].Personalizations[0].To[0].Email != "a@corp.example.com" {
+		t.Errorf("sendgrid mails = %+v, want only the corp recipient", mails)
+	}
+	messages := service.mailjet.Messages()
+	if len(messages) != 1 || len(messages[0].To) != 1 || messages[0].To[0].Email != "b@example.org" {
+		t.Errorf("mailjet messages = %+v, want only the external recipient", messages)
+	}
+
+	message := service.message(t, id)
+	if message.Provider != "sendgrid,mailjet" || len(message.ProviderMessageIDs) != 2 {
+		t.Errorf("delivered via %q with IDs %v, want sendgrid,mailjet with two IDs", message.Provider, message.ProviderMessageIDs)
+	}
+}
+
+func TestE2E_RoutesByClient(t *testing.T) {
+	service := newE2EService(t, e2eRoutes)
+
+	status, _ := service.sendAs(t, "crm", e2eMail)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if len(service.sendgrid.Mails()) != 1 || len(service.mailjet.Requests()) != 0 {
+		t.Errorf("sendgrid got %d mails, mailjet %d, want 1 and 0", len(service.sendgrid.Mails()), len(service.mailjet.Requests()))
+	}
+
+	// the client cannot be claimed in the request body
+	body := `{"to":"a@example.org","subject":"Invoice","content":"<p>Hi</p>","client":"crm"}`
+	if status, _ := service.send(t, body); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if len(service.mailjet.Requests()) != 1 {
+		t.Errorf("mailjet got %d requests, want 1", len(service.mailjet.Requests()))
+	}
+}
+
+func TestE2E_BalancesSendGridAccounts(t *testing.T) {
+	service := newE2EService(t, "", "primary", "secondary")
+	// mailjet is down for all three mails, two attempts each
+	unavailable := fakeesp.Response{StatusCode: http.StatusServiceUnavailable, Body: "maintenance"}
+	service.mailjet.FailNext(unavailable, unavailable, unavailable, unavailable, unavailable, unavailable)
+
+	for range 2 {
+		if status, _ := service.send(t, e2eMail); status != http.StatusOK {
+			t.Fatalf("status = %d, want %d", status, http.StatusOK)
+		}
+	}
+	if keys := service.sendgrid.APIKeys(); len(keys) != 2 || keys[0] != "primary-key" || keys[1] != "secondary-key" {
+		t.Errorf("sendgrid API keys = %v, want one mail per account", keys)
+	}
+
+	// a rate limited account is skipped in favor of the other one
+	service.sendgrid.Reset()
+	service.sendgrid.FailNext(fakeesp.TooManyRequests("60"))
+	status, id := service.send(t, e2eMail)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if keys := service.sendgrid.APIKeys(); len(keys) != 2 || keys[0] != "primary-key" || keys[1] != "secondary-key" {
+		t.Errorf("sendgrid API keys = %v, want the throttled primary followed by secondary", keys)
+	}
+	if message := service.message(t, id); message.Provider != config.ProviderSendGrid {
+		t.Errorf("delivered via %q, want sendgrid", message.Provider)
+	}
+}
+
+func TestE2E_OpenCircuitSkipsMailjet(t *testing.T) {
+	service := newE2EService(t, "")
+	unavailable := fakeesp.Response{StatusCode: http.StatusServiceUnavailable, Body: "maintenance"}
+	service.mailjet.FailNext(unavailable, unavailable, unavailable, unavailable, unavailable, unavailable)
+
+	for range 4 {
+		if status, _ := service.send(t, e2eMail); status != http.StatusOK {
+			t.Fatalf("status = %d, want %d", status, http.StatusOK)
+		}
+	}
+	// three mails with two attempts each open the circuit; the fourth goes straight to sendgrid
+	if n := len(service.mailjet.Requests()); n != 6 {
+		t.Errorf("mailjet received %d requests, want 6", n)
+	}
+	if n := len(service.sendgrid.Mails()); n != 4 {
+		t.Errorf("sendgrid received %d mails, want 4", n)
+	}
+
+	resp, err := http.Get(service.url + "/v1/providers")
+	if err != nil {
+		t.Fatalf("GET /v1/providers error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var providers listProvidersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&providers); err != nil {
+		t.Fatalf("decoding providers: %v", err)
+	}
+	if len(providers.Providers) != 2 || providers.Providers[0].State != breaker.StateOpen || providers.Providers[1].State != breaker.StateClosed {
+		t.Errorf("providers = %+v, want mailjet open and sendgrid closed", providers.Providers)
+	}
+
+	metrics, err := http.Get(service.url + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	defer func() { _ = metrics.Body.Close() }()
+	body, _ := io.ReadAll(metrics.Body)
+	if !strings.Contains(string(body), `mail_provider_circuit_state{provider="mailjet",state="open"} 1`) {
+		t.Errorf("metrics = %s, want mailjet open", body)
+	}
+}
+
+func TestE2E_RateLimitedProviderFailsOver(t *testing.T) {
+	service := newE2EService(t, `  rateLimitMaxWait: "1ms"
+  rateLimits:
+    mailjet:
+      perMinute: 1
+    sendgrid:
+      perMinute: 1`)
+
+	// the first mail uses up mailjet's quota, the second one sendgrid's
+	for range 2 {
+		if status, _ := service.send(t, e2eMail); status != http.StatusOK {
+			t.Fatalf("status = %d, want %d", status, http.StatusOK)
+		}
+	}
+	if status, _ := service.send(t, e2eMail); status != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if len(service.mailjet.Requests()) != 1 || len(service.sendgrid.Mails()) != 1 {
+		t.Errorf("mailjet got %d requests and sendgrid %d mails, want one each", len(service.mailjet.Requests()), len(service.sendgrid.Mails()))
+	}
+}
+
+// sendBatch posts mails to /v1/sendmail/batch and returns the results.
+func (s *e2eService) sendBatch(t *testing.T, mails ...string) []batchResult {
+	t.Helper()
+	body := `{"messages":[` + strings.Join(mails, ",") + `]}`
+	resp, err := http.Post(s.url+"/v1/sendmail/batch", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /v1/sendmail/batch error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var batch sendBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		t.Fatalf("decoding batch response: %v", err)
+	}
+	return batch.Results
+}
+
+func TestE2E_SendBatch(t *testing.T) {
+	service := newE2EService(t, "")
+
+	results := service.sendBatch(t,
+		`{"to":"a@example.com","subject":"Invoice 1","content":"<p>Your invoice</p>"}`,
+		`{"to":"b@example.com","subject":"Invoice 2","content":"<p>Your invoice</p>"}`,
+		`{"to":"c@example.com","subject":"Invoice 3"}`,
+	)
+	if len(results) != 3 || results[0].Code != http.StatusOK || results[1].Code != http.StatusOK || results[2].Code != http.StatusBadRequest {
+		t.Fatalf("results = %+v, want two sent and one rejected", results)
+	}
+	requests := service.mailjet.Requests()
+	if len(requests) != 1 || len(requests[0].Messages) != 2 {
+		t.Fatalf("mailjet got %d requests, want the two mails in one", len(requests))
+	}
+	if message := service.message(t, results[1].ID); message.Status != outbox.StatusSent || len(message.ProviderMessageIDs) != 1 || message.ProviderMessageIDs[0] != "mailjet-2" {
+		t.Errorf("message = %+v, want sent as mailjet-2", message)
+	}
+
+	// with mailjet down, the batch fails over to sendgrid as personalizations of one mail
+	unavailable := fakeesp.Response{StatusCode: http.StatusServiceUnavailable, Body: "maintenance"}
+	service.mailjet.FailNext(unavailable, unavailable)
+	results = service.sendBatch(t,
+		`{"to":"a@example.com","subject":"Invoice 1","content":"<p>Your invoice</p>"}`,
+		`{"to":"b@example.com","subject":"Invoice 2","content":"<p>Your invoice</p>"}`,
+	)
+	if results[0].Code != http.StatusOK || results[1].Code != http.StatusOK {
+		t.Fatalf("results = %+v, want both sent", results)
+	}
+	mails := service.sendgrid.Mails()
+	if len(mails) != 1 || len(mails[0].Personalizations) != 2 {
+		t.Errorf("sendgrid got %d mails, want one with two personalizations", len(mails))
+	}
+	if message := service.message(t, results[0].ID); message.Provider != config.ProviderSendGrid {
+		t.Errorf("delivered via %q, want sendgrid", message.Provider)
+	}
+}
+
+func TestE2E_SendWithTemplate(t *testing.T) {
+	service := newE2EService(t, "")
+
+	resp, err := http.Post(service.url+"/v1/templates", "application/json", strings.NewReader(
+		`{"id":"invoice","subject":"Invoice {{.number}}","content":"<p>Dear {{.name}}, your invoice {{.number}}</p>"}`))
+	if err != nil {
+		t.Fatalf("POST /v1/templates error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create template status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	// each mail of a batch carries the data of its recipient
+	results := service.sendBatch(t,
+		`{"to":"a@example.com","templateId":"invoice","data":{"name":"Ann","number":1}}`,
+		`{"to":"b@example.com","templateId":"invoice","data":{"name":"<Bob>","number":2}}`,
+	)
+	if results[0].Code != http.StatusOK || results[1].Code != http.StatusOK {
+		t.Fatalf("results = %+v, want both sent", results)
+	}
+	messages := service.mailjet.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("mailjet received %d messages, want 2", len(messages))
+	}
+	if got := messages[0]; got.Subject != "Invoice 1" || got.HTMLPart != "<p>Dear Ann, your invoice 1</p>" || got.TextPart != "Dear Ann, your invoice 1" {
+		t.Errorf("first message = %+v", got)
+	}
+	if got := messages[1]; got.Subject != "Invoice 2" || got.HTMLPart != "<p>Dear &lt;Bob&gt;, your invoice 2</p>" {
+		t.Errorf("second message = %+v", got)
+	}
+}
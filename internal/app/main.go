@@ -5,20 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	netmail "net/mail"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/go-playground/validator"
+	"github.com/jo-hoe/go-mail-service/internal/compose"
 	"github.com/jo-hoe/go-mail-service/internal/config"
 	"github.com/jo-hoe/go-mail-service/internal/logging"
 	"github.com/jo-hoe/go-mail-service/internal/mail"
-	"github.com/jo-hoe/go-mail-service/internal/mail/mailjet"
-	"github.com/jo-hoe/go-mail-service/internal/mail/noop"
-	"github.com/jo-hoe/go-mail-service/internal/mail/sendgrid"
+	"github.com/jo-hoe/go-mail-service/internal/mail/breaker"
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
 	appsmtp "github.com/jo-hoe/go-mail-service/internal/smtp"
+	"github.com/jo-hoe/go-mail-service/internal/templates"
 	"github.com/jo-hoe/go-mail-service/internal/validation"
 
 	"github.com/labstack/echo/v4"
@@ -29,6 +33,9 @@ const configPathEnvKey = "CONFIG_PATH"
 const defaultConfigPath = "/config/config.yaml"
 const shutdownTimeout = 10 * time.Second
 
+// clientIDHeader identifies the API client that submits a mail, e.g. for routing.
+const clientIDHeader = "X-Client-ID"
+
 func main() {
 	cfgPath := os.Getenv(configPathEnvKey)
 	if cfgPath == "" {
@@ -47,14 +54,40 @@ func main() {
 		JSON:      false,
 	})
 
-	svc, err := resolveMailService(cfg)
+	svc, breakers, err := resolveMailService(cfg)
 	if err != nil {
 		slog.Error("failed to resolve mail service", "error", err)
 		os.Exit(1)
 	}
 
-	e := buildHTTPServer(svc)
-	smtpServer, err := appsmtp.NewSMTPServer(&cfg.SMTP, svc)
+	// Both HTTP and SMTP ingestion go through the outbox so every message is
+	// recorded. With the queue enabled, it is persisted and delivered in the background.
+	store, err := openMessageStore(cfg)
+	if err != nil {
+		slog.Error("failed to open message store", "error", err)
+		os.Exit(1)
+	}
+	box := outbox.New(store, svc, cfg)
+	if err := box.Start(context.Background()); err != nil {
+		slog.Error("failed to start outbox", "error", err)
+		os.Exit(1)
+	}
+
+	templateStore, err := openTemplateStore(cfg)
+	if err != nil {
+		slog.Error("failed to open template store", "error", err)
+		os.Exit(1)
+	}
+	library := templates.NewLibrary(templateStore)
+
+	composer, err := compose.NewComposer(cfg)
+	if err != nil {
+		slog.Error("failed to create composer", "error", err)
+		os.Exit(1)
+	}
+
+	e := buildHTTPServer(cfg, box, library, composer, breakers)
+	smtpServer, err := appsmtp.NewSMTPServer(cfg, box, composer)
 	if err != nil {
 		slog.Error("failed to create smtp server", "error", err)
 		os.Exit(1)
@@ -84,75 +117,176 @@ func main() {
 	if err := errors.Join(e.Shutdown(ctx), smtpServer.Shutdown(ctx)); err != nil {
 		slog.Error("shutdown error", "error", err)
 	}
+	if err := errors.Join(box.Shutdown(ctx), store.Close()); err != nil {
+		slog.Error("outbox shutdown error", "error", err)
+	}
+	if err := templateStore.Close(); err != nil {
+		slog.Error("template store shutdown error", "error", err)
+	}
+}
+
+// openMessageStore opens the persistent queue database, or an in-memory store
+// that only records message status when the queue is disabled.
+func openMessageStore(cfg *config.Config) (outbox.Store, error) {
+	if !cfg.Queue.Enabled {
+		return outbox.NewMemoryStore(), nil
+	}
+	return outbox.OpenBoltStore(cfg.Queue.Path)
+}
+
+// openTemplateStore opens the template database, or an in-memory store if
+// no path is configured.
+func openTemplateStore(cfg *config.Config) (templates.Store, error) {
+	if cfg.Templates.Path == "" {
+		slog.Warn("templates.path is not set, templates are lost on restart")
+		return templates.NewMemoryStore(), nil
+	}
+	return templates.OpenBoltStore(cfg.Templates.Path)
 }
 
-func buildHTTPServer(svc mail.MailService) *echo.Echo {
+func buildHTTPServer(cfg *config.Config, box *outbox.Outbox, library *templates.Library, composer *compose.Composer, breakers []*breaker.BreakerService) *echo.Echo {
 	e := echo.New()
 	e.Use(middleware.RequestLoggerWithConfig(requestLoggerConfig()))
 	e.Use(middleware.Recover())
 	e.Validator = &validation.GenericValidator{Validator: validator.New()}
 
-	e.POST("/v1/sendmail", sendMailHandler(svc))
+	e.POST("/v1/sendmail", sendMailHandler(box, library, composer, cfg))
+	e.POST("/v1/sendmail/batch", sendBatchHandler(box, library, composer, cfg))
+	e.POST("/v1/templates", createTemplateHandler(library))
+	e.GET("/v1/templates", listTemplatesHandler(library))
+	e.GET("/v1/templates/:id", getTemplateHandler(library))
+	e.PUT("/v1/templates/:id", updateTemplateHandler(library))
+	e.DELETE("/v1/templates/:id", deleteTemplateHandler(library))
+	e.GET("/v1/templates/:id/versions/:version", getTemplateVersionHandler(library))
+	e.POST("/v1/render", renderHandler(library, composer, cfg))
+	e.GET("/v1/messages", listMessagesHandler(box))
+	e.GET("/v1/messages/:id", getMessageHandler(box))
+	e.GET("/v1/providers", listProvidersHandler(breakers))
+	e.GET("/metrics", metricsHandler(breakers))
 	e.GET("/", probeHandler)
 
 	return e
 }
 
-func sendMailHandler(svc mail.MailService) echo.HandlerFunc {
+// sendMailResponse echoes the mail together with its message ID and status.
+// Bcc recipients and attachments are left out, see newSendMailResponse.
+type sendMailResponse struct {
+	mail.MailAttributes
+	ID     string        `json:"id"`
+	Status outbox.Status `json:"status"`
+}
+
+// newSendMailResponse echoes attributes without the Bcc recipients, which
+// must not be shown, and the attachments, which the client already has.
+func newSendMailResponse(attributes mail.MailAttributes, msg outbox.Message) sendMailResponse {
+	attributes.Bcc, attributes.Attachments = nil, nil
+	return sendMailResponse{MailAttributes: attributes, ID: msg.ID, Status: msg.Status}
+}
+
+// sendMailHandler sends the mail right away and answers 200, or, when the
+// queue is enabled, stores it for background delivery and answers 202.
+// A mail that names a template is rendered before it is validated.
+func sendMailHandler(box *outbox.Outbox, library *templates.Library, composer *compose.Composer, cfg *config.Config) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
-		attrs := new(mail.MailAttributes)
-		if err := ctx.Bind(attrs); err != nil {
+		req := new(sendMailRequest)
+		if err := ctx.Bind(req); err != nil {
 			slog.Error("failed to bind mail attributes", "error", err)
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
-		if err := ctx.Validate(attrs); err != nil {
-			slog.Error("failed to validate mail attributes", "error", err)
+		if err := readMail(ctx, cfg, library, composer, req); err != nil {
 			return err
 		}
+		attrs := &req.MailAttributes
 
 		slog.Info("received mail request")
-		if err := svc.SendMail(ctx.Request().Context(), *attrs); err != nil {
-			slog.Error("failed to send mail", "error", err)
+		if box.Queued() {
+			msg, err := box.Enqueue(*attrs)
+			if err != nil {
+				slog.Error("failed to queue mail", "error", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+			return ctx.JSON(http.StatusAccepted, newSendMailResponse(*attrs, msg))
+		}
+
+		msg, err := box.Send(ctx.Request().Context(), *attrs)
+		if errors.Is(err, mail.ErrRateLimited) {
+			slog.Warn("mail held back by rate limit", "id", msg.ID, "error", err)
+			if retryAfter := mail.RetryAfter(err); retryAfter > 0 {
+				ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			}
+			return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+		}
+		if err != nil {
+			slog.Error("failed to send mail", "id", msg.ID, "error", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
 
-		return ctx.JSON(http.StatusOK, attrs)
+		return ctx.JSON(http.StatusOK, newSendMailResponse(*attrs, msg))
 	}
 }
 
-func probeHandler(ctx echo.Context) error {
-	return ctx.NoContent(http.StatusOK)
+// validateMail checks a mail submitted over HTTP and returns the HTTP error to reject it with.
+// A sender written as "Name <address>" is split into From and FromName.
+func validateMail(ctx echo.Context, cfg *config.Config, attrs *mail.MailAttributes) error {
+	if err := ctx.Validate(attrs); err != nil {
+		slog.Error("failed to validate mail attributes", "error", err)
+		return err
+	}
+	for _, address := range attrs.ToAddresses() {
+		if _, err := netmail.ParseAddress(address); err != nil {
+			slog.Error("rejected recipient address", "to", address, "error", err)
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("recipient address %q is invalid", address))
+		}
+	}
+	if attrs.From != "" {
+		// providers take the bare address, a display name goes to FromName
+		from, err := netmail.ParseAddress(attrs.From)
+		if err != nil {
+			slog.Error("rejected sender address", "from", attrs.From, "error", err)
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("sender address %q is invalid", attrs.From))
+		}
+		attrs.From = from.Address
+		if attrs.FromName == "" {
+			attrs.FromName = from.Name
+		}
+	}
+	if attrs.From != "" && !cfg.Sender.IsAllowed(attrs.From) {
+		slog.Error("rejected sender address", "from", attrs.From)
+		return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("sender address %q is not allowed", attrs.From))
+	}
+	if err := mail.ValidateAttachmentSize(attrs.Attachments, cfg.Attachments.MaxTotalBytes); err != nil {
+		slog.Error("failed to validate attachments", "error", err)
+		if errors.Is(err, mail.ErrAttachmentsTooLarge) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return nil
 }
 
-// resolveMailService returns the highest-priority enabled mail provider.
-func resolveMailService(cfg *config.Config) (mail.MailService, error) {
-	p := cfg.Provider
-	switch {
-	case p.Mailjet.Enabled:
-		mCfg := mailjet.NewMailjetConfig(
-			p.Mailjet.APIKeyPublic,
-			p.Mailjet.APIKeyPrivate,
-			cfg.Sender.Address,
-			cfg.Sender.Name,
-		)
-		return mailjet.NewMailjetService(mCfg), nil
-	case p.SendGrid.Enabled:
-		sCfg := sendgrid.NewSendGridConfig(
-			p.SendGrid.APIKey,
-			cfg.Sender.Address,
-			cfg.Sender.Name,
-		)
-		return sendgrid.NewSendGridService(sCfg), nil
-	case p.Noop.Enabled:
-		return noop.NewNoopService(), nil
-	default:
-		return nil, fmt.Errorf("no mail provider is enabled")
+// composeMail renders the content of a mail into the HTML sent to the provider.
+func composeMail(composer *compose.Composer, attrs *mail.MailAttributes) error {
+	err := composer.Compose(attrs)
+	if errors.Is(err, compose.ErrContentType) {
+		slog.Error("failed to compose mail", "error", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		slog.Error("failed to compose mail", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	return nil
+}
+
+func probeHandler(ctx echo.Context) error {
+	return ctx.NoContent(http.StatusOK)
 }
 
 func requestLoggerConfig() middleware.RequestLoggerConfig {
 	return middleware.RequestLoggerConfig{
-		Skipper:      func(c echo.Context) bool { return c.Request().Method == http.MethodGet && c.Path() == "/" },
+		Skipper: func(c echo.Context) bool {
+			return c.Request().Method == http.MethodGet && (c.Path() == "/" || c.Path() == "/metrics")
+		},
 		LogStatus:    true,
 		LogLatency:   true,
 		LogURI:       true,
@@ -3,13 +3,21 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-playground/validator"
+	"github.com/jo-hoe/go-mail-service/internal/compose"
+	"github.com/jo-hoe/go-mail-service/internal/config"
 	"github.com/jo-hoe/go-mail-service/internal/mail"
 	"github.com/jo-hoe/go-mail-service/internal/mail/noop"
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
 	"github.com/jo-hoe/go-mail-service/internal/validation"
 	"github.com/labstack/echo/v4"
 )
@@ -43,6 +51,85 @@ func Test_sendMailHandler(t *testing.T) {
 			wantStatus: http.StatusOK,
 			wantErr:    false,
 		},
+		{
+			name:       "cc, bcc and reply-to",
+			body:       `{"to": "a@example.com", "cc": ["b@example.com"], "bcc": ["c@example.com"], "replyTo": "d@example.com", "subject": "Test", "content": "Body"}`,
+			svc:        noop.NewNoopService(),
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name:    "invalid cc address",
+			body:    `{"to": "a@example.com", "cc": ["not-an-address"], "subject": "Test", "content": "Body"}`,
+			svc:     noop.NewNoopService(),
+			wantErr: true,
+		},
+		{
+			name:    "header injection in to",
+			body:    `{"to": "a@example.com\r\nX-Evil: 1", "subject": "Test", "content": "Body"}`,
+			svc:     noop.NewNoopService(),
+			wantErr: true,
+		},
+		{
+			name:    "invalid reply-to address",
+			body:    `{"to": "a@example.com", "replyTo": "not-an-address", "subject": "Test", "content": "Body"}`,
+			svc:     noop.NewNoopService(),
+			wantErr: true,
+		},
+		{
+			name:       "attachment within limit",
+			body:       `{"to": "a@example.com", "subject": "Test", "content": "Body", "attachments": [{"filename": "a.txt", "contentType": "text/plain", "content": "aGVsbG8="}]}`,
+			svc:        noop.NewNoopService(),
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name:       "attachments over limit",
+			body:       `{"to": "a@example.com", "subject": "Test", "content": "Body", "attachments": [{"filename": "a.txt", "contentType": "text/plain", "content": "MDEyMzQ1Njc4OTAxMjM0NTY3ODk="}]}`,
+			svc:        noop.NewNoopService(),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantErr:    true,
+		},
+		{
+			name:    "attachment without filename",
+			body:    `{"to": "a@example.com", "subject": "Test", "content": "Body", "attachments": [{"contentType": "text/plain", "content": "aGVsbG8="}]}`,
+			svc:     noop.NewNoopService(),
+			wantErr: true,
+		},
+		{
+			name:    "attachment with invalid base64",
+			body:    `{"to": "a@example.com", "subject": "Test", "content": "Body", "attachments": [{"filename": "a.txt", "contentType": "text/plain", "content": "not base64"}]}`,
+			svc:     noop.NewNoopService(),
+			wantErr: true,
+		},
+		{
+			name:       "allowed sender override",
+			body:       `{"to": "a@example.com", "subject": "Test", "content": "Body", "from": "billing@example.com", "fromName": "Billing"}`,
+			svc:        noop.NewNoopService(),
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name:       "sender with display name",
+			body:       `{"to": "a@example.com", "subject": "Test", "content": "Body", "from": "Billing <billing@example.com>"}`,
+			svc:        noop.NewNoopService(),
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name:       "invalid sender address",
+			body:       `{"to": "a@example.com", "subject": "Test", "content": "Body", "from": "billing"}`,
+			svc:        noop.NewNoopService(),
+			wantStatus: http.StatusBadRequest,
+			wantErr:    true,
+		},
+		{
+			name:       "sender not on allowlist",
+			body:       `{"to": "a@example.com", "subject": "Test", "content": "Body", "from": "ceo@evil.com"}`,
+			svc:        noop.NewNoopService(),
+			wantStatus: http.StatusForbidden,
+			wantErr:    true,
+		},
 		{
 			name:    "missing required field",
 			body:    `{}`,
@@ -66,15 +153,177 @@ func Test_sendMailHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := newContextWithBody(tt.body)
-			handler := sendMailHandler(tt.svc)
+			cfg := testConfig()
+			handler := sendMailHandler(outbox.New(outbox.NewMemoryStore(), tt.svc, cfg), testLibrary(), testComposer(t), cfg)
 			err := handler(ctx)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("sendMailHandler() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if tt.wantStatus != 0 {
+				if status := responseStatus(ctx, err); status != tt.wantStatus {
+					t.Errorf("sendMailHandler() status = %d, want %d", status, tt.wantStatus)
+				}
+			}
 		})
 	}
 }
 
+func Test_sendMailHandler_SplitsSenderName(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantFrom     string
+		wantFromName string
+	}{
+		{
+			name:         "display name moves to fromName",
+			body:         `{"to": "a@example.com", "subject": "Test", "content": "Body", "from": "Billing Team <billing@example.com>"}`,
+			wantFrom:     "billing@example.com",
+			wantFromName: "Billing Team",
+		},
+		{
+			name:         "fromName wins over the display name",
+			body:         `{"to": "a@example.com", "subject": "Test", "content": "Body", "from": "Billing <billing@example.com>", "fromName": "Invoices"}`,
+			wantFrom:     "billing@example.com",
+			wantFromName: "Invoices",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			svc := &recordingService{}
+			ctx := newContextWithBody(tt.body)
+			if err := sendMailHandler(outbox.New(outbox.NewMemoryStore(), svc, cfg), testLibrary(), testComposer(t), cfg)(ctx); err != nil {
+				t.Fatalf("sendMailHandler() error = %v", err)
+			}
+			if len(svc.sent) != 1 || svc.sent[0].From != tt.wantFrom || svc.sent[0].FromName != tt.wantFromName {
+				t.Errorf("sent = %+v, want from %q with name %q", svc.sent, tt.wantFrom, tt.wantFromName)
+			}
+		})
+	}
+}
+
+func Test_sendMailHandler_ResponseHidesBccAndAttachments(t *testing.T) {
+	cfg := testConfig()
+	ctx := newContextWithBody(`{"to": "a@example.com", "bcc": ["hidden@example.com"], "subject": "Test", "content": "Body",
+		"attachments": [{"filename": "a.txt", "contentType": "text/plain", "content": "aGVsbG8="}]}`)
+	if err := sendMailHandler(outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg), testLibrary(), testComposer(t), cfg)(ctx); err != nil {
+		t.Fatalf("sendMailHandler() error = %v", err)
+	}
+
+	body := ctx.Response().Writer.(*httptest.ResponseRecorder).Body.String()
+	if strings.Contains(body, "hidden@example.com") || strings.Contains(body, "aGVsbG8=") {
+		t.Errorf("response = %s, want it without bcc recipients and attachments", body)
+	}
+	if !strings.Contains(body, `"to":"a@example.com"`) {
+		t.Errorf("response = %s, want the echoed recipient", body)
+	}
+}
+
+func Test_sendMailHandler_Queued(t *testing.T) {
+	store, err := outbox.OpenBoltStore(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatalf("OpenBoltStore() error = %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	// the outbox is not started, so the failing provider must not be called by the handler
+	cfg := testConfig()
+	cfg.Queue = config.QueueConfig{Enabled: true, Workers: 1, MaxAttempts: 1}
+	queue := outbox.New(store, &errorMailService{}, cfg)
+
+	ctx := newContextWithBody(`{"to": "a@example.com", "subject": "Test", "content": "Body"}`)
+	if err := sendMailHandler(queue, testLibrary(), testComposer(t), cfg)(ctx); err != nil {
+		t.Fatalf("sendMailHandler() error = %v", err)
+	}
+	if status := ctx.Response().Status; status != http.StatusAccepted {
+		t.Errorf("sendMailHandler() status = %d, want %d", status, http.StatusAccepted)
+	}
+
+	var resp sendMailResponse
+	if err := json.Unmarshal(ctx.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.ID == "" || resp.Status != outbox.StatusAccepted || resp.To != "a@example.com" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	msg, err := queue.Get(resp.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if msg.Status != outbox.StatusAccepted || msg.Attributes.Subject != "Test" {
+		t.Errorf("stored message = %+v", msg)
+	}
+}
+
+// rateLimitedMailService fails like a provider whose quota is used up.
+type rateLimitedMailService struct{}
+
+func (rateLimitedMailService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	return &mail.ProviderError{Provider: "sendgrid", Transient: true, RetryAfter: 1500 * time.Millisecond, Err: mail.ErrRateLimited}
+}
+
+func Test_sendMailHandler_RateLimited(t *testing.T) {
+	cfg := testConfig()
+	ctx := newContextWithBody(`{"to": "a@example.com", "subject": "Test", "content": "Body"}`)
+	err := sendMailHandler(outbox.New(outbox.NewMemoryStore(), rateLimitedMailService{}, cfg), testLibrary(), testComposer(t), cfg)(ctx)
+	if status := responseStatus(ctx, err); status != http.StatusTooManyRequests {
+		t.Errorf("sendMailHandler() status = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if got := ctx.Response().Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+}
+
+func Test_sendMailHandler_Markdown(t *testing.T) {
+	cfg := testConfig()
+	box := outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg)
+
+	ctx := newContextWithBody(`{"to": "a@example.com", "subject": "Deploy", "content": "# Done\n\n**api** is live", "contentType": "markdown"}`)
+	if err := sendMailHandler(box, testLibrary(), testComposer(t), cfg)(ctx); err != nil {
+		t.Fatalf("sendMailHandler() error = %v", err)
+	}
+	var resp sendMailResponse
+	if err := json.Unmarshal(ctx.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if !strings.Contains(resp.HtmlContent, "<h1>Done</h1>") || !strings.Contains(resp.HtmlContent, "<strong>api</strong>") {
+		t.Errorf("content = %q, want rendered markdown", resp.HtmlContent)
+	}
+	if resp.TextContent != "# Done\n\n**api** is live" || resp.ContentType != "" {
+		t.Errorf("textContent = %q, contentType = %q, want the markdown source as text", resp.TextContent, resp.ContentType)
+	}
+
+	ctx = newContextWithBody(`{"to": "a@example.com", "subject": "Deploy", "content": "Done", "contentType": "rtf"}`)
+	err := sendMailHandler(box, testLibrary(), testComposer(t), cfg)(ctx)
+	if status := responseStatus(ctx, err); status != http.StatusBadRequest {
+		t.Errorf("sendMailHandler() with unknown content type status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func Test_sendMailHandler_InlinesCSS(t *testing.T) {
+	cfg := testConfig()
+	cfg.HTML.Enabled = true
+	box := outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg)
+	composer, err := compose.NewComposer(cfg)
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+
+	ctx := newContextWithBody(`{"to": "a@example.com", "subject": "Hi", "content": "<style>.lead { font-weight: bold; }</style><p class=\"lead\">Hi</p>"}`)
+	if err := sendMailHandler(box, testLibrary(), composer, cfg)(ctx); err != nil {
+		t.Fatalf("sendMailHandler() error = %v", err)
+	}
+	var resp sendMailResponse
+	if err := json.Unmarshal(ctx.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if !strings.Contains(resp.HtmlContent, `<p class="lead" style="font-weight:bold;">Hi</p>`) || strings.Contains(resp.HtmlContent, "<style>") {
+		t.Errorf("content = %q, want inlined CSS", resp.HtmlContent)
+	}
+}
+
 func Test_probeHandler(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -89,6 +338,25 @@ func Test_probeHandler(t *testing.T) {
 	}
 }
 
+func testConfig() *config.Config {
+	return &config.Config{
+		Sender: config.SenderConfig{
+			Address:        "noreply@example.com",
+			AllowedDomains: []string{"example.com"},
+		},
+		Attachments: config.AttachmentsConfig{MaxTotalBytes: 16},
+	}
+}
+
+// responseStatus returns the status code of an HTTP error or, if there is none, of the written response.
+func responseStatus(ctx echo.Context, err error) int {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return ctx.Response().Status
+}
+
 func newContextWithBody(body string) echo.Context {
 	e := echo.New()
 	e.Validator = &validation.GenericValidator{Validator: validator.New()}
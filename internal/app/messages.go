@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// messageResponse is the status view of a message. Content, Bcc recipients
+// and attachments are left out; Recipients counts all of them.
+type messageResponse struct {
+	ID                 string         `json:"id"`
+	Status             outbox.Status  `json:"status"`
+	To                 string         `json:"to"`
+	Cc                 []string       `json:"cc,omitempty"`
+	Recipients         int            `json:"recipients"`
+	Subject            string         `json:"subject"`
+	From               string         `json:"from,omitempty"`
+	Attempts           int            `json:"attempts"`
+	LastError          string         `json:"lastError,omitempty"`
+	Provider           string         `json:"provider,omitempty"`
+	ProviderMessageIDs []string       `json:"providerMessageIds,omitempty"`
+	CreatedAt          time.Time      `json:"createdAt"`
+	UpdatedAt          time.Time      `json:"updatedAt"`
+	NextAttemptAt      *time.Time     `json:"nextAttemptAt,omitempty"`
+	History            []outbox.Event `json:"history"`
+}
+
+type listMessagesResponse struct {
+	Messages []messageResponse `json:"messages"`
+}
+
+func newMessageResponse(msg outbox.Message) messageResponse {
+	resp := messageResponse{
+		ID:                 msg.ID,
+		Status:             msg.Status,
+		To:                 msg.Attributes.To,
+		Cc:                 msg.Attributes.Cc,
+		Recipients:         msg.Recipients,
+		Subject:            msg.Attributes.Subject,
+		From:               msg.Attributes.From,
+		Attempts:           msg.Attempts,
+		LastError:          msg.LastError,
+		Provider:           msg.Provider,
+		ProviderMessageIDs: msg.ProviderMessageIDs,
+		CreatedAt:          msg.CreatedAt,
+		UpdatedAt:          msg.UpdatedAt,
+		History:            msg.History,
+	}
+	if msg.Status == outbox.StatusAccepted {
+		next := msg.NextAttemptAt
+		resp.NextAttemptAt = &next
+	}
+	return resp
+}
+
+func getMessageHandler(box *outbox.Outbox) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		msg, err := box.Get(ctx.Param("id"))
+		if errors.Is(err, outbox.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			slog.Error("failed to load message", "id", ctx.Param("id"), "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		return ctx.JSON(http.StatusOK, newMessageResponse(msg))
+	}
+}
+
+// listMessagesHandler lists messages, newest first. Supported query
+// parameters are status, recipient, since and until (RFC 3339) and limit.
+func listMessagesHandler(box *outbox.Outbox) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		filter, err := parseMessageFilter(ctx)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		messages, err := box.List(filter)
+		if err != nil {
+			slog.Error("failed to list messages", "error", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		resp := listMessagesResponse{Messages: make([]messageResponse, 0, len(messages))}
+		for _, msg := range messages {
+			resp.Messages = append(resp.Messages, newMessageResponse(msg))
+		}
+		return ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+func parseMessageFilter(ctx echo.Context) (outbox.Filter, error) {
+	filter := outbox.Filter{
+		Recipient: ctx.QueryParam("recipient"),
+		Limit:     defaultListLimit,
+	}
+
+	if status := ctx.QueryParam("status"); status != "" {
+		parsed, err := outbox.ParseStatus(status)
+		if err != nil {
+			return filter, err
+		}
+		filter.Status = parsed
+	}
+
+	for param, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := ctx.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New(param + " must be an RFC 3339 timestamp")
+		}
+		*target = parsed
+	}
+
+	if limit := ctx.QueryParam("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxListLimit {
+			return filter, errors.New("limit must be between 1 and " + strconv.Itoa(maxListLimit))
+		}
+		filter.Limit = parsed
+	}
+
+	return filter, nil
+}
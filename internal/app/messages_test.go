@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/noop"
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
+	"github.com/labstack/echo/v4"
+)
+
+func newMessagesTestServer(t *testing.T) (*echo.Echo, *outbox.Outbox) {
+	t.Helper()
+	cfg := testConfig()
+	box := outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg)
+	return buildHTTPServer(cfg, box, testLibrary(), testComposer(t), nil), box
+}
+
+func serve(e *echo.Echo, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func Test_getMessageHandler(t *testing.T) {
+	e, box := newMessagesTestServer(t)
+	msg, err := box.Send(context.Background(), mail.MailAttributes{To: "a@example.com", Bcc: []string{"audit@example.com"}, Subject: "Hello", HtmlContent: "Body"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	rec := serve(e, http.MethodGet, "/v1/messages/"+msg.ID)
+	if body := rec.Body.String(); strings.Contains(body, "audit@example.com") || strings.Contains(body, "Body") {
+		t.Errorf("response = %s, want no Bcc recipients and content", body)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var resp messageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.ID != msg.ID || resp.Status != outbox.StatusSent || resp.Provider != "noop" || resp.Subject != "Hello" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Recipients != 2 {
+		t.Errorf("recipients = %d, want 2", resp.Recipients)
+	}
+	if len(resp.History) != 3 {
+		t.Errorf("history has %d events, want 3", len(resp.History))
+	}
+
+	if rec := serve(e, http.MethodGet, "/v1/messages/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown id status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func Test_listMessagesHandler(t *testing.T) {
+	e, box := newMessagesTestServer(t)
+	for _, to := range []string{"a@example.com", "b@example.com", "a@example.com"} {
+		if _, err := box.Send(context.Background(), mail.MailAttributes{To: to, Subject: "Hello", HtmlContent: "Body"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{name: "all", query: "", wantStatus: http.StatusOK, wantCount: 3},
+		{name: "by recipient", query: "?recipient=a@example.com", wantStatus: http.StatusOK, wantCount: 2},
+		{name: "by status", query: "?status=failed", wantStatus: http.StatusOK, wantCount: 0},
+		{name: "with limit", query: "?limit=1", wantStatus: http.StatusOK, wantCount: 1},
+		{name: "since", query: "?since=2000-01-01T00:00:00Z", wantStatus: http.StatusOK, wantCount: 3},
+		{name: "unknown status", query: "?status=bounced", wantStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=0", wantStatus: http.StatusBadRequest},
+		{name: "invalid since", query: "?since=yesterday", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(e, http.MethodGet, "/v1/messages"+tt.query)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp listMessagesResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if len(resp.Messages) != tt.wantCount {
+				t.Errorf("got %d messages, want %d", len(resp.Messages), tt.wantCount)
+			}
+		})
+	}
+}
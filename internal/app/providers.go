@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/balancer"
+	"github.com/jo-hoe/go-mail-service/internal/mail/breaker"
+	"github.com/jo-hoe/go-mail-service/internal/mail/failover"
+	"github.com/jo-hoe/go-mail-service/internal/mail/httpclient"
+	"github.com/jo-hoe/go-mail-service/internal/mail/mailgun"
+	"github.com/jo-hoe/go-mail-service/internal/mail/mailjet"
+	"github.com/jo-hoe/go-mail-service/internal/mail/noop"
+	"github.com/jo-hoe/go-mail-service/internal/mail/postmark"
+	"github.com/jo-hoe/go-mail-service/internal/mail/ratelimit"
+	"github.com/jo-hoe/go-mail-service/internal/mail/resend"
+	"github.com/jo-hoe/go-mail-service/internal/mail/retry"
+	"github.com/jo-hoe/go-mail-service/internal/mail/routing"
+	"github.com/jo-hoe/go-mail-service/internal/mail/sendgrid"
+	"github.com/jo-hoe/go-mail-service/internal/mail/ses"
+	"github.com/jo-hoe/go-mail-service/internal/mail/smtprelay"
+)
+
+// resolveMailService builds the failover chain of the providers in
+// provider.order. Each provider retries its own transient errors before the
+// chain moves on to the next one, unless the queue retries them instead, and is skipped while its circuit breaker
+// is open. With provider.routes, the recipients a route matches go through
+// the chain of its providers instead. The breakers are returned in the order
+// their providers were first listed, to report their state.
+func resolveMailService(cfg *config.Config) (mail.MailService, []*breaker.BreakerService, error) {
+	if len(cfg.Provider.Order) == 0 {
+		return nil, nil, fmt.Errorf("no mail provider is enabled")
+	}
+
+	// providers are shared between chains so that, e.g., an SMTP relay keeps
+	// one connection pool and an outage opens the circuit of every chain
+	services := make(map[string]*breaker.BreakerService)
+	var breakers []*breaker.BreakerService
+	chain := func(names []string) (mail.MailService, error) {
+		providers := make([]failover.Provider, 0, len(names))
+		for _, name := range names {
+			svc, ok := services[name]
+			if !ok {
+				created, err := newProvider(cfg, name)
+				if err != nil {
+					return nil, err
+				}
+				if limits, ok := cfg.Provider.RateLimits[name]; ok {
+					created = ratelimit.NewLimitService(name, created, limits, rateLimitMaxWait(cfg))
+				}
+				svc = breaker.NewBreakerService(name, retry.NewRetryService(created, providerRetry(cfg)), cfg.CircuitBreaker)
+				services[name] = svc
+				breakers = append(breakers, svc)
+			}
+			providers = append(providers, failover.Provider{Name: name, Service: svc})
+		}
+		return failover.NewFailoverService(providers...), nil
+	}
+
+	fallback, err := chain(cfg.Provider.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.Info("mail providers resolved", "order", cfg.Provider.Order)
+	if len(cfg.Provider.Routes) == 0 {
+		return fallback, breakers, nil
+	}
+
+	routes := make([]routing.Route, 0, len(cfg.Provider.Routes))
+	for _, route := range cfg.Provider.Routes {
+		svc, err := chain(route.Providers)
+		if err != nil {
+			return nil, nil, err
+		}
+		routes = append(routes, routing.Route{
+			Name:             route.Name,
+			RecipientDomains: route.Match.RecipientDomains,
+			Senders:          route.Match.Senders,
+			Tags:             route.Match.Tags,
+			Clients:          route.Match.Clients,
+			Service:          svc,
+		})
+		slog.Info("mail route resolved", "route", route.Name, "providers", route.Providers)
+	}
+	return routing.NewRoutingService(fallback, cfg.Sender.Address, routes...), breakers, nil
+}
+
+// rateLimitMaxWait is how long a mail waits for a provider's rate limit. With
+// the queue, it does not wait at all because the outbox postpones it instead.
+func rateLimitMaxWait(cfg *config.Config) time.Duration {
+	if cfg.Queue.Enabled {
+		return 0
+	}
+	return cfg.Provider.RateLimitMaxWait
+}
+
+// providerRetry configures the retries of each provider. With the queue, a
+// provider is tried once per delivery attempt because the outbox retries the
+// mail with its own backoff, which would otherwise multiply the attempts.
+func providerRetry(cfg *config.Config) config.RetryConfig {
+	if cfg.Queue.Enabled {
+		retryCfg := cfg.Retry
+		retryCfg.MaxAttempts = 1
+		return retryCfg
+	}
+	return cfg.Retry
+}
+
+// newProvider creates the mail service of the named provider. A provider with
+// several accounts balances the mail across them.
+func newProvider(cfg *config.Config, name string) (mail.MailService, error) {
+	p := cfg.Provider
+	switch name {
+	case config.ProviderMailjet:
+		client, err := httpclient.New(p.Mailjet.HTTPClientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("mailjet: %w", err)
+		}
+		account := func(public, private string) mail.MailService {
+			mCfg := mailjet.NewMailjetConfig(
+				public,
+				private,
+				cfg.Sender.Address,
+				cfg.Sender.Name,
+				p.Mailjet.BaseURL,
+			)
+			return mailjet.NewMailjetService(mCfg, client)
+		}
+		if len(p.Mailjet.Accounts) == 0 {
+			return account(p.Mailjet.APIKeyPublic, p.Mailjet.APIKeyPrivate), nil
+		}
+		accounts := make([]balancer.Account, 0, len(p.Mailjet.Accounts))
+		for _, a := range p.Mailjet.Accounts {
+			svc := ratelimit.NewLimitService(name+"/"+a.Name, account(a.APIKeyPublic, a.APIKeyPrivate), a.RateLimit, rateLimitMaxWait(cfg))
+			accounts = append(accounts, balancer.Account{Name: a.Name, Weight: a.Weight, Service: svc})
+		}
+		return balancer.NewBalancingService(name, p.AccountCooldown, accounts...), nil
+	case config.ProviderSendGrid:
+		client, err := httpclient.New(p.SendGrid.HTTPClientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("sendgrid: %w", err)
+		}
+		account := func(apiKey string) mail.MailService {
+			sCfg := sendgrid.NewSendGridConfig(
+				apiKey,
+				cfg.Sender.Address,
+				cfg.Sender.Name,
+				p.SendGrid.BaseURL,
+			)
+			return sendgrid.NewSendGridService(sCfg, client)
+		}
+		if len(p.SendGrid.Accounts) == 0 {
+			return account(p.SendGrid.APIKey), nil
+		}
+		accounts := make([]balancer.Account, 0, len(p.SendGrid.Accounts))
+		for _, a := range p.SendGrid.Accounts {
+			svc := ratelimit.NewLimitService(name+"/"+a.Name, account(a.APIKey), a.RateLimit, rateLimitMaxWait(cfg))
+			accounts = append(accounts, balancer.Account{Name: a.Name, Weight: a.Weight, Service: svc})
+		}
+		return balancer.NewBalancingService(name, p.AccountCooldown, accounts...), nil
+	case config.ProviderSMTP:
+		return smtprelay.NewSMTPRelayService(p.SMTP, cfg.Sender.Address, cfg.Sender.Name)
+	case config.ProviderSES:
+		client, err := httpclient.New(p.SES.HTTPClientConfig())
+		if err != nil {
+			return nil, fmt.Errorf("ses: %w", err)
+		}
+		return ses.NewSESService(ses.NewSESConfig(p.SES, cfg.Sender.Address, cfg.Sender.Name), client), nil
+	case config.ProviderMailgun:
+		client, err := httpclient.New(p.Mailgun.HTTPClientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("mailgun: %w", err)
+		}
+		mCfg := mailgun.NewMailgunConfig(
+			p.Mailgun.APIKey,
+			p.Mailgun.Domain,
+			p.Mailgun.Region,
+			cfg.Sender.Address,
+			cfg.Sender.Name,
+			p.Mailgun.BaseURL,
+		)
+		return mailgun.NewMailgunService(mCfg, client), nil
+	case config.ProviderPostmark:
+		client, err := httpclient.New(p.Postmark.HTTPClientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("postmark: %w", err)
+		}
+		pCfg := postmark.NewPostmarkConfig(
+			p.Postmark.ServerToken,
+			p.Postmark.MessageStream,
+			cfg.Sender.Address,
+			cfg.Sender.Name,
+			p.Postmark.BaseURL,
+		)
+		return postmark.NewPostmarkService(pCfg, client), nil
+	case config.ProviderResend:
+		client, err := httpclient.New(p.Resend.HTTPClientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("resend: %w", err)
+		}
+		rCfg := resend.NewResendConfig(
+			p.Resend.APIKey,
+			cfg.Sender.Address,
+			cfg.Sender.Name,
+			p.Resend.BaseURL,
+		)
+		return resend.NewResendService(rCfg, client), nil
+	case config.ProviderNoop:
+		return noop.NewNoopService(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail provider %q", name)
+	}
+}
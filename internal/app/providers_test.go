@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+func Test_resolveMailService(t *testing.T) {
+	cfg := testConfig()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 1}
+	cfg.Provider = config.ProviderConfig{
+		Order: []string{config.ProviderNoop},
+		Noop:  config.NoopProviderConfig{Enabled: true},
+	}
+
+	svc, breakers, err := resolveMailService(cfg)
+	if err != nil {
+		t.Fatalf("resolveMailService() error = %v", err)
+	}
+	if len(breakers) != 1 || breakers[0].Status().Provider != config.ProviderNoop {
+		t.Errorf("resolveMailService() breakers = %d, want one for noop", len(breakers))
+	}
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	if err := svc.SendMail(ctx, mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"}); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if delivery.Provider != config.ProviderNoop {
+		t.Errorf("delivered by %q, want %q", delivery.Provider, config.ProviderNoop)
+	}
+}
+
+func Test_resolveMailService_NoProvider(t *testing.T) {
+	if _, _, err := resolveMailService(testConfig()); err == nil {
+		t.Error("resolveMailService() expected error without enabled providers")
+	}
+}
+
+func Test_providerRetry(t *testing.T) {
+	cfg := testConfig()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 3}
+	if got := providerRetry(cfg).MaxAttempts; got != 3 {
+		t.Errorf("providerRetry() attempts = %d without the queue, want 3", got)
+	}
+
+	cfg.Queue.Enabled = true
+	if got := providerRetry(cfg).MaxAttempts; got != 1 {
+		t.Errorf("providerRetry() attempts = %d with the queue, want 1", got)
+	}
+}
+
+func Test_newProvider_Unknown(t *testing.T) {
+	if _, err := newProvider(testConfig(), "postfix"); err == nil {
+		t.Error("newProvider() expected error for unknown provider")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/compose"
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail/mimemessage"
+	"github.com/jo-hoe/go-mail-service/internal/preview"
+	"github.com/jo-hoe/go-mail-service/internal/templates"
+	"github.com/labstack/echo/v4"
+)
+
+// renderResponse shows a mail as it would be handed to the provider.
+type renderResponse struct {
+	Subject     string `json:"subject"`
+	HtmlContent string `json:"content"`
+	// TextContent is the text part, generated from the HTML if the mail has none.
+	TextContent     string              `json:"textContent"`
+	Headers         []mimemessage.Field `json:"headers"`
+	TemplateID      string              `json:"templateId,omitempty"`
+	TemplateVersion int                 `json:"templateVersion,omitempty"`
+	// Locale is the translation of the template used, empty for its default content.
+	Locale   string            `json:"locale,omitempty"`
+	Warnings []preview.Warning `json:"warnings"`
+}
+
+// renderHandler renders a mail in the format of /v1/sendmail without sending
+// it. Problems that would fail the send or break the mail in a mail client,
+// such as missing template variables, are reported as warnings.
+func renderHandler(library *templates.Library, composer *compose.Composer, cfg *config.Config) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		req := new(sendMailRequest)
+		if err := ctx.Bind(req); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		req.Client = ctx.Request().Header.Get(clientIDHeader)
+
+		resp := renderResponse{TemplateID: req.TemplateID, Warnings: []preview.Warning{}}
+		rendered, err := renderTemplate(library, req, true)
+		if err != nil {
+			return err
+		}
+		resp.TemplateVersion, resp.Locale = rendered.Version, rendered.Locale
+		if req.TemplateID != "" && req.Locale != "" && rendered.Locale == "" {
+			resp.Warnings = append(resp.Warnings, preview.Warning{
+				Code:    preview.CodeMissingTranslation,
+				Message: fmt.Sprintf("the template has no translation for %s, the default content is used", req.Locale),
+			})
+		}
+		for _, variable := range rendered.Missing {
+			resp.Warnings = append(resp.Warnings, preview.Warning{
+				Code:    preview.CodeMissingVariable,
+				Message: fmt.Sprintf("%s is missing from data, sending the mail would fail", variable),
+			})
+		}
+		if err := validateMail(ctx, cfg, &req.MailAttributes); err != nil {
+			resp.Warnings = append(resp.Warnings, preview.Warning{Code: preview.CodeInvalidMail, Message: httpErrorMessage(err)})
+		}
+		if err := composeMail(composer, &req.MailAttributes); err != nil {
+			return err
+		}
+		resp.Warnings = append(resp.Warnings, preview.Check(req.MailAttributes)...)
+
+		attrs := req.MailAttributes
+		from, fromName := attrs.Sender(cfg.Sender.Address, cfg.Sender.Name)
+		resp.Subject, resp.HtmlContent, resp.TextContent = attrs.Subject, attrs.HtmlContent, attrs.PlainText()
+		headers, err := mimemessage.Headers(attrs, mimemessage.Options{FromAddress: from, FromName: fromName, Date: time.Now().UTC()})
+		if err != nil {
+			resp.Warnings = append(resp.Warnings, preview.Warning{Code: preview.CodeInvalidMail, Message: err.Error()})
+		}
+		resp.Headers = headers
+		return ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+// httpErrorMessage returns the message of an error returned by a handler.
+func httpErrorMessage(err error) string {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return fmt.Sprint(httpErr.Message)
+	}
+	return err.Error()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail/mimemessage"
+	"github.com/jo-hoe/go-mail-service/internal/preview"
+	"github.com/jo-hoe/go-mail-service/internal/templates"
+)
+
+func Test_renderHandler(t *testing.T) {
+	library := testLibrary()
+	if _, err := library.Create("welcome", templates.Definition{Content: templates.Content{
+		Subject:     "Welcome {{.name}}",
+		HtmlContent: `<p>Hi {{.name}}, see <a href="{{.link}}">your account</a></p>`,
+		Locales: map[string]templates.Content{
+			"de": {Subject: "Willkommen {{.name}}", HtmlContent: `<p>Hallo {{.name}}, siehe <a href="{{.link}}">dein Konto</a></p>`},
+		},
+	}}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantSubject  string
+		wantText     string
+		wantVersion  int
+		wantLocale   string
+		wantWarnings []string
+	}{
+		{
+			name:         "raw content",
+			body:         `{"to": "a@example.com", "subject": "Hi", "content": "<p>Hi</p>"}`,
+			wantStatus:   http.StatusOK,
+			wantSubject:  "Hi",
+			wantText:     "Hi",
+			wantWarnings: []string{},
+		},
+		{
+			name:         "broken link",
+			body:         `{"to": "a@example.com", "subject": "Hi", "content": "<a href=\"/account\">Account</a>"}`,
+			wantStatus:   http.StatusOK,
+			wantSubject:  "Hi",
+			wantText:     "Account (/account)",
+			wantWarnings: []string{preview.CodeBrokenLink},
+		},
+		{
+			name:         "template",
+			body:         `{"to": "a@example.com", "templateId": "welcome", "data": {"name": "Ann", "link": "https://example.com"}}`,
+			wantStatus:   http.StatusOK,
+			wantSubject:  "Welcome Ann",
+			wantText:     "Hi Ann, see your account (https://example.com)",
+			wantVersion:  1,
+			wantWarnings: []string{},
+		},
+		{
+			name:         "template with missing variable",
+			body:         `{"to": "a@example.com", "templateId": "welcome", "data": {"name": "Ann"}}`,
+			wantStatus:   http.StatusOK,
+			wantSubject:  "Welcome Ann",
+			wantText:     "Hi Ann, see your account",
+			wantVersion:  1,
+			wantWarnings: []string{preview.CodeMissingVariable, preview.CodeBrokenLink},
+		},
+		{
+			name:         "locale fallback",
+			body:         `{"to": "a@example.com", "templateId": "welcome", "locale": "de-AT", "data": {"name": "Ann", "link": "https://example.com"}}`,
+			wantStatus:   http.StatusOK,
+			wantSubject:  "Willkommen Ann",
+			wantText:     "Hallo Ann, siehe dein Konto (https://example.com)",
+			wantVersion:  1,
+			wantLocale:   "de",
+			wantWarnings: []string{},
+		},
+		{
+			name:         "missing translation",
+			body:         `{"to": "a@example.com", "templateId": "welcome", "locale": "fr", "data": {"name": "Ann", "link": "https://example.com"}}`,
+			wantStatus:   http.StatusOK,
+			wantSubject:  "Welcome Ann",
+			wantText:     "Hi Ann, see your account (https://example.com)",
+			wantVersion:  1,
+			wantWarnings: []string{preview.CodeMissingTranslation},
+		},
+		{
+			name:         "invalid mail",
+			body:         `{"subject": "Hi", "content": "<p>Hi</p>"}`,
+			wantStatus:   http.StatusOK,
+			wantSubject:  "Hi",
+			wantText:     "Hi",
+			wantWarnings: []string{preview.CodeInvalidMail},
+		},
+		{
+			name:       "unknown template",
+			body:       `{"to": "a@example.com", "templateId": "missing"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "content and template",
+			body:       `{"to": "a@example.com", "content": "Body", "templateId": "welcome"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newContextWithBody(tt.body)
+			err := renderHandler(library, testComposer(t), testConfig())(ctx)
+			if status := responseStatus(ctx, err); status != tt.wantStatus {
+				t.Fatalf("renderHandler() status = %d, want %d (error = %v)", status, tt.wantStatus, err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp renderResponse
+			if err := json.Unmarshal(ctx.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Subject != tt.wantSubject || resp.TextContent != tt.wantText || resp.TemplateVersion != tt.wantVersion || resp.Locale != tt.wantLocale {
+				t.Errorf("response = %+v", resp)
+			}
+			codes := []string{}
+			for _, w := range resp.Warnings {
+				codes = append(codes, w.Code)
+			}
+			if !slices.Equal(codes, tt.wantWarnings) {
+				t.Errorf("warnings = %+v, want codes %v", resp.Warnings, tt.wantWarnings)
+			}
+			if !slices.ContainsFunc(resp.Headers, func(f mimemessage.Field) bool { return f.Name == "Subject" }) {
+				t.Errorf("headers = %+v, want a Subject header", resp.Headers)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail/breaker"
+	"github.com/labstack/echo/v4"
+)
+
+type listProvidersResponse struct {
+	Providers []breaker.Status `json:"providers"`
+}
+
+// listProvidersHandler reports the circuit breaker state of every provider.
+func listProvidersHandler(breakers []*breaker.BreakerService) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		resp := listProvidersResponse{Providers: make([]breaker.Status, 0, len(breakers))}
+		for _, b := range breakers {
+			resp.Providers = append(resp.Providers, b.Status())
+		}
+		return ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+// metricsHandler exposes the circuit breaker state of every provider in the
+// Prometheus text format.
+func metricsHandler(breakers []*breaker.BreakerService) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		statuses := make([]breaker.Status, 0, len(breakers))
+		for _, b := range breakers {
+			statuses = append(statuses, b.Status())
+		}
+
+		var sb strings.Builder
+		sb.WriteString("# HELP mail_provider_circuit_state Circuit breaker state of the provider, 1 for the current state.\n")
+		sb.WriteString("# TYPE mail_provider_circuit_state gauge\n")
+		for _, status := range statuses {
+			for _, state := range breaker.States {
+				value := 0
+				if status.State == state {
+					value = 1
+				}
+				fmt.Fprintf(&sb, "mail_provider_circuit_state{provider=%q,state=%q} %d\n", status.Provider, state, value)
+			}
+		}
+		sb.WriteString("# HELP mail_provider_consecutive_failures Mails the provider failed in a row with a transient error.\n")
+		sb.WriteString("# TYPE mail_provider_consecutive_failures gauge\n")
+		for _, status := range statuses {
+			fmt.Fprintf(&sb, "mail_provider_consecutive_failures{provider=%q} %d\n", status.Provider, status.ConsecutiveFailures)
+		}
+		sb.WriteString("# HELP mail_provider_circuit_opens_total Times the circuit breaker of the provider opened.\n")
+		sb.WriteString("# TYPE mail_provider_circuit_opens_total counter\n")
+		for _, status := range statuses {
+			fmt.Fprintf(&sb, "mail_provider_circuit_opens_total{provider=%q} %d\n", status.Provider, status.Opens)
+		}
+
+		return ctx.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(sb.String()))
+	}
+}
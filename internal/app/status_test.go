@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/breaker"
+	"github.com/jo-hoe/go-mail-service/internal/mail/noop"
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
+)
+
+// unavailableMailService always fails with a transient error.
+type unavailableMailService struct{}
+
+func (unavailableMailService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	return mail.NewStatusError("mailjet", http.StatusServiceUnavailable, "", errors.New("unavailable"))
+}
+
+// newOpenBreaker returns the breaker of a Mailjet that is down, opened by a single failure.
+func newOpenBreaker(t *testing.T) *breaker.BreakerService {
+	t.Helper()
+	mailjet := breaker.NewBreakerService(config.ProviderMailjet, unavailableMailService{}, config.CircuitBreakerConfig{FailureThreshold: 1})
+	_ = mailjet.SendMail(context.Background(), mail.MailAttributes{})
+	return mailjet
+}
+
+func Test_listProvidersHandler(t *testing.T) {
+	mailjet := newOpenBreaker(t)
+	sendgrid := breaker.NewBreakerService(config.ProviderSendGrid, noop.NewNoopService(), config.CircuitBreakerConfig{FailureThreshold: 1})
+	cfg := testConfig()
+	e := buildHTTPServer(cfg, outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg), testLibrary(), testComposer(t), []*breaker.BreakerService{mailjet, sendgrid})
+
+	rec := serve(e, http.MethodGet, "/v1/providers")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var resp listProvidersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Providers) != 2 {
+		t.Fatalf("providers = %+v, want 2", resp.Providers)
+	}
+	if got := resp.Providers[0]; got.Provider != config.ProviderMailjet || got.State != breaker.StateOpen || got.Opens != 1 || got.OpenedAt == nil {
+		t.Errorf("mailjet = %+v, want open", got)
+	}
+	if got := resp.Providers[1]; got.Provider != config.ProviderSendGrid || got.State != breaker.StateClosed || got.OpenedAt != nil {
+		t.Errorf("sendgrid = %+v, want closed", got)
+	}
+}
+
+func Test_metricsHandler(t *testing.T) {
+	mailjet := newOpenBreaker(t)
+	cfg := testConfig()
+	e := buildHTTPServer(cfg, outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg), testLibrary(), testComposer(t), []*breaker.BreakerService{mailjet})
+
+	rec := serve(e, http.MethodGet, "/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	for _, want := range []string{
+		"# TYPE mail_provider_circuit_state gauge",
+		`mail_provider_circuit_state{provider="mailjet",state="closed"} 0`,
+		`mail_provider_circuit_state{provider="mailjet",state="open"} 1`,
+		`mail_provider_circuit_state{provider="mailjet",state="half-open"} 0`,
+		`mail_provider_consecutive_failures{provider="mailjet"} 1`,
+		`mail_provider_circuit_opens_total{provider="mailjet"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+}
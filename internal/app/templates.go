@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/compose"
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/templates"
+	"github.com/labstack/echo/v4"
+)
+
+// sendMailRequest is a mail submitted over HTTP. Instead of a subject and
+// content, it can name a stored template that is rendered with data.
+type sendMailRequest struct {
+	mail.MailAttributes
+	TemplateID string `json:"templateId,omitempty"`
+	// TemplateVersion pins a version of the template, 0 selects the latest.
+	TemplateVersion int `json:"templateVersion,omitempty"`
+	// Locale selects the translation of the template, e.g. "de-AT", which
+	// falls back to "de" and then to the default content.
+	Locale string `json:"locale,omitempty"`
+	// Data is rendered once for all recipients of the mail. To personalize a
+	// template per recipient, send one mail per recipient with its own data
+	// through /v1/sendmail/batch.
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// createTemplateRequest is the body of POST /v1/templates.
+type createTemplateRequest struct {
+	ID string `json:"id"`
+	templates.Definition
+}
+
+// versionSummary lists a version of a template without its content.
+type versionSummary struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// templateResponse shows a template with the content of its latest version.
+// Earlier versions can be fetched from /v1/templates/:id/versions/:version.
+type templateResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Version     int    `json:"version"`
+	templates.Content
+	CreatedAt time.Time        `json:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	Versions  []versionSummary `json:"versions"`
+}
+
+type listTemplatesResponse struct {
+	Templates []templateResponse `json:"templates"`
+}
+
+func newTemplateResponse(t templates.Template) templateResponse {
+	latest := t.Latest()
+	resp := templateResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		Version:     latest.Number,
+		Content:     latest.Content,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+		Versions:    make([]versionSummary, 0, len(t.Versions)),
+	}
+	for _, v := range t.Versions {
+		resp.Versions = append(resp.Versions, versionSummary{Version: v.Number, CreatedAt: v.CreatedAt})
+	}
+	return resp
+}
+
+// readMail binds a mail submitted over HTTP, renders its template if it names
+// one, validates it and composes its HTML. It returns the HTTP error to reject
+// the mail with.
+func readMail(ctx echo.Context, cfg *config.Config, library *templates.Library, composer *compose.Composer, req *sendMailRequest) error {
+	// the client is identified by the header only, never by the body
+	req.Client = ctx.Request().Header.Get(clientIDHeader)
+	if _, err := renderTemplate(library, req, false); err != nil {
+		return err
+	}
+	if err := validateMail(ctx, cfg, &req.MailAttributes); err != nil {
+		return err
+	}
+	return composeMail(composer, &req.MailAttributes)
+}
+
+// renderTemplate fills in the subject and content of a mail that names a
+// template and returns the version and translation it used. For a preview,
+// variables missing from the data are returned instead of failing the mail.
+func renderTemplate(library *templates.Library, req *sendMailRequest, preview bool) (templates.Rendered, error) {
+	if req.TemplateID == "" {
+		return templates.Rendered{}, nil
+	}
+	if req.Subject != "" || req.HtmlContent != "" || req.TextContent != "" || req.ContentType != "" {
+		// the template decides whether its content is HTML or Markdown
+		return templates.Rendered{}, echo.NewHTTPError(http.StatusBadRequest, "subject, content and contentType must not be set together with templateId")
+	}
+
+	render := library.Render
+	if preview {
+		render = library.Preview
+	}
+	rendered, err := render(req.TemplateID, req.TemplateVersion, req.Locale, req.Data)
+	if errors.Is(err, templates.ErrNotFound) || errors.Is(err, templates.ErrRender) {
+		slog.Error("failed to render template", "template", req.TemplateID, "error", err)
+		return templates.Rendered{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		slog.Error("failed to load template", "template", req.TemplateID, "error", err)
+		return templates.Rendered{}, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	slog.Debug("rendered template", "template", req.TemplateID, "version", rendered.Version, "locale", rendered.Locale)
+	req.Subject, req.HtmlContent, req.TextContent = rendered.Subject, rendered.HtmlContent, rendered.TextContent
+	req.ContentType = rendered.ContentType
+	return rendered, nil
+}
+
+// templateError maps an error of the template library to an HTTP error.
+func templateError(err error) error {
+	switch {
+	case errors.Is(err, templates.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, templates.ErrExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, templates.ErrInvalid):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("template store failed", "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func createTemplateHandler(library *templates.Library) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		req := new(createTemplateRequest)
+		if err := ctx.Bind(req); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		t, err := library.Create(req.ID, req.Definition)
+		if err != nil {
+			return templateError(err)
+		}
+		slog.Info("created template", "template", t.ID)
+		return ctx.JSON(http.StatusCreated, newTemplateResponse(t))
+	}
+}
+
+// updateTemplateHandler stores the body as a new version of the template.
+func updateTemplateHandler(library *templates.Library) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		def := new(templates.Definition)
+		if err := ctx.Bind(def); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		t, err := library.Update(ctx.Param("id"), *def)
+		if err != nil {
+			return templateError(err)
+		}
+		slog.Info("updated template", "template", t.ID, "version", t.Latest().Number)
+		return ctx.JSON(http.StatusOK, newTemplateResponse(t))
+	}
+}
+
+func getTemplateHandler(library *templates.Library) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		t, err := library.Get(ctx.Param("id"))
+		if err != nil {
+			return templateError(err)
+		}
+		return ctx.JSON(http.StatusOK, newTemplateResponse(t))
+	}
+}
+
+func getTemplateVersionHandler(library *templates.Library) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		number, err := strconv.Atoi(ctx.Param("version"))
+		if err != nil || number < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "version must be a positive number")
+		}
+		t, err := library.Get(ctx.Param("id"))
+		if err != nil {
+			return templateError(err)
+		}
+		v, err := t.Version(number)
+		if err != nil {
+			return templateError(err)
+		}
+		return ctx.JSON(http.StatusOK, v)
+	}
+}
+
+func listTemplatesHandler(library *templates.Library) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		all, err := library.List()
+		if err != nil {
+			return templateError(err)
+		}
+		resp := listTemplatesResponse{Templates: make([]templateResponse, 0, len(all))}
+		for _, t := range all {
+			resp.Templates = append(resp.Templates, newTemplateResponse(t))
+		}
+		return ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+func deleteTemplateHandler(library *templates.Library) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if err := library.Delete(ctx.Param("id")); err != nil {
+			return templateError(err)
+		}
+		slog.Info("deleted template", "template", ctx.Param("id"))
+		return ctx.NoContent(http.StatusNoContent)
+	}
+}
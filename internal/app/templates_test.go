@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/compose"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/noop"
+	"github.com/jo-hoe/go-mail-service/internal/outbox"
+	"github.com/jo-hoe/go-mail-service/internal/templates"
+	"github.com/labstack/echo/v4"
+)
+
+func testLibrary() *templates.Library {
+	return templates.NewLibrary(templates.NewMemoryStore())
+}
+
+func testComposer(t *testing.T) *compose.Composer {
+	t.Helper()
+	composer, err := compose.NewComposer(testConfig())
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+	return composer
+}
+
+// serveJSON sends body as JSON to the server.
+func serveJSON(e *echo.Echo, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+const welcomeTemplate = `{"id":"welcome","name":"Welcome","subject":"Welcome {{.name}}","content":"<p>Hi {{.name}}</p>"}`
+
+func Test_templateHandlers(t *testing.T) {
+	cfg := testConfig()
+	e := buildHTTPServer(cfg, outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg), testLibrary(), testComposer(t), nil)
+
+	if rec := serveJSON(e, http.MethodPost, "/v1/templates", welcomeTemplate); rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if rec := serveJSON(e, http.MethodPost, "/v1/templates", welcomeTemplate); rec.Code != http.StatusConflict {
+		t.Errorf("create twice status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if rec := serveJSON(e, http.MethodPost, "/v1/templates", `{"id":"broken","subject":"Hi","content":"{{.name"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("create with syntax error status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec := serveJSON(e, http.MethodPut, "/v1/templates/welcome", `{"subject":"Hello {{.name}}","content":"<p>Hello {{.name}}</p>"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var updated templateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if updated.Version != 2 || updated.Subject != "Hello {{.name}}" || updated.Name != "Welcome" || len(updated.Versions) != 2 {
+		t.Errorf("updated template = %+v", updated)
+	}
+	if rec := serveJSON(e, http.MethodPut, "/v1/templates/missing", `{"subject":"Hi","content":"Hi"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("update unknown status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = serve(e, http.MethodGet, "/v1/templates/welcome/versions/1")
+	var first templates.Version
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("get version status = %d, error = %v", rec.Code, err)
+	}
+	if first.Number != 1 || first.Subject != "Welcome {{.name}}" {
+		t.Errorf("version 1 = %+v", first)
+	}
+	for target, want := range map[string]int{
+		"/v1/templates/welcome/versions/3": http.StatusNotFound,
+		"/v1/templates/welcome/versions/x": http.StatusBadRequest,
+		"/v1/templates/missing":            http.StatusNotFound,
+	} {
+		if rec := serve(e, http.MethodGet, target); rec.Code != want {
+			t.Errorf("GET %s status = %d, want %d", target, rec.Code, want)
+		}
+	}
+
+	rec = serve(e, http.MethodGet, "/v1/templates")
+	var list listTemplatesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(list.Templates) != 1 || list.Templates[0].ID != "welcome" {
+		t.Errorf("listed templates = %+v", list.Templates)
+	}
+
+	if rec := serve(e, http.MethodDelete, "/v1/templates/welcome"); rec.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := serve(e, http.MethodGet, "/v1/templates/welcome"); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func Test_sendMailHandler_Template(t *testing.T) {
+	library := testLibrary()
+	if _, err := library.Create("welcome", templates.Definition{Content: templates.Content{
+		Subject:     "Welcome {{.name}}",
+		HtmlContent: "<p>Hi {{.name}}</p>",
+		Locales: map[string]templates.Content{
+			"de": {Subject: "Willkommen {{.name}}", HtmlContent: "<p>Hi {{.name}}</p>"},
+		},
+	}}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantSubject string
+	}{
+		{
+			name:        "rendered with data",
+			body:        `{"to": "a@example.com", "templateId": "welcome", "data": {"name": "Ann"}}`,
+			wantStatus:  http.StatusOK,
+			wantSubject: "Welcome Ann",
+		},
+		{
+			name:        "pinned version",
+			body:        `{"to": "a@example.com", "templateId": "welcome", "templateVersion": 1, "data": {"name": "Ann"}}`,
+			wantStatus:  http.StatusOK,
+			wantSubject: "Welcome Ann",
+		},
+		{
+			name:        "localized",
+			body:        `{"to": "a@example.com", "templateId": "welcome", "locale": "de-AT", "data": {"name": "Ann"}}`,
+			wantStatus:  http.StatusOK,
+			wantSubject: "Willkommen Ann",
+		},
+		{
+			name:        "missing translation",
+			body:        `{"to": "a@example.com", "templateId": "welcome", "locale": "fr", "data": {"name": "Ann"}}`,
+			wantStatus:  http.StatusOK,
+			wantSubject: "Welcome Ann",
+		},
+		{
+			name:       "unknown template",
+			body:       `{"to": "a@example.com", "templateId": "missing"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown version",
+			body:       `{"to": "a@example.com", "templateId": "welcome", "templateVersion": 2, "data": {"name": "Ann"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing data",
+			body:       `{"to": "a@example.com", "templateId": "welcome"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "content and template",
+			body:       `{"to": "a@example.com", "content": "Body", "templateId": "welcome", "data": {"name": "Ann"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "content type and template",
+			body:       `{"to": "a@example.com", "contentType": "markdown", "templateId": "welcome", "data": {"name": "Ann"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "template without recipient",
+			body:       `{"templateId": "welcome", "data": {"name": "Ann"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			ctx := newContextWithBody(tt.body)
+			err := sendMailHandler(outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg), library, testComposer(t), cfg)(ctx)
+			if status := responseStatus(ctx, err); status != tt.wantStatus {
+				t.Fatalf("sendMailHandler() status = %d, want %d (error = %v)", status, tt.wantStatus, err)
+			}
+			if tt.wantSubject == "" {
+				return
+			}
+			var resp sendMailResponse
+			if err := json.Unmarshal(ctx.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Subject != tt.wantSubject || resp.HtmlContent != "<p>Hi Ann</p>" {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
+
+func Test_sendMailHandler_MarkdownTemplate(t *testing.T) {
+	library := testLibrary()
+	if _, err := library.Create("invoice", templates.Definition{Content: templates.Content{
+		Subject:     "Invoice for {{.name}}",
+		HtmlContent: "Hi **{{.name}}**, your invoice from {{.company}} is attached. {{.note}}",
+		ContentType: mail.ContentTypeMarkdown,
+	}}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	cfg := testConfig()
+	ctx := newContextWithBody(`{"to": "a@example.com", "templateId": "invoice", "data": {"name": "O'Brien <ob>", "company": "Smith & Sons", "note": "[Pay now](https://evil.example)"}}`)
+	if err := sendMailHandler(outbox.New(outbox.NewMemoryStore(), noop.NewNoopService(), cfg), library, testComposer(t), cfg)(ctx); err != nil {
+		t.Fatalf("sendMailHandler() error = %v", err)
+	}
+	var resp sendMailResponse
+	if err := json.Unmarshal(ctx.Response().Writer.(*httptest.ResponseRecorder).Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	// the text part shows the data as it is
+	if want := "Hi **O'Brien <ob>**, your invoice from Smith & Sons is attached. [Pay now](https://evil.example)"; resp.TextContent != want {
+		t.Errorf("textContent = %q, want %q", resp.TextContent, want)
+	}
+	// the HTML shows it as text, escaped once, and without the link
+	if !strings.Contains(resp.HtmlContent, "<strong>O'Brien &lt;ob&gt;</strong>") || !strings.Contains(resp.HtmlContent, "from Smith &amp; Sons") ||
+		!strings.Contains(resp.HtmlContent, "[Pay now](https://evil.example)") || strings.Contains(resp.HtmlContent, "<a ") {
+		t.Errorf("content = %q, want the data as escaped text", resp.HtmlContent)
+	}
+}
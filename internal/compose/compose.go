@@ -0,0 +1,148 @@
+// Package compose turns the content of a received mail into the HTML that is
+// handed to the providers, e.g. by rendering Markdown into the branded layout
+// and inlining CSS.
+package compose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// ErrContentType is returned for a mail whose content type is not supported.
+var ErrContentType = errors.New("unsupported content type")
+
+// defaultLayout is used unless markdown.layoutFile is configured. Its styles
+// are inline, as many mail clients drop <style> elements.
+const defaultLayout = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Subject}}</title>
+</head>
+<body style="margin:0;padding:0;background-color:#f4f4f5;">
+<div style="max-width:600px;margin:0 auto;padding:24px;font-family:Helvetica,Arial,sans-serif;font-size:15px;line-height:1.5;color:#18181b;">
+{{- if or .LogoURL .Header}}
+<div style="padding-bottom:16px;">
+{{- if .LogoURL}}<img src="{{.LogoURL}}" alt="{{.Header}}" style="max-height:48px;border:0;">{{end}}
+{{- if .Header}}<div style="font-size:18px;font-weight:bold;">{{.Header}}</div>{{end}}
+</div>
+{{- end}}
+<div style="background-color:#ffffff;border-radius:6px;padding:24px;">
+{{.Content}}
+</div>
+{{- if .Footer}}
+<div style="padding-top:16px;font-size:12px;color:#71717a;">{{.Footer}}</div>
+{{- end}}
+</div>
+</body>
+</html>
+`
+
+// LayoutData is what the layout is executed with.
+type LayoutData struct {
+	Subject string
+	// Content is the HTML rendered from the Markdown of the mail.
+	Content template.HTML
+	LogoURL string
+	Header  string
+	Footer  string
+}
+
+// Composer renders the content of mails into the HTML sent to the providers.
+type Composer struct {
+	markdown goldmark.Markdown
+	layout   *template.Template
+	branding config.MarkdownConfig
+	html     config.HTMLConfig
+}
+
+// NewComposer creates a composer with the layout configured in cfg. It fails
+// if the configured layout file cannot be read or parsed.
+func NewComposer(cfg *config.Config) (*Composer, error) {
+	source := defaultLayout
+	if cfg.Markdown.LayoutFile != "" {
+		data, err := os.ReadFile(cfg.Markdown.LayoutFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading markdown layout: %w", err)
+		}
+		source = string(data)
+	}
+	layout, err := template.New("layout").Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("parsing markdown layout %q: %w", cfg.Markdown.LayoutFile, err)
+	}
+
+	return &Composer{
+		// raw HTML in the Markdown is left out, like the GitHub flavor it extends
+		markdown: goldmark.New(goldmark.WithExtensions(extension.GFM)),
+		layout:   layout,
+		branding: cfg.Markdown,
+		html:     cfg.HTML,
+	}, nil
+}
+
+// Compose replaces the content of a mail written in Markdown by its HTML,
+// rendered into the layout. If html.enabled is configured, the HTML is then
+// normalized, see normalize.
+func (c *Composer) Compose(attributes *mail.MailAttributes) error {
+	switch attributes.ContentType {
+	case "", mail.ContentTypeHTML:
+	case mail.ContentTypeMarkdown:
+		if err := c.composeMarkdown(attributes); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrContentType, attributes.ContentType)
+	}
+
+	if !c.html.Enabled {
+		return nil
+	}
+	return c.normalize(attributes)
+}
+
+// composeMarkdown renders the Markdown content of a mail into the layout. The
+// Markdown source becomes the text part unless the mail has one. The content
+// type is cleared, as the content is HTML now.
+func (c *Composer) composeMarkdown(attributes *mail.MailAttributes) error {
+	html, err := c.renderMarkdown(attributes.Subject, attributes.HtmlContent)
+	if err != nil {
+		return err
+	}
+	if attributes.TextContent == "" {
+		attributes.TextContent = attributes.HtmlContent
+	}
+	attributes.HtmlContent = html
+	attributes.ContentType = ""
+	return nil
+}
+
+// renderMarkdown converts source to HTML and executes the layout with it.
+func (c *Composer) renderMarkdown(subject, source string) (string, error) {
+	var content bytes.Buffer
+	if err := c.markdown.Convert([]byte(source), &content); err != nil {
+		return "", fmt.Errorf("rendering markdown: %w", err)
+	}
+
+	var out bytes.Buffer
+	err := c.layout.Execute(&out, LayoutData{
+		Subject: subject,
+		Content: template.HTML(content.String()),
+		LogoURL: c.branding.LogoURL,
+		Header:  c.branding.Header,
+		Footer:  c.branding.Footer,
+	})
+	if err != nil {
+		return "", fmt.Errorf("executing markdown layout: %w", err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,124 @@
+package compose
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+func newTestComposer(t *testing.T, markdown config.MarkdownConfig) *Composer {
+	t.Helper()
+	composer, err := NewComposer(&config.Config{Markdown: markdown})
+	if err != nil {
+		t.Fatalf("NewComposer() error = %v", err)
+	}
+	return composer
+}
+
+func TestComposer_Compose(t *testing.T) {
+	composer := newTestComposer(t, config.MarkdownConfig{
+		LogoURL: "https://example.com/logo.png",
+		Header:  "Example Ops",
+		Footer:  "Sent by <ops>",
+	})
+	source := "# Deploy finished\n\n- **api** is on `v2`\n- see [the log](https://example.com/log)\n\n<script>alert(1)</script>\n"
+
+	tests := []struct {
+		name         string
+		attributes   mail.MailAttributes
+		wantContains []string
+		wantText     string
+		wantErr      error
+	}{
+		{
+			name:       "markdown",
+			attributes: mail.MailAttributes{Subject: "Deploy <done>", HtmlContent: source, ContentType: mail.ContentTypeMarkdown},
+			wantContains: []string{
+				"<title>Deploy &lt;done&gt;</title>",
+				`<img src="https://example.com/logo.png" alt="Example Ops"`,
+				"<h1>Deploy finished</h1>",
+				"<li><strong>api</strong> is on <code>v2</code></li>",
+				`<a href="https://example.com/log">the log</a>`,
+				"Sent by &lt;ops&gt;",
+			},
+			wantText: source,
+		},
+		{
+			name:         "markdown with text part",
+			attributes:   mail.MailAttributes{HtmlContent: "Hi *there*", TextContent: "Hi there", ContentType: mail.ContentTypeMarkdown},
+			wantContains: []string{"<p>Hi <em>there</em></p>"},
+			wantText:     "Hi there",
+		},
+		{
+			name:         "html",
+			attributes:   mail.MailAttributes{HtmlContent: "<p>Hi</p>", ContentType: mail.ContentTypeHTML},
+			wantContains: []string{"<p>Hi</p>"},
+		},
+		{
+			name:       "unknown content type",
+			attributes: mail.MailAttributes{HtmlContent: "Hi", ContentType: "rtf"},
+			wantErr:    ErrContentType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes := tt.attributes
+			err := composer.Compose(&attributes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Compose() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(attributes.HtmlContent, want) {
+					t.Errorf("Compose() content = %s, want it to contain %q", attributes.HtmlContent, want)
+				}
+			}
+			if strings.Contains(attributes.HtmlContent, "<script>") {
+				t.Errorf("Compose() kept raw HTML: %s", attributes.HtmlContent)
+			}
+			if attributes.TextContent != tt.wantText {
+				t.Errorf("Compose() text = %q, want %q", attributes.TextContent, tt.wantText)
+			}
+			if attributes.ContentType == mail.ContentTypeMarkdown {
+				t.Errorf("Compose() kept the markdown content type")
+			}
+		})
+	}
+}
+
+func TestNewComposer_LayoutFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "layout.html")
+	if err := os.WriteFile(path, []byte(`<main>{{.Content}}</main><footer>{{.Footer}}</footer>`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	composer := newTestComposer(t, config.MarkdownConfig{LayoutFile: path, Footer: "Example"})
+	attributes := mail.MailAttributes{HtmlContent: "Hi", ContentType: mail.ContentTypeMarkdown}
+	if err := composer.Compose(&attributes); err != nil {
+		t.Fatalf("Compose() error = %v", err)
+	}
+	if want := "<main><p>Hi</p>\n</main><footer>Example</footer>"; attributes.HtmlContent != want {
+		t.Errorf("Compose() content = %q, want %q", attributes.HtmlContent, want)
+	}
+
+	for name, content := range map[string]string{"missing": "", "broken": "{{.Content"} {
+		path := filepath.Join(dir, name+".html")
+		if content != "" {
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := NewComposer(&config.Config{Markdown: config.MarkdownConfig{LayoutFile: path}}); err == nil {
+			t.Errorf("NewComposer() with a %s layout file succeeded", name)
+		}
+	}
+}
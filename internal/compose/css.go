@@ -0,0 +1,248 @@
+package compose
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+var (
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// dynamicPseudoClass matches selectors that depend on the state of the
+	// element, e.g. a:hover. They cannot be inlined and stay in a <style>.
+	dynamicPseudoClass = regexp.MustCompile(`(?i):(hover|active|focus|visited|target)\b`)
+	important          = regexp.MustCompile(`(?i)!\s*important$`)
+)
+
+// droppedAtRules are not supported by mail clients and left out entirely.
+var droppedAtRules = map[string]bool{"import": true, "charset": true, "namespace": true}
+
+// declaration is a property of a CSS rule or a style attribute.
+type declaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+// cssRule is a single selector of a stylesheet rule with its declarations.
+type cssRule struct {
+	selector     cascadia.Sel
+	specificity  cascadia.Specificity
+	declarations []declaration
+}
+
+// stylesheet is a parsed <style> element. Rules that cannot be inlined, like
+// @media queries and :hover selectors, are kept as CSS in remaining.
+type stylesheet struct {
+	rules     []cssRule
+	remaining []string
+}
+
+// parseStylesheet splits css into rules that can be inlined and those that
+// have to stay in a <style> element.
+func parseStylesheet(css string) stylesheet {
+	var sheet stylesheet
+	css = cssComment.ReplaceAllString(css, "")
+	for i := 0; i < len(css); {
+		open := strings.IndexAny(css[i:], "{;")
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(css[i : i+open])
+		i += open
+		if css[i] == ';' {
+			// a statement at-rule, e.g. @import, or a stray semicolon
+			i++
+			if strings.HasPrefix(prelude, "@") && !droppedAtRules[atRuleName(prelude)] {
+				sheet.remaining = append(sheet.remaining, prelude+";")
+			}
+			continue
+		}
+
+		end := matchingBrace(css, i)
+		block := css[i+1 : end]
+		i = end + 1
+		switch {
+		case prelude == "":
+		case strings.HasPrefix(prelude, "@"):
+			if !droppedAtRules[atRuleName(prelude)] {
+				sheet.remaining = append(sheet.remaining, prelude+"{"+strings.TrimSpace(block)+"}")
+			}
+		default:
+			sheet.addRule(prelude, block)
+		}
+	}
+	return sheet
+}
+
+// addRule adds a rule for each of the comma-separated selectors in prelude.
+func (sheet *stylesheet) addRule(prelude, block string) {
+	declarations := parseDeclarations(block)
+	if len(declarations) == 0 {
+		return
+	}
+	for _, selector := range splitOutside(prelude, ',') {
+		selector = strings.TrimSpace(selector)
+		if selector == "" {
+			continue
+		}
+		sel, err := cascadia.Parse(selector)
+		if err != nil || dynamicPseudoClass.MatchString(selector) {
+			// pseudo-elements and selectors unknown to the parser are left
+			// to the mail clients that support them
+			sheet.remaining = append(sheet.remaining, selector+"{"+strings.TrimSpace(block)+"}")
+			continue
+		}
+		sheet.rules = append(sheet.rules, cssRule{selector: sel, specificity: sel.Specificity(), declarations: declarations})
+	}
+}
+
+// parseDeclarations parses the declarations of a rule or a style attribute,
+// e.g. "color: red; margin: 0 !important".
+func parseDeclarations(block string) []declaration {
+	var declarations []declaration
+	for _, part := range splitOutside(block, ';') {
+		property, value, ok := strings.Cut(part, ":")
+		property = strings.ToLower(strings.TrimSpace(property))
+		value = strings.TrimSpace(value)
+		if !ok || property == "" || value == "" {
+			continue
+		}
+		d := declaration{property: property, value: value}
+		if loc := important.FindStringIndex(value); loc != nil {
+			d.value, d.important = strings.TrimSpace(value[:loc[0]]), true
+		}
+		declarations = append(declarations, d)
+	}
+	return declarations
+}
+
+// inlineStyle applies the cascade to node: the declarations of the matching
+// rules in order of specificity, then its style attribute, then the
+// !important declarations. It returns the resulting style attribute, or style
+// if no rule matches. The !important flag is kept for declarations of the
+// style attribute only, so that the remaining @media rules can still override
+// the inlined ones.
+func inlineStyle(node *html.Node, rules []cssRule, style string) string {
+	type applied struct {
+		declaration
+		specificity cascadia.Specificity
+		inline      bool
+	}
+	var matched []applied
+	for _, rule := range rules {
+		if !rule.selector.Match(node) {
+			continue
+		}
+		for _, d := range rule.declarations {
+			matched = append(matched, applied{declaration: d, specificity: rule.specificity})
+		}
+	}
+	if len(matched) == 0 {
+		return style
+	}
+	for _, d := range parseDeclarations(style) {
+		matched = append(matched, applied{declaration: d, inline: true})
+	}
+	// rules are in document order, which decides between equal specificities
+	slices.SortStableFunc(matched, func(a, b applied) int {
+		switch {
+		case a.important != b.important:
+			return boolOrder(a.important, b.important)
+		case a.inline != b.inline:
+			return boolOrder(a.inline, b.inline)
+		case a.specificity.Less(b.specificity):
+			return -1
+		case b.specificity.Less(a.specificity):
+			return 1
+		}
+		return 0
+	})
+
+	// a declaration that overrides an earlier one moves to the end, so that
+	// a longhand like margin-top still comes after the margin it overrides
+	var result []declaration
+	for _, d := range matched {
+		result = slices.DeleteFunc(result, func(r declaration) bool { return r.property == d.property })
+		d.important = d.important && d.inline
+		result = append(result, d.declaration)
+	}
+
+	var b strings.Builder
+	for _, d := range result {
+		b.WriteString(d.property + ":" + d.value)
+		if d.important {
+			b.WriteString(" !important")
+		}
+		b.WriteString(";")
+	}
+	return b.String()
+}
+
+// boolOrder sorts false before true.
+func boolOrder(a, b bool) int {
+	if a && !b {
+		return 1
+	}
+	if b && !a {
+		return -1
+	}
+	return 0
+}
+
+// atRuleName returns the lowercased name of an at-rule, e.g. "media" for "@media screen".
+func atRuleName(prelude string) string {
+	name := strings.TrimPrefix(prelude, "@")
+	if i := strings.IndexFunc(name, func(r rune) bool { return r == ' ' || r == '(' || r == '"' || r == '\'' }); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// matchingBrace returns the index of the brace closing the one at open, or
+// the end of css if it is not closed.
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(css)
+}
+
+// splitOutside splits s at sep, except inside parentheses or quotes, e.g. in
+// :is(h1, h2) or url("a;b").
+func splitOutside(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
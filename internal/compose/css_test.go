@@ -0,0 +1,105 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseStylesheet(t *testing.T) {
+	sheet := parseStylesheet(`
+@charset "utf-8";
+@import url("https://example.com/fonts.css");
+/* the brand color */
+h1, .title { color: #123456; }
+a:hover { color: red }
+p::before { content: "; " }
+@media (max-width: 600px) { .column { width: 100% !important; } }
+.empty { }
+`)
+
+	var selectors []string
+	for _, rule := range sheet.rules {
+		selectors = append(selectors, rule.selector.String())
+	}
+	if want := []string{"h1", ".title"}; !reflect.DeepEqual(selectors, want) {
+		t.Errorf("parseStylesheet() rules = %v, want %v", selectors, want)
+	}
+	want := []string{
+		"a:hover{color: red}",
+		`p::before{content: "; "}`,
+		"@media (max-width: 600px){.column { width: 100% !important; }}",
+	}
+	if !reflect.DeepEqual(sheet.remaining, want) {
+		t.Errorf("parseStylesheet() remaining = %q, want %q", sheet.remaining, want)
+	}
+}
+
+func TestParseDeclarations(t *testing.T) {
+	got := parseDeclarations(`Color: red; background: url("a;b.png") ;; margin:0 ! IMPORTANT; broken`)
+	want := []declaration{
+		{property: "color", value: "red"},
+		{property: "background", value: `url("a;b.png")`},
+		{property: "margin", value: "0", important: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDeclarations() = %+v, want %+v", got, want)
+	}
+}
+
+func TestInlineStyle(t *testing.T) {
+	sheet := parseStylesheet(`
+p { color: black; margin: 0; }
+.note { color: gray; font-size: 12px !important; }
+#intro { color: blue; }
+p { margin-top: 8px; }
+.note { color: green; }
+h1 { color: red; }
+`)
+
+	tests := []struct {
+		name  string
+		html  string
+		style string
+		want  string
+	}{
+		{
+			name: "specificity and order",
+			html: `<p id="intro" class="note">`,
+			want: "margin:0;margin-top:8px;color:blue;font-size:12px;",
+		},
+		{
+			name:  "style attribute wins over rules",
+			html:  `<p class="note">`,
+			style: "color: purple; font-size: 20px",
+			want:  "margin:0;margin-top:8px;color:purple;font-size:12px;",
+		},
+		{
+			name:  "important style attribute",
+			html:  `<p class="note">`,
+			style: "font-size: 20px !important",
+			want:  "margin:0;margin-top:8px;color:green;font-size:20px !important;",
+		},
+		{
+			name:  "no matching rule",
+			html:  `<span>`,
+			style: "color: red",
+			want:  "color: red",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := doc.FirstChild.LastChild
+			if got := inlineStyle(body.FirstChild, sheet.rules, tt.style); got != tt.want {
+				t.Errorf("inlineStyle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
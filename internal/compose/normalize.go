@@ -0,0 +1,207 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// unsupportedElements are removed with their content. Mail clients do not run
+// or load them, and some flag mails that contain them as spam.
+var unsupportedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Noscript: true, atom.Iframe: true, atom.Frame: true, atom.Frameset: true,
+	atom.Object: true, atom.Embed: true, atom.Applet: true, atom.Base: true, atom.Link: true,
+}
+
+// urlAttributes are the attributes whose javascript: URLs are removed.
+var urlAttributes = map[string]bool{"href": true, "src": true, "action": true, "formaction": true, "background": true}
+
+// preheaderStyle hides the preheader in the body of the mail in all clients, including Outlook.
+const preheaderStyle = "display:none;font-size:1px;line-height:1px;max-height:0;max-width:0;opacity:0;overflow:hidden;mso-hide:all;"
+
+// normalize inlines the <style> elements of the HTML of a mail, removes what
+// mail clients do not support and adds the configured preheader. Conditional
+// comments for Outlook are kept.
+func (c *Composer) normalize(attributes *mail.MailAttributes) error {
+	if attributes.HtmlContent == "" {
+		return nil
+	}
+	doc, err := html.Parse(strings.NewReader(attributes.HtmlContent))
+	if err != nil {
+		return fmt.Errorf("parsing HTML: %w", err)
+	}
+
+	removeUnsupported(doc)
+	inlineStyles(doc)
+	if c.html.Preheader != "" {
+		if attributes.TextContent == "" {
+			// the text part generated from the HTML would start with the hidden preheader
+			attributes.TextContent = mail.HTMLToText(attributes.HtmlContent)
+		}
+		addPreheader(doc, c.html.Preheader)
+	}
+
+	var b strings.Builder
+	if err := html.Render(&b, doc); err != nil {
+		return fmt.Errorf("rendering HTML: %w", err)
+	}
+	attributes.HtmlContent = b.String()
+	return nil
+}
+
+// removeUnsupported removes the unsupported elements below node, as well as
+// event handler attributes and javascript: URLs.
+func removeUnsupported(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode && (unsupportedElements[child.DataAtom] || isRefresh(child)) {
+			node.RemoveChild(child)
+			child = next
+			continue
+		}
+		if child.Type == html.ElementNode {
+			child.Attr = removeUnsafeAttributes(child.Attr)
+		}
+		removeUnsupported(child)
+		child = next
+	}
+}
+
+// removeUnsafeAttributes returns attrs without on* event handlers and javascript: URLs.
+func removeUnsafeAttributes(attrs []html.Attribute) []html.Attribute {
+	kept := attrs[:0]
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if strings.HasPrefix(key, "on") {
+			continue
+		}
+		if urlAttributes[key] && strings.HasPrefix(strings.ToLower(strings.TrimSpace(attr.Val)), "javascript:") {
+			continue
+		}
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+// isRefresh reports whether node is a <meta http-equiv="refresh">, which redirects the reader.
+func isRefresh(node *html.Node) bool {
+	return node.DataAtom == atom.Meta && strings.EqualFold(strings.TrimSpace(getAttribute(node, "http-equiv")), "refresh")
+}
+
+// inlineStyles moves the rules of the <style> elements in doc into the style
+// attributes of the elements of the body. Rules that cannot be inlined stay in
+// a <style> element in the head. <style> elements for media other than
+// screen, e.g. print, are left as they are.
+func inlineStyles(doc *html.Node) {
+	var css []string
+	var elements []*html.Node
+	walkElements(doc, func(node *html.Node) {
+		if node.DataAtom != atom.Style || !isScreenMedia(getAttribute(node, "media")) {
+			return
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.TextNode {
+				css = append(css, child.Data)
+			}
+		}
+		elements = append(elements, node)
+	})
+	if len(elements) == 0 {
+		return
+	}
+	for _, element := range elements {
+		element.Parent.RemoveChild(element)
+	}
+
+	sheet := parseStylesheet(strings.Join(css, "\n"))
+	if head := findElement(doc, atom.Head); head != nil && len(sheet.remaining) > 0 {
+		style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: strings.Join(sheet.remaining, "\n")})
+		head.AppendChild(style)
+	}
+	if len(sheet.rules) == 0 {
+		return
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return
+	}
+	inline := func(node *html.Node) {
+		if style := inlineStyle(node, sheet.rules, getAttribute(node, "style")); style != "" {
+			setAttribute(node, "style", style)
+		}
+	}
+	inline(body)
+	walkElements(body, inline)
+}
+
+// isScreenMedia reports whether the media attribute of a <style> element
+// applies to mail clients.
+func isScreenMedia(media string) bool {
+	switch strings.ToLower(strings.TrimSpace(media)) {
+	case "", "all", "screen":
+		return true
+	}
+	return false
+}
+
+// addPreheader inserts text as hidden element at the top of the body.
+func addPreheader(doc *html.Node, text string) {
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return
+	}
+	preheader := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+		Attr:     []html.Attribute{{Key: "style", Val: preheaderStyle}},
+	}
+	preheader.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	body.InsertBefore(preheader, body.FirstChild)
+}
+
+// walkElements calls fn for each element below node in document order.
+func walkElements(node *html.Node, fn func(*html.Node)) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode {
+			fn(child)
+		}
+		walkElements(child, fn)
+	}
+}
+
+// findElement returns the first element below node with the given tag, or nil.
+func findElement(node *html.Node, tag atom.Atom) *html.Node {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == tag {
+			return child
+		}
+		if found := findElement(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func getAttribute(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func setAttribute(node *html.Node, key, val string) {
+	for i, attr := range node.Attr {
+		if attr.Key == key {
+			node.Attr[i].Val = val
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: key, Val: val})
+}
@@ -0,0 +1,112 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+func TestComposer_Compose_Normalize(t *testing.T) {
+	document := `<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="refresh" content="0; url=https://example.com">
+<link rel="stylesheet" href="https://example.com/site.css">
+<style>
+h1 { color: #123456; font-size: 24px; }
+.button { background-color: #0055ff; }
+a:hover { text-decoration: underline; }
+@media (max-width: 600px) { h1 { font-size: 18px !important; } }
+</style>
+<style media="print">h1 { color: black; }</style>
+</head>
+<body onload="track()">
+<!--[if mso]><table><tr><td><![endif]-->
+<h1 style="margin: 0">Welcome</h1>
+<a class="button" href="https://example.com" onclick="track()">Start</a>
+<a href="javascript:alert(1)">Hack</a>
+<script>alert(1)</script>
+<iframe src="https://example.com"></iframe>
+</body>
+</html>`
+
+	tests := []struct {
+		name            string
+		html            config.HTMLConfig
+		attributes      mail.MailAttributes
+		wantContains    []string
+		wantNotContains []string
+		wantText        string
+	}{
+		{
+			name:       "disabled",
+			attributes: mail.MailAttributes{HtmlContent: document},
+			wantContains: []string{
+				"<script>alert(1)</script>",
+				"h1 { color: #123456; font-size: 24px; }",
+			},
+		},
+		{
+			name:       "enabled",
+			html:       config.HTMLConfig{Enabled: true},
+			attributes: mail.MailAttributes{HtmlContent: document},
+			wantContains: []string{
+				`<h1 style="color:#123456;font-size:24px;margin:0;">Welcome</h1>`,
+				`<a class="button" href="https://example.com" style="background-color:#0055ff;">Start</a>`,
+				"<style>a:hover{text-decoration: underline;}\n@media (max-width: 600px){h1 { font-size: 18px !important; }}</style>",
+				`<style media="print">h1 { color: black; }</style>`,
+				"<!--[if mso]><table><tr><td><![endif]-->",
+				"<a>Hack</a>",
+				"<body>",
+			},
+			wantNotContains: []string{"<script", "<iframe", "<link", "refresh", "onclick", "onload", "javascript:", ".button {"},
+		},
+		{
+			name:       "preheader",
+			html:       config.HTMLConfig{Enabled: true, Preheader: "Your weekly summary"},
+			attributes: mail.MailAttributes{HtmlContent: "<p>Hi</p>"},
+			wantContains: []string{
+				`<body><div style="` + preheaderStyle + `">Your weekly summary</div><p>Hi</p></body>`,
+			},
+			wantText: "Hi",
+		},
+		{
+			name:       "markdown",
+			html:       config.HTMLConfig{Enabled: true, Preheader: "Deploy <done>"},
+			attributes: mail.MailAttributes{HtmlContent: "Hi", ContentType: mail.ContentTypeMarkdown},
+			wantContains: []string{
+				"<p>Hi</p>",
+				">Deploy &lt;done&gt;</div>",
+			},
+			wantText: "Hi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			composer, err := NewComposer(&config.Config{HTML: tt.html})
+			if err != nil {
+				t.Fatalf("NewComposer() error = %v", err)
+			}
+			attributes := tt.attributes
+			if err := composer.Compose(&attributes); err != nil {
+				t.Fatalf("Compose() error = %v", err)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(attributes.HtmlContent, want) {
+					t.Errorf("Compose() content = %s, want it to contain %q", attributes.HtmlContent, want)
+				}
+			}
+			for _, unwanted := range tt.wantNotContains {
+				if strings.Contains(attributes.HtmlContent, unwanted) {
+					t.Errorf("Compose() content = %s, want it not to contain %q", attributes.HtmlContent, unwanted)
+				}
+			}
+			if attributes.TextContent != tt.wantText {
+				t.Errorf("Compose() text = %q, want %q", attributes.TextContent, tt.wantText)
+			}
+		})
+	}
+}
@@ -4,25 +4,103 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	netmail "net/mail"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config is the complete application configuration loaded from a YAML file.
 type Config struct {
-	LogLevel string         `yaml:"logLevel"`
-	Sender   SenderConfig   `yaml:"sender"`
-	HTTP     HTTPConfig     `yaml:"http"`
-	SMTP     SMTPConfig     `yaml:"smtp"`
-	Provider ProviderConfig `yaml:"provider"`
+	LogLevel       string               `yaml:"logLevel"`
+	Sender         SenderConfig         `yaml:"sender"`
+	HTTP           HTTPConfig           `yaml:"http"`
+	SMTP           SMTPConfig           `yaml:"smtp"`
+	Attachments    AttachmentsConfig    `yaml:"attachments"`
+	Queue          QueueConfig          `yaml:"queue"`
+	Messages       MessagesConfig       `yaml:"messages"`
+	Templates      TemplatesConfig      `yaml:"templates"`
+	Markdown       MarkdownConfig       `yaml:"markdown"`
+	HTML           HTMLConfig           `yaml:"html"`
+	Retry          RetryConfig          `yaml:"retry"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker"`
+	Provider       ProviderConfig       `yaml:"provider"`
 }
 
-// SenderConfig holds the default outbound sender identity.
+// DefaultMaxAttachmentBytes is the total attachment size limit used when none is configured.
+const DefaultMaxAttachmentBytes int64 = 10 * 1024 * 1024 // 10 MB
+
+// Defaults for the outbound queue.
+const (
+	DefaultQueueWorkers      = 4
+	DefaultQueueMaxAttempts  = 10
+	DefaultQueueRetryBackoff = 30 * time.Second
+	DefaultQueuePollInterval = time.Second
+)
+
+// Defaults for retrying transient provider errors.
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 500 * time.Millisecond
+	DefaultRetryMaxBackoff     = 10 * time.Second
+)
+
+// Defaults for the circuit breaker of each provider.
+const (
+	DefaultCircuitBreakerFailureThreshold  = 5
+	DefaultCircuitBreakerOpenDuration      = 30 * time.Second
+	DefaultCircuitBreakerHalfOpenSuccesses = 1
+)
+
+// DefaultMessageRetention is how long sent and failed messages are kept when none is configured.
+const DefaultMessageRetention = 7 * 24 * time.Hour
+
+// SenderConfig holds the default outbound sender identity and the
+// sender addresses clients may use instead of it.
 type SenderConfig struct {
-	Address string `yaml:"address"`
-	Name    string `yaml:"name"`
+	Address          string   `yaml:"address"`
+	Name             string   `yaml:"name"`
+	AllowedAddresses []string `yaml:"allowedAddresses"`
+	AllowedDomains   []string `yaml:"allowedDomains"`
+}
+
+// IsAllowed reports whether a client may send as the given address.
+// The default sender address is always allowed, others only when listed in
+// AllowedAddresses or when their domain is listed in AllowedDomains.
+func (s SenderConfig) IsAllowed(address string) bool {
+	if parsed, err := netmail.ParseAddress(address); err == nil {
+		address = parsed.Address
+	}
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" {
+		return false
+	}
+
+	if address == strings.ToLower(s.Address) {
+		return true
+	}
+	for _, allowed := range s.AllowedAddresses {
+		if address == strings.ToLower(strings.TrimSpace(allowed)) {
+			return true
+		}
+	}
+
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return false
+	}
+	domain := address[at+1:]
+	for _, allowed := range s.AllowedDomains {
+		if domain == strings.ToLower(strings.TrimPrefix(strings.TrimSpace(allowed), "@")) {
+			return true
+		}
+	}
+	return false
 }
 
 // HTTPConfig holds HTTP server settings.
@@ -54,21 +132,255 @@ type SMTPTLSConfig struct {
 	KeyFile  string `yaml:"keyFile"`
 }
 
-// ProviderConfig selects and configures the active mail provider.
+// AttachmentsConfig limits the attachments accepted per mail.
+type AttachmentsConfig struct {
+	// MaxTotalBytes is the maximum decoded size of all attachments of one mail.
+	MaxTotalBytes int64 `yaml:"maxTotalBytes"`
+}
+
+// QueueConfig configures the persistent outbox. When enabled, mail is stored
+// in the database file at Path and delivered in the background by Workers.
+type QueueConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	Workers int    `yaml:"workers"`
+	// MaxAttempts is the number of delivery attempts before a message is marked as failed.
+	MaxAttempts int `yaml:"maxAttempts"`
+	// RetryBackoff is the delay before the first retry; it doubles with every further attempt.
+	RetryBackoff time.Duration `yaml:"retryBackoff"`
+	// PollInterval is how often the queue is checked for messages that are due.
+	PollInterval time.Duration `yaml:"pollInterval"`
+}
+
+// MessagesConfig configures the message status records kept for lookup.
+type MessagesConfig struct {
+	// Retention is how long sent and failed messages can still be looked up.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// TemplatesConfig configures the store of the mail templates.
+type TemplatesConfig struct {
+	// Path is the database file the templates are kept in. If empty, they
+	// are kept in memory and lost on restart.
+	Path string `yaml:"path"`
+}
+
+// MarkdownConfig configures the layout that mails written in Markdown are
+// rendered into. The built-in layout shows the logo and the header above the
+// content and the footer below it.
+type MarkdownConfig struct {
+	// LayoutFile is an html/template file that replaces the built-in layout. It
+	// is executed with .Subject, .Content, .LogoURL, .Header and .Footer.
+	LayoutFile string `yaml:"layoutFile"`
+	// LogoURL is the absolute URL of the logo image; mail clients cannot
+	// resolve relative URLs.
+	LogoURL string `yaml:"logoUrl"`
+	Header  string `yaml:"header"`
+	Footer  string `yaml:"footer"`
+}
+
+// HTMLConfig configures the processing of the HTML of every mail before it is
+// sent, whether it was received over HTTP or SMTP.
+type HTMLConfig struct {
+	// Enabled inlines the rules of <style> elements into style attributes and
+	// removes what mail clients do not support, such as scripts.
+	Enabled bool `yaml:"enabled"`
+	// Preheader is the text mail clients show next to the subject in the inbox.
+	// It is added as hidden text at the top of the body if Enabled is set.
+	Preheader string `yaml:"preheader"`
+}
+
+// RetryConfig configures how often a transient provider error, such as a
+// 429, a 5xx or a network error, is retried before the send fails.
+// With the queue enabled, QueueConfig takes over the retries.
+type RetryConfig struct {
+	// MaxAttempts includes the first attempt; 1 disables retries.
+	MaxAttempts    int           `yaml:"maxAttempts"`
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	// MaxBackoff caps the delay between two attempts. A longer Retry-After ends the retries.
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker in front of each
+// provider. A provider whose circuit is open is skipped, so that mail goes
+// straight to the next provider instead of waiting for timeouts and retries.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of mails in a row that must fail with a
+	// transient error, after retries, to open the circuit. A batch counts as one mail.
+	FailureThreshold int `yaml:"failureThreshold"`
+	// OpenDuration is how long the circuit stays open before a single probe
+	// mail is let through (half-open).
+	OpenDuration time.Duration `yaml:"openDuration"`
+	// HalfOpenSuccesses is the number of probes in a row that must succeed to close the circuit.
+	HalfOpenSuccesses int `yaml:"halfOpenSuccesses"`
+}
+
+// Provider names as used in ProviderConfig.Order.
+const (
+	ProviderMailjet  = "mailjet"
+	ProviderSendGrid = "sendgrid"
+	ProviderSMTP     = "smtp"
+	ProviderSES      = "ses"
+	ProviderMailgun  = "mailgun"
+	ProviderPostmark = "postmark"
+	ProviderResend   = "resend"
+	ProviderNoop     = "noop"
+)
+
+// TLS modes of the SMTP relay provider.
+const (
+	SMTPRelayTLSStartTLS = "starttls"
+	SMTPRelayTLSImplicit = "implicit"
+	SMTPRelayTLSNone     = "none"
+)
+
+// Mailgun regions.
+const (
+	MailgunRegionUS = "us"
+	MailgunRegionEU = "eu"
+)
+
+// DefaultPostmarkMessageStream is the transactional stream every Postmark server has.
+const DefaultPostmarkMessageStream = "outbound"
+
+// DefaultProviderHTTPTimeout bounds a single request to an HTTP provider API
+// when no timeout is configured.
+const DefaultProviderHTTPTimeout = 30 * time.Second
+
+// DefaultRateLimitMaxWait is how long a mail sent without the queue waits
+// for a rate limit before it fails.
+const DefaultRateLimitMaxWait = 10 * time.Second
+
+// DefaultAccountCooldown is how long an account that failed with a transient
+// error is skipped when the provider did not ask for a specific delay.
+const DefaultAccountCooldown = 30 * time.Second
+
+// Defaults for the SMTP relay provider.
+const (
+	DefaultSMTPRelayMaxConnections = 4
+	DefaultSMTPRelayTimeout        = 30 * time.Second
+)
+
+// ProviderConfig configures the mail providers and the order they are tried in.
 type ProviderConfig struct {
-	Mailjet  MailjetProviderConfig  `yaml:"mailjet"`
-	SendGrid SendGridProviderConfig `yaml:"sendgrid"`
-	Noop     NoopProviderConfig     `yaml:"noop"`
+	// Order lists the providers to try, first to last. A mail goes to the next
+	// provider when the previous one fails with a transient error. Defaults to
+	// the enabled providers in the order mailjet, sendgrid, smtp, ses,
+	// mailgun, postmark, resend; noop is only used when no other provider is
+	// enabled.
+	Order []string `yaml:"order"`
+	// Routes send the recipients they match through their own providers
+	// instead of Order. The first matching route wins.
+	Routes []RouteConfig `yaml:"routes"`
+	// AccountCooldown is how long a provider account is skipped after a
+	// transient error, unless the provider asked for a longer delay.
+	AccountCooldown time.Duration `yaml:"accountCooldown"`
+	// RateLimits cap how many recipients each provider sends to, keyed by
+	// provider name. Accounts of a provider can have their own limits in addition.
+	RateLimits map[string]RateLimitConfig `yaml:"rateLimits"`
+	// RateLimitMaxWait is how long a mail sent without the queue waits for a
+	// rate limit before it fails. With the queue, the delivery is postponed instead.
+	RateLimitMaxWait time.Duration `yaml:"rateLimitMaxWait"`
+
+	Mailjet  MailjetProviderConfig   `yaml:"mailjet"`
+	SendGrid SendGridProviderConfig  `yaml:"sendgrid"`
+	SMTP     SMTPRelayProviderConfig `yaml:"smtp"`
+	SES      SESProviderConfig       `yaml:"ses"`
+	Mailgun  MailgunProviderConfig   `yaml:"mailgun"`
+	Postmark PostmarkProviderConfig  `yaml:"postmark"`
+	Resend   ResendProviderConfig    `yaml:"resend"`
+	Noop     NoopProviderConfig      `yaml:"noop"`
+}
+
+// RateLimitConfig caps how many recipients are sent to with one token bucket
+// per window, each To, Cc and Bcc recipient taking a token: a full bucket
+// allows a burst of the whole limit, which then refills evenly over the
+// window. 0 leaves a window unlimited.
+type RateLimitConfig struct {
+	PerSecond int `yaml:"perSecond"`
+	PerMinute int `yaml:"perMinute"`
+	PerDay    int `yaml:"perDay"`
+}
+
+// validate checks the limits at path.
+func (r RateLimitConfig) validate(path string) []error {
+	if r.PerSecond < 0 || r.PerMinute < 0 || r.PerDay < 0 {
+		return []error{fmt.Errorf("%s limits must not be negative", path)}
+	}
+	return nil
+}
+
+// ClientSMTP is the client of mail received by the SMTP server.
+const ClientSMTP = "smtp"
+
+// RouteConfig sends the recipients it matches through its own providers,
+// first to last like ProviderConfig.Order.
+type RouteConfig struct {
+	Name      string           `yaml:"name"`
+	Match     RouteMatchConfig `yaml:"match"`
+	Providers []string         `yaml:"providers"`
+}
+
+// RouteMatchConfig lists the conditions of a route. A recipient matches when
+// every condition that is set matches; within a condition any entry may match.
+type RouteMatchConfig struct {
+	// RecipientDomains match the domain of each recipient, e.g. "corp.example.com".
+	RecipientDomains []string `yaml:"recipientDomains"`
+	// Senders match the sender address, or its domain when written as "@example.com".
+	Senders []string `yaml:"senders"`
+	// Tags match any of the tags of the mail.
+	Tags []string `yaml:"tags"`
+	// Clients match the API client that submitted the mail, sent in the
+	// X-Client-ID header; mail received over SMTP has client ClientSMTP.
+	Clients []string `yaml:"clients"`
+}
+
+// isEmpty reports whether no condition is set.
+func (m RouteMatchConfig) isEmpty() bool {
+	return len(m.RecipientDomains) == 0 && len(m.Senders) == 0 && len(m.Tags) == 0 && len(m.Clients) == 0
+}
+
+// HTTPClientConfig configures how an HTTP provider API is reached.
+type HTTPClientConfig struct {
+	// BaseURL overrides the provider's public API, e.g. for a regional
+	// endpoint or a local stand-in. Empty uses the provider's default.
+	BaseURL string `yaml:"baseURL"`
+	// Timeout bounds a single request including reading the response;
+	// 0 uses DefaultProviderHTTPTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// ProxyURL routes requests through an HTTP(S) proxy. Empty uses the
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string `yaml:"proxyURL"`
+	// CAFile optionally holds PEM certificates trusted in addition to the system roots.
+	CAFile string `yaml:"caFile"`
 }
 
 // MailjetProviderConfig holds Mailjet settings.
 // Credentials are resolved from the file paths at load time.
 type MailjetProviderConfig struct {
-	Enabled          bool   `yaml:"enabled"`
-	APIKeyPublicFile string `yaml:"apiKeyPublicFile"`
+	Enabled           bool   `yaml:"enabled"`
+	APIKeyPublicFile  string `yaml:"apiKeyPublicFile"`
+	APIKeyPrivateFile string `yaml:"apiKeyPrivateFile"`
+	APIKeyPublic      string `yaml:"-"` // resolved at load time
+	APIKeyPrivate     string `yaml:"-"` // resolved at load time
+	// Accounts replace the API keys above with several Mailjet accounts that
+	// share the load by weight. They share the HTTP settings.
+	Accounts []MailjetAccountConfig `yaml:"accounts"`
+
+	HTTPClientConfig `yaml:",inline"`
+}
+
+// MailjetAccountConfig holds the credentials of one of several Mailjet accounts.
+type MailjetAccountConfig struct {
+	Name string `yaml:"name"`
+	// Weight is the account's share of the mail relative to the other accounts; defaults to 1.
+	Weight            int    `yaml:"weight"`
+	APIKeyPublicFile  string `yaml:"apiKeyPublicFile"`
 	APIKeyPrivateFile string `yaml:"apiKeyPrivateFile"`
-	APIKeyPublic     string `yaml:"-"` // resolved at load time
-	APIKeyPrivate    string `yaml:"-"` // resolved at load time
+	APIKeyPublic      string `yaml:"-"` // resolved at load time
+	APIKeyPrivate     string `yaml:"-"` // resolved at load time
+	// RateLimit caps how many recipients this account sends to.
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
 }
 
 // SendGridProviderConfig holds SendGrid settings.
@@ -77,6 +389,108 @@ type SendGridProviderConfig struct {
 	Enabled    bool   `yaml:"enabled"`
 	APIKeyFile string `yaml:"apiKeyFile"`
 	APIKey     string `yaml:"-"` // resolved at load time
+	// Accounts replace the API key above with several SendGrid accounts or
+	// subaccounts that share the load by weight. They share the HTTP settings.
+	Accounts []SendGridAccountConfig `yaml:"accounts"`
+
+	HTTPClientConfig `yaml:",inline"`
+}
+
+// SendGridAccountConfig holds the credentials of one of several SendGrid accounts.
+type SendGridAccountConfig struct {
+	Name string `yaml:"name"`
+	// Weight is the account's share of the mail relative to the other accounts; defaults to 1.
+	Weight     int    `yaml:"weight"`
+	APIKeyFile string `yaml:"apiKeyFile"`
+	APIKey     string `yaml:"-"` // resolved at load time
+	// RateLimit caps how many recipients this account sends to.
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+}
+
+// SMTPRelayProviderConfig holds the settings of an outbound SMTP relay such
+// as Postfix or an ISP smarthost. Password is resolved from PasswordFile at
+// load time when Username is set.
+type SMTPRelayProviderConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Host    string `yaml:"host"`
+	Port    int    `yaml:"port"`
+	// TLS is one of starttls (default), implicit or none.
+	TLS string `yaml:"tls"`
+	// CAFile optionally holds PEM certificates trusted in addition to the system roots.
+	CAFile       string `yaml:"caFile"`
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"passwordFile"`
+	Password     string `yaml:"-"` // resolved at load time
+	// AuthMechanism is plain or login; empty picks what the server offers, preferring plain.
+	AuthMechanism string `yaml:"authMechanism"`
+	// HeloName is sent in EHLO; defaults to the sender address domain.
+	HeloName       string        `yaml:"heloName"`
+	MaxConnections int           `yaml:"maxConnections"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+// SESProviderConfig holds Amazon SES settings.
+// Credentials are resolved from the file paths at load time.
+type SESProviderConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Region  string `yaml:"region"`
+	// Endpoint overrides the regional SES endpoint, e.g. for a VPC endpoint or a local stand-in.
+	Endpoint            string `yaml:"endpoint"`
+	AccessKeyIDFile     string `yaml:"accessKeyIdFile"`
+	SecretAccessKeyFile string `yaml:"secretAccessKeyFile"`
+	// SessionTokenFile is only needed for temporary credentials.
+	SessionTokenFile string `yaml:"sessionTokenFile"`
+	// ConfigurationSet is the optional SES configuration set applied to every mail.
+	ConfigurationSet string `yaml:"configurationSet"`
+	AccessKeyID      string `yaml:"-"` // resolved at load time
+	SecretAccessKey  string `yaml:"-"` // resolved at load time
+	SessionToken     string `yaml:"-"` // resolved at load time
+	// Timeout, ProxyURL and CAFile configure the HTTP client like those of HTTPClientConfig.
+	Timeout  time.Duration `yaml:"timeout"`
+	ProxyURL string        `yaml:"proxyURL"`
+	CAFile   string        `yaml:"caFile"`
+}
+
+// HTTPClientConfig returns the HTTP client settings of SES with Endpoint as base URL.
+func (s SESProviderConfig) HTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{BaseURL: s.Endpoint, Timeout: s.Timeout, ProxyURL: s.ProxyURL, CAFile: s.CAFile}
+}
+
+// MailgunProviderConfig holds Mailgun settings.
+// Credentials are resolved from the file path at load time.
+type MailgunProviderConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Domain is the sending domain configured in Mailgun.
+	Domain string `yaml:"domain"`
+	// Region is us (default) or eu.
+	Region     string `yaml:"region"`
+	APIKeyFile string `yaml:"apiKeyFile"`
+	APIKey     string `yaml:"-"` // resolved at load time
+
+	// HTTPClientConfig.BaseURL overrides the API host of the region.
+	HTTPClientConfig `yaml:",inline"`
+}
+
+// PostmarkProviderConfig holds Postmark settings.
+// Credentials are resolved from the file path at load time.
+type PostmarkProviderConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	ServerTokenFile string `yaml:"serverTokenFile"`
+	// MessageStream defaults to the transactional stream "outbound".
+	MessageStream string `yaml:"messageStream"`
+	ServerToken   string `yaml:"-"` // resolved at load time
+
+	HTTPClientConfig `yaml:",inline"`
+}
+
+// ResendProviderConfig holds Resend settings.
+// Credentials are resolved from the file path at load time.
+type ResendProviderConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	APIKeyFile string `yaml:"apiKeyFile"`
+	APIKey     string `yaml:"-"` // resolved at load time
+
+	HTTPClientConfig `yaml:",inline"`
 }
 
 // NoopProviderConfig enables the no-op provider for development.
@@ -84,6 +498,47 @@ type NoopProviderConfig struct {
 	Enabled bool `yaml:"enabled"`
 }
 
+// isEnabled reports whether the named provider is enabled and whether the name is known at all.
+func (p ProviderConfig) isEnabled(name string) (enabled bool, known bool) {
+	switch name {
+	case ProviderMailjet:
+		return p.Mailjet.Enabled, true
+	case ProviderSendGrid:
+		return p.SendGrid.Enabled, true
+	case ProviderSMTP:
+		return p.SMTP.Enabled, true
+	case ProviderSES:
+		return p.SES.Enabled, true
+	case ProviderMailgun:
+		return p.Mailgun.Enabled, true
+	case ProviderPostmark:
+		return p.Postmark.Enabled, true
+	case ProviderResend:
+		return p.Resend.Enabled, true
+	case ProviderNoop:
+		return p.Noop.Enabled, true
+	default:
+		return false, false
+	}
+}
+
+// defaultOrder returns the enabled providers in their historical priority.
+func (p ProviderConfig) defaultOrder() []string {
+	var order []string
+	for _, name := range []string{
+		ProviderMailjet, ProviderSendGrid, ProviderSMTP, ProviderSES,
+		ProviderMailgun, ProviderPostmark, ProviderResend,
+	} {
+		if enabled, _ := p.isEnabled(name); enabled {
+			order = append(order, name)
+		}
+	}
+	if len(order) == 0 && p.Noop.Enabled {
+		order = append(order, ProviderNoop)
+	}
+	return order
+}
+
 // Load reads the YAML file at path, resolves all secret files, and validates the result.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path) // #nosec G304 -- config path is operator-supplied (CONFIG_PATH), not user input
@@ -96,6 +551,8 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("parsing config file %q: %w", path, err)
 	}
 
+	cfg.applyDefaults()
+
 	if err := cfg.resolveSecrets(); err != nil {
 		return nil, err
 	}
@@ -107,6 +564,101 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// applyDefaults fills optional settings that were left empty in the YAML file.
+func (c *Config) applyDefaults() {
+	if c.Attachments.MaxTotalBytes == 0 {
+		c.Attachments.MaxTotalBytes = DefaultMaxAttachmentBytes
+	}
+	if c.Queue.Workers == 0 {
+		c.Queue.Workers = DefaultQueueWorkers
+	}
+	if c.Queue.MaxAttempts == 0 {
+		c.Queue.MaxAttempts = DefaultQueueMaxAttempts
+	}
+	if c.Queue.RetryBackoff == 0 {
+		c.Queue.RetryBackoff = DefaultQueueRetryBackoff
+	}
+	if c.Queue.PollInterval == 0 {
+		c.Queue.PollInterval = DefaultQueuePollInterval
+	}
+	if c.Messages.Retention == 0 {
+		c.Messages.Retention = DefaultMessageRetention
+	}
+	if c.Retry.MaxAttempts == 0 {
+		c.Retry.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if c.Retry.InitialBackoff == 0 {
+		c.Retry.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if c.Retry.MaxBackoff == 0 {
+		c.Retry.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if c.CircuitBreaker.FailureThreshold == 0 {
+		c.CircuitBreaker.FailureThreshold = DefaultCircuitBreakerFailureThreshold
+	}
+	if c.CircuitBreaker.OpenDuration == 0 {
+		c.CircuitBreaker.OpenDuration = DefaultCircuitBreakerOpenDuration
+	}
+	if c.CircuitBreaker.HalfOpenSuccesses == 0 {
+		c.CircuitBreaker.HalfOpenSuccesses = DefaultCircuitBreakerHalfOpenSuccesses
+	}
+	if c.Provider.Mailjet.Timeout == 0 {
+		c.Provider.Mailjet.Timeout = DefaultProviderHTTPTimeout
+	}
+	if c.Provider.SendGrid.Timeout == 0 {
+		c.Provider.SendGrid.Timeout = DefaultProviderHTTPTimeout
+	}
+	if c.Provider.Mailgun.Timeout == 0 {
+		c.Provider.Mailgun.Timeout = DefaultProviderHTTPTimeout
+	}
+	if c.Provider.Postmark.Timeout == 0 {
+		c.Provider.Postmark.Timeout = DefaultProviderHTTPTimeout
+	}
+	if c.Provider.Resend.Timeout == 0 {
+		c.Provider.Resend.Timeout = DefaultProviderHTTPTimeout
+	}
+	if c.Provider.RateLimitMaxWait == 0 {
+		c.Provider.RateLimitMaxWait = DefaultRateLimitMaxWait
+	}
+	if c.Provider.AccountCooldown == 0 {
+		c.Provider.AccountCooldown = DefaultAccountCooldown
+	}
+	for i := range c.Provider.Mailjet.Accounts {
+		if c.Provider.Mailjet.Accounts[i].Weight == 0 {
+			c.Provider.Mailjet.Accounts[i].Weight = 1
+		}
+	}
+	for i := range c.Provider.SendGrid.Accounts {
+		if c.Provider.SendGrid.Accounts[i].Weight == 0 {
+			c.Provider.SendGrid.Accounts[i].Weight = 1
+		}
+	}
+	if c.Provider.SMTP.TLS == "" {
+		c.Provider.SMTP.TLS = SMTPRelayTLSStartTLS
+	}
+	if c.Provider.SMTP.MaxConnections == 0 {
+		c.Provider.SMTP.MaxConnections = DefaultSMTPRelayMaxConnections
+	}
+	if c.Provider.SMTP.Timeout == 0 {
+		c.Provider.SMTP.Timeout = DefaultSMTPRelayTimeout
+	}
+	if c.Provider.SES.Endpoint == "" && c.Provider.SES.Region != "" {
+		c.Provider.SES.Endpoint = "https://email." + c.Provider.SES.Region + ".amazonaws.com"
+	}
+	if c.Provider.SES.Timeout == 0 {
+		c.Provider.SES.Timeout = DefaultProviderHTTPTimeout
+	}
+	if c.Provider.Mailgun.Region == "" {
+		c.Provider.Mailgun.Region = MailgunRegionUS
+	}
+	if c.Provider.Postmark.MessageStream == "" {
+		c.Provider.Postmark.MessageStream = DefaultPostmarkMessageStream
+	}
+	if len(c.Provider.Order) == 0 {
+		c.Provider.Order = c.Provider.defaultOrder()
+	}
+}
+
 // resolveSecrets reads all referenced secret files and populates the in-memory credential fields.
 func (c *Config) resolveSecrets() error {
 	if c.SMTP.Auth.Required {
@@ -117,7 +669,21 @@ func (c *Config) resolveSecrets() error {
 		c.SMTP.Auth.Password = pw
 	}
 
-	if c.Provider.Mailjet.Enabled {
+	if c.Provider.Mailjet.Enabled && len(c.Provider.Mailjet.Accounts) > 0 {
+		for i := range c.Provider.Mailjet.Accounts {
+			account := &c.Provider.Mailjet.Accounts[i]
+			pub, err := readSecretFile(account.APIKeyPublicFile)
+			if err != nil {
+				return fmt.Errorf("mailjet account %q apiKeyPublic: %w", account.Name, err)
+			}
+			priv, err := readSecretFile(account.APIKeyPrivateFile)
+			if err != nil {
+				return fmt.Errorf("mailjet account %q apiKeyPrivate: %w", account.Name, err)
+			}
+			account.APIKeyPublic = pub
+			account.APIKeyPrivate = priv
+		}
+	} else if c.Provider.Mailjet.Enabled {
 		pub, err := readSecretFile(c.Provider.Mailjet.APIKeyPublicFile)
 		if err != nil {
 			return fmt.Errorf("mailjet apiKeyPublic: %w", err)
@@ -130,7 +696,16 @@ func (c *Config) resolveSecrets() error {
 		c.Provider.Mailjet.APIKeyPrivate = priv
 	}
 
-	if c.Provider.SendGrid.Enabled {
+	if c.Provider.SendGrid.Enabled && len(c.Provider.SendGrid.Accounts) > 0 {
+		for i := range c.Provider.SendGrid.Accounts {
+			account := &c.Provider.SendGrid.Accounts[i]
+			key, err := readSecretFile(account.APIKeyFile)
+			if err != nil {
+				return fmt.Errorf("sendgrid account %q apiKey: %w", account.Name, err)
+			}
+			account.APIKey = key
+		}
+	} else if c.Provider.SendGrid.Enabled {
 		key, err := readSecretFile(c.Provider.SendGrid.APIKeyFile)
 		if err != nil {
 			return fmt.Errorf("sendgrid apiKey: %w", err)
@@ -138,6 +713,58 @@ func (c *Config) resolveSecrets() error {
 		c.Provider.SendGrid.APIKey = key
 	}
 
+	if c.Provider.SMTP.Enabled && c.Provider.SMTP.Username != "" {
+		pw, err := readSecretFile(c.Provider.SMTP.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("smtp relay password: %w", err)
+		}
+		c.Provider.SMTP.Password = pw
+	}
+
+	if c.Provider.SES.Enabled {
+		id, err := readSecretFile(c.Provider.SES.AccessKeyIDFile)
+		if err != nil {
+			return fmt.Errorf("ses accessKeyId: %w", err)
+		}
+		secret, err := readSecretFile(c.Provider.SES.SecretAccessKeyFile)
+		if err != nil {
+			return fmt.Errorf("ses secretAccessKey: %w", err)
+		}
+		c.Provider.SES.AccessKeyID = id
+		c.Provider.SES.SecretAccessKey = secret
+		if c.Provider.SES.SessionTokenFile != "" {
+			token, err := readSecretFile(c.Provider.SES.SessionTokenFile)
+			if err != nil {
+				return fmt.Errorf("ses sessionToken: %w", err)
+			}
+			c.Provider.SES.SessionToken = token
+		}
+	}
+
+	if c.Provider.Mailgun.Enabled {
+		key, err := readSecretFile(c.Provider.Mailgun.APIKeyFile)
+		if err != nil {
+			return fmt.Errorf("mailgun apiKey: %w", err)
+		}
+		c.Provider.Mailgun.APIKey = key
+	}
+
+	if c.Provider.Postmark.Enabled {
+		token, err := readSecretFile(c.Provider.Postmark.ServerTokenFile)
+		if err != nil {
+			return fmt.Errorf("postmark serverToken: %w", err)
+		}
+		c.Provider.Postmark.ServerToken = token
+	}
+
+	if c.Provider.Resend.Enabled {
+		key, err := readSecretFile(c.Provider.Resend.APIKeyFile)
+		if err != nil {
+			return fmt.Errorf("resend apiKey: %w", err)
+		}
+		c.Provider.Resend.APIKey = key
+	}
+
 	return nil
 }
 
@@ -161,6 +788,59 @@ func (c *Config) Validate() error {
 		errs = append(errs, errors.New("smtp.domain is required"))
 	}
 
+	if c.Attachments.MaxTotalBytes < 0 {
+		errs = append(errs, errors.New("attachments.maxTotalBytes must not be negative"))
+	}
+
+	if c.Queue.Enabled {
+		if c.Queue.Path == "" {
+			errs = append(errs, errors.New("queue.path is required when the queue is enabled"))
+		}
+		if c.Queue.Workers < 1 {
+			errs = append(errs, errors.New("queue.workers must be greater than 0"))
+		}
+		if c.Queue.MaxAttempts < 1 {
+			errs = append(errs, errors.New("queue.maxAttempts must be greater than 0"))
+		}
+		if c.Queue.RetryBackoff < 0 {
+			errs = append(errs, errors.New("queue.retryBackoff must not be negative"))
+		}
+		if c.Queue.PollInterval <= 0 {
+			errs = append(errs, errors.New("queue.pollInterval must be greater than 0"))
+		}
+	}
+
+	if c.Messages.Retention < 0 {
+		errs = append(errs, errors.New("messages.retention must not be negative"))
+	}
+
+	if c.Templates.Path != "" && c.Queue.Enabled && c.Templates.Path == c.Queue.Path {
+		errs = append(errs, errors.New("templates.path must differ from queue.path"))
+	}
+	if c.Markdown.LogoURL != "" && !isHTTPURL(c.Markdown.LogoURL) {
+		errs = append(errs, fmt.Errorf("markdown.logoUrl %q must be an absolute http or https URL", c.Markdown.LogoURL))
+	}
+
+	if c.Retry.MaxAttempts < 1 {
+		errs = append(errs, errors.New("retry.maxAttempts must be greater than 0"))
+	}
+	if c.Retry.InitialBackoff < 0 || c.Retry.MaxBackoff < 0 {
+		errs = append(errs, errors.New("retry backoffs must not be negative"))
+	}
+	if c.Retry.InitialBackoff > c.Retry.MaxBackoff {
+		errs = append(errs, errors.New("retry.initialBackoff must not exceed retry.maxBackoff"))
+	}
+
+	if c.CircuitBreaker.FailureThreshold < 0 {
+		errs = append(errs, errors.New("circuitBreaker.failureThreshold must not be negative"))
+	}
+	if c.CircuitBreaker.OpenDuration < 0 {
+		errs = append(errs, errors.New("circuitBreaker.openDuration must not be negative"))
+	}
+	if c.CircuitBreaker.HalfOpenSuccesses < 0 {
+		errs = append(errs, errors.New("circuitBreaker.halfOpenSuccesses must not be negative"))
+	}
+
 	if c.SMTP.Auth.Required {
 		if c.SMTP.Auth.Username == "" {
 			errs = append(errs, errors.New("smtp.auth.username is required when auth is required"))
@@ -180,41 +860,256 @@ func (c *Config) Validate() error {
 	}
 
 	if c.Provider.Mailjet.Enabled {
-		if c.Provider.Mailjet.APIKeyPublic == "" {
-			errs = append(errs, errors.New("mailjet apiKeyPublic resolved to empty"))
+		errs = append(errs, c.Provider.Mailjet.validateAccounts()...)
+		errs = append(errs, c.Provider.Mailjet.HTTPClientConfig.validate("provider.mailjet")...)
+	}
+
+	if c.Provider.SendGrid.Enabled {
+		errs = append(errs, c.Provider.SendGrid.validateAccounts()...)
+		errs = append(errs, c.Provider.SendGrid.HTTPClientConfig.validate("provider.sendgrid")...)
+	}
+
+	if c.Provider.SMTP.Enabled {
+		errs = append(errs, c.Provider.SMTP.validate()...)
+	}
+
+	if c.Provider.SES.Enabled {
+		if c.Provider.SES.Region == "" {
+			errs = append(errs, errors.New("provider.ses.region is required"))
 		}
-		if c.Provider.Mailjet.APIKeyPrivate == "" {
-			errs = append(errs, errors.New("mailjet apiKeyPrivate resolved to empty"))
+		if c.Provider.SES.AccessKeyID == "" {
+			errs = append(errs, errors.New("ses accessKeyId resolved to empty"))
+		}
+		if c.Provider.SES.SecretAccessKey == "" {
+			errs = append(errs, errors.New("ses secretAccessKey resolved to empty"))
+		}
+		if c.Provider.SES.Endpoint != "" && !isHTTPURL(c.Provider.SES.Endpoint) {
+			errs = append(errs, fmt.Errorf("provider.ses.endpoint must be an absolute http or https URL, got %q", c.Provider.SES.Endpoint))
+		}
+		// the endpoint is the base URL and checked above
+		errs = append(errs, HTTPClientConfig{Timeout: c.Provider.SES.Timeout, ProxyURL: c.Provider.SES.ProxyURL}.validate("provider.ses")...)
+	}
+
+	if c.Provider.Mailgun.Enabled {
+		if c.Provider.Mailgun.Domain == "" {
+			errs = append(errs, errors.New("provider.mailgun.domain is required"))
+		}
+		if c.Provider.Mailgun.Region != MailgunRegionUS && c.Provider.Mailgun.Region != MailgunRegionEU {
+			errs = append(errs, fmt.Errorf("provider.mailgun.region must be us or eu, got %q", c.Provider.Mailgun.Region))
 		}
+		if c.Provider.Mailgun.APIKey == "" {
+			errs = append(errs, errors.New("mailgun apiKey resolved to empty"))
+		}
+		errs = append(errs, c.Provider.Mailgun.HTTPClientConfig.validate("provider.mailgun")...)
 	}
 
-	if c.Provider.SendGrid.Enabled && c.Provider.SendGrid.APIKey == "" {
-		errs = append(errs, errors.New("sendgrid apiKey resolved to empty"))
+	if c.Provider.Postmark.Enabled {
+		if c.Provider.Postmark.ServerToken == "" {
+			errs = append(errs, errors.New("postmark serverToken resolved to empty"))
+		}
+		errs = append(errs, c.Provider.Postmark.HTTPClientConfig.validate("provider.postmark")...)
 	}
 
-	warnMultipleProviders(c)
+	if c.Provider.Resend.Enabled {
+		if c.Provider.Resend.APIKey == "" {
+			errs = append(errs, errors.New("resend apiKey resolved to empty"))
+		}
+		errs = append(errs, c.Provider.Resend.HTTPClientConfig.validate("provider.resend")...)
+	}
+
+	errs = append(errs, c.Provider.validateProviderList("provider.order", c.Provider.Order)...)
+
+	routeNames := make(map[string]bool)
+	for i, route := range c.Provider.Routes {
+		path := fmt.Sprintf("provider.routes[%d]", i)
+		switch {
+		case route.Name == "":
+			errs = append(errs, fmt.Errorf("%s.name is required", path))
+		case routeNames[route.Name]:
+			errs = append(errs, fmt.Errorf("%s: route %q is defined twice", path, route.Name))
+		}
+		routeNames[route.Name] = true
+		if route.Match.isEmpty() {
+			errs = append(errs, fmt.Errorf("%s.match must set at least one condition", path))
+		}
+		if len(route.Providers) == 0 {
+			errs = append(errs, fmt.Errorf("%s.providers must list at least one provider", path))
+		}
+		errs = append(errs, c.Provider.validateProviderList(path+".providers", route.Providers)...)
+	}
+
+	if c.Provider.AccountCooldown < 0 {
+		errs = append(errs, errors.New("provider.accountCooldown must not be negative"))
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Provider.RateLimits)) {
+		limit := c.Provider.RateLimits[name]
+		if _, known := c.Provider.isEnabled(name); !known {
+			errs = append(errs, fmt.Errorf("provider.rateLimits: unknown provider %q", name))
+		}
+		errs = append(errs, limit.validate("provider.rateLimits."+name)...)
+	}
+	if c.Provider.RateLimitMaxWait < 0 {
+		errs = append(errs, errors.New("provider.rateLimitMaxWait must not be negative"))
+	}
 
-	if !c.Provider.Mailjet.Enabled && !c.Provider.SendGrid.Enabled && !c.Provider.Noop.Enabled {
+	if len(c.Provider.Order) == 0 {
 		slog.Warn("no mail provider is enabled — mail will not be sent")
 	}
 
 	return errors.Join(errs...)
 }
 
-func warnMultipleProviders(c *Config) {
-	enabled := 0
-	if c.Provider.Mailjet.Enabled {
-		enabled++
+// validateProviderList checks that every listed provider exists, is enabled and is listed once.
+func (p ProviderConfig) validateProviderList(path string, names []string) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, name := range names {
+		enabled, known := p.isEnabled(name)
+		switch {
+		case !known:
+			errs = append(errs, fmt.Errorf("%s: unknown provider %q", path, name))
+		case !enabled:
+			errs = append(errs, fmt.Errorf("%s: provider %q is not enabled", path, name))
+		case seen[name]:
+			errs = append(errs, fmt.Errorf("%s: provider %q is listed twice", path, name))
+		}
+		seen[name] = true
 	}
-	if c.Provider.SendGrid.Enabled {
-		enabled++
+	return errs
+}
+
+// validateAccounts checks the credentials of the single account or of every listed account.
+func (m MailjetProviderConfig) validateAccounts() []error {
+	if len(m.Accounts) == 0 {
+		var errs []error
+		if m.APIKeyPublic == "" {
+			errs = append(errs, errors.New("mailjet apiKeyPublic resolved to empty"))
+		}
+		if m.APIKeyPrivate == "" {
+			errs = append(errs, errors.New("mailjet apiKeyPrivate resolved to empty"))
+		}
+		return errs
+	}
+
+	var errs []error
+	if m.APIKeyPublicFile != "" || m.APIKeyPrivateFile != "" {
+		errs = append(errs, errors.New("provider.mailjet: set either the API key files or accounts, not both"))
+	}
+	names := make([]string, len(m.Accounts))
+	for i, account := range m.Accounts {
+		path := fmt.Sprintf("provider.mailjet.accounts[%d]", i)
+		names[i] = account.Name
+		if account.APIKeyPublic == "" {
+			errs = append(errs, fmt.Errorf("%s apiKeyPublic resolved to empty", path))
+		}
+		if account.APIKeyPrivate == "" {
+			errs = append(errs, fmt.Errorf("%s apiKeyPrivate resolved to empty", path))
+		}
+		if account.Weight < 1 {
+			errs = append(errs, fmt.Errorf("%s.weight must be greater than 0", path))
+		}
+		errs = append(errs, account.RateLimit.validate(path+".rateLimit")...)
+	}
+	return append(errs, validateAccountNames("provider.mailjet.accounts", names)...)
+}
+
+// validateAccounts checks the credentials of the single account or of every listed account.
+func (s SendGridProviderConfig) validateAccounts() []error {
+	if len(s.Accounts) == 0 {
+		if s.APIKey == "" {
+			return []error{errors.New("sendgrid apiKey resolved to empty")}
+		}
+		return nil
+	}
+
+	var errs []error
+	if s.APIKeyFile != "" {
+		errs = append(errs, errors.New("provider.sendgrid: set either apiKeyFile or accounts, not both"))
+	}
+	names := make([]string, len(s.Accounts))
+	for i, account := range s.Accounts {
+		path := fmt.Sprintf("provider.sendgrid.accounts[%d]", i)
+		names[i] = account.Name
+		if account.APIKey == "" {
+			errs = append(errs, fmt.Errorf("%s apiKey resolved to empty", path))
+		}
+		if account.Weight < 1 {
+			errs = append(errs, fmt.Errorf("%s.weight must be greater than 0", path))
+		}
+		errs = append(errs, account.RateLimit.validate(path+".rateLimit")...)
+	}
+	return append(errs, validateAccountNames("provider.sendgrid.accounts", names)...)
+}
+
+// validateAccountNames checks that every account at path has a name of its own.
+func validateAccountNames(path string, names []string) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, name := range names {
+		switch {
+		case name == "":
+			errs = append(errs, fmt.Errorf("%s[%d].name is required", path, i))
+		case seen[name]:
+			errs = append(errs, fmt.Errorf("%s[%d]: account %q is defined twice", path, i, name))
+		}
+		seen[name] = true
+	}
+	return errs
+}
+
+// validate checks the HTTP settings of the provider at path.
+func (h HTTPClientConfig) validate(path string) []error {
+	var errs []error
+	if h.BaseURL != "" && !isHTTPURL(h.BaseURL) {
+		errs = append(errs, fmt.Errorf("%s.baseURL must be an absolute http or https URL, got %q", path, h.BaseURL))
+	}
+	if h.ProxyURL != "" && !isHTTPURL(h.ProxyURL) {
+		errs = append(errs, fmt.Errorf("%s.proxyURL must be an absolute http or https URL, got %q", path, h.ProxyURL))
+	}
+	if h.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout must not be negative", path))
+	}
+	return errs
+}
+
+// isHTTPURL reports whether raw is an absolute http or https URL with a host.
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validate checks the settings of an enabled SMTP relay provider.
+func (s SMTPRelayProviderConfig) validate() []error {
+	var errs []error
+	if s.Host == "" {
+		errs = append(errs, errors.New("provider.smtp.host is required"))
+	}
+	if s.Port <= 0 {
+		errs = append(errs, errors.New("provider.smtp.port must be greater than 0"))
+	}
+	switch s.TLS {
+	case SMTPRelayTLSStartTLS, SMTPRelayTLSImplicit, SMTPRelayTLSNone:
+	default:
+		errs = append(errs, fmt.Errorf("provider.smtp.tls must be starttls, implicit or none, got %q", s.TLS))
+	}
+	switch s.AuthMechanism {
+	case "", "plain", "login":
+	default:
+		errs = append(errs, fmt.Errorf("provider.smtp.authMechanism must be plain or login, got %q", s.AuthMechanism))
+	}
+	if s.Username != "" && s.Password == "" {
+		errs = append(errs, errors.New("provider.smtp password resolved to empty — check passwordFile"))
 	}
-	if c.Provider.Noop.Enabled {
-		enabled++
+	if s.MaxConnections < 1 {
+		errs = append(errs, errors.New("provider.smtp.maxConnections must be greater than 0"))
 	}
-	if enabled > 1 {
-		slog.Warn("multiple providers enabled — only highest priority will be used (mailjet > sendgrid > noop)")
+	if s.Timeout <= 0 {
+		errs = append(errs, errors.New("provider.smtp.timeout must be greater than 0"))
 	}
+	return errs
 }
 
 // readSecretFile reads a single-line secret from a file, trimming whitespace.
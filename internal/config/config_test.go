@@ -3,8 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func writeFile(t *testing.T, dir, name, content string) string {
@@ -202,3 +205,996 @@ func TestReadSecretFile_EmptyPath(t *testing.T) {
 		t.Fatal("readSecretFile() expected error for empty path")
 	}
 }
+
+func TestLoad_DefaultAttachmentLimit(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := writeFile(t, dir, "config.yaml", validConfigYAML(false, "", "", "", ""))
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.Attachments.MaxTotalBytes != DefaultMaxAttachmentBytes {
+		t.Errorf("attachments.maxTotalBytes = %d, want %d", cfg.Attachments.MaxTotalBytes, DefaultMaxAttachmentBytes)
+	}
+}
+
+func TestValidate_NegativeAttachmentLimitRejected(t *testing.T) {
+	cfg := &Config{
+		Sender:      SenderConfig{Address: "a@b.com"},
+		HTTP:        HTTPConfig{Port: 8080},
+		SMTP:        SMTPConfig{Port: 587, Domain: "example.com"},
+		Attachments: AttachmentsConfig{MaxTotalBytes: -1},
+		Provider:    ProviderConfig{Noop: NoopProviderConfig{Enabled: true}},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("Validate() expected error for negative attachments.maxTotalBytes")
+	}
+}
+
+func TestSenderConfig_IsAllowed(t *testing.T) {
+	sender := SenderConfig{
+		Address:          "noreply@example.com",
+		AllowedAddresses: []string{"billing@partner.org"},
+		AllowedDomains:   []string{"example.com", "@Team.Example.NET"},
+	}
+
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{address: "noreply@example.com", want: true},
+		{address: "NoReply@Example.com", want: true},
+		{address: "Support <support@example.com>", want: true},
+		{address: "billing@partner.org", want: true},
+		{address: "sales@partner.org", want: false},
+		{address: "ops@team.example.net", want: true},
+		{address: "ceo@sub.example.com", want: false},
+		{address: "attacker@evil.com", want: false},
+		{address: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := sender.IsAllowed(tt.address); got != tt.want {
+				t.Errorf("IsAllowed(%q) = %v, want %v", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSenderConfig_IsAllowed_DefaultOnly(t *testing.T) {
+	sender := SenderConfig{Address: "noreply@example.com"}
+	if !sender.IsAllowed("noreply@example.com") {
+		t.Error("default sender address should always be allowed")
+	}
+	if sender.IsAllowed("other@example.com") {
+		t.Error("other addresses should be rejected without an allowlist")
+	}
+}
+
+func TestLoad_QueueSettings(t *testing.T) {
+	dir := t.TempDir()
+	content := validConfigYAML(false, "", "", "", "") + `queue:
+  enabled: true
+  path: "` + yamlPath(filepath.Join(dir, "outbox.db")) + `"
+  retryBackoff: "1m"
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if !cfg.Queue.Enabled {
+		t.Error("queue.enabled = false, want true")
+	}
+	if cfg.Queue.RetryBackoff != time.Minute {
+		t.Errorf("queue.retryBackoff = %v, want %v", cfg.Queue.RetryBackoff, time.Minute)
+	}
+	if cfg.Queue.Workers != DefaultQueueWorkers {
+		t.Errorf("queue.workers = %d, want %d", cfg.Queue.Workers, DefaultQueueWorkers)
+	}
+	if cfg.Queue.MaxAttempts != DefaultQueueMaxAttempts {
+		t.Errorf("queue.maxAttempts = %d, want %d", cfg.Queue.MaxAttempts, DefaultQueueMaxAttempts)
+	}
+	if cfg.Queue.PollInterval != DefaultQueuePollInterval {
+		t.Errorf("queue.pollInterval = %v, want %v", cfg.Queue.PollInterval, DefaultQueuePollInterval)
+	}
+	if cfg.Messages.Retention != DefaultMessageRetention {
+		t.Errorf("messages.retention = %v, want %v", cfg.Messages.Retention, DefaultMessageRetention)
+	}
+}
+
+func TestValidate_QueueWithoutPathRejected(t *testing.T) {
+	cfg := &Config{
+		Sender:   SenderConfig{Address: "a@b.com"},
+		HTTP:     HTTPConfig{Port: 8080},
+		SMTP:     SMTPConfig{Port: 587, Domain: "example.com"},
+		Queue:    QueueConfig{Enabled: true, Workers: 1, MaxAttempts: 1, PollInterval: time.Second},
+		Provider: ProviderConfig{Noop: NoopProviderConfig{Enabled: true}},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for missing queue.path")
+	}
+	if !strings.Contains(err.Error(), "queue.path") {
+		t.Errorf("Validate() error = %v, want mention of queue.path", err)
+	}
+}
+
+func TestValidate_TemplatesSharingQueuePathRejected(t *testing.T) {
+	cfg := &Config{
+		Sender:    SenderConfig{Address: "a@b.com"},
+		HTTP:      HTTPConfig{Port: 8080},
+		SMTP:      SMTPConfig{Port: 587, Domain: "example.com"},
+		Queue:     QueueConfig{Enabled: true, Path: "/data/outbox.db", Workers: 1, MaxAttempts: 1, PollInterval: time.Second},
+		Templates: TemplatesConfig{Path: "/data/outbox.db"},
+		Retry:     RetryConfig{MaxAttempts: 1},
+		Provider:  ProviderConfig{Noop: NoopProviderConfig{Enabled: true}},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "templates.path must differ from queue.path") {
+		t.Errorf("Validate() error = %v, want templates.path rejected", err)
+	}
+
+	cfg.Templates.Path = "/data/templates.db"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+}
+
+func TestValidate_MarkdownLogoURL(t *testing.T) {
+	cfg := &Config{
+		Sender:   SenderConfig{Address: "a@b.com"},
+		HTTP:     HTTPConfig{Port: 8080},
+		SMTP:     SMTPConfig{Port: 587, Domain: "example.com"},
+		Markdown: MarkdownConfig{LogoURL: "/logo.png"},
+		Retry:    RetryConfig{MaxAttempts: 1},
+		Provider: ProviderConfig{Noop: NoopProviderConfig{Enabled: true}},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "markdown.logoUrl") {
+		t.Errorf("Validate() error = %v, want markdown.logoUrl rejected", err)
+	}
+
+	cfg.Markdown.LogoURL = "https://example.com/logo.png"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+}
+
+func TestLoad_DefaultRetry(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := writeFile(t, dir, "config.yaml", validConfigYAML(false, "", "", "", ""))
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	want := RetryConfig{
+		MaxAttempts:    DefaultRetryMaxAttempts,
+		InitialBackoff: DefaultRetryInitialBackoff,
+		MaxBackoff:     DefaultRetryMaxBackoff,
+	}
+	if cfg.Retry != want {
+		t.Errorf("retry = %+v, want %+v", cfg.Retry, want)
+	}
+}
+
+func TestLoad_CircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    CircuitBreakerConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: CircuitBreakerConfig{
+				FailureThreshold:  DefaultCircuitBreakerFailureThreshold,
+				OpenDuration:      DefaultCircuitBreakerOpenDuration,
+				HalfOpenSuccesses: DefaultCircuitBreakerHalfOpenSuccesses,
+			},
+		},
+		{
+			name: "configured",
+			yaml: `circuitBreaker:
+  failureThreshold: 10
+  openDuration: "2m"
+  halfOpenSuccesses: 3
+`,
+			want: CircuitBreakerConfig{FailureThreshold: 10, OpenDuration: 2 * time.Minute, HalfOpenSuccesses: 3},
+		},
+		{
+			name: "negative threshold",
+			yaml: `circuitBreaker:
+  failureThreshold: -1
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := writeFile(t, dir, "config.yaml", validConfigYAML(false, "", "", "", "")+tt.yaml)
+
+			cfg, err := Load(cfgPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Load() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error: %v", err)
+			}
+			if cfg.CircuitBreaker != tt.want {
+				t.Errorf("circuitBreaker = %+v, want %+v", cfg.CircuitBreaker, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_InitialBackoffAboveMaxRejected(t *testing.T) {
+	dir := t.TempDir()
+	content := validConfigYAML(false, "", "", "", "") + `retry:
+  initialBackoff: "1m"
+  maxBackoff: "10s"
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	if _, err := Load(cfgPath); err == nil {
+		t.Fatal("Load() expected error for retry.initialBackoff above retry.maxBackoff")
+	}
+}
+
+func TestProviderConfig_DefaultOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider ProviderConfig
+		want     []string
+	}{
+		{
+			name:     "nothing enabled",
+			provider: ProviderConfig{},
+			want:     nil,
+		},
+		{
+			name:     "noop only",
+			provider: ProviderConfig{Noop: NoopProviderConfig{Enabled: true}},
+			want:     []string{ProviderNoop},
+		},
+		{
+			name: "mailjet before sendgrid",
+			provider: ProviderConfig{
+				Mailjet:  MailjetProviderConfig{Enabled: true},
+				SendGrid: SendGridProviderConfig{Enabled: true},
+			},
+			want: []string{ProviderMailjet, ProviderSendGrid},
+		},
+		{
+			name: "noop left out next to a real provider",
+			provider: ProviderConfig{
+				SendGrid: SendGridProviderConfig{Enabled: true},
+				Noop:     NoopProviderConfig{Enabled: true},
+			},
+			want: []string{ProviderSendGrid},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.provider.defaultOrder(); !slices.Equal(got, tt.want) {
+				t.Errorf("defaultOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_ProviderOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []string
+		wantErr string
+	}{
+		{name: "valid", order: []string{ProviderSendGrid, ProviderNoop}},
+		{name: "unknown provider", order: []string{"postfix"}, wantErr: `unknown provider "postfix"`},
+		{name: "disabled provider", order: []string{ProviderMailjet}, wantErr: `provider "mailjet" is not enabled`},
+		{name: "duplicate provider", order: []string{ProviderNoop, ProviderNoop}, wantErr: `provider "noop" is listed twice`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Sender: SenderConfig{Address: "a@b.com"},
+				HTTP:   HTTPConfig{Port: 8080},
+				SMTP:   SMTPConfig{Port: 587, Domain: "example.com"},
+				Retry:  RetryConfig{MaxAttempts: 1},
+				Provider: ProviderConfig{
+					Order:    tt.order,
+					SendGrid: SendGridProviderConfig{Enabled: true, APIKey: "key"},
+					Noop:     NoopProviderConfig{Enabled: true},
+				},
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_SMTPRelayProvider(t *testing.T) {
+	dir := t.TempDir()
+	pwFile := writeFile(t, dir, "relay-password", "relay-secret\n")
+	content := `sender:
+  address: "noreply@example.com"
+http:
+  port: 8080
+smtp:
+  port: 587
+  domain: "mail.example.com"
+provider:
+  smtp:
+    enabled: true
+    host: "smtp.example.com"
+    port: 587
+    username: "relay-user"
+    passwordFile: "` + yamlPath(pwFile) + `"
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	relay := cfg.Provider.SMTP
+	if relay.Password != "relay-secret" {
+		t.Errorf("provider.smtp password = %q, want %q", relay.Password, "relay-secret")
+	}
+	if relay.TLS != SMTPRelayTLSStartTLS {
+		t.Errorf("provider.smtp.tls = %q, want %q", relay.TLS, SMTPRelayTLSStartTLS)
+	}
+	if relay.MaxConnections != DefaultSMTPRelayMaxConnections || relay.Timeout != DefaultSMTPRelayTimeout {
+		t.Errorf("provider.smtp defaults = %d/%v", relay.MaxConnections, relay.Timeout)
+	}
+	if !slices.Equal(cfg.Provider.Order, []string{ProviderSMTP}) {
+		t.Errorf("provider.order = %v, want [smtp]", cfg.Provider.Order)
+	}
+}
+
+func TestValidate_SMTPRelayProvider(t *testing.T) {
+	valid := SMTPRelayProviderConfig{
+		Enabled:        true,
+		Host:           "smtp.example.com",
+		Port:           465,
+		TLS:            SMTPRelayTLSImplicit,
+		MaxConnections: 1,
+		Timeout:        time.Second,
+	}
+	tests := []struct {
+		name    string
+		modify  func(*SMTPRelayProviderConfig)
+		wantErr string
+	}{
+		{name: "valid", modify: func(*SMTPRelayProviderConfig) {}},
+		{name: "missing host", modify: func(s *SMTPRelayProviderConfig) { s.Host = "" }, wantErr: "provider.smtp.host"},
+		{name: "unknown tls mode", modify: func(s *SMTPRelayProviderConfig) { s.TLS = "ssl" }, wantErr: "provider.smtp.tls"},
+		{name: "unknown auth mechanism", modify: func(s *SMTPRelayProviderConfig) { s.AuthMechanism = "cram-md5" }, wantErr: "provider.smtp.authMechanism"},
+		{name: "username without password", modify: func(s *SMTPRelayProviderConfig) { s.Username = "user" }, wantErr: "provider.smtp password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay := valid
+			tt.modify(&relay)
+			cfg := &Config{
+				Sender:   SenderConfig{Address: "a@b.com"},
+				HTTP:     HTTPConfig{Port: 8080},
+				SMTP:     SMTPConfig{Port: 587, Domain: "example.com"},
+				Retry:    RetryConfig{MaxAttempts: 1},
+				Provider: ProviderConfig{Order: []string{ProviderSMTP}, SMTP: relay},
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_SESProvider(t *testing.T) {
+	dir := t.TempDir()
+	idFile := writeFile(t, dir, "accessKeyId", "key-id\n")
+	secretFile := writeFile(t, dir, "secretAccessKey", "secret\n")
+	content := `sender:
+  address: "noreply@example.com"
+http:
+  port: 8080
+smtp:
+  port: 587
+  domain: "mail.example.com"
+provider:
+  ses:
+    enabled: true
+    region: "eu-west-1"
+    accessKeyIdFile: "` + yamlPath(idFile) + `"
+    secretAccessKeyFile: "` + yamlPath(secretFile) + `"
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	ses := cfg.Provider.SES
+	if ses.AccessKeyID != "key-id" || ses.SecretAccessKey != "secret" {
+		t.Errorf("ses credentials = %q/%q, want key-id/secret", ses.AccessKeyID, ses.SecretAccessKey)
+	}
+	if ses.SessionToken != "" {
+		t.Errorf("ses session token = %q, want empty", ses.SessionToken)
+	}
+	if ses.Endpoint != "https://email.eu-west-1.amazonaws.com" {
+		t.Errorf("provider.ses.endpoint = %q, want the regional endpoint", ses.Endpoint)
+	}
+	want := HTTPClientConfig{BaseURL: "https://email.eu-west-1.amazonaws.com", Timeout: DefaultProviderHTTPTimeout}
+	if got := ses.HTTPClientConfig(); got != want {
+		t.Errorf("provider.ses http client = %+v, want %+v", got, want)
+	}
+	if !slices.Equal(cfg.Provider.Order, []string{ProviderSES}) {
+		t.Errorf("provider.order = %v, want [ses]", cfg.Provider.Order)
+	}
+}
+
+func TestValidate_SESWithoutRegionRejected(t *testing.T) {
+	cfg := &Config{
+		Sender: SenderConfig{Address: "a@b.com"},
+		HTTP:   HTTPConfig{Port: 8080},
+		SMTP:   SMTPConfig{Port: 587, Domain: "example.com"},
+		Retry:  RetryConfig{MaxAttempts: 1},
+		Provider: ProviderConfig{
+			Order: []string{ProviderSES},
+			SES:   SESProviderConfig{Enabled: true, AccessKeyID: "id", SecretAccessKey: "secret"},
+		},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "provider.ses.region") {
+		t.Errorf("Validate() error = %v, want mention of provider.ses.region", err)
+	}
+}
+
+func TestValidate_SESHTTPClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		ses     SESProviderConfig
+		wantErr string
+	}{
+		{name: "local endpoint", ses: SESProviderConfig{Endpoint: "http://localhost:4566", Timeout: time.Second}},
+		{name: "endpoint without scheme", ses: SESProviderConfig{Endpoint: "email.eu-west-1.amazonaws.com"}, wantErr: "provider.ses.endpoint"},
+		{name: "proxy without scheme", ses: SESProviderConfig{ProxyURL: "egress:3128"}, wantErr: "provider.ses.proxyURL"},
+		{name: "negative timeout", ses: SESProviderConfig{Timeout: -time.Second}, wantErr: "provider.ses.timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ses := tt.ses
+			ses.Enabled, ses.Region, ses.AccessKeyID, ses.SecretAccessKey = true, "eu-west-1", "id", "secret"
+			cfg := &Config{
+				Sender:   SenderConfig{Address: "a@b.com"},
+				HTTP:     HTTPConfig{Port: 8080},
+				SMTP:     SMTPConfig{Port: 587, Domain: "example.com"},
+				Retry:    RetryConfig{MaxAttempts: 1},
+				Provider: ProviderConfig{Order: []string{ProviderSES}, SES: ses},
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_MailgunPostmarkResend(t *testing.T) {
+	dir := t.TempDir()
+	mailgunKey := writeFile(t, dir, "mailgun", "mg-key")
+	postmarkToken := writeFile(t, dir, "postmark", "pm-token")
+	resendKey := writeFile(t, dir, "resend", "re-key")
+	content := `sender:
+  address: "noreply@example.com"
+http:
+  port: 8080
+smtp:
+  port: 587
+  domain: "mail.example.com"
+provider:
+  mailgun:
+    enabled: true
+    domain: "mg.example.com"
+    apiKeyFile: "` + yamlPath(mailgunKey) + `"
+  postmark:
+    enabled: true
+    serverTokenFile: "` + yamlPath(postmarkToken) + `"
+  resend:
+    enabled: true
+    apiKeyFile: "` + yamlPath(resendKey) + `"
+    baseURL: "http://localhost:8081"
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	p := cfg.Provider
+	if p.Mailgun.APIKey != "mg-key" || p.Postmark.ServerToken != "pm-token" || p.Resend.APIKey != "re-key" {
+		t.Errorf("secrets = %q/%q/%q", p.Mailgun.APIKey, p.Postmark.ServerToken, p.Resend.APIKey)
+	}
+	if p.Mailgun.Region != MailgunRegionUS {
+		t.Errorf("provider.mailgun.region = %q, want %q", p.Mailgun.Region, MailgunRegionUS)
+	}
+	if p.Postmark.MessageStream != DefaultPostmarkMessageStream {
+		t.Errorf("provider.postmark.messageStream = %q, want %q", p.Postmark.MessageStream, DefaultPostmarkMessageStream)
+	}
+	if p.Mailgun.Timeout != DefaultProviderHTTPTimeout || p.Postmark.Timeout != DefaultProviderHTTPTimeout || p.Resend.Timeout != DefaultProviderHTTPTimeout {
+		t.Errorf("provider timeouts = %v/%v/%v, want %v", p.Mailgun.Timeout, p.Postmark.Timeout, p.Resend.Timeout, DefaultProviderHTTPTimeout)
+	}
+	if p.Resend.BaseURL != "http://localhost:8081" {
+		t.Errorf("provider.resend.baseURL = %q, want %q", p.Resend.BaseURL, "http://localhost:8081")
+	}
+	if want := []string{ProviderMailgun, ProviderPostmark, ProviderResend}; !slices.Equal(p.Order, want) {
+		t.Errorf("provider.order = %v, want %v", p.Order, want)
+	}
+}
+
+func TestValidate_MailgunRegionRejected(t *testing.T) {
+	cfg := &Config{
+		Sender: SenderConfig{Address: "a@b.com"},
+		HTTP:   HTTPConfig{Port: 8080},
+		SMTP:   SMTPConfig{Port: 587, Domain: "example.com"},
+		Retry:  RetryConfig{MaxAttempts: 1},
+		Provider: ProviderConfig{
+			Order:   []string{ProviderMailgun},
+			Mailgun: MailgunProviderConfig{Enabled: true, Domain: "mg.example.com", Region: "asia", APIKey: "key"},
+		},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "provider.mailgun.region") {
+		t.Errorf("Validate() error = %v, want mention of provider.mailgun.region", err)
+	}
+}
+
+func TestLoad_ProviderHTTPClient(t *testing.T) {
+	dir := t.TempDir()
+	pubFile := writeFile(t, dir, "pub", "pub-key")
+	privFile := writeFile(t, dir, "priv", "priv-key")
+	keyFile := writeFile(t, dir, "sendgrid", "sg-key")
+	content := `sender:
+  address: "noreply@example.com"
+http:
+  port: 8080
+smtp:
+  port: 587
+  domain: "mail.example.com"
+provider:
+  mailjet:
+    enabled: true
+    apiKeyPublicFile: "` + yamlPath(pubFile) + `"
+    apiKeyPrivateFile: "` + yamlPath(privFile) + `"
+    baseURL: "https://api.eu.mailjet.com"
+    timeout: "5s"
+    proxyURL: "http://egress.internal:3128"
+    caFile: "/etc/ssl/egress-ca.pem"
+  sendgrid:
+    enabled: true
+    apiKeyFile: "` + yamlPath(keyFile) + `"
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	want := HTTPClientConfig{
+		BaseURL:  "https://api.eu.mailjet.com",
+		Timeout:  5 * time.Second,
+		ProxyURL: "http://egress.internal:3128",
+		CAFile:   "/etc/ssl/egress-ca.pem",
+	}
+	if cfg.Provider.Mailjet.HTTPClientConfig != want {
+		t.Errorf("provider.mailjet http client = %+v, want %+v", cfg.Provider.Mailjet.HTTPClientConfig, want)
+	}
+	if cfg.Provider.Mailjet.APIKeyPublic != "pub-key" {
+		t.Errorf("provider.mailjet apiKeyPublic = %q, want pub-key", cfg.Provider.Mailjet.APIKeyPublic)
+	}
+	if want := (HTTPClientConfig{Timeout: DefaultProviderHTTPTimeout}); cfg.Provider.SendGrid.HTTPClientConfig != want {
+		t.Errorf("provider.sendgrid http client = %+v, want %+v", cfg.Provider.SendGrid.HTTPClientConfig, want)
+	}
+}
+
+func TestValidate_ProviderHTTPClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		http    HTTPClientConfig
+		wantErr string
+	}{
+		{name: "defaults", http: HTTPClientConfig{}},
+		{name: "local base URL", http: HTTPClientConfig{BaseURL: "http://localhost:8081", Timeout: time.Second}},
+		{name: "relative base URL", http: HTTPClientConfig{BaseURL: "/v3"}, wantErr: "provider.sendgrid.baseURL"},
+		{name: "proxy without scheme", http: HTTPClientConfig{ProxyURL: "egress:3128"}, wantErr: "provider.sendgrid.proxyURL"},
+		{name: "negative timeout", http: HTTPClientConfig{Timeout: -time.Second}, wantErr: "provider.sendgrid.timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Sender: SenderConfig{Address: "a@b.com"},
+				HTTP:   HTTPConfig{Port: 8080},
+				SMTP:   SMTPConfig{Port: 587, Domain: "example.com"},
+				Retry:  RetryConfig{MaxAttempts: 1},
+				Provider: ProviderConfig{
+					Order:    []string{ProviderSendGrid},
+					SendGrid: SendGridProviderConfig{Enabled: true, APIKey: "key", HTTPClientConfig: tt.http},
+				},
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want mention of %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_ProviderRoutes(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writeFile(t, dir, "sendgrid", "sg-key")
+	content := `sender:
+  address: "noreply@example.com"
+http:
+  port: 8080
+smtp:
+  port: 587
+  domain: "mail.example.com"
+provider:
+  routes:
+    - name: "internal"
+      match:
+        recipientDomains: ["corp.example.com"]
+      providers: ["smtp"]
+    - name: "newsletter"
+      match:
+        senders: ["@news.example.com"]
+        tags: ["newsletter"]
+        clients: ["crm"]
+      providers: ["sendgrid"]
+  sendgrid:
+    enabled: true
+    apiKeyFile: "` + yamlPath(keyFile) + `"
+  smtp:
+    enabled: true
+    host: "relay.corp.example.com"
+    port: 25
+    tls: "none"
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	want := []RouteConfig{
+		{
+			Name:      "internal",
+			Match:     RouteMatchConfig{RecipientDomains: []string{"corp.example.com"}},
+			Providers: []string{ProviderSMTP},
+		},
+		{
+			Name: "newsletter",
+			Match: RouteMatchConfig{
+				Senders: []string{"@news.example.com"},
+				Tags:    []string{"newsletter"},
+				Clients: []string{"crm"},
+			},
+			Providers: []string{ProviderSendGrid},
+		},
+	}
+	if !reflect.DeepEqual(cfg.Provider.Routes, want) {
+		t.Errorf("provider.routes = %+v, want %+v", cfg.Provider.Routes, want)
+	}
+}
+
+func TestValidate_ProviderRoutes(t *testing.T) {
+	match := RouteMatchConfig{RecipientDomains: []string{"corp.example.com"}}
+	tests := []struct {
+		name    string
+		routes  []RouteConfig
+		wantErr string
+	}{
+		{name: "valid", routes: []RouteConfig{{Name: "internal", Match: match, Providers: []string{ProviderNoop}}}},
+		{name: "missing name", routes: []RouteConfig{{Match: match, Providers: []string{ProviderNoop}}}, wantErr: "provider.routes[0].name is required"},
+		{
+			name: "duplicate name",
+			routes: []RouteConfig{
+				{Name: "internal", Match: match, Providers: []string{ProviderNoop}},
+				{Name: "internal", Match: match, Providers: []string{ProviderSendGrid}},
+			},
+			wantErr: `route "internal" is defined twice`,
+		},
+		{name: "no condition", routes: []RouteConfig{{Name: "all", Providers: []string{ProviderNoop}}}, wantErr: "provider.routes[0].match must set at least one condition"},
+		{name: "no providers", routes: []RouteConfig{{Name: "internal", Match: match}}, wantErr: "provider.routes[0].providers must list at least one provider"},
+		{name: "disabled provider", routes: []RouteConfig{{Name: "internal", Match: match, Providers: []string{ProviderSMTP}}}, wantErr: `provider.routes[0].providers: provider "smtp" is not enabled`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Sender: SenderConfig{Address: "a@b.com"},
+				HTTP:   HTTPConfig{Port: 8080},
+				SMTP:   SMTPConfig{Port: 587, Domain: "example.com"},
+				Retry:  RetryConfig{MaxAttempts: 1},
+				Provider: ProviderConfig{
+					Order:    []string{ProviderSendGrid},
+					Routes:   tt.routes,
+					SendGrid: SendGridProviderConfig{Enabled: true, APIKey: "key"},
+					Noop:     NoopProviderConfig{Enabled: true},
+				},
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_ProviderAccounts(t *testing.T) {
+	dir := t.TempDir()
+	content := `sender:
+  address: "noreply@example.com"
+http:
+  port: 8080
+smtp:
+  port: 587
+  domain: "mail.example.com"
+provider:
+  accountCooldown: "1m"
+  mailjet:
+    enabled: true
+    accounts:
+      - name: "eu"
+        apiKeyPublicFile: "` + yamlPath(writeFile(t, dir, "eu-pub", "eu-pub-key")) + `"
+        apiKeyPrivateFile: "` + yamlPath(writeFile(t, dir, "eu-priv", "eu-priv-key")) + `"
+  sendgrid:
+    enabled: true
+    accounts:
+      - name: "transactional"
+        weight: 3
+        apiKeyFile: "` + yamlPath(writeFile(t, dir, "sg-transactional", "tx-key")) + `"
+      - name: "bulk"
+        apiKeyFile: "` + yamlPath(writeFile(t, dir, "sg-bulk", "bulk-key")) + `"
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.Provider.AccountCooldown != time.Minute {
+		t.Errorf("provider.accountCooldown = %v, want 1m", cfg.Provider.AccountCooldown)
+	}
+	mailjet := cfg.Provider.Mailjet.Accounts
+	if len(mailjet) != 1 || mailjet[0].APIKeyPublic != "eu-pub-key" || mailjet[0].APIKeyPrivate != "eu-priv-key" || mailjet[0].Weight != 1 {
+		t.Errorf("provider.mailjet.accounts = %+v", mailjet)
+	}
+	sendgrid := cfg.Provider.SendGrid.Accounts
+	if len(sendgrid) != 2 {
+		t.Fatalf("provider.sendgrid.accounts = %+v, want 2 accounts", sendgrid)
+	}
+	if sendgrid[0].APIKey != "tx-key" || sendgrid[0].Weight != 3 || sendgrid[1].APIKey != "bulk-key" || sendgrid[1].Weight != 1 {
+		t.Errorf("provider.sendgrid.accounts = %+v", sendgrid)
+	}
+}
+
+func TestValidate_ProviderAccounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		sendgrid SendGridProviderConfig
+		wantErr  string
+	}{
+		{
+			name:     "single key",
+			sendgrid: SendGridProviderConfig{Enabled: true, APIKey: "key"},
+		},
+		{
+			name: "accounts",
+			sendgrid: SendGridProviderConfig{Enabled: true, Accounts: []SendGridAccountConfig{
+				{Name: "a", Weight: 1, APIKey: "key-a"},
+				{Name: "b", Weight: 2, APIKey: "key-b"},
+			}},
+		},
+		{
+			name: "key file and accounts",
+			sendgrid: SendGridProviderConfig{Enabled: true, APIKeyFile: "/run/secrets/sendgrid", Accounts: []SendGridAccountConfig{
+				{Name: "a", Weight: 1, APIKey: "key-a"},
+			}},
+			wantErr: "set either apiKeyFile or accounts",
+		},
+		{
+			name: "missing name",
+			sendgrid: SendGridProviderConfig{Enabled: true, Accounts: []SendGridAccountConfig{
+				{Weight: 1, APIKey: "key-a"},
+			}},
+			wantErr: "provider.sendgrid.accounts[0].name is required",
+		},
+		{
+			name: "duplicate name",
+			sendgrid: SendGridProviderConfig{Enabled: true, Accounts: []SendGridAccountConfig{
+				{Name: "a", Weight: 1, APIKey: "key-a"},
+				{Name: "a", Weight: 1, APIKey: "key-b"},
+			}},
+			wantErr: `account "a" is defined twice`,
+		},
+		{
+			name: "negative weight",
+			sendgrid: SendGridProviderConfig{Enabled: true, Accounts: []SendGridAccountConfig{
+				{Name: "a", Weight: -1, APIKey: "key-a"},
+			}},
+			wantErr: "provider.sendgrid.accounts[0].weight must be greater than 0",
+		},
+		{
+			name: "empty key",
+			sendgrid: SendGridProviderConfig{Enabled: true, Accounts: []SendGridAccountConfig{
+				{Name: "a", Weight: 1},
+			}},
+			wantErr: "provider.sendgrid.accounts[0] apiKey resolved to empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Sender: SenderConfig{Address: "a@b.com"},
+				HTTP:   HTTPConfig{Port: 8080},
+				SMTP:   SMTPConfig{Port: 587, Domain: "example.com"},
+				Retry:  RetryConfig{MaxAttempts: 1},
+				Provider: ProviderConfig{
+					Order:    []string{ProviderSendGrid},
+					SendGrid: tt.sendgrid,
+				},
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_ProviderRateLimits(t *testing.T) {
+	dir := t.TempDir()
+	content := `sender:
+  address: "noreply@example.com"
+http:
+  port: 8080
+smtp:
+  port: 587
+  domain: "mail.example.com"
+provider:
+  rateLimits:
+    mailjet:
+      perSecond: 10
+      perDay: 6000
+  sendgrid:
+    enabled: true
+    accounts:
+      - name: "free"
+        apiKeyFile: "` + yamlPath(writeFile(t, dir, "sg-free", "free-key")) + `"
+        rateLimit:
+          perDay: 100
+`
+	cfgPath := writeFile(t, dir, "config.yaml", content)
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if got := cfg.Provider.RateLimits[ProviderMailjet]; got != (RateLimitConfig{PerSecond: 10, PerDay: 6000}) {
+		t.Errorf("provider.rateLimits.mailjet = %+v", got)
+	}
+	if got := cfg.Provider.SendGrid.Accounts[0].RateLimit; got != (RateLimitConfig{PerDay: 100}) {
+		t.Errorf("provider.sendgrid.accounts[0].rateLimit = %+v", got)
+	}
+	if cfg.Provider.RateLimitMaxWait != DefaultRateLimitMaxWait {
+		t.Errorf("provider.rateLimitMaxWait = %v, want %v", cfg.Provider.RateLimitMaxWait, DefaultRateLimitMaxWait)
+	}
+}
+
+func TestValidate_ProviderRateLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider ProviderConfig
+		wantErr  string
+	}{
+		{
+			name:     "limits",
+			provider: ProviderConfig{RateLimits: map[string]RateLimitConfig{ProviderSendGrid: {PerMinute: 60}}},
+		},
+		{
+			name:     "unknown provider",
+			provider: ProviderConfig{RateLimits: map[string]RateLimitConfig{"postfix": {PerMinute: 60}}},
+			wantErr:  `provider.rateLimits: unknown provider "postfix"`,
+		},
+		{
+			name:     "negative limit",
+			provider: ProviderConfig{RateLimits: map[string]RateLimitConfig{ProviderSendGrid: {PerDay: -1}}},
+			wantErr:  "provider.rateLimits.sendgrid limits must not be negative",
+		},
+		{
+			name:     "negative max wait",
+			provider: ProviderConfig{RateLimitMaxWait: -time.Second},
+			wantErr:  "provider.rateLimitMaxWait must not be negative",
+		},
+		{
+			name: "negative account limit",
+			provider: ProviderConfig{SendGrid: SendGridProviderConfig{Enabled: true, Accounts: []SendGridAccountConfig{
+				{Name: "a", Weight: 1, APIKey: "key-a", RateLimit: RateLimitConfig{PerSecond: -1}},
+			}}},
+			wantErr: "provider.sendgrid.accounts[0].rateLimit limits must not be negative",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := tt.provider
+			provider.Order = []string{ProviderSendGrid}
+			if !provider.SendGrid.Enabled {
+				provider.SendGrid = SendGridProviderConfig{Enabled: true, APIKey: "key"}
+			}
+			cfg := &Config{
+				Sender:   SenderConfig{Address: "a@b.com"},
+				HTTP:     HTTPConfig{Port: 8080},
+				SMTP:     SMTPConfig{Port: 587, Domain: "example.com"},
+				Retry:    RetryConfig{MaxAttempts: 1},
+				Provider: provider,
+			}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrAttachmentsTooLarge is returned when the attachments of a mail exceed the configured size limit.
+var ErrAttachmentsTooLarge = errors.New("attachments exceed the maximum total size")
+
+// Attachment is a file sent along with the mail.
+// Content holds the base64 encoded file data. Setting ContentID marks the attachment
+// as inline so that it can be referenced from the HTML body via "cid:<ContentID>".
+type Attachment struct {
+	Filename    string `json:"filename" validate:"required"`
+	ContentType string `json:"contentType" validate:"required"`
+	Content     string `json:"content" validate:"required,base64"`
+	ContentID   string `json:"contentId,omitempty"`
+}
+
+// IsInline reports whether the attachment is embedded in the HTML body.
+func (attachment Attachment) IsInline() bool {
+	return attachment.ContentID != ""
+}
+
+// Size returns the decoded size of the attachment in bytes.
+func (attachment Attachment) Size() (int64, error) {
+	data, err := base64.StdEncoding.DecodeString(attachment.Content)
+	if err != nil {
+		return 0, fmt.Errorf("attachment %q is not valid base64: %w", attachment.Filename, err)
+	}
+	return int64(len(data)), nil
+}
+
+// ValidateAttachmentSize checks that the decoded size of all attachments does not exceed maxBytes.
+// A maxBytes of zero or less disables the check.
+func ValidateAttachmentSize(attachments []Attachment, maxBytes int64) error {
+	if maxBytes <= 0 {
+		return nil
+	}
+
+	var total int64
+	for _, attachment := range attachments {
+		size, err := attachment.Size()
+		if err != nil {
+			return err
+		}
+		total += size
+	}
+
+	if total > maxBytes {
+		return fmt.Errorf("%w: %d bytes > %d bytes", ErrAttachmentsTooLarge, total, maxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestValidateAttachmentSize(t *testing.T) {
+	tenBytes := Attachment{
+		Filename:    "a.txt",
+		ContentType: "text/plain",
+		Content:     base64.StdEncoding.EncodeToString([]byte("0123456789")),
+	}
+
+	tests := []struct {
+		name        string
+		attachments []Attachment
+		maxBytes    int64
+		wantErr     error
+	}{
+		{name: "no attachments", attachments: nil, maxBytes: 1},
+		{name: "within limit", attachments: []Attachment{tenBytes}, maxBytes: 10},
+		{name: "total over limit", attachments: []Attachment{tenBytes, tenBytes}, maxBytes: 15, wantErr: ErrAttachmentsTooLarge},
+		{name: "limit disabled", attachments: []Attachment{tenBytes, tenBytes}, maxBytes: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAttachmentSize(tt.attachments, tt.maxBytes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateAttachmentSize() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAttachmentSize_InvalidBase64(t *testing.T) {
+	attachments := []Attachment{{Filename: "broken.bin", ContentType: "application/octet-stream", Content: "not base64!"}}
+	if err := ValidateAttachmentSize(attachments, 1024); err == nil {
+		t.Error("ValidateAttachmentSize() expected error for invalid base64, got nil")
+	}
+}
+
+func TestAttachment_IsInline(t *testing.T) {
+	if (Attachment{ContentID: "logo"}).IsInline() != true {
+		t.Error("attachment with content id should be inline")
+	}
+	if (Attachment{}).IsInline() != false {
+		t.Error("attachment without content id should not be inline")
+	}
+}
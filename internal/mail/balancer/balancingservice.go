@@ -0,0 +1,202 @@
+// Package balancer spreads mail across several accounts of the same provider.
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// Account is one named account of a provider.
+type Account struct {
+	Name string
+	// Weight is the account's share of the mail relative to the other accounts.
+	Weight  int
+	Service mail.MailService
+}
+
+// account tracks the balancing and health state of an Account.
+type account struct {
+	Account
+	// current is the smooth weighted round-robin counter.
+	current int
+	// throttledAt is when the account last failed with a transient error.
+	throttledAt time.Time
+	// healthyAt is when the account may be used again after that error.
+	healthyAt time.Time
+}
+
+// BalancingService implements MailService by spreading mail across accounts
+// with smooth weighted round-robin. An account that fails with a transient
+// error, e.g. because it is rate limited, is skipped for the delay the
+// provider asked for, or the cooldown, and the mail goes to the next account.
+// When every account is cooling down, the least recently throttled one is
+// tried anyway. Permanent errors are returned right away.
+type BalancingService struct {
+	provider string
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	accounts []*account
+}
+
+// NewBalancingService creates a BalancingService for the accounts of provider.
+func NewBalancingService(provider string, cooldown time.Duration, accounts ...Account) *BalancingService {
+	service := &BalancingService{
+		provider: provider,
+		cooldown: cooldown,
+		now:      time.Now,
+	}
+	for _, a := range accounts {
+		service.accounts = append(service.accounts, &account{Account: a})
+	}
+	return service
+}
+
+func (service *BalancingService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	if len(service.accounts) == 0 {
+		return fmt.Errorf("%s: no account is configured", service.provider)
+	}
+
+	tried := make(map[*account]bool, len(service.accounts))
+	var errs []error
+	for len(tried) < len(service.accounts) {
+		a := service.next(tried)
+		tried[a] = true
+
+		err := a.Service.SendMail(ctx, attributes)
+		if err == nil {
+			service.markHealthy(a)
+			slog.Debug("balancer: mail sent", "provider", service.provider, "account", a.Name)
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("account %s: %w", a.Name, err))
+		if !mail.IsTransient(err) || ctx.Err() != nil {
+			break
+		}
+		service.markThrottled(a, err)
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// SendBatch sends the batch through one account. The mails that failed with a
+// transient error go on to the next account together.
+func (service *BalancingService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	results := make([]mail.BatchResult, len(mails))
+	if len(service.accounts) == 0 {
+		for i := range results {
+			results[i].Err = fmt.Errorf("%s: no account is configured", service.provider)
+		}
+		return results
+	}
+
+	pending := make([]int, len(mails))
+	for i := range pending {
+		pending[i] = i
+	}
+	tried := make(map[*account]bool, len(service.accounts))
+	errs := make([][]error, len(mails))
+	for len(pending) > 0 && len(tried) < len(service.accounts) {
+		a := service.next(tried)
+		tried[a] = true
+
+		mail.SendBatchSubset(ctx, a.Service, mails, pending, results)
+		var failed []int
+		var throttled error
+		for _, i := range pending {
+			err := results[i].Err
+			if err == nil {
+				continue
+			}
+			errs[i] = append(errs[i], fmt.Errorf("account %s: %w", a.Name, err))
+			if mail.IsTransient(err) && ctx.Err() == nil {
+				failed = append(failed, i)
+				throttled = err
+			}
+		}
+		if len(failed) < len(pending) {
+			service.markHealthy(a)
+			slog.Debug("balancer: batch sent", "provider", service.provider, "account", a.Name, "mails", len(pending)-len(failed))
+		}
+		if throttled != nil {
+			service.markThrottled(a, throttled)
+		}
+		pending = failed
+	}
+
+	for i := range results {
+		switch {
+		case results[i].Err == nil:
+		case len(errs[i]) == 1:
+			results[i].Err = errs[i][0]
+		default:
+			results[i].Err = errors.Join(errs[i]...)
+		}
+	}
+	return results
+}
+
+// next picks the account for the next attempt among those not yet tried.
+func (service *BalancingService) next(tried map[*account]bool) *account {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	now := service.now()
+	var chosen *account
+	total := 0
+	for _, a := range service.accounts {
+		if tried[a] || now.Before(a.healthyAt) {
+			continue
+		}
+		a.current += a.Weight
+		total += a.Weight
+		if chosen == nil || a.current > chosen.current {
+			chosen = a
+		}
+	}
+	if chosen != nil {
+		chosen.current -= total
+		return chosen
+	}
+
+	// every remaining account is cooling down
+	for _, a := range service.accounts {
+		if !tried[a] && (chosen == nil || a.throttledAt.Before(chosen.throttledAt)) {
+			chosen = a
+		}
+	}
+	return chosen
+}
+
+// markThrottled skips a for the delay requested with err, or at least the cooldown.
+func (service *BalancingService) markThrottled(a *account, err error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	delay := max(mail.RetryAfter(err), service.cooldown)
+	a.throttledAt = service.now()
+	a.healthyAt = a.throttledAt.Add(delay)
+	slog.Warn("balancer: account failed, skipping it", "provider", service.provider, "account", a.Name, "for", delay, "error", err)
+}
+
+// markHealthy makes a available again after it sent a mail.
+func (service *BalancingService) markHealthy(a *account) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if !a.healthyAt.IsZero() {
+		slog.Info("balancer: account recovered", "provider", service.provider, "account", a.Name)
+	}
+	a.healthyAt = time.Time{}
+}
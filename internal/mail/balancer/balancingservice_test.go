@@ -0,0 +1,170 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// stubService counts its calls and fails with the scripted errors in order.
+type stubService struct {
+	errs  []error
+	calls int
+}
+
+func (s *stubService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return s.errs[s.calls-1]
+	}
+	return nil
+}
+
+// newTestBalancer returns a BalancingService whose clock only moves when advanced.
+func newTestBalancer(accounts ...Account) (*BalancingService, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	service := NewBalancingService("sendgrid", time.Minute, accounts...)
+	service.now = func() time.Time { return now }
+	return service, &now
+}
+
+func TestBalancingService_Weights(t *testing.T) {
+	heavy := &stubService{}
+	light := &stubService{}
+	service, _ := newTestBalancer(
+		Account{Name: "heavy", Weight: 3, Service: heavy},
+		Account{Name: "light", Weight: 1, Service: light},
+	)
+
+	for range 8 {
+		if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+	}
+	if heavy.calls != 6 || light.calls != 2 {
+		t.Errorf("heavy sent %d mails, light %d, want 6 and 2", heavy.calls, light.calls)
+	}
+}
+
+func TestBalancingService_SkipsThrottledAccount(t *testing.T) {
+	throttled := mail.NewStatusError("sendgrid", 429, "120", errors.New("too many requests"))
+	first := &stubService{errs: []error{throttled}}
+	second := &stubService{}
+	service, now := newTestBalancer(
+		Account{Name: "first", Weight: 1, Service: first},
+		Account{Name: "second", Weight: 1, Service: second},
+	)
+
+	if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if first.calls != 1 || second.calls != 1 {
+		t.Fatalf("first called %d times, second %d, want the mail to move on to second", first.calls, second.calls)
+	}
+
+	// first is skipped for the two minutes SendGrid asked for, longer than the cooldown
+	*now = now.Add(90 * time.Second)
+	for range 3 {
+		if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+	}
+	if first.calls != 1 || second.calls != 4 {
+		t.Errorf("while throttled: first called %d times, second %d, want 1 and 4", first.calls, second.calls)
+	}
+
+	*now = now.Add(time.Minute)
+	for range 2 {
+		if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+	}
+	if first.calls != 2 {
+		t.Errorf("after the delay: first called %d times, want it back in rotation", first.calls)
+	}
+}
+
+func TestBalancingService_AllAccountsThrottled(t *testing.T) {
+	throttled := mail.NewStatusError("sendgrid", 429, "", errors.New("too many requests"))
+	first := &stubService{errs: []error{throttled}}
+	second := &stubService{errs: []error{throttled, throttled}}
+	service, now := newTestBalancer(
+		Account{Name: "first", Weight: 1, Service: first},
+		Account{Name: "second", Weight: 1, Service: second},
+	)
+
+	err := service.SendMail(context.Background(), mail.MailAttributes{})
+	if !mail.IsTransient(err) || !strings.Contains(err.Error(), "account first") || !strings.Contains(err.Error(), "account second") {
+		t.Fatalf("SendMail() error = %v, want a transient error naming both accounts", err)
+	}
+
+	// both are cooling down; first was throttled longer ago and is tried first
+	*now = now.Add(time.Second)
+	if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if first.calls != 2 || second.calls != 1 {
+		t.Errorf("first called %d times, second %d, want 2 and 1", first.calls, second.calls)
+	}
+}
+
+func TestBalancingService_PermanentErrorIsReturned(t *testing.T) {
+	permanent := mail.NewStatusError("sendgrid", 400, "", errors.New("invalid recipient"))
+	first := &stubService{errs: []error{permanent}}
+	second := &stubService{}
+	service, _ := newTestBalancer(
+		Account{Name: "first", Weight: 1, Service: first},
+		Account{Name: "second", Weight: 1, Service: second},
+	)
+
+	err := service.SendMail(context.Background(), mail.MailAttributes{})
+	if !errors.Is(err, permanent) {
+		t.Errorf("SendMail() error = %v, want %v", err, permanent)
+	}
+	if second.calls != 0 {
+		t.Errorf("second called %d times, want 0", second.calls)
+	}
+
+	// a permanent error says nothing about the account's health
+	if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if first.calls != 2 {
+		t.Errorf("first called %d times, want 2", first.calls)
+	}
+}
+
+func TestBalancingService_SendBatch(t *testing.T) {
+	throttled := mail.NewStatusError("sendgrid", 429, "", errors.New("too many requests"))
+	permanent := mail.NewStatusError("sendgrid", 400, "", errors.New("invalid recipient"))
+	first := &stubService{errs: []error{nil, throttled, permanent}}
+	second := &stubService{}
+	service, _ := newTestBalancer(
+		Account{Name: "first", Weight: 1, Service: first},
+		Account{Name: "second", Weight: 1, Service: second},
+	)
+
+	results := service.SendBatch(context.Background(), make([]mail.MailAttributes, 3))
+	if first.calls != 3 || second.calls != 1 {
+		t.Errorf("first called %d times, second %d, want only the throttled mail to move on", first.calls, second.calls)
+	}
+	if results[0].Err != nil || results[1].Err != nil {
+		t.Errorf("results = %+v, want the first two mails sent", results)
+	}
+	if err := results[2].Err; !errors.Is(err, permanent) || !strings.Contains(err.Error(), "account first") {
+		t.Errorf("results[2] error = %v, want the permanent error of account first", err)
+	}
+
+	// first is cooling down, so the next batch goes to second
+	_ = service.SendBatch(context.Background(), make([]mail.MailAttributes, 2))
+	if first.calls != 3 || second.calls != 3 {
+		t.Errorf("first called %d times, second %d, want 3 each", first.calls, second.calls)
+	}
+}
@@ -0,0 +1,46 @@
+package mail
+
+import "context"
+
+// BatchService is implemented by mail services that send several mails at
+// once, e.g. with a provider's native batch API.
+type BatchService interface {
+	// SendBatch sends mails and returns one result per mail, in the same order.
+	SendBatch(ctx context.Context, mails []MailAttributes) []BatchResult
+}
+
+// BatchResult is the outcome of one mail of a batch.
+type BatchResult struct {
+	// Delivery describes how the provider accepted the mail, if Err is nil.
+	Delivery Delivery
+	Err      error
+}
+
+// SendBatch sends mails through service in a single call if it implements
+// BatchService, and otherwise one after the other.
+func SendBatch(ctx context.Context, service MailService, mails []MailAttributes) []BatchResult {
+	if batch, ok := service.(BatchService); ok {
+		return batch.SendBatch(ctx, mails)
+	}
+
+	results := make([]BatchResult, len(mails))
+	for i, attributes := range mails {
+		mailCtx, delivery := NewDeliveryContext(ctx)
+		err := service.SendMail(mailCtx, attributes)
+		results[i] = BatchResult{Delivery: *delivery, Err: err}
+	}
+	return results
+}
+
+// SendBatchSubset sends the mails at indices through service and stores the
+// results at the same indices of results, e.g. to send again only the mails
+// of a batch that failed.
+func SendBatchSubset(ctx context.Context, service MailService, mails []MailAttributes, indices []int, results []BatchResult) {
+	subset := make([]MailAttributes, len(indices))
+	for i, index := range indices {
+		subset[i] = mails[index]
+	}
+	for i, result := range SendBatch(ctx, service, subset) {
+		results[indices[i]] = result
+	}
+}
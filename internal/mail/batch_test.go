@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordingService records the subjects it sends and fails those in fail.
+type recordingService struct {
+	sent []string
+	fail map[string]error
+}
+
+func (s *recordingService) SendMail(ctx context.Context, attributes MailAttributes) error {
+	s.sent = append(s.sent, attributes.Subject)
+	if err := s.fail[attributes.Subject]; err != nil {
+		return err
+	}
+	RecordDelivery(ctx, "recording", "id-"+attributes.Subject)
+	return nil
+}
+
+// batchingService records the batches it is given.
+type batchingService struct {
+	recordingService
+	batches [][]string
+}
+
+func (s *batchingService) SendBatch(_ context.Context, mails []MailAttributes) []BatchResult {
+	var subjects []string
+	results := make([]BatchResult, len(mails))
+	for i, attributes := range mails {
+		subjects = append(subjects, attributes.Subject)
+		results[i].Delivery = Delivery{Provider: "batching"}
+	}
+	s.batches = append(s.batches, subjects)
+	return results
+}
+
+func TestSendBatch_OneByOne(t *testing.T) {
+	errRejected := errors.New("rejected")
+	svc := &recordingService{fail: map[string]error{"b": errRejected}}
+
+	results := SendBatch(context.Background(), svc, []MailAttributes{{Subject: "a"}, {Subject: "b"}, {Subject: "c"}})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(svc.sent, want) {
+		t.Errorf("sent %v, want %v", svc.sent, want)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if results[0].Err != nil || results[0].Delivery.Provider != "recording" || !reflect.DeepEqual(results[0].Delivery.MessageIDs, []string{"id-a"}) {
+		t.Errorf("results[0] = %+v, want delivered", results[0])
+	}
+	if !errors.Is(results[1].Err, errRejected) || results[1].Delivery.Provider != "" {
+		t.Errorf("results[1] = %+v, want rejected", results[1])
+	}
+}
+
+func TestSendBatch_Native(t *testing.T) {
+	svc := &batchingService{}
+	mails := []MailAttributes{{Subject: "a"}, {Subject: "b"}, {Subject: "c"}}
+	results := make([]BatchResult, len(mails))
+
+	SendBatchSubset(context.Background(), svc, mails, []int{0, 2}, results)
+	if want := [][]string{{"a", "c"}}; !reflect.DeepEqual(svc.batches, want) || len(svc.sent) != 0 {
+		t.Errorf("batches = %v, want %v and no single mails", svc.batches, want)
+	}
+	if results[0].Delivery.Provider != "batching" || results[1].Delivery.Provider != "" || results[2].Delivery.Provider != "batching" {
+		t.Errorf("results = %+v, want the subset at its indices", results)
+	}
+}
@@ -0,0 +1,219 @@
+// Package breaker stops sending mail to a provider that keeps failing.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// State is the state of a circuit.
+type State string
+
+const (
+	// StateClosed lets every mail through.
+	StateClosed State = "closed"
+	// StateOpen rejects every mail until the open duration has passed.
+	StateOpen State = "open"
+	// StateHalfOpen lets a single probe mail through at a time.
+	StateHalfOpen State = "half-open"
+)
+
+// States lists all states, e.g. to report each of them as a metric.
+var States = []State{StateClosed, StateOpen, StateHalfOpen}
+
+// ErrOpen is returned, wrapped in a transient ProviderError, while the circuit is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Status is a snapshot of a circuit.
+type Status struct {
+	Provider string `json:"provider"`
+	State    State  `json:"state"`
+	// ConsecutiveFailures counts the mails that failed in a row with a transient error.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// Opens counts how often the circuit opened since the start.
+	Opens int `json:"opens"`
+	// OpenedAt is when the circuit last opened, nil if it never did.
+	OpenedAt *time.Time `json:"openedAt,omitempty"`
+}
+
+// BreakerService implements MailService by wrapping a provider in a circuit
+// breaker. After FailureThreshold mails in a row failed with a transient
+// error, the circuit opens and mails fail right away with a transient error,
+// so that a failover chain moves on to the next provider without waiting.
+// Once OpenDuration has passed, probe mails are let through one at a time;
+// HalfOpenSuccesses successful probes close the circuit and a failed probe
+// opens it again. Permanent errors show that the provider is reachable and
+// count as successes; mail held back by a rate limit does not count at all.
+// A batch counts like a single mail. A FailureThreshold below 1 disables the
+// breaker.
+type BreakerService struct {
+	provider string
+	service  mail.MailService
+	config   config.CircuitBreakerConfig
+	now      func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	successes int
+	probing   bool
+	opens     int
+	openedAt  time.Time
+}
+
+// NewBreakerService wraps the service of provider in a closed circuit breaker.
+func NewBreakerService(provider string, service mail.MailService, config config.CircuitBreakerConfig) *BreakerService {
+	return &BreakerService{
+		provider: provider,
+		service:  service,
+		config:   config,
+		now:      time.Now,
+		state:    StateClosed,
+	}
+}
+
+func (service *BreakerService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	probe, err := service.allow()
+	if err != nil {
+		return err
+	}
+	err = service.service.SendMail(ctx, attributes)
+	service.record(ctx, probe, err)
+	return err
+}
+
+// SendBatch admits the batch as a whole, like a single mail, and records a
+// single outcome for it, see batchOutcome.
+func (service *BreakerService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	probe, err := service.allow()
+	if err != nil {
+		results := make([]mail.BatchResult, len(mails))
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+	results := mail.SendBatch(ctx, service.service, mails)
+	service.record(ctx, probe, batchOutcome(results))
+	return results
+}
+
+// batchOutcome returns the error that failed the batch request as a whole:
+// the transient error shared by every mail of the batch. Mails that failed
+// with different errors failed on their own, which shows that the provider
+// is reachable, so the batch then counts as a success.
+func batchOutcome(results []mail.BatchResult) error {
+	if len(results) == 0 || results[0].Err == nil || !mail.IsTransient(results[0].Err) {
+		return nil
+	}
+	for _, result := range results[1:] {
+		if result.Err == nil || result.Err.Error() != results[0].Err.Error() {
+			return nil
+		}
+	}
+	return results[0].Err
+}
+
+// Status returns a snapshot of the circuit.
+func (service *BreakerService) Status() Status {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	status := Status{
+		Provider:            service.provider,
+		State:               service.state,
+		ConsecutiveFailures: service.failures,
+		Opens:               service.opens,
+	}
+	if !service.openedAt.IsZero() {
+		openedAt := service.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// allow returns an error if the mail must not be sent, and otherwise
+// admits it, reporting whether it is the probe of a half-open circuit.
+func (service *BreakerService) allow() (probe bool, err error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	switch service.state {
+	case StateOpen:
+		remaining := service.openedAt.Add(service.config.OpenDuration).Sub(service.now())
+		if remaining > 0 {
+			return false, service.openError(remaining)
+		}
+		service.state = StateHalfOpen
+		service.successes = 0
+		slog.Info("breaker: circuit half-open, probing provider", "provider", service.provider)
+		fallthrough
+	case StateHalfOpen:
+		if service.probing {
+			return false, service.openError(0)
+		}
+		service.probing = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// record updates the circuit with the outcome of an admitted mail. Only the
+// probe decides about a half-open circuit; mails admitted before the circuit
+// opened only update the failure count.
+func (service *BreakerService) record(ctx context.Context, probe bool, err error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if probe {
+		service.probing = false
+	}
+	switch {
+	case err == nil || !mail.IsTransient(err):
+		service.failures = 0
+		if probe {
+			service.successes++
+			if service.successes >= service.config.HalfOpenSuccesses {
+				service.state = StateClosed
+				slog.Info("breaker: circuit closed, provider recovered", "provider", service.provider)
+			}
+		}
+	case ctx.Err() != nil || errors.Is(err, mail.ErrRateLimited):
+		// the caller gave up or the mail never reached the provider, which says nothing about it
+	default:
+		service.failures++
+		tripped := service.state == StateClosed && service.config.FailureThreshold > 0 && service.failures >= service.config.FailureThreshold
+		if probe || tripped {
+			service.open(err)
+		}
+	}
+}
+
+// open opens the circuit after err.
+func (service *BreakerService) open(err error) {
+	service.state = StateOpen
+	service.opens++
+	service.openedAt = service.now()
+	slog.Warn("breaker: circuit opened, skipping provider",
+		"provider", service.provider,
+		"consecutive_failures", service.failures,
+		"open_duration", service.config.OpenDuration,
+		"error", err,
+	)
+}
+
+// openError is returned instead of sending while the circuit is open.
+func (service *BreakerService) openError(retryAfter time.Duration) error {
+	return &mail.ProviderError{
+		Provider:   service.provider,
+		Transient:  true,
+		RetryAfter: retryAfter,
+		Err:        ErrOpen,
+	}
+}
@@ -0,0 +1,251 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// stubService fails with err and counts its calls.
+type stubService struct {
+	err   error
+	calls int
+}
+
+func (s *stubService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	s.calls++
+	return s.err
+}
+
+var (
+	transient   = mail.NewStatusError("mailjet", 503, "", errors.New("unavailable"))
+	permanent   = mail.NewStatusError("mailjet", 400, "", errors.New("invalid recipient"))
+	rateLimited = &mail.ProviderError{Provider: "mailjet", Transient: true, Err: mail.ErrRateLimited}
+)
+
+// newTestBreaker returns a BreakerService whose clock only moves when advanced.
+func newTestBreaker(svc mail.MailService, halfOpenSuccesses int) (*BreakerService, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	service := NewBreakerService("mailjet", svc, config.CircuitBreakerConfig{
+		FailureThreshold:  3,
+		OpenDuration:      time.Minute,
+		HalfOpenSuccesses: halfOpenSuccesses,
+	})
+	service.now = func() time.Time { return now }
+	return service, &now
+}
+
+func send(service *BreakerService, times int) error {
+	var err error
+	for range times {
+		err = service.SendMail(context.Background(), mail.MailAttributes{})
+	}
+	return err
+}
+
+func TestBreakerService_Opens(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantState State
+	}{
+		{name: "transient errors below the threshold", errs: []error{transient, transient}, wantState: StateClosed},
+		{name: "transient errors reach the threshold", errs: []error{transient, transient, transient}, wantState: StateOpen},
+		{name: "success resets the count", errs: []error{transient, transient, nil, transient}, wantState: StateClosed},
+		{name: "permanent errors do not count", errs: []error{transient, transient, permanent, transient}, wantState: StateClosed},
+		{name: "rate limited mails do not count", errs: []error{transient, transient, rateLimited, transient}, wantState: StateOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubService{}
+			service, _ := newTestBreaker(svc, 1)
+			for _, err := range tt.errs {
+				svc.err = err
+				_ = service.SendMail(context.Background(), mail.MailAttributes{})
+			}
+			if got := service.Status().State; got != tt.wantState {
+				t.Errorf("state = %q, want %q", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestBreakerService_OpenSkipsProvider(t *testing.T) {
+	svc := &stubService{err: transient}
+	service, now := newTestBreaker(svc, 1)
+	_ = send(service, 3)
+
+	*now = now.Add(20 * time.Second)
+	err := service.SendMail(context.Background(), mail.MailAttributes{})
+	if !errors.Is(err, ErrOpen) || !mail.IsTransient(err) {
+		t.Fatalf("SendMail() error = %v, want a transient ErrOpen", err)
+	}
+	if got := mail.RetryAfter(err); got != 40*time.Second {
+		t.Errorf("RetryAfter = %v, want the remaining 40s", got)
+	}
+	if svc.calls != 3 {
+		t.Errorf("provider called %d times, want 3", svc.calls)
+	}
+
+	status := service.Status()
+	if status.Opens != 1 || status.ConsecutiveFailures != 3 || status.OpenedAt == nil || !status.OpenedAt.Equal(now.Add(-20*time.Second)) {
+		t.Errorf("Status() = %+v", status)
+	}
+}
+
+func TestBreakerService_HalfOpen(t *testing.T) {
+	tests := []struct {
+		name              string
+		halfOpenSuccesses int
+		probeErrs         []error
+		wantState         State
+		wantOpens         int
+	}{
+		{name: "probe succeeds", halfOpenSuccesses: 1, probeErrs: []error{nil}, wantState: StateClosed, wantOpens: 1},
+		{name: "probe fails", halfOpenSuccesses: 1, probeErrs: []error{transient}, wantState: StateOpen, wantOpens: 2},
+		{name: "permanent error closes", halfOpenSuccesses: 1, probeErrs: []error{permanent}, wantState: StateClosed, wantOpens: 1},
+		{name: "more successes needed", halfOpenSuccesses: 2, probeErrs: []error{nil}, wantState: StateHalfOpen, wantOpens: 1},
+		{name: "enough successes", halfOpenSuccesses: 2, probeErrs: []error{nil, nil}, wantState: StateClosed, wantOpens: 1},
+		{name: "second probe fails", halfOpenSuccesses: 2, probeErrs: []error{nil, transient}, wantState: StateOpen, wantOpens: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubService{err: transient}
+			service, now := newTestBreaker(svc, tt.halfOpenSuccesses)
+			_ = send(service, 3)
+			*now = now.Add(time.Minute)
+
+			for _, err := range tt.probeErrs {
+				svc.err = err
+				_ = service.SendMail(context.Background(), mail.MailAttributes{})
+			}
+			status := service.Status()
+			if status.State != tt.wantState || status.Opens != tt.wantOpens {
+				t.Errorf("state = %q after %d opens, want %q after %d", status.State, status.Opens, tt.wantState, tt.wantOpens)
+			}
+			if svc.calls != 3+len(tt.probeErrs) {
+				t.Errorf("provider called %d times, want %d", svc.calls, 3+len(tt.probeErrs))
+			}
+		})
+	}
+}
+
+// blockingService blocks until release is closed.
+type blockingService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	close(s.started)
+	<-s.release
+	return nil
+}
+
+func TestBreakerService_SingleProbe(t *testing.T) {
+	svc := &stubService{err: transient}
+	service, now := newTestBreaker(svc, 1)
+	_ = send(service, 3)
+	*now = now.Add(time.Minute)
+
+	probe := &blockingService{started: make(chan struct{}), release: make(chan struct{})}
+	service.service = probe
+	done := make(chan error)
+	go func() { done <- service.SendMail(context.Background(), mail.MailAttributes{}) }()
+	<-probe.started
+
+	if err := service.SendMail(context.Background(), mail.MailAttributes{}); !errors.Is(err, ErrOpen) {
+		t.Errorf("SendMail() during the probe error = %v, want ErrOpen", err)
+	}
+	close(probe.release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if got := service.Status().State; got != StateClosed {
+		t.Errorf("state = %q, want closed", got)
+	}
+}
+
+func TestBreakerService_Disabled(t *testing.T) {
+	svc := &stubService{err: transient}
+	service := NewBreakerService("mailjet", svc, config.CircuitBreakerConfig{})
+	_ = send(service, 10)
+	if svc.calls != 10 || service.Status().State != StateClosed {
+		t.Errorf("provider called %d times in state %q, want 10 while closed", svc.calls, service.Status().State)
+	}
+}
+
+func TestBreakerService_CanceledMailDoesNotCount(t *testing.T) {
+	svc := &stubService{err: transient}
+	service, _ := newTestBreaker(svc, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 5 {
+		_ = service.SendMail(ctx, mail.MailAttributes{})
+	}
+	if status := service.Status(); status.State != StateClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("Status() = %+v, want closed without failures", status)
+	}
+}
+
+func TestBreakerService_SendBatch(t *testing.T) {
+	svc := &stubService{err: transient}
+	service, _ := newTestBreaker(svc, 1)
+
+	// a failed batch counts once, however many mails it holds
+	for range 2 {
+		_ = service.SendBatch(context.Background(), make([]mail.MailAttributes, 5))
+	}
+	if got := service.Status(); got.State != StateClosed || got.ConsecutiveFailures != 2 {
+		t.Fatalf("status = %+v after 2 failed batches, want closed with 2 failures", got)
+	}
+
+	results := service.SendBatch(context.Background(), make([]mail.MailAttributes, 3))
+	if svc.calls != 13 || service.Status().State != StateOpen {
+		t.Fatalf("provider called %d times in state %q, want 13 and open", svc.calls, service.Status().State)
+	}
+	for i, result := range results {
+		if !errors.Is(result.Err, transient) {
+			t.Errorf("results[%d] error = %v, want the provider error", i, result.Err)
+		}
+	}
+
+	results = service.SendBatch(context.Background(), make([]mail.MailAttributes, 2))
+	if svc.calls != 13 || len(results) != 2 || !errors.Is(results[0].Err, ErrOpen) || !errors.Is(results[1].Err, ErrOpen) {
+		t.Errorf("results = %+v after %d calls, want ErrOpen without calling the provider", results, svc.calls)
+	}
+}
+
+func TestBatchOutcome(t *testing.T) {
+	otherTransient := mail.NewStatusError("mailjet", 502, "", errors.New("bad gateway"))
+	tests := []struct {
+		name string
+		errs []error
+		want error
+	}{
+		{name: "empty batch", errs: nil, want: nil},
+		{name: "all succeeded", errs: []error{nil, nil}, want: nil},
+		{name: "same transient error", errs: []error{transient, transient, transient}, want: transient},
+		{name: "some succeeded", errs: []error{transient, nil, transient}, want: nil},
+		{name: "different transient errors", errs: []error{transient, otherTransient}, want: nil},
+		{name: "same permanent error", errs: []error{permanent, permanent}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]mail.BatchResult, len(tt.errs))
+			for i, err := range tt.errs {
+				results[i].Err = err
+			}
+			if got := batchOutcome(results); got != tt.want {
+				t.Errorf("batchOutcome() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
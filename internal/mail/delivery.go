@@ -0,0 +1,31 @@
+package mail
+
+import "context"
+
+// Delivery describes how a provider accepted a mail.
+type Delivery struct {
+	// Provider is the name of the provider that accepted the mail.
+	Provider string
+	// MessageIDs are the identifiers the provider assigned, e.g. one Mailjet MessageUUID per recipient.
+	MessageIDs []string
+}
+
+type deliveryKey struct{}
+
+// NewDeliveryContext returns a context that collects the delivery reported by
+// the MailService it is passed to. The returned Delivery is filled once SendMail succeeds.
+func NewDeliveryContext(ctx context.Context) (context.Context, *Delivery) {
+	delivery := &Delivery{}
+	return context.WithValue(ctx, deliveryKey{}, delivery), delivery
+}
+
+// RecordDelivery is called by providers after a mail was accepted. It does
+// nothing if ctx was not created by NewDeliveryContext.
+func RecordDelivery(ctx context.Context, provider string, messageIDs ...string) {
+	delivery, ok := ctx.Value(deliveryKey{}).(*Delivery)
+	if !ok {
+		return
+	}
+	delivery.Provider = provider
+	delivery.MessageIDs = messageIDs
+}
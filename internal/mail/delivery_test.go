@@ -0,0 +1,25 @@
+package mail
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRecordDelivery(t *testing.T) {
+	ctx, delivery := NewDeliveryContext(context.Background())
+
+	RecordDelivery(ctx, "mailjet", "uuid-1", "uuid-2")
+
+	if delivery.Provider != "mailjet" {
+		t.Errorf("Provider = %q, want %q", delivery.Provider, "mailjet")
+	}
+	if want := []string{"uuid-1", "uuid-2"}; !reflect.DeepEqual(delivery.MessageIDs, want) {
+		t.Errorf("MessageIDs = %v, want %v", delivery.MessageIDs, want)
+	}
+}
+
+func TestRecordDelivery_WithoutDeliveryContext(t *testing.T) {
+	// must not panic
+	RecordDelivery(context.Background(), "mailjet", "uuid-1")
+}
@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRateLimited is wrapped by the error of a mail that was held back to stay
+// within the configured rate limits of a provider. It is never sent to the provider.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ProviderError is returned by a MailService when the provider could not
+// accept a mail. Transient errors, such as rate limits, server errors and
+// network failures, may succeed when retried; permanent errors will not.
+type ProviderError struct {
+	Provider string
+	// StatusCode is the HTTP status returned by the provider, 0 if no response was received.
+	StatusCode int
+	Transient  bool
+	// RetryAfter is the delay requested by the provider, 0 if it did not ask for one.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// NewStatusError classifies a failed HTTP response: 408, 429 and 5xx are
+// transient, every other status is permanent. retryAfter is the raw value of
+// the Retry-After response header and may be empty.
+func NewStatusError(provider string, statusCode int, retryAfter string, err error) *ProviderError {
+	return &ProviderError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Transient:  statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500,
+		RetryAfter: ParseRetryAfter(retryAfter, time.Now()),
+		Err:        err,
+	}
+}
+
+// NewNetworkError wraps an error that occurred before a response was received. It is transient.
+func NewNetworkError(provider string, err error) *ProviderError {
+	return &ProviderError{Provider: provider, Transient: true, Err: err}
+}
+
+// IsTransient reports whether err is a ProviderError that may succeed when retried.
+func IsTransient(err error) bool {
+	var providerErr *ProviderError
+	return errors.As(err, &providerErr) && providerErr.Transient
+}
+
+// IsPermanent reports whether err is a ProviderError that will fail again when retried.
+func IsPermanent(err error) bool {
+	var providerErr *ProviderError
+	return errors.As(err, &providerErr) && !providerErr.Transient
+}
+
+// RetryAfter returns the delay requested by the provider that caused err, or 0.
+func RetryAfter(err error) time.Duration {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
+
+// ParseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date. It returns 0 for empty, invalid or past values.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
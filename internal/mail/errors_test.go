@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNewStatusError(t *testing.T) {
+	tests := []struct {
+		statusCode    int
+		wantTransient bool
+	}{
+		{statusCode: 400, wantTransient: false},
+		{statusCode: 401, wantTransient: false},
+		{statusCode: 403, wantTransient: false},
+		{statusCode: 408, wantTransient: true},
+		{statusCode: 429, wantTransient: true},
+		{statusCode: 500, wantTransient: true},
+		{statusCode: 503, wantTransient: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.statusCode), func(t *testing.T) {
+			err := NewStatusError("mailjet", tt.statusCode, "", errors.New("failed"))
+			if IsTransient(err) != tt.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v", IsTransient(err), tt.wantTransient)
+			}
+			if IsPermanent(err) == tt.wantTransient {
+				t.Errorf("IsPermanent() = %v, want %v", IsPermanent(err), !tt.wantTransient)
+			}
+		})
+	}
+}
+
+func TestProviderError_Wrapped(t *testing.T) {
+	cause := errors.New("connection reset")
+	err := fmt.Errorf("sending: %w", NewNetworkError("sendgrid", cause))
+
+	if !IsTransient(err) {
+		t.Error("IsTransient() = false for wrapped network error")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is() does not find the cause")
+	}
+	if err.Error() != "sending: connection reset" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestIsTransient_UnclassifiedError(t *testing.T) {
+	err := errors.New("something else")
+	if IsTransient(err) || IsPermanent(err) {
+		t.Error("unclassified errors must be neither transient nor permanent")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "120", want: 2 * time.Minute},
+		{value: "-5", want: 0},
+		{value: "Mon, 01 Jan 2024 12:00:30 GMT", want: 30 * time.Second},
+		{value: "Mon, 01 Jan 2024 11:00:00 GMT", want: 0},
+		{value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", NewStatusError("sendgrid", 429, "7", errors.New("too many requests")))
+	if got := RetryAfter(err); got != 7*time.Second {
+		t.Errorf("RetryAfter() = %v, want 7s", got)
+	}
+	if got := RetryAfter(errors.New("plain")); got != 0 {
+		t.Errorf("RetryAfter(plain) = %v, want 0", got)
+	}
+}
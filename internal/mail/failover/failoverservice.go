@@ -0,0 +1,111 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// Provider is a named mail service in a failover chain.
+type Provider struct {
+	Name    string
+	Service mail.MailService
+}
+
+// FailoverService implements MailService by trying its providers in order.
+// A mail goes to the next provider only when the previous one failed with a
+// transient error; permanent errors, such as an invalid recipient, would
+// fail everywhere and are returned right away.
+type FailoverService struct {
+	providers []Provider
+}
+
+// NewFailoverService creates a chain that tries providers first to last.
+func NewFailoverService(providers ...Provider) *FailoverService {
+	return &FailoverService{providers: providers}
+}
+
+func (service *FailoverService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	if len(service.providers) == 0 {
+		return errors.New("no mail provider is enabled")
+	}
+
+	var errs []error
+	for i, provider := range service.providers {
+		err := provider.Service.SendMail(ctx, attributes)
+		if err == nil {
+			if i > 0 {
+				slog.Info("failover: mail sent by fallback provider", "provider", provider.Name)
+			}
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+		if !mail.IsTransient(err) || ctx.Err() != nil {
+			break
+		}
+		if i < len(service.providers)-1 {
+			slog.Warn("failover: provider failed, trying next", "provider", provider.Name, "next", service.providers[i+1].Name, "error", err)
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// SendBatch sends the batch through the first provider. The mails that failed
+// with a transient error go on to the next provider together.
+func (service *FailoverService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	results := make([]mail.BatchResult, len(mails))
+	if len(service.providers) == 0 {
+		for i := range results {
+			results[i].Err = errors.New("no mail provider is enabled")
+		}
+		return results
+	}
+
+	pending := make([]int, len(mails))
+	for i := range pending {
+		pending[i] = i
+	}
+	errs := make([][]error, len(mails))
+	for i, provider := range service.providers {
+		if len(pending) == 0 {
+			break
+		}
+		if i > 0 {
+			slog.Warn("failover: provider failed for part of the batch, trying next", "provider", service.providers[i-1].Name, "next", provider.Name, "mails", len(pending))
+		}
+
+		mail.SendBatchSubset(ctx, provider.Service, mails, pending, results)
+		var failed []int
+		for _, j := range pending {
+			if err := results[j].Err; err != nil {
+				errs[j] = append(errs[j], fmt.Errorf("%s: %w", provider.Name, err))
+				if mail.IsTransient(err) && ctx.Err() == nil {
+					failed = append(failed, j)
+				}
+			}
+		}
+		if i > 0 && len(failed) < len(pending) {
+			slog.Info("failover: mails sent by fallback provider", "provider", provider.Name, "mails", len(pending)-len(failed))
+		}
+		pending = failed
+	}
+
+	for i := range results {
+		switch {
+		case results[i].Err == nil:
+		case len(errs[i]) == 1:
+			results[i].Err = errs[i][0]
+		default:
+			results[i].Err = errors.Join(errs[i]...)
+		}
+	}
+	return results
+}
@@ -0,0 +1,130 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// stubService returns err and records whether it was called.
+type stubService struct {
+	name   string
+	err    error
+	called bool
+}
+
+func (s *stubService) SendMail(ctx context.Context, _ mail.MailAttributes) error {
+	s.called = true
+	if s.err == nil {
+		mail.RecordDelivery(ctx, s.name, s.name+"-id")
+	}
+	return s.err
+}
+
+func chain(services ...*stubService) *FailoverService {
+	providers := make([]Provider, 0, len(services))
+	for _, service := range services {
+		providers = append(providers, Provider{Name: service.name, Service: service})
+	}
+	return NewFailoverService(providers...)
+}
+
+func TestFailoverService_SendMail(t *testing.T) {
+	transient := mail.NewStatusError("primary", 503, "", errors.New("unavailable"))
+	permanent := mail.NewStatusError("primary", 400, "", errors.New("invalid recipient"))
+
+	tests := []struct {
+		name          string
+		primaryErr    error
+		secondaryErr  error
+		wantSecondary bool
+		wantProvider  string
+		wantErr       bool
+	}{
+		{name: "primary succeeds", wantProvider: "primary"},
+		{name: "transient error fails over", primaryErr: transient, wantSecondary: true, wantProvider: "secondary"},
+		{name: "permanent error does not fail over", primaryErr: permanent, wantErr: true},
+		{name: "unclassified error does not fail over", primaryErr: errors.New("bug"), wantErr: true},
+		{name: "all providers fail", primaryErr: transient, secondaryErr: transient, wantSecondary: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubService{name: "primary", err: tt.primaryErr}
+			secondary := &stubService{name: "secondary", err: tt.secondaryErr}
+
+			ctx, delivery := mail.NewDeliveryContext(context.Background())
+			err := chain(primary, secondary).SendMail(ctx, mail.MailAttributes{})
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SendMail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if secondary.called != tt.wantSecondary {
+				t.Errorf("secondary called = %v, want %v", secondary.called, tt.wantSecondary)
+			}
+			if delivery.Provider != tt.wantProvider {
+				t.Errorf("delivered by %q, want %q", delivery.Provider, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestFailoverService_ErrorKeepsClassification(t *testing.T) {
+	transient := mail.NewStatusError("primary", 429, "30", errors.New("rate limited"))
+	err := chain(&stubService{name: "primary", err: transient}, &stubService{name: "secondary", err: transient}).
+		SendMail(context.Background(), mail.MailAttributes{})
+
+	if !mail.IsTransient(err) {
+		t.Errorf("IsTransient() = false for %v", err)
+	}
+	if err.Error() != "primary: rate limited\nsecondary: rate limited" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestFailoverService_NoProviders(t *testing.T) {
+	if err := NewFailoverService().SendMail(context.Background(), mail.MailAttributes{}); err == nil {
+		t.Error("SendMail() expected error without providers")
+	}
+}
+
+// subjectService fails the mails whose subject has an error in errs.
+type subjectService struct {
+	name string
+	errs map[string]error
+	sent []string
+}
+
+func (s *subjectService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	s.sent = append(s.sent, attributes.Subject)
+	if err := s.errs[attributes.Subject]; err != nil {
+		return err
+	}
+	mail.RecordDelivery(ctx, s.name, s.name+"-"+attributes.Subject)
+	return nil
+}
+
+func TestFailoverService_SendBatch(t *testing.T) {
+	transient := mail.NewStatusError("primary", 503, "", errors.New("unavailable"))
+	permanent := mail.NewStatusError("primary", 400, "", errors.New("invalid recipient"))
+	primary := &subjectService{name: "primary", errs: map[string]error{"b": transient, "c": permanent, "d": transient}}
+	secondary := &subjectService{name: "secondary", errs: map[string]error{"d": transient}}
+	service := NewFailoverService(Provider{Name: "primary", Service: primary}, Provider{Name: "secondary", Service: secondary})
+
+	results := service.SendBatch(context.Background(), []mail.MailAttributes{{Subject: "a"}, {Subject: "b"}, {Subject: "c"}, {Subject: "d"}})
+	if len(secondary.sent) != 2 || secondary.sent[0] != "b" || secondary.sent[1] != "d" {
+		t.Errorf("secondary sent %v, want only the mails that failed transiently", secondary.sent)
+	}
+	if results[0].Delivery.Provider != "primary" || results[1].Delivery.Provider != "secondary" || results[1].Err != nil {
+		t.Errorf("results = %+v, want a via primary and b via secondary", results)
+	}
+	if !errors.Is(results[2].Err, permanent) || !mail.IsPermanent(results[2].Err) {
+		t.Errorf("results[2] error = %v, want the permanent error", results[2].Err)
+	}
+	if err := results[3].Err; !mail.IsTransient(err) || !strings.Contains(err.Error(), "primary: ") || !strings.Contains(err.Error(), "secondary: ") {
+		t.Errorf("results[3] error = %v, want the errors of both providers", err)
+	}
+}
@@ -0,0 +1,51 @@
+// Package fakeesp provides in-process stand-ins for the Mailjet and SendGrid
+// send APIs, so the service can be tested end to end without provider
+// credentials. Point a provider's baseURL at URL() of a fake server.
+package fakeesp
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Response is a canned reply a fake server sends instead of its success
+// response, e.g. to simulate rate limiting or a rejected message.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// TooManyRequests returns a 429 response with the given Retry-After header.
+// An empty retryAfter leaves the header out.
+func TooManyRequests(retryAfter string) Response {
+	response := Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{},
+		Body:       `{"errors":[{"message":"too many requests"}]}`,
+	}
+	if retryAfter != "" {
+		response.Header.Set("Retry-After", retryAfter)
+	}
+	return response
+}
+
+func (r Response) write(w http.ResponseWriter) {
+	for name, values := range r.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(r.StatusCode)
+	_, _ = w.Write([]byte(r.Body))
+}
+
+// writeJSON sends body as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,214 @@
+package fakeesp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// MailjetRequest is the payload of a Mailjet v3.1 send request.
+type MailjetRequest struct {
+	Messages []MailjetMessage `json:"Messages"`
+}
+
+// MailjetMessage is a single message of a send request.
+type MailjetMessage struct {
+	From     MailjetAddress   `json:"From"`
+	To       []MailjetAddress `json:"To"`
+	Cc       []MailjetAddress `json:"Cc,omitempty"`
+	Bcc      []MailjetAddress `json:"Bcc,omitempty"`
+	ReplyTo  *MailjetAddress  `json:"ReplyTo,omitempty"`
+	Subject  string           `json:"Subject"`
+	TextPart string           `json:"TextPart,omitempty"`
+	HTMLPart string           `json:"HTMLPart,omitempty"`
+
+	Attachments        []MailjetAttachment `json:"Attachments,omitempty"`
+	InlinedAttachments []MailjetAttachment `json:"InlinedAttachments,omitempty"`
+}
+
+// MailjetAddress is an email address with an optional name.
+type MailjetAddress struct {
+	Email string `json:"Email"`
+	Name  string `json:"Name,omitempty"`
+}
+
+// MailjetAttachment is a regular or inlined attachment.
+type MailjetAttachment struct {
+	ContentType   string `json:"ContentType"`
+	Filename      string `json:"Filename"`
+	Base64Content string `json:"Base64Content"`
+	ContentID     string `json:"ContentID,omitempty"`
+}
+
+// MailjetError is an entry of the Errors array of a rejected message.
+type MailjetError struct {
+	ErrorIdentifier string   `json:"ErrorIdentifier"`
+	ErrorCode       string   `json:"ErrorCode"`
+	StatusCode      int      `json:"StatusCode"`
+	ErrorMessage    string   `json:"ErrorMessage"`
+	ErrorRelatedTo  []string `json:"ErrorRelatedTo,omitempty"`
+}
+
+type mailjetResponse struct {
+	Messages []mailjetMessageResponse `json:"Messages"`
+}
+
+type mailjetMessageResponse struct {
+	Status string                     `json:"Status"`
+	To     []mailjetRecipientResponse `json:"To,omitempty"`
+	Cc     []mailjetRecipientResponse `json:"Cc,omitempty"`
+	Bcc    []mailjetRecipientResponse `json:"Bcc,omitempty"`
+	Errors []MailjetError             `json:"Errors,omitempty"`
+}
+
+type mailjetRecipientResponse struct {
+	Email       string `json:"Email"`
+	MessageUUID string `json:"MessageUUID"`
+	MessageID   int64  `json:"MessageID"`
+	MessageHref string `json:"MessageHref"`
+}
+
+// MailjetErrors returns a response that rejects a message with errs, the
+// way Mailjet reports validation errors.
+func MailjetErrors(statusCode int, errs ...MailjetError) Response {
+	body, _ := json.Marshal(mailjetResponse{
+		Messages: []mailjetMessageResponse{{Status: "error", Errors: errs}},
+	})
+	return Response{StatusCode: statusCode, Body: string(body)}
+}
+
+// MailjetServer is a fake of the Mailjet v3.1 send API. It accepts requests
+// authenticated with its API keys and answers each recipient with a
+// MessageUUID of the form mailjet-<n>.
+type MailjetServer struct {
+	server        *httptest.Server
+	apiKeyPublic  string
+	apiKeyPrivate string
+
+	mu       sync.Mutex
+	requests []MailjetRequest
+	failures []Response
+	sent     int64
+}
+
+// NewMailjetServer starts a fake Mailjet API that accepts the given API keys.
+func NewMailjetServer(apiKeyPublic, apiKeyPrivate string) *MailjetServer {
+	fake := &MailjetServer{
+		apiKeyPublic:  apiKeyPublic,
+		apiKeyPrivate: apiKeyPrivate,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v3.1/send", fake.handleSend)
+	fake.server = httptest.NewServer(mux)
+	return fake
+}
+
+// URL returns the base URL to configure as the Mailjet baseURL.
+func (s *MailjetServer) URL() string {
+	return s.server.URL
+}
+
+// Close shuts down the server.
+func (s *MailjetServer) Close() {
+	s.server.Close()
+}
+
+// Requests returns a copy of all authenticated send requests received so
+// far, including those answered with an injected failure.
+func (s *MailjetServer) Requests() []MailjetRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]MailjetRequest, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// Messages returns all messages of all received send requests.
+func (s *MailjetServer) Messages() []MailjetMessage {
+	var messages []MailjetMessage
+	for _, request := range s.Requests() {
+		messages = append(messages, request.Messages...)
+	}
+	return messages
+}
+
+// FailNext answers the next send requests with responses, one per request
+// and in order. Later requests succeed again.
+func (s *MailjetServer) FailNext(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, responses...)
+}
+
+// Reset clears recorded requests and pending failures.
+func (s *MailjetServer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.failures = nil
+}
+
+func (s *MailjetServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	user, password, ok := r.BasicAuth()
+	if !ok || user != s.apiKeyPublic || password != s.apiKeyPrivate {
+		writeJSON(w, http.StatusUnauthorized, MailjetError{
+			ErrorIdentifier: "fake-401",
+			StatusCode:      http.StatusUnauthorized,
+			ErrorMessage:    "API key authentication/authorization failure. You may be unauthorized to access the API or your API key may be expired. Visit API keys management section to check your keys.",
+		})
+		return
+	}
+
+	var request MailjetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Messages) == 0 {
+		writeJSON(w, http.StatusBadRequest, MailjetError{
+			ErrorIdentifier: "fake-400",
+			ErrorCode:       "mj-0002",
+			StatusCode:      http.StatusBadRequest,
+			ErrorMessage:    "Malformed JSON, please review the syntax and properties types.",
+		})
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	if len(s.failures) > 0 {
+		failure := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mu.Unlock()
+		failure.write(w)
+		return
+	}
+
+	response := mailjetResponse{}
+	for _, message := range request.Messages {
+		response.Messages = append(response.Messages, mailjetMessageResponse{
+			Status: "success",
+			To:     s.recipients(message.To),
+			Cc:     s.recipients(message.Cc),
+			Bcc:    s.recipients(message.Bcc),
+		})
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// recipients assigns message IDs to addresses; the caller holds s.mu.
+func (s *MailjetServer) recipients(addresses []MailjetAddress) []mailjetRecipientResponse {
+	var recipients []mailjetRecipientResponse
+	for _, address := range addresses {
+		s.sent++
+		recipients = append(recipients, mailjetRecipientResponse{
+			Email:       address.Email,
+			MessageUUID: fmt.Sprintf("mailjet-%d", s.sent),
+			MessageID:   s.sent,
+			MessageHref: fmt.Sprintf("%s/v3/REST/message/%d", s.server.URL, s.sent),
+		})
+	}
+	return recipients
+}
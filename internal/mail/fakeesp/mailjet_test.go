@@ -0,0 +1,86 @@
+package fakeesp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func postMailjet(t *testing.T, server *MailjetServer, user, password, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL()+"/v3.1/send", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(user, password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+const mailjetBody = `{"Messages":[{"From":{"Email":"sender@example.com"},"To":[{"Email":"a@example.com"},{"Email":"b@example.com"}],"Subject":"Hello","HTMLPart":"<p>Hi</p>"}]}`
+
+func TestMailjetServer_Send(t *testing.T) {
+	server := NewMailjetServer("public", "private")
+	defer server.Close()
+
+	resp := postMailjet(t, server, "public", "private", mailjetBody)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("recorded %d messages, want 1", len(messages))
+	}
+	if messages[0].Subject != "Hello" || len(messages[0].To) != 2 || messages[0].From.Email != "sender@example.com" {
+		t.Errorf("recorded message = %+v", messages[0])
+	}
+}
+
+func TestMailjetServer_RejectsWrongCredentials(t *testing.T) {
+	server := NewMailjetServer("public", "private")
+	defer server.Close()
+
+	resp := postMailjet(t, server, "public", "wrong", mailjetBody)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("recorded %d requests, want 0", len(server.Requests()))
+	}
+}
+
+func TestMailjetServer_FailNext(t *testing.T) {
+	server := NewMailjetServer("public", "private")
+	defer server.Close()
+
+	server.FailNext(
+		MailjetErrors(http.StatusBadRequest, MailjetError{ErrorCode: "mj-0013", StatusCode: 400, ErrorMessage: "invalid email"}),
+		TooManyRequests("3"),
+	)
+
+	resp := postMailjet(t, server, "public", "private", mailjetBody)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("first status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	resp = postMailjet(t, server, "public", "private", mailjetBody)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "3" {
+		t.Errorf("second status = %d, Retry-After = %q, want 429 and 3", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	resp = postMailjet(t, server, "public", "private", mailjetBody)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("third status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if len(server.Requests()) != 3 {
+		t.Errorf("recorded %d requests, want 3", len(server.Requests()))
+	}
+
+	server.Reset()
+	if len(server.Requests()) != 0 {
+		t.Errorf("recorded %d requests after Reset, want 0", len(server.Requests()))
+	}
+}
@@ -0,0 +1,204 @@
+package fakeesp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SendGridMail is the payload of a SendGrid v3 mail/send request.
+type SendGridMail struct {
+	Personalizations []SendGridPersonalization `json:"personalizations"`
+	From             *SendGridAddress          `json:"from"`
+	ReplyTo          *SendGridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject,omitempty"`
+	Content          []SendGridContent         `json:"content,omitempty"`
+	Attachments      []SendGridAttachment      `json:"attachments,omitempty"`
+}
+
+// SendGridPersonalization holds the recipients of a mail.
+type SendGridPersonalization struct {
+	To      []SendGridAddress `json:"to"`
+	Cc      []SendGridAddress `json:"cc,omitempty"`
+	Bcc     []SendGridAddress `json:"bcc,omitempty"`
+	Subject string            `json:"subject,omitempty"`
+}
+
+// SendGridAddress is an email address with an optional name.
+type SendGridAddress struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+}
+
+// SendGridContent is a text/plain or text/html body.
+type SendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// SendGridAttachment is a regular or inline attachment.
+type SendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sendGridErrorResponse struct {
+	Errors []sendGridError `json:"errors"`
+}
+
+type sendGridError struct {
+	Message string  `json:"message"`
+	Field   *string `json:"field"`
+	Help    *string `json:"help"`
+}
+
+// SendGridErrors returns a response in SendGrid's error format with one
+// error per message.
+func SendGridErrors(statusCode int, messages ...string) Response {
+	response := sendGridErrorResponse{Errors: []sendGridError{}}
+	for _, message := range messages {
+		response.Errors = append(response.Errors, sendGridError{Message: message})
+	}
+	body, _ := json.Marshal(response)
+	return Response{StatusCode: statusCode, Body: string(body)}
+}
+
+// SendGridServer is a fake of the SendGrid v3 mail/send API. It accepts
+// requests authenticated with any of its API keys, e.g. of several
+// subaccounts, and answers each accepted mail with an X-Message-Id of the
+// form sendgrid-<n>.
+type SendGridServer struct {
+	server  *httptest.Server
+	apiKeys []string
+
+	mu       sync.Mutex
+	mails    []SendGridMail
+	keys     []string
+	failures []Response
+	sent     int
+}
+
+// NewSendGridServer starts a fake SendGrid API that accepts the given API keys.
+func NewSendGridServer(apiKeys ...string) *SendGridServer {
+	fake := &SendGridServer{apiKeys: apiKeys}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v3/mail/send", fake.handleSend)
+	fake.server = httptest.NewServer(mux)
+	return fake
+}
+
+// URL returns the base URL to configure as the SendGrid baseURL.
+func (s *SendGridServer) URL() string {
+	return s.server.URL
+}
+
+// Close shuts down the server.
+func (s *SendGridServer) Close() {
+	s.server.Close()
+}
+
+// Mails returns a copy of all authenticated mails received so far,
+// including those answered with an injected failure.
+func (s *SendGridServer) Mails() []SendGridMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mails := make([]SendGridMail, len(s.mails))
+	copy(mails, s.mails)
+	return mails
+}
+
+// APIKeys returns the API key each mail returned by Mails was sent with.
+func (s *SendGridServer) APIKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, len(s.keys))
+	copy(keys, s.keys)
+	return keys
+}
+
+// FailNext answers the next send requests with responses, one per request
+// and in order. Later requests succeed again.
+func (s *SendGridServer) FailNext(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, responses...)
+}
+
+// Reset clears recorded mails and pending failures.
+func (s *SendGridServer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mails = nil
+	s.keys = nil
+	s.failures = nil
+}
+
+func (s *SendGridServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	apiKey, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !slices.Contains(s.apiKeys, apiKey) {
+		SendGridErrors(http.StatusUnauthorized, "The provided authorization grant is invalid, expired, or revoked").write(w)
+		return
+	}
+
+	var mail SendGridMail
+	if err := json.NewDecoder(r.Body).Decode(&mail); err != nil {
+		SendGridErrors(http.StatusBadRequest, "Bad Request").write(w)
+		return
+	}
+	if problems := validateSendGridMail(mail); len(problems) > 0 {
+		SendGridErrors(http.StatusBadRequest, problems...).write(w)
+		return
+	}
+
+	s.mu.Lock()
+	s.mails = append(s.mails, mail)
+	s.keys = append(s.keys, apiKey)
+	if len(s.failures) > 0 {
+		failure := s.failures[0]
+		s.failures = s.failures[1:]
+		s.mu.Unlock()
+		failure.write(w)
+		return
+	}
+	s.sent++
+	messageID := fmt.Sprintf("sendgrid-%d", s.sent)
+	s.mu.Unlock()
+
+	w.Header().Set("X-Message-Id", messageID)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validateSendGridMail checks the fields SendGrid rejects a mail without.
+func validateSendGridMail(mail SendGridMail) []string {
+	var problems []string
+	if mail.From == nil || mail.From.Email == "" {
+		problems = append(problems, "The from object must be provided for every email send.")
+	}
+	if len(mail.Personalizations) == 0 {
+		problems = append(problems, "The personalizations field is required and must have at least one personalization.")
+	}
+	recipients := 0
+	for _, personalization := range mail.Personalizations {
+		if len(personalization.To) == 0 {
+			problems = append(problems, "The to array is required for all personalization objects, and must have at least one email object with a valid email address.")
+		}
+		recipients += len(personalization.To) + len(personalization.Cc) + len(personalization.Bcc)
+	}
+	if recipients > 1000 {
+		problems = append(problems, "The total number of recipients must be no more than 1000.")
+	}
+	if len(mail.Content) == 0 {
+		problems = append(problems, "Unless a valid template_id is provided, the content parameter is required.")
+	}
+	return problems
+}
@@ -0,0 +1,116 @@
+package fakeesp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func postSendGrid(t *testing.T, server *SendGridServer, apiKey, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL()+"/v3/mail/send", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+const sendGridBody = `{"personalizations":[{"to":[{"email":"a@example.com"}],"subject":"Hello"}],"from":{"email":"sender@example.com"},"content":[{"type":"text/html","value":"<p>Hi</p>"}]}`
+
+func TestSendGridServer_Send(t *testing.T) {
+	server := NewSendGridServer("key")
+	defer server.Close()
+
+	resp := postSendGrid(t, server, "key", sendGridBody)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if id := resp.Header.Get("X-Message-Id"); id != "sendgrid-1" {
+		t.Errorf("X-Message-Id = %q, want sendgrid-1", id)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("recorded %d mails, want 1", len(mails))
+	}
+	if mails[0].Personalizations[0].Subject != "Hello" || mails[0].From.Email != "sender@example.com" {
+		t.Errorf("recorded mail = %+v", mails[0])
+	}
+}
+
+func TestSendGridServer_Rejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		body       string
+		wantStatus int
+	}{
+		{name: "wrong api key", apiKey: "other", body: sendGridBody, wantStatus: http.StatusUnauthorized},
+		{name: "malformed body", apiKey: "key", body: "{", wantStatus: http.StatusBadRequest},
+		{name: "missing recipients", apiKey: "key", body: `{"personalizations":[],"from":{"email":"sender@example.com"},"content":[{"type":"text/plain","value":"Hi"}]}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewSendGridServer("key")
+			defer server.Close()
+
+			resp := postSendGrid(t, server, tt.apiKey, tt.body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			var body sendGridErrorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || len(body.Errors) == 0 {
+				t.Errorf("error body = %+v (decode error %v), want at least one error", body, err)
+			}
+			if len(server.Mails()) != 0 {
+				t.Errorf("recorded %d mails, want 0", len(server.Mails()))
+			}
+		})
+	}
+}
+
+func TestSendGridServer_FailNext(t *testing.T) {
+	server := NewSendGridServer("key")
+	defer server.Close()
+
+	server.FailNext(TooManyRequests("2"))
+
+	resp := postSendGrid(t, server, "key", sendGridBody)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "2" {
+		t.Errorf("first status = %d, Retry-After = %q, want 429 and 2", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "too many requests") {
+		t.Errorf("first body = %s", body)
+	}
+
+	resp = postSendGrid(t, server, "key", sendGridBody)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("second status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if len(server.Mails()) != 2 {
+		t.Errorf("recorded %d mails, want 2", len(server.Mails()))
+	}
+}
+
+func TestSendGridServer_SeveralAPIKeys(t *testing.T) {
+	server := NewSendGridServer("primary", "secondary")
+	defer server.Close()
+
+	for _, key := range []string{"secondary", "primary"} {
+		if resp := postSendGrid(t, server, key, sendGridBody); resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("status with key %q = %d, want %d", key, resp.StatusCode, http.StatusAccepted)
+		}
+	}
+	if keys := server.APIKeys(); len(keys) != 2 || keys[0] != "secondary" || keys[1] != "primary" {
+		t.Errorf("APIKeys() = %v, want [secondary primary]", keys)
+	}
+}
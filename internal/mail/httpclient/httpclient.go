@@ -0,0 +1,51 @@
+// Package httpclient builds the HTTP clients the API based mail providers
+// send their requests with.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+)
+
+// New creates an HTTP client with the timeout, proxy and trusted CAs of cfg.
+// Without a proxy URL the proxy is taken from the environment.
+func New(cfg config.HTTPClientConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile) // #nosec G304 -- CA file path comes from operator-supplied config, not user input
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+	}, nil
+}
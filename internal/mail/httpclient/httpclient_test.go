@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+)
+
+func TestNew_TrustsCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// the test server certificate is unknown to the system roots
+	client, err := New(config.HTTPClientConfig{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("Get() without CA file succeeded, want certificate error")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err = New(config.HTTPClientConfig{Timeout: time.Second, CAFile: caFile})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() with CA file error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+}
+
+func TestNew_InvalidCAFile(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{caFile, filepath.Join(t.TempDir(), "missing.pem")} {
+		if _, err := New(config.HTTPClientConfig{CAFile: path}); err == nil {
+			t.Errorf("New() with CA file %q succeeded, want error", path)
+		}
+	}
+}
+
+func TestNew_Proxy(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		// a forward proxy receives the absolute URL of the target
+		if !strings.HasPrefix(r.RequestURI, "http://api.example.test/") {
+			t.Errorf("proxy got request URI %q, want absolute target URL", r.RequestURI)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer proxy.Close()
+
+	client, err := New(config.HTTPClientConfig{Timeout: time.Second, ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	resp, err := client.Get("http://api.example.test/v3/send")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if proxied.Load() != 1 {
+		t.Errorf("proxy received %d requests, want 1", proxied.Load())
+	}
+}
+
+func TestNew_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := New(config.HTTPClientConfig{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, err = client.Get(server.URL)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Get() error = %v, want timeout", err)
+	}
+}
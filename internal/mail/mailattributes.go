@@ -1,10 +1,70 @@
 package mail
 
+import "strings"
+
+// Content types of the HtmlContent of a mail.
+const (
+	ContentTypeHTML     = "html"
+	ContentTypeMarkdown = "markdown"
+)
+
 // MailAttributes contains E-Mail attributes
 type MailAttributes struct {
-	To          string `json:"to" validate:"required"`
-	Subject     string `json:"subject" validate:"required"`
-	HtmlContent string `json:"content" validate:"required"`
-	From        string `json:"from,omitempty"`
-	FromName    string `json:"fromName,omitempty"`
+	To          string       `json:"to" validate:"required"`
+	Cc          []string     `json:"cc,omitempty" validate:"omitempty,dive,email"`
+	Bcc         []string     `json:"bcc,omitempty" validate:"omitempty,dive,email"`
+	ReplyTo     string       `json:"replyTo,omitempty" validate:"omitempty,email"`
+	Subject     string       `json:"subject" validate:"required"`
+	HtmlContent string       `json:"content" validate:"required"`
+	TextContent string       `json:"textContent,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty" validate:"omitempty,dive"`
+	From        string       `json:"from,omitempty"`
+	FromName    string       `json:"fromName,omitempty"`
+	// ContentType tells how HtmlContent is written, ContentTypeHTML if empty.
+	// Markdown is rendered to HTML when the mail is received.
+	ContentType string `json:"contentType,omitempty" validate:"omitempty,oneof=html markdown"`
+	// Tags label the mail for routing, e.g. "newsletter" or "invoice".
+	Tags []string `json:"tags,omitempty"`
+	// Client identifies the API client or ingestion path that submitted the mail.
+	// It is set by the service, not by the request body.
+	Client string `json:"client,omitempty"`
+}
+
+// Sender returns the sender address and name of the mail, falling back to the
+// given defaults for whichever of From and FromName was not set.
+func (attributes MailAttributes) Sender(defaultAddress, defaultName string) (address, name string) {
+	address, name = attributes.From, attributes.FromName
+	if address == "" {
+		address = defaultAddress
+	}
+	if name == "" {
+		name = defaultName
+	}
+	return address, name
+}
+
+// PlainText returns the plain-text alternative of the mail.
+// If no TextContent was provided it is generated from the HTML content.
+func (attributes MailAttributes) PlainText() string {
+	if attributes.TextContent != "" {
+		return attributes.TextContent
+	}
+	return HTMLToText(attributes.HtmlContent)
+}
+
+// ToAddresses returns the trimmed, non-empty addresses of the comma-separated To field.
+func (attributes MailAttributes) ToAddresses() []string {
+	return SplitAddresses(attributes.To)
+}
+
+// SplitAddresses splits a comma-separated address list and drops empty entries.
+func SplitAddresses(list string) []string {
+	addresses := []string{}
+	for _, address := range strings.Split(list, ",") {
+		address = strings.TrimSpace(address)
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
 }
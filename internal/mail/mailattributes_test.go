@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+		want []string
+	}{
+		{name: "single", list: "a@example.com", want: []string{"a@example.com"}},
+		{name: "trims spaces", list: " a@example.com , b@example.com ", want: []string{"a@example.com", "b@example.com"}},
+		{name: "drops empty entries", list: "a@example.com,,", want: []string{"a@example.com"}},
+		{name: "empty", list: "", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitAddresses(tt.list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitAddresses(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMailAttributes_Sender(t *testing.T) {
+	tests := []struct {
+		name        string
+		attributes  MailAttributes
+		wantAddress string
+		wantName    string
+	}{
+		{name: "defaults", attributes: MailAttributes{}, wantAddress: "default@example.com", wantName: "Default"},
+		{name: "override both", attributes: MailAttributes{From: "a@example.com", FromName: "A"}, wantAddress: "a@example.com", wantName: "A"},
+		{name: "override address only", attributes: MailAttributes{From: "a@example.com"}, wantAddress: "a@example.com", wantName: "Default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, name := tt.attributes.Sender("default@example.com", "Default")
+			if address != tt.wantAddress || name != tt.wantName {
+				t.Errorf("Sender() = (%q, %q), want (%q, %q)", address, name, tt.wantAddress, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package mailgun
+
+import "strings"
+
+// Mailgun regions; each has its own API host.
+const (
+	RegionUS = "us"
+	RegionEU = "eu"
+)
+
+// MailgunConfig contains all attributes to initialize the Mailgun mail service.
+type MailgunConfig struct {
+	APIKey string
+	// Domain is the sending domain configured in Mailgun.
+	Domain        string
+	Region        string
+	OriginAddress string
+	OriginName    string
+	// BaseURL overrides the API host of the region when set.
+	BaseURL string
+}
+
+// NewMailgunConfig creates a MailgunConfig from the provided credentials, domain, region, sender identity and optional base URL.
+func NewMailgunConfig(apiKey, domain, region, originAddress, originName, baseURL string) *MailgunConfig {
+	return &MailgunConfig{
+		APIKey:        apiKey,
+		Domain:        domain,
+		Region:        region,
+		OriginAddress: originAddress,
+		OriginName:    originName,
+		BaseURL:       baseURL,
+	}
+}
+
+// baseURL returns the API base URL without a trailing slash, by default the
+// API host of the configured region.
+func (config *MailgunConfig) baseURL() string {
+	if config.BaseURL != "" {
+		return strings.TrimSuffix(config.BaseURL, "/")
+	}
+	if config.Region == RegionEU {
+		return "https://api.eu.mailgun.net"
+	}
+	return "https://api.mailgun.net"
+}
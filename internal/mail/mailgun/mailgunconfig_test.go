@@ -0,0 +1,50 @@
+package mailgun
+
+import (
+	"testing"
+)
+
+func TestNewMailgunConfig(t *testing.T) {
+	cfg := NewMailgunConfig("api-key", "mg.example.com", RegionEU, "sender@example.com", "Sender Name", "http://localhost:8025")
+
+	if cfg.APIKey != "api-key" {
+		t.Errorf("APIKey = %q, want %q", cfg.APIKey, "api-key")
+	}
+	if cfg.Domain != "mg.example.com" {
+		t.Errorf("Domain = %q, want %q", cfg.Domain, "mg.example.com")
+	}
+	if cfg.Region != RegionEU {
+		t.Errorf("Region = %q, want %q", cfg.Region, RegionEU)
+	}
+	if cfg.OriginAddress != "sender@example.com" {
+		t.Errorf("OriginAddress = %q, want %q", cfg.OriginAddress, "sender@example.com")
+	}
+	if cfg.OriginName != "Sender Name" {
+		t.Errorf("OriginName = %q, want %q", cfg.OriginName, "Sender Name")
+	}
+	if cfg.BaseURL != "http://localhost:8025" {
+		t.Errorf("BaseURL = %q, want %q", cfg.BaseURL, "http://localhost:8025")
+	}
+}
+
+func TestMailgunConfig_baseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		region  string
+		baseURL string
+		want    string
+	}{
+		{name: "us", region: RegionUS, want: "https://api.mailgun.net"},
+		{name: "eu", region: RegionEU, want: "https://api.eu.mailgun.net"},
+		{name: "default region", region: "", want: "https://api.mailgun.net"},
+		{name: "override", region: RegionEU, baseURL: "http://localhost:8025/", want: "http://localhost:8025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &MailgunConfig{Region: tt.region, BaseURL: tt.baseURL}
+			if got := cfg.baseURL(); got != tt.want {
+				t.Errorf("baseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
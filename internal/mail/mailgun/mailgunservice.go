@@ -0,0 +1,182 @@
+package mailgun
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	netmail "net/mail"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// providerName identifies Mailgun in delivery records and errors
+const providerName = "mailgun"
+
+// MailgunService implements MailService
+type MailgunService struct {
+	config *MailgunConfig
+	client *http.Client
+}
+
+// NewMailgunService creates a MailgunService that sends its requests with client
+func NewMailgunService(config *MailgunConfig, client *http.Client) *MailgunService {
+	return &MailgunService{
+		config: config,
+		client: client,
+	}
+}
+
+// mailgunResponse represents the Mailgun messages API response
+type mailgunResponse struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+func (service *MailgunService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	slog.Info("mailgun: preparing to send mail")
+
+	err := service.sendRequest(ctx, attributes)
+
+	if err != nil {
+		slog.Error("mailgun: failed to send mail", "error", err)
+		return err
+	}
+
+	slog.Info("mailgun: mail sent successfully")
+	return nil
+}
+
+// createForm creates the form fields of a Mailgun message from mail attributes
+func (service *MailgunService) createForm(attributes mail.MailAttributes) url.Values {
+	address, name := attributes.Sender(service.config.OriginAddress, service.config.OriginName)
+	from := address
+	if name != "" {
+		from = (&netmail.Address{Name: name, Address: address}).String()
+	}
+
+	form := url.Values{}
+	form.Set("from", from)
+	for _, to := range attributes.ToAddresses() {
+		form.Add("to", to)
+	}
+	for _, cc := range attributes.Cc {
+		form.Add("cc", cc)
+	}
+	for _, bcc := range attributes.Bcc {
+		form.Add("bcc", bcc)
+	}
+	form.Set("subject", attributes.Subject)
+	form.Set("text", attributes.PlainText())
+	form.Set("html", attributes.HtmlContent)
+	if attributes.ReplyTo != "" {
+		form.Set("h:Reply-To", attributes.ReplyTo)
+	}
+	return form
+}
+
+// encodeBody encodes the message as application/x-www-form-urlencoded, or as
+// multipart/form-data when files have to be uploaded.
+func encodeBody(form url.Values, attachments []mail.Attachment) (io.Reader, string, error) {
+	if len(attachments) == 0 {
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for key, values := range form {
+		for _, value := range values {
+			if err := writer.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for _, attachment := range attachments {
+		content, err := base64.StdEncoding.DecodeString(attachment.Content)
+		if err != nil {
+			return nil, "", fmt.Errorf("decoding attachment %q: %w", attachment.Filename, err)
+		}
+		// Mailgun uses the file name of an inline file as its content id
+		field, filename := "attachment", attachment.Filename
+		if attachment.IsInline() {
+			field, filename = "inline", attachment.ContentID
+		}
+		part, err := writer.CreatePart(fileHeader(field, filename, attachment.ContentType))
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, writer.FormDataContentType(), nil
+}
+
+// fileHeader returns the part header of an uploaded file
+func fileHeader(field, filename, contentType string) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Disposition": {fmt.Sprintf(`form-data; name=%q; filename=%q`, field, filename)},
+		"Content-Type":        {contentType},
+	}
+}
+
+func (service *MailgunService) sendRequest(ctx context.Context, attributes mail.MailAttributes) error {
+	body, contentType, err := encodeBody(service.createForm(attributes), attributes.Attachments)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	endpoint := service.config.baseURL() + "/v3/" + url.PathEscape(service.config.Domain) + "/messages"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.SetBasicAuth("api", service.config.APIKey)
+
+	slog.Info("mailgun: sending request to Mailgun API")
+
+	resp, err := service.client.Do(req)
+	if err != nil {
+		return mail.NewNetworkError(providerName, fmt.Errorf("failed to send request: %w", err))
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return mail.NewNetworkError(providerName, fmt.Errorf("failed to read response body: %w", err))
+	}
+
+	slog.Info("mailgun: received response", "status_code", resp.StatusCode)
+
+	var mailgunResp mailgunResponse
+	parseErr := json.Unmarshal(respBody, &mailgunResp)
+
+	if resp.StatusCode != http.StatusOK {
+		message := string(respBody)
+		if parseErr == nil && mailgunResp.Message != "" {
+			message = mailgunResp.Message
+		}
+		return mail.NewStatusError(providerName, resp.StatusCode, resp.Header.Get("Retry-After"),
+			fmt.Errorf("mailgun API returned status %d: %s", resp.StatusCode, message))
+	}
+	if parseErr != nil {
+		return fmt.Errorf("failed to parse response: %w", parseErr)
+	}
+
+	mail.RecordDelivery(ctx, providerName, mailgunResp.ID)
+	return nil
+}
@@ -0,0 +1,217 @@
+package mailgun
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// redirectTransport sends every request to target instead of the Mailgun API.
+type redirectTransport struct {
+	target string
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(rt.target)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newTestService(serverURL string) *MailgunService {
+	return NewMailgunService(
+		NewMailgunConfig("key-123", "mg.example.com", RegionEU, "sender@example.com", "Sender", ""),
+		&http.Client{Transport: redirectTransport{target: serverURL}},
+	)
+}
+
+func TestMailgunService_SendMail(t *testing.T) {
+	var gotHost, gotPath, gotUser, gotPassword, gotContentType string
+	var gotForm url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+		gotPath = r.URL.Path
+		gotUser, gotPassword, _ = r.BasicAuth()
+		gotContentType = r.Header.Get("Content-Type")
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() error = %v", err)
+		}
+		gotForm = r.PostForm
+		_, _ = w.Write([]byte(`{"id":"<20240101.1@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer server.Close()
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	err := newTestService(server.URL).SendMail(ctx, mail.MailAttributes{
+		To:          "a@example.com, b@example.com",
+		Cc:          []string{"c@example.com"},
+		Bcc:         []string{"d@example.com"},
+		ReplyTo:     "support@example.com",
+		Subject:     "Test Subject",
+		HtmlContent: "<p>Test Content</p>",
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	if gotHost != "api.eu.mailgun.net" {
+		t.Errorf("host = %q, want the EU region", gotHost)
+	}
+	if gotPath != "/v3/mg.example.com/messages" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotUser != "api" || gotPassword != "key-123" {
+		t.Errorf("basic auth = %q:%q, want api:key-123", gotUser, gotPassword)
+	}
+	if gotContentType != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type = %q", gotContentType)
+	}
+	wantForm := url.Values{
+		"from":       {`"Sender" <sender@example.com>`},
+		"to":         {"a@example.com", "b@example.com"},
+		"cc":         {"c@example.com"},
+		"bcc":        {"d@example.com"},
+		"subject":    {"Test Subject"},
+		"text":       {"Test Content"},
+		"html":       {"<p>Test Content</p>"},
+		"h:Reply-To": {"support@example.com"},
+	}
+	if !reflect.DeepEqual(gotForm, wantForm) {
+		t.Errorf("form = %v, want %v", gotForm, wantForm)
+	}
+	if want := []string{"<20240101.1@mg.example.com>"}; !reflect.DeepEqual(delivery.MessageIDs, want) {
+		t.Errorf("MessageIDs = %v, want %v", delivery.MessageIDs, want)
+	}
+}
+
+func TestMailgunService_SendMail_Attachments(t *testing.T) {
+	files := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm() error = %v", err)
+		}
+		if got := r.FormValue("subject"); got != "Invoice" {
+			t.Errorf("subject = %q", got)
+		}
+		for field, headers := range r.MultipartForm.File {
+			for _, header := range headers {
+				f, _ := header.Open()
+				content, _ := io.ReadAll(f)
+				files[field+"/"+header.Filename+"/"+header.Header.Get("Content-Type")] = string(content)
+			}
+		}
+		_, _ = w.Write([]byte(`{"id":"<id@mg.example.com>"}`))
+	}))
+	defer server.Close()
+
+	err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{
+		To:          "a@example.com",
+		Subject:     "Invoice",
+		HtmlContent: `<img src="cid:logo">`,
+		Attachments: []mail.Attachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Content: base64.StdEncoding.EncodeToString([]byte("%PDF"))},
+			{Filename: "logo.png", ContentType: "image/png", Content: base64.StdEncoding.EncodeToString([]byte("png")), ContentID: "logo"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	want := map[string]string{
+		"attachment/invoice.pdf/application/pdf": "%PDF",
+		"inline/logo/image/png":                  "png",
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+}
+
+func TestMailgunService_SendMail_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name           string
+		statusCode     int
+		retryAfter     string
+		body           string
+		wantTransient  bool
+		wantRetryAfter time.Duration
+		wantMessage    string
+	}{
+		{
+			name:        "invalid recipient",
+			statusCode:  http.StatusBadRequest,
+			body:        `{"message":"to parameter is not a valid address"}`,
+			wantMessage: "to parameter is not a valid address",
+		},
+		{
+			name:        "forbidden",
+			statusCode:  http.StatusUnauthorized,
+			body:        "Forbidden",
+			wantMessage: "Forbidden",
+		},
+		{
+			name:           "rate limited",
+			statusCode:     http.StatusTooManyRequests,
+			retryAfter:     "3",
+			wantTransient:  true,
+			wantRetryAfter: 3 * time.Second,
+		},
+		{
+			name:          "server error",
+			statusCode:    http.StatusInternalServerError,
+			wantTransient: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+			if err == nil {
+				t.Fatal("SendMail() expected error")
+			}
+			if mail.IsTransient(err) != tt.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v (error: %v)", mail.IsTransient(err), tt.wantTransient, err)
+			}
+			if mail.IsPermanent(err) == tt.wantTransient {
+				t.Errorf("IsPermanent() = %v, want %v", mail.IsPermanent(err), !tt.wantTransient)
+			}
+			if got := mail.RetryAfter(err); got != tt.wantRetryAfter {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.wantRetryAfter)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestMailgunService_SendMail_NetworkErrorIsTransient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+	if !mail.IsTransient(err) {
+		t.Errorf("IsTransient() = false, want true for error %v", err)
+	}
+}
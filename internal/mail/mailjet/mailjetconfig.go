@@ -1,19 +1,36 @@
 package mailjet
 
+import "strings"
+
+// DefaultBaseURL is the public Mailjet API. Accounts in the EU region can
+// use https://api.eu.mailjet.com instead.
+const DefaultBaseURL = "https://api.mailjet.com"
+
 // MailjetConfig contains all attributes to initialize the Mailjet mail service.
 type MailjetConfig struct {
 	APIKeyPublic  string
 	APIKeyPrivate string
 	OriginAddress string
 	OriginName    string
+	// BaseURL overrides DefaultBaseURL when set.
+	BaseURL string
 }
 
 // NewMailjetConfig creates a MailjetConfig from the provided credentials and sender identity.
-func NewMailjetConfig(apiKeyPublic, apiKeyPrivate, originAddress, originName string) *MailjetConfig {
+func NewMailjetConfig(apiKeyPublic, apiKeyPrivate, originAddress, originName, baseURL string) *MailjetConfig {
 	return &MailjetConfig{
 		APIKeyPublic:  apiKeyPublic,
 		APIKeyPrivate: apiKeyPrivate,
 		OriginAddress: originAddress,
 		OriginName:    originName,
+		BaseURL:       baseURL,
+	}
+}
+
+// baseURL returns the API base URL without a trailing slash.
+func (config *MailjetConfig) baseURL() string {
+	if config.BaseURL == "" {
+		return DefaultBaseURL
 	}
+	return strings.TrimSuffix(config.BaseURL, "/")
 }
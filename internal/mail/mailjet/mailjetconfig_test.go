@@ -5,7 +5,7 @@ import (
 )
 
 func TestNewMailjetConfig(t *testing.T) {
-	cfg := NewMailjetConfig("pub-key", "priv-key", "sender@example.com", "Sender Name")
+	cfg := NewMailjetConfig("pub-key", "priv-key", "sender@example.com", "Sender Name", "https://api.eu.mailjet.com")
 
 	if cfg.APIKeyPublic != "pub-key" {
 		t.Errorf("APIKeyPublic = %q, want %q", cfg.APIKeyPublic, "pub-key")
@@ -19,4 +19,24 @@ func TestNewMailjetConfig(t *testing.T) {
 	if cfg.OriginName != "Sender Name" {
 		t.Errorf("OriginName = %q, want %q", cfg.OriginName, "Sender Name")
 	}
+	if cfg.BaseURL != "https://api.eu.mailjet.com" {
+		t.Errorf("BaseURL = %q, want %q", cfg.BaseURL, "https://api.eu.mailjet.com")
+	}
+}
+
+func TestMailjetConfig_baseURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{baseURL: "", want: DefaultBaseURL},
+		{baseURL: "https://api.eu.mailjet.com", want: "https://api.eu.mailjet.com"},
+		{baseURL: "http://localhost:8081/", want: "http://localhost:8081"},
+	}
+	for _, tt := range tests {
+		cfg := MailjetConfig{BaseURL: tt.baseURL}
+		if got := cfg.baseURL(); got != tt.want {
+			t.Errorf("baseURL() with BaseURL %q = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
 }
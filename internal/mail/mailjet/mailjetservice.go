@@ -14,17 +14,23 @@ import (
 	"github.com/jo-hoe/go-mail-service/internal/mail"
 )
 
+// providerName identifies Mailjet in delivery records and errors
+const providerName = "mailjet"
+
+// maxMessagesPerRequest is the most messages Mailjet accepts in one send request
+const maxMessagesPerRequest = 50
+
 // MailjetService implements MailService
 type MailjetService struct {
 	config *MailjetConfig
 	client *http.Client
 }
 
-// NewMailjetService creates a MailjetService
-func NewMailjetService(config *MailjetConfig) *MailjetService {
+// NewMailjetService creates a MailjetService that sends its requests with client
+func NewMailjetService(config *MailjetConfig, client *http.Client) *MailjetService {
 	return &MailjetService{
 		config: config,
-		client: &http.Client{},
+		client: client,
 	}
 }
 
@@ -32,9 +38,23 @@ func NewMailjetService(config *MailjetConfig) *MailjetService {
 type mailjetMessage struct {
 	From     mailjetEmail   `json:"From"`
 	To       []mailjetEmail `json:"To"`
+	Cc       []mailjetEmail `json:"Cc,omitempty"`
+	Bcc      []mailjetEmail `json:"Bcc,omitempty"`
+	ReplyTo  *mailjetEmail  `json:"ReplyTo,omitempty"`
 	Subject  string         `json:"Subject"`
 	TextPart string         `json:"TextPart,omitempty"`
 	HTMLPart string         `json:"HTMLPart,omitempty"`
+
+	Attachments        []mailjetAttachment `json:"Attachments,omitempty"`
+	InlinedAttachments []mailjetAttachment `json:"InlinedAttachments,omitempty"`
+}
+
+// mailjetAttachment represents a regular or inlined attachment
+type mailjetAttachment struct {
+	ContentType   string `json:"ContentType"`
+	Filename      string `json:"Filename"`
+	Base64Content string `json:"Base64Content"`
+	ContentID     string `json:"ContentID,omitempty"`
 }
 
 // mailjetEmail represents an email address with optional name
@@ -94,48 +114,157 @@ func (service *MailjetService) SendMail(ctx context.Context, attributes mail.Mai
 
 // createMessage creates a Mailjet message from mail attributes
 func (service *MailjetService) createMessage(attributes mail.MailAttributes) mailjetMessage {
+	address, name := attributes.Sender(service.config.OriginAddress, service.config.OriginName)
 	from := mailjetEmail{
-		Email: service.config.OriginAddress,
-		Name:  service.config.OriginName,
-	}
-
-	// Parse recipients (comma-separated)
-	toEmails := []mailjetEmail{}
-	mailAddresses := strings.Split(attributes.To, ",")
-	for _, mailAddress := range mailAddresses {
-		mailAddress = strings.TrimSpace(mailAddress)
-		if mailAddress != "" {
-			toEmails = append(toEmails, mailjetEmail{
-				Email: mailAddress,
-			})
-		}
+		Email: address,
+		Name:  name,
 	}
 
-	return mailjetMessage{
+	message := mailjetMessage{
 		From:     from,
-		To:       toEmails,
+		To:       toMailjetEmails(attributes.ToAddresses()),
+		Cc:       toMailjetEmails(attributes.Cc),
+		Bcc:      toMailjetEmails(attributes.Bcc),
 		Subject:  attributes.Subject,
+		TextPart: attributes.PlainText(),
 		HTMLPart: attributes.HtmlContent,
 	}
+
+	if attributes.ReplyTo != "" {
+		message.ReplyTo = &mailjetEmail{Email: attributes.ReplyTo}
+	}
+
+	for _, attachment := range attributes.Attachments {
+		mailjetAttachment := mailjetAttachment{
+			ContentType:   attachment.ContentType,
+			Filename:      attachment.Filename,
+			Base64Content: attachment.Content,
+		}
+		if attachment.IsInline() {
+			mailjetAttachment.ContentID = attachment.ContentID
+			message.InlinedAttachments = append(message.InlinedAttachments, mailjetAttachment)
+		} else {
+			message.Attachments = append(message.Attachments, mailjetAttachment)
+		}
+	}
+
+	return message
+}
+
+// toMailjetEmails converts plain addresses into Mailjet recipients, skipping blanks
+func toMailjetEmails(addresses []string) []mailjetEmail {
+	var emails []mailjetEmail
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if address != "" {
+			emails = append(emails, mailjetEmail{Email: address})
+		}
+	}
+	return emails
 }
 
 func (service *MailjetService) sendRequest(ctx context.Context, message mailjetMessage) error {
+	response, err := service.post(ctx, []mailjetMessage{message})
+	if err != nil {
+		return err
+	}
+
+	result := messageResult(response.Messages, 0)
+	if result.Err != nil {
+		return result.Err
+	}
+	mail.RecordDelivery(ctx, providerName, result.Delivery.MessageIDs...)
+	return nil
+}
+
+// SendBatch sends the mails as messages of as few requests as possible.
+// Mailjet accepts or rejects each message on its own.
+func (service *MailjetService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	slog.Info("mailjet: preparing to send batch", "mails", len(mails))
+
+	results := make([]mail.BatchResult, len(mails))
+	for start := 0; start < len(mails); start += maxMessagesPerRequest {
+		end := min(start+maxMessagesPerRequest, len(mails))
+		messages := make([]mailjetMessage, 0, end-start)
+		for _, attributes := range mails[start:end] {
+			messages = append(messages, service.createMessage(attributes))
+		}
+
+		response, err := service.post(ctx, messages)
+		for i := range messages {
+			switch {
+			case err != nil && response == nil:
+				results[start+i].Err = err
+			case err != nil && response.Messages[i].Status != "success":
+				// the response of a rejected request only names the errors of some messages
+				if results[start+i] = messageResult(response.Messages, i); results[start+i].Err == nil {
+					results[start+i].Err = err
+				}
+			default:
+				results[start+i] = messageResult(response.Messages, i)
+			}
+		}
+	}
+	return results
+}
+
+// messageResult returns the outcome of the i-th message of a request. A
+// message the response has no entry for is not known to be sent, so it fails
+// with a transient error and is retried.
+func messageResult(messages []mailjetMessageResponse, i int) mail.BatchResult {
+	if i >= len(messages) {
+		slog.Error("mailjet: response has no status for message", "message", i, "statuses", len(messages))
+		err := fmt.Errorf("mailjet response has no status for message %d of the request", i+1)
+		return mail.BatchResult{Err: &mail.ProviderError{Provider: providerName, Transient: true, Err: err}}
+	}
+
+	msg := messages[i]
+	slog.Info("mailjet: response status", "status", msg.Status)
+
+	// Log recipient count and message IDs if available
+	var messageIDs []string
+	if len(msg.To) > 0 {
+		slog.Info("mailjet: message sent", "recipients", len(msg.To))
+		for _, recipient := range msg.To {
+			slog.Debug("mailjet: message meta",
+				"message_id", recipient.MessageID,
+				"message_uuid", recipient.MessageUUID)
+			messageIDs = append(messageIDs, recipient.MessageUUID)
+		}
+	}
+
+	if msg.Status == "error" && len(msg.Errors) > 0 {
+		firstError := msg.Errors[0]
+		slog.Error("mailjet: error",
+			"identifier", firstError.ErrorIdentifier,
+			"code", firstError.ErrorCode,
+			"message", firstError.ErrorMessage)
+		return mail.BatchResult{Err: mail.NewStatusError(providerName, firstError.StatusCode, "",
+			fmt.Errorf("mailjet error [%s]: %s", firstError.ErrorCode, firstError.ErrorMessage))}
+	}
+	return mail.BatchResult{Delivery: mail.Delivery{Provider: providerName, MessageIDs: messageIDs}}
+}
+
+// post sends messages in a single request. If the request is rejected, it
+// returns the error together with the response if that has a status for
+// every message.
+func (service *MailjetService) post(ctx context.Context, messages []mailjetMessage) (*mailjetResponse, error) {
 	// Create request payload
 	payload := mailjetRequest{
-		Messages: []mailjetMessage{message},
+		Messages: messages,
 	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		slog.Error("mailjet: failed to marshal JSON", "error", err)
-		return fmt.Errorf("failed to marshal JSON: %w", err)
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.mailjet.com/v3.1/send", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", service.config.baseURL()+"/v3.1/send", bytes.NewBuffer(jsonData))
 	if err != nil {
 		slog.Error("mailjet: failed to create request", "error", err)
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
@@ -145,13 +274,13 @@ func (service *MailjetService) sendRequest(ctx context.Context, message mailjetM
 	auth := base64.StdEncoding.EncodeToString([]byte(service.config.APIKeyPublic + ":" + service.config.APIKeyPrivate))
 	req.Header.Set("Authorization", "Basic "+auth)
 
-	slog.Info("mailjet: sending request to Mailjet API")
+	slog.Info("mailjet: sending request to Mailjet API", "messages", len(messages))
 
 	// Send request
 	resp, err := service.client.Do(req)
 	if err != nil {
 		slog.Error("mailjet: request error", "error", err)
-		return fmt.Errorf("failed to send request: %w", err)
+		return nil, mail.NewNetworkError(providerName, fmt.Errorf("failed to send request: %w", err))
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -161,7 +290,7 @@ func (service *MailjetService) sendRequest(ctx context.Context, message mailjetM
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		slog.Error("mailjet: failed to read response body", "error", err)
-		return fmt.Errorf("failed to read response body: %w", err)
+		return nil, mail.NewNetworkError(providerName, fmt.Errorf("failed to read response body: %w", err))
 	}
 
 	slog.Info("mailjet: received response", "status_code", resp.StatusCode)
@@ -169,40 +298,21 @@ func (service *MailjetService) sendRequest(ctx context.Context, message mailjetM
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		slog.Error("mailjet: API error", "status_code", resp.StatusCode, "body", string(body))
-		return fmt.Errorf("mailjet API returned status %d: %s", resp.StatusCode, string(body))
+		statusErr := mail.NewStatusError(providerName, resp.StatusCode, resp.Header.Get("Retry-After"),
+			fmt.Errorf("mailjet API returned status %d: %s", resp.StatusCode, string(body)))
+
+		var mailjetResp mailjetResponse
+		if json.Unmarshal(body, &mailjetResp) != nil || len(mailjetResp.Messages) != len(messages) {
+			return nil, statusErr
+		}
+		return &mailjetResp, statusErr
 	}
 
 	// Parse response
 	var mailjetResp mailjetResponse
 	if err := json.Unmarshal(body, &mailjetResp); err != nil {
 		slog.Error("mailjet: failed to parse response", "error", err)
-		return fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	// Check for errors in response
-	if len(mailjetResp.Messages) > 0 {
-		msg := mailjetResp.Messages[0]
-		slog.Info("mailjet: response status", "status", msg.Status)
-
-		// Log recipient count and message IDs if available
-		if len(msg.To) > 0 {
-			slog.Info("mailjet: message sent", "recipients", len(msg.To))
-			for _, recipient := range msg.To {
-				slog.Debug("mailjet: message meta",
-					"message_id", recipient.MessageID,
-					"message_uuid", recipient.MessageUUID)
-			}
-		}
-
-		if msg.Status == "error" && len(msg.Errors) > 0 {
-			firstError := msg.Errors[0]
-			slog.Error("mailjet: error",
-				"identifier", firstError.ErrorIdentifier,
-				"code", firstError.ErrorCode,
-				"message", firstError.ErrorMessage)
-			return fmt.Errorf("mailjet error [%s]: %s", firstError.ErrorCode, firstError.ErrorMessage)
-		}
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
-
-	return nil
+	return &mailjetResp, nil
 }
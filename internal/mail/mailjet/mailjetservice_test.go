@@ -1,12 +1,18 @@
 package mailjet
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/fakeesp"
 )
 
 func TestMailjetService_SendMail(t *testing.T) {
@@ -222,7 +228,7 @@ func TestMailjetService_createMessage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewMailjetService(config)
+			service := NewMailjetService(config, http.DefaultClient)
 			message := service.createMessage(tt.attributes)
 
 			if len(message.To) != tt.expectedToLen {
@@ -249,3 +255,378 @@ func TestMailjetService_createMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestMailjetService_createMessage_CcBccReplyTo(t *testing.T) {
+	service := NewMailjetService(&MailjetConfig{
+		OriginAddress: "sender@example.com",
+		OriginName:    "Test Sender",
+	}, http.DefaultClient)
+
+	message := service.createMessage(mail.MailAttributes{
+		To:          "to@example.com",
+		Cc:          []string{"manager@example.com", " "},
+		Bcc:         []string{"audit@example.com"},
+		ReplyTo:     "support@example.com",
+		Subject:     "Test",
+		HtmlContent: "<p>Content</p>",
+	})
+
+	if len(message.Cc) != 1 || message.Cc[0].Email != "manager@example.com" {
+		t.Errorf("Expected Cc [manager@example.com], got %v", message.Cc)
+	}
+	if len(message.Bcc) != 1 || message.Bcc[0].Email != "audit@example.com" {
+		t.Errorf("Expected Bcc [audit@example.com], got %v", message.Bcc)
+	}
+	if message.ReplyTo == nil || message.ReplyTo.Email != "support@example.com" {
+		t.Errorf("Expected ReplyTo support@example.com, got %v", message.ReplyTo)
+	}
+}
+
+func TestMailjetService_createMessage_OmitsEmptyOptionalRecipients(t *testing.T) {
+	service := NewMailjetService(&MailjetConfig{OriginAddress: "sender@example.com"}, http.DefaultClient)
+
+	message := service.createMessage(mail.MailAttributes{
+		To:          "to@example.com",
+		Subject:     "Test",
+		HtmlContent: "<p>Content</p>",
+	})
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	for _, key := range []string{`"Cc"`, `"Bcc"`, `"ReplyTo"`} {
+		if strings.Contains(string(payload), key) {
+			t.Errorf("Expected %s to be omitted, got %s", key, payload)
+		}
+	}
+}
+
+func TestMailjetService_createMessage_Attachments(t *testing.T) {
+	service := NewMailjetService(&MailjetConfig{OriginAddress: "sender@example.com"}, http.DefaultClient)
+
+	message := service.createMessage(mail.MailAttributes{
+		To:          "to@example.com",
+		Subject:     "Invoice",
+		HtmlContent: `<p>See attached</p><img src="cid:logo">`,
+		Attachments: []mail.Attachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Content: "JVBERi0xLjQ="},
+			{Filename: "logo.png", ContentType: "image/png", Content: "iVBORw0KGgo=", ContentID: "logo"},
+		},
+	})
+
+	if len(message.Attachments) != 1 {
+		t.Fatalf("Expected 1 attachment, got %d", len(message.Attachments))
+	}
+	if message.Attachments[0].Filename != "invoice.pdf" || message.Attachments[0].Base64Content != "JVBERi0xLjQ=" {
+		t.Errorf("Unexpected attachment %+v", message.Attachments[0])
+	}
+	if len(message.InlinedAttachments) != 1 {
+		t.Fatalf("Expected 1 inlined attachment, got %d", len(message.InlinedAttachments))
+	}
+	if message.InlinedAttachments[0].ContentID != "logo" {
+		t.Errorf("Expected inlined ContentID logo, got %s", message.InlinedAttachments[0].ContentID)
+	}
+}
+
+func TestMailjetService_createMessage_TextPart(t *testing.T) {
+	service := NewMailjetService(&MailjetConfig{OriginAddress: "sender@example.com"}, http.DefaultClient)
+
+	generated := service.createMessage(mail.MailAttributes{
+		To:          "to@example.com",
+		Subject:     "Test",
+		HtmlContent: "<p>Hello <b>World</b></p>",
+	})
+	if generated.TextPart != "Hello World" {
+		t.Errorf("Expected generated TextPart %q, got %q", "Hello World", generated.TextPart)
+	}
+
+	explicit := service.createMessage(mail.MailAttributes{
+		To:          "to@example.com",
+		Subject:     "Test",
+		HtmlContent: "<p>Hello</p>",
+		TextContent: "Hello in plain text",
+	})
+	if explicit.TextPart != "Hello in plain text" {
+		t.Errorf("Expected TextPart %q, got %q", "Hello in plain text", explicit.TextPart)
+	}
+}
+
+func TestMailjetService_createMessage_PerRequestSender(t *testing.T) {
+	service := NewMailjetService(&MailjetConfig{
+		OriginAddress: "sender@example.com",
+		OriginName:    "Test Sender",
+	}, http.DefaultClient)
+
+	message := service.createMessage(mail.MailAttributes{
+		To:          "to@example.com",
+		Subject:     "Test",
+		HtmlContent: "<p>Content</p>",
+		From:        "billing@example.com",
+		FromName:    "Billing",
+	})
+
+	if message.From.Email != "billing@example.com" {
+		t.Errorf("Expected from email billing@example.com, got %s", message.From.Email)
+	}
+	if message.From.Name != "Billing" {
+		t.Errorf("Expected from name Billing, got %s", message.From.Name)
+	}
+}
+
+func TestMailjetService_SendMail_RecordsMessageUUIDs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(mailjetResponse{
+			Messages: []mailjetMessageResponse{{
+				Status: "success",
+				To: []mailjetRecipientResponse{
+					{Email: "a@example.com", MessageUUID: "uuid-a"},
+					{Email: "b@example.com", MessageUUID: "uuid-b"},
+				},
+			}},
+		})
+	}))
+	defer server.Close()
+
+	service := NewMailjetService(NewMailjetConfig("public", "private", "sender@example.com", "", server.URL), http.DefaultClient)
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	err := service.SendMail(ctx, mail.MailAttributes{To: "a@example.com,b@example.com", Subject: "s", HtmlContent: "c"})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	if delivery.Provider != "mailjet" {
+		t.Errorf("Provider = %q, want mailjet", delivery.Provider)
+	}
+	if want := []string{"uuid-a", "uuid-b"}; !reflect.DeepEqual(delivery.MessageIDs, want) {
+		t.Errorf("MessageIDs = %v, want %v", delivery.MessageIDs, want)
+	}
+}
+
+func TestMailjetService_SendMail_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name           string
+		statusCode     int
+		retryAfter     string
+		body           string
+		wantTransient  bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:          "validation error",
+			statusCode:    http.StatusBadRequest,
+			body:          `{"Messages":[{"Status":"error","Errors":[{"ErrorCode":"mj-0013","StatusCode":400,"ErrorMessage":"invalid email"}]}]}`,
+			wantTransient: false,
+		},
+		{
+			name:          "unauthorized",
+			statusCode:    http.StatusUnauthorized,
+			wantTransient: false,
+		},
+		{
+			name:           "rate limited",
+			statusCode:     http.StatusTooManyRequests,
+			retryAfter:     "5",
+			wantTransient:  true,
+			wantRetryAfter: 5 * time.Second,
+		},
+		{
+			name:          "server error",
+			statusCode:    http.StatusServiceUnavailable,
+			wantTransient: true,
+		},
+		{
+			name:          "message error in successful response",
+			statusCode:    http.StatusOK,
+			body:          `{"Messages":[{"Status":"error","Errors":[{"ErrorCode":"send-0008","StatusCode":403,"ErrorMessage":"sender not allowed"}]}]}`,
+			wantTransient: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			service := NewMailjetService(NewMailjetConfig("public", "private", "sender@example.com", "", server.URL), http.DefaultClient)
+
+			err := service.SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+			if err == nil {
+				t.Fatal("SendMail() expected error")
+			}
+			if mail.IsTransient(err) != tt.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v (error: %v)", mail.IsTransient(err), tt.wantTransient, err)
+			}
+			if mail.IsPermanent(err) == tt.wantTransient {
+				t.Errorf("IsPermanent() = %v, want %v", mail.IsPermanent(err), !tt.wantTransient)
+			}
+			if got := mail.RetryAfter(err); got != tt.wantRetryAfter {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestMailjetService_SendMail_NetworkErrorIsTransient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	service := NewMailjetService(NewMailjetConfig("public", "private", "sender@example.com", "", server.URL), http.DefaultClient)
+
+	err := service.SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+	if !mail.IsTransient(err) {
+		t.Errorf("IsTransient() = false, want true for error %v", err)
+	}
+}
+
+func TestMailjetService_SendMail_FakeServer(t *testing.T) {
+	server := fakeesp.NewMailjetServer("public", "private")
+	defer server.Close()
+
+	// a trailing slash must not produce a double slash in the request path
+	config := NewMailjetConfig("public", "private", "sender@example.com", "Sender", server.URL()+"/")
+	service := NewMailjetService(config, http.DefaultClient)
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	err := service.SendMail(ctx, mail.MailAttributes{
+		To:          "a@example.com",
+		Cc:          []string{"c@example.com"},
+		Subject:     "Hello",
+		HtmlContent: "<p>Hi</p>",
+		Attachments: []mail.Attachment{{Filename: "a.txt", ContentType: "text/plain", Content: "aGk="}},
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("fake received %d messages, want 1", len(messages))
+	}
+	got := messages[0]
+	if got.From != (fakeesp.MailjetAddress{Email: "sender@example.com", Name: "Sender"}) {
+		t.Errorf("From = %+v", got.From)
+	}
+	if len(got.To) != 1 || len(got.Cc) != 1 || len(got.Attachments) != 1 || got.TextPart != "Hi" {
+		t.Errorf("message = %+v", got)
+	}
+	if want := []string{"mailjet-1"}; !reflect.DeepEqual(delivery.MessageIDs, want) {
+		t.Errorf("MessageIDs = %v, want %v", delivery.MessageIDs, want)
+	}
+}
+
+func TestMailjetService_SendMail_FakeServerErrors(t *testing.T) {
+	server := fakeesp.NewMailjetServer("public", "private")
+	defer server.Close()
+
+	server.FailNext(fakeesp.MailjetErrors(http.StatusBadRequest, fakeesp.MailjetError{
+		ErrorCode:    "mj-0013",
+		StatusCode:   http.StatusBadRequest,
+		ErrorMessage: "\"invalid\" is an invalid email address.",
+	}))
+
+	config := NewMailjetConfig("public", "private", "sender@example.com", "", server.URL())
+	service := NewMailjetService(config, http.DefaultClient)
+
+	err := service.SendMail(context.Background(), mail.MailAttributes{To: "invalid", Subject: "s", HtmlContent: "c"})
+	if !mail.IsPermanent(err) {
+		t.Errorf("IsPermanent() = false, want true for error %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "mj-0013") {
+		t.Errorf("error = %v, want the Mailjet error code", err)
+	}
+
+	config.APIKeyPrivate = "wrong"
+	err = service.SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+	if !mail.IsPermanent(err) {
+		t.Errorf("IsPermanent() = false, want true for rejected credentials: %v", err)
+	}
+}
+
+func TestMailjetService_SendBatch_FakeServer(t *testing.T) {
+	server := fakeesp.NewMailjetServer("public", "private")
+	defer server.Close()
+	service := NewMailjetService(NewMailjetConfig("public", "private", "sender@example.com", "", server.URL()), http.DefaultClient)
+
+	mails := make([]mail.MailAttributes, maxMessagesPerRequest+1)
+	for i := range mails {
+		mails[i] = mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"}
+	}
+	results := service.SendBatch(context.Background(), mails)
+
+	requests := server.Requests()
+	if len(requests) != 2 || len(requests[0].Messages) != maxMessagesPerRequest || len(requests[1].Messages) != 1 {
+		t.Fatalf("fake received %d requests, want %d messages and 1", len(requests), maxMessagesPerRequest)
+	}
+	for i, result := range results {
+		if want := fmt.Sprintf("mailjet-%d", i+1); result.Err != nil || result.Delivery.Provider != "mailjet" || !reflect.DeepEqual(result.Delivery.MessageIDs, []string{want}) {
+			t.Errorf("results[%d] = %+v, want delivered as %s", i, result, want)
+		}
+	}
+}
+
+func TestMailjetService_SendBatch_PartialFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"Messages":[` +
+			`{"Status":"success","To":[{"Email":"a@example.com","MessageUUID":"uuid-a"}]},` +
+			`{"Status":"error","Errors":[{"ErrorCode":"mj-0013","StatusCode":400,"ErrorMessage":"invalid email"}]}]}`))
+	}))
+	defer server.Close()
+	service := NewMailjetService(NewMailjetConfig("public", "private", "sender@example.com", "", server.URL), http.DefaultClient)
+
+	results := service.SendBatch(context.Background(), []mail.MailAttributes{
+		{To: "a@example.com", Subject: "s", HtmlContent: "c"},
+		{To: "invalid", Subject: "s", HtmlContent: "c"},
+	})
+	if results[0].Err != nil || !reflect.DeepEqual(results[0].Delivery.MessageIDs, []string{"uuid-a"}) {
+		t.Errorf("results[0] = %+v, want delivered", results[0])
+	}
+	if !mail.IsPermanent(results[1].Err) || !strings.Contains(results[1].Err.Error(), "mj-0013") {
+		t.Errorf("results[1] error = %v, want the permanent Mailjet error", results[1].Err)
+	}
+}
+
+func TestMailjetService_SendBatch_RejectedRequest(t *testing.T) {
+	server := fakeesp.NewMailjetServer("public", "private")
+	defer server.Close()
+	server.FailNext(fakeesp.TooManyRequests("5"))
+	service := NewMailjetService(NewMailjetConfig("public", "private", "sender@example.com", "", server.URL()), http.DefaultClient)
+
+	results := service.SendBatch(context.Background(), []mail.MailAttributes{
+		{To: "a@example.com", Subject: "s", HtmlContent: "c"},
+		{To: "b@example.com", Subject: "s", HtmlContent: "c"},
+	})
+	for i, result := range results {
+		if !mail.IsTransient(result.Err) || mail.RetryAfter(result.Err) != 5*time.Second {
+			t.Errorf("results[%d] error = %v, want the transient rate limit", i, result.Err)
+		}
+	}
+}
+
+func TestMailjetService_SendBatch_ShortResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Messages":[{"Status":"success","To":[{"Email":"a@example.com","MessageUUID":"uuid-a"}]}]}`))
+	}))
+	defer server.Close()
+	service := NewMailjetService(NewMailjetConfig("public", "private", "sender@example.com", "", server.URL), http.DefaultClient)
+
+	results := service.SendBatch(context.Background(), []mail.MailAttributes{
+		{To: "a@example.com", Subject: "s", HtmlContent: "c"},
+		{To: "b@example.com", Subject: "s", HtmlContent: "c"},
+	})
+	if results[0].Err != nil || !reflect.DeepEqual(results[0].Delivery.MessageIDs, []string{"uuid-a"}) {
+		t.Errorf("results[0] = %+v, want delivered", results[0])
+	}
+	// a message without a status in the response is not known to be sent
+	if !mail.IsTransient(results[1].Err) {
+		t.Errorf("results[1] error = %v, want a transient error", results[1].Err)
+	}
+}
@@ -0,0 +1,272 @@
+// Package mimemessage renders MailAttributes as RFC 5322 messages for
+// providers that accept raw MIME, such as SMTP relays.
+package mimemessage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// base64LineLength is the maximum encoded line length required by RFC 2045.
+const base64LineLength = 76
+
+// ErrInvalidHeader is returned for an address, content type or content id
+// that cannot be written into a header, e.g. because it contains a line break.
+var ErrInvalidHeader = errors.New("invalid header value")
+
+// Options complete the headers that are not part of the MailAttributes.
+type Options struct {
+	FromAddress string
+	FromName    string
+	// MessageID is written without angle brackets, e.g. as returned by NewMessageID.
+	MessageID string
+	Date      time.Time
+}
+
+// part is a MIME entity that has not been written yet.
+type part struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// Build renders the mail as a MIME message. The text and HTML bodies form a
+// multipart/alternative, inline attachments are wrapped with it in a
+// multipart/related and regular attachments in a multipart/mixed.
+// Bcc recipients are left out of the headers.
+func Build(attributes mail.MailAttributes, opts Options) ([]byte, error) {
+	root, err := alternativePart(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	var inline, regular []part
+	for _, attachment := range attributes.Attachments {
+		p, err := attachmentPart(attachment)
+		if err != nil {
+			return nil, err
+		}
+		if attachment.IsInline() {
+			inline = append(inline, p)
+		} else {
+			regular = append(regular, p)
+		}
+	}
+	if len(inline) > 0 {
+		if root, err = multipartPart("related", append([]part{root}, inline...)); err != nil {
+			return nil, err
+		}
+	}
+	if len(regular) > 0 {
+		if root, err = multipartPart("mixed", append([]part{root}, regular...)); err != nil {
+			return nil, err
+		}
+	}
+
+	headers, err := Headers(attributes, opts)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, field := range headers {
+		writeHeader(&buf, field.Name, field.Value)
+	}
+	writeHeader(&buf, "MIME-Version", "1.0")
+	writeHeader(&buf, "Content-Type", root.header.Get("Content-Type"))
+	if encoding := root.header.Get("Content-Transfer-Encoding"); encoding != "" {
+		writeHeader(&buf, "Content-Transfer-Encoding", encoding)
+	}
+	buf.WriteString("\r\n")
+	buf.Write(root.body)
+
+	return buf.Bytes(), nil
+}
+
+// Field is a header field of a message.
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Headers returns the header fields that Build writes ahead of the MIME
+// headers, in order. Bcc recipients are left out. An address that cannot be
+// parsed is rejected with ErrInvalidHeader.
+func Headers(attributes mail.MailAttributes, opts Options) ([]Field, error) {
+	from, err := formatAddress(opts.FromAddress, opts.FromName)
+	if err != nil {
+		return nil, err
+	}
+	to, err := formatAddressList(attributes.ToAddresses())
+	if err != nil {
+		return nil, err
+	}
+	fields := []Field{{Name: "From", Value: from}, {Name: "To", Value: to}}
+	if len(attributes.Cc) > 0 {
+		cc, err := formatAddressList(attributes.Cc)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{Name: "Cc", Value: cc})
+	}
+	if attributes.ReplyTo != "" {
+		replyTo, err := formatAddressList([]string{attributes.ReplyTo})
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{Name: "Reply-To", Value: replyTo})
+	}
+	fields = append(fields,
+		Field{Name: "Subject", Value: mime.QEncoding.Encode("utf-8", attributes.Subject)},
+		Field{Name: "Date", Value: opts.Date.Format(time.RFC1123Z)},
+	)
+	if opts.MessageID != "" {
+		fields = append(fields, Field{Name: "Message-ID", Value: "<" + opts.MessageID + ">"})
+	}
+	return fields, nil
+}
+
+// NewMessageID returns a unique Message-ID in the given domain, without angle brackets.
+func NewMessageID(domain string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating message id: %w", err)
+	}
+	if domain == "" {
+		domain = "localhost"
+	}
+	return fmt.Sprintf("%x.%d@%s", b, time.Now().UnixNano(), domain), nil
+}
+
+func alternativePart(attributes mail.MailAttributes) (part, error) {
+	text, err := textPart("text/plain", attributes.PlainText())
+	if err != nil {
+		return part{}, err
+	}
+	html, err := textPart("text/html", attributes.HtmlContent)
+	if err != nil {
+		return part{}, err
+	}
+	return multipartPart("alternative", []part{text, html})
+}
+
+func textPart(contentType, content string) (part, error) {
+	var body bytes.Buffer
+	w := quotedprintable.NewWriter(&body)
+	if _, err := w.Write([]byte(content)); err != nil {
+		return part{}, fmt.Errorf("encoding %s part: %w", contentType, err)
+	}
+	if err := w.Close(); err != nil {
+		return part{}, fmt.Errorf("encoding %s part: %w", contentType, err)
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"}))
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return part{header: header, body: body.Bytes()}, nil
+}
+
+func attachmentPart(attachment mail.Attachment) (part, error) {
+	data, err := base64.StdEncoding.DecodeString(attachment.Content)
+	if err != nil {
+		return part{}, fmt.Errorf("attachment %q: invalid base64 content: %w", attachment.Filename, err)
+	}
+
+	// the content type is rebuilt from its parts, so nothing but it ends up in the header
+	mediaType, params, err := mime.ParseMediaType(attachment.ContentType)
+	contentType := mime.FormatMediaType(mediaType, params)
+	if err != nil || contentType == "" {
+		return part{}, fmt.Errorf("%w: attachment %q: content type %q", ErrInvalidHeader, attachment.Filename, attachment.ContentType)
+	}
+
+	disposition := "attachment"
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "base64")
+	if attachment.IsInline() {
+		if strings.ContainsAny(attachment.ContentID, "\r\n") {
+			return part{}, fmt.Errorf("%w: attachment %q: content id %q", ErrInvalidHeader, attachment.Filename, attachment.ContentID)
+		}
+		disposition = "inline"
+		header.Set("Content-ID", "<"+attachment.ContentID+">")
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+
+	return part{header: header, body: wrapBase64(data)}, nil
+}
+
+// multipartPart combines parts into a multipart entity of the given subtype.
+func multipartPart(subtype string, parts []part) (part, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, p := range parts {
+		pw, err := w.CreatePart(p.header)
+		if err != nil {
+			return part{}, err
+		}
+		if _, err := pw.Write(p.body); err != nil {
+			return part{}, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return part{}, err
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": w.Boundary()}))
+	return part{header: header, body: body.Bytes()}, nil
+}
+
+func wrapBase64(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > base64LineLength {
+		buf.WriteString(encoded[:base64LineLength])
+		buf.WriteString("\r\n")
+		encoded = encoded[base64LineLength:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString("\r\n")
+}
+
+// formatAddress formats address with the display name name. The name is
+// encoded if needed, the address must parse.
+func formatAddress(address, name string) (string, error) {
+	parsed, err := netmail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("%w: address %q: %w", ErrInvalidHeader, address, err)
+	}
+	return (&netmail.Address{Name: name, Address: parsed.Address}).String(), nil
+}
+
+// formatAddressList formats addresses that may carry display names. Unparsable
+// addresses are rejected, so that they cannot inject header fields.
+func formatAddressList(addresses []string) (string, error) {
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		parsed, err := netmail.ParseAddress(address)
+		if err != nil {
+			return "", fmt.Errorf("%w: address %q: %w", ErrInvalidHeader, address, err)
+		}
+		formatted = append(formatted, parsed.String())
+	}
+	return strings.Join(formatted, ", "), nil
+}
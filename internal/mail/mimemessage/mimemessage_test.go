@@ -0,0 +1,291 @@
+package mimemessage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+var testOptions = Options{
+	FromAddress: "sender@example.com",
+	FromName:    "Sender Näme",
+	MessageID:   "abc@example.com",
+	Date:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
+// leaf is a decoded non-multipart entity of a parsed message.
+type leaf struct {
+	contentType string
+	header      map[string][]string
+	body        string
+}
+
+// parse reads a built message back and returns its headers and leaf parts in order.
+func parse(t *testing.T, raw []byte) (*netmail.Message, []leaf) {
+	t.Helper()
+	msg, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	var leaves []leaf
+	collect(t, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Header, msg.Body, &leaves)
+	return msg, leaves
+}
+
+func collect(t *testing.T, contentType, encoding string, header map[string][]string, body io.Reader, leaves *[]leaf) {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("ParseMediaType(%q) error = %v", contentType, err)
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		*leaves = append(*leaves, leaf{contentType: mediaType})
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := reader.NextRawPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatalf("NextRawPart() error = %v", err)
+			}
+			collect(t, p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p.Header, p, leaves)
+		}
+	}
+
+	var decoded io.Reader = body
+	switch encoding {
+	case "base64":
+		decoded = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		decoded = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(decoded)
+	if err != nil {
+		t.Fatalf("reading %s body: %v", mediaType, err)
+	}
+	*leaves = append(*leaves, leaf{contentType: mediaType, header: header, body: string(data)})
+}
+
+func contentTypes(leaves []leaf) []string {
+	types := make([]string, 0, len(leaves))
+	for _, l := range leaves {
+		types = append(types, l.contentType)
+	}
+	return types
+}
+
+func TestBuild_Headers(t *testing.T) {
+	attributes := mail.MailAttributes{
+		To:          "a@example.com, Bob <b@example.com>",
+		Cc:          []string{"c@example.com"},
+		Bcc:         []string{"hidden@example.com"},
+		ReplyTo:     "support@example.com",
+		Subject:     "Grüße",
+		HtmlContent: "<p>Hello</p>",
+	}
+
+	raw, err := Build(attributes, testOptions)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	msg, _ := parse(t, raw)
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Grüße" {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, "Grüße")
+	}
+	from, err := msg.Header.AddressList("From")
+	if err != nil || from[0].Name != "Sender Näme" || from[0].Address != "sender@example.com" {
+		t.Errorf("From = %v (%v)", from, err)
+	}
+	to, err := msg.Header.AddressList("To")
+	if err != nil || len(to) != 2 || to[1].Name != "Bob" {
+		t.Errorf("To = %v (%v)", to, err)
+	}
+	if got := msg.Header.Get("Cc"); got != "<c@example.com>" {
+		t.Errorf("Cc = %q", got)
+	}
+	if got := msg.Header.Get("Reply-To"); got != "<support@example.com>" {
+		t.Errorf("Reply-To = %q", got)
+	}
+	if got := msg.Header.Get("Message-ID"); got != "<abc@example.com>" {
+		t.Errorf("Message-ID = %q", got)
+	}
+	if got := msg.Header.Get("Date"); got != "Tue, 02 Jan 2024 03:04:05 +0000" {
+		t.Errorf("Date = %q", got)
+	}
+	if strings.Contains(string(raw), "hidden@example.com") {
+		t.Error("Bcc recipient must not appear in the message")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	attributes := mail.MailAttributes{To: "a@example.com", Bcc: []string{"hidden@example.com"}, Subject: "Hello"}
+
+	fields, err := Headers(attributes, Options{FromAddress: "sender@example.com", Date: testOptions.Date})
+	if err != nil {
+		t.Fatalf("Headers() error = %v", err)
+	}
+	var names []string
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+	if want := []string{"From", "To", "Subject", "Date"}; !slices.Equal(names, want) {
+		t.Errorf("Headers() names = %v, want %v", names, want)
+	}
+}
+
+func TestBuild_RejectsHeaderInjection(t *testing.T) {
+	attachment := mail.Attachment{Filename: "a.pdf", ContentType: "application/pdf", Content: "YQ=="}
+	tests := []struct {
+		name   string
+		modify func(*mail.MailAttributes, *Options)
+	}{
+		{name: "to", modify: func(a *mail.MailAttributes, _ *Options) { a.To = "a@example.com\r\nX-Evil: 1" }},
+		{name: "cc", modify: func(a *mail.MailAttributes, _ *Options) { a.Cc = []string{"not an address"} }},
+		{name: "reply-to", modify: func(a *mail.MailAttributes, _ *Options) { a.ReplyTo = "a@example.com\nBcc: b@example.com" }},
+		{name: "from", modify: func(_ *mail.MailAttributes, o *Options) { o.FromAddress = "sender@example.com\r\nX-Evil: 1" }},
+		{name: "content type", modify: func(a *mail.MailAttributes, _ *Options) {
+			a.Attachments[0].ContentType = "application/pdf\r\nX-Evil: 1"
+		}},
+		{name: "content id", modify: func(a *mail.MailAttributes, _ *Options) {
+			a.Attachments[0].ContentID = "logo>\r\nX-Evil: <1"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes := mail.MailAttributes{To: "a@example.com", Subject: "Hello", HtmlContent: "body", Attachments: []mail.Attachment{attachment}}
+			opts := testOptions
+			tt.modify(&attributes, &opts)
+			if _, err := Build(attributes, opts); !errors.Is(err, ErrInvalidHeader) {
+				t.Errorf("Build() error = %v, want %v", err, ErrInvalidHeader)
+			}
+		})
+	}
+}
+
+func TestBuild_NormalizesAttachmentContentType(t *testing.T) {
+	attributes := mail.MailAttributes{
+		To:          "a@example.com",
+		Subject:     "Report",
+		HtmlContent: "body",
+		Attachments: []mail.Attachment{{Filename: "a.csv", ContentType: `TEXT/CSV; Charset="utf-8"`, Content: "YQ=="}},
+	}
+	raw, err := Build(attributes, testOptions)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	_, leaves := parse(t, raw)
+	if got := textproto.MIMEHeader(leaves[len(leaves)-1].header).Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("attachment Content-Type = %q, want %q", got, "text/csv; charset=utf-8")
+	}
+}
+
+func TestBuild_Alternative(t *testing.T) {
+	attributes := mail.MailAttributes{
+		To:          "a@example.com",
+		Subject:     "Hello",
+		HtmlContent: "<p>Hällo " + strings.Repeat("long line ", 20) + "</p>",
+		TextContent: "Hällo",
+	}
+
+	raw, err := Build(attributes, testOptions)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	_, leaves := parse(t, raw)
+
+	want := []string{"multipart/alternative", "text/plain", "text/html"}
+	if got := contentTypes(leaves); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("structure = %v, want %v", got, want)
+	}
+	if leaves[1].body != "Hällo" {
+		t.Errorf("text body = %q", leaves[1].body)
+	}
+	if leaves[2].body != attributes.HtmlContent {
+		t.Errorf("html body = %q", leaves[2].body)
+	}
+	for _, line := range strings.Split(string(raw), "\r\n") {
+		if len(line) > 998 {
+			t.Errorf("line exceeds 998 characters: %d", len(line))
+		}
+	}
+}
+
+func TestBuild_Attachments(t *testing.T) {
+	pdf := bytes.Repeat([]byte("%PDF-1.4 "), 30)
+	attributes := mail.MailAttributes{
+		To:          "a@example.com",
+		Subject:     "Invoice",
+		HtmlContent: `<img src="cid:logo"><p>attached</p>`,
+		Attachments: []mail.Attachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Content: base64.StdEncoding.EncodeToString(pdf)},
+			{Filename: "logo.png", ContentType: "image/png", Content: base64.StdEncoding.EncodeToString([]byte("png")), ContentID: "logo"},
+		},
+	}
+
+	raw, err := Build(attributes, testOptions)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	_, leaves := parse(t, raw)
+
+	want := []string{"multipart/mixed", "multipart/related", "multipart/alternative", "text/plain", "text/html", "image/png", "application/pdf"}
+	if got := contentTypes(leaves); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("structure = %v, want %v", got, want)
+	}
+
+	logo := leaves[5]
+	if got := textproto.MIMEHeader(logo.header).Get("Content-Id"); got != "<logo>" {
+		t.Errorf("inline Content-ID = %q", got)
+	}
+	if got := textproto.MIMEHeader(logo.header).Get("Content-Disposition"); got != "inline; filename=logo.png" {
+		t.Errorf("inline Content-Disposition = %q", got)
+	}
+
+	invoice := leaves[6]
+	if invoice.body != string(pdf) {
+		t.Errorf("attachment content does not round-trip")
+	}
+	if got := textproto.MIMEHeader(invoice.header).Get("Content-Disposition"); got != "attachment; filename=invoice.pdf" {
+		t.Errorf("attachment Content-Disposition = %q", got)
+	}
+}
+
+func TestBuild_InvalidAttachment(t *testing.T) {
+	attributes := mail.MailAttributes{
+		To:          "a@example.com",
+		Subject:     "Invoice",
+		HtmlContent: "body",
+		Attachments: []mail.Attachment{{Filename: "a.pdf", ContentType: "application/pdf", Content: "not base64!"}},
+	}
+	if _, err := Build(attributes, testOptions); err == nil {
+		t.Error("Build() expected error for invalid base64 content")
+	}
+}
+
+func TestNewMessageID(t *testing.T) {
+	a, err := NewMessageID("example.com")
+	if err != nil {
+		t.Fatalf("NewMessageID() error = %v", err)
+	}
+	b, _ := NewMessageID("example.com")
+	if a == b || !strings.HasSuffix(a, "@example.com") {
+		t.Errorf("NewMessageID() = %q, %q", a, b)
+	}
+}
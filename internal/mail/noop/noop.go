@@ -14,8 +14,9 @@ func NewNoopService() *NoopService {
 }
 
 func (service *NoopService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
-	slog.Info("noop: preparing to send mail", "to", attributes.To, "subject", attributes.Subject)
-	slog.Debug("noop: mail details", "from", attributes.From, "from_name", attributes.FromName, "html_len", len(attributes.HtmlContent))
+	slog.Info("noop: preparing to send mail", "to", attributes.To, "cc", attributes.Cc, "bcc", attributes.Bcc, "subject", attributes.Subject)
+	slog.Debug("noop: mail details", "from", attributes.From, "from_name", attributes.FromName, "reply_to", attributes.ReplyTo, "html_len", len(attributes.HtmlContent), "text_len", len(attributes.TextContent), "attachments", len(attributes.Attachments))
 	slog.Info("noop: mail processed (no actual sending - noop mode)")
+	mail.RecordDelivery(ctx, "noop")
 	return nil
 }
@@ -0,0 +1,141 @@
+package mail
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	horizontalSpace = regexp.MustCompile(`[ \t\r\f\v]+`)
+	blankLines      = regexp.MustCompile(`\n{3,}`)
+)
+
+// blockElements start on a new line when rendered as text.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Fieldset: true,
+	atom.Figure: true, atom.Footer: true, atom.Form: true, atom.H1: true, atom.H2: true,
+	atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true,
+	atom.Hr: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true,
+	atom.Section: true, atom.Table: true, atom.Tr: true, atom.Ul: true, atom.Pre: true,
+}
+
+// skippedElements never contribute visible text.
+var skippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Title: true, atom.Template: true,
+}
+
+// HTMLToText renders an HTML document as readable plain text.
+// Block elements and <br> become line breaks, list items are prefixed with "- "
+// and links are followed by their target in parentheses.
+func HTMLToText(document string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(document))
+	var out strings.Builder
+	var links []openLink
+	skipDepth, preDepth := 0, 0
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				break
+			}
+			return strings.TrimSpace(document)
+		}
+
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch {
+			case skippedElements[token.DataAtom]:
+				if tokenType == html.StartTagToken {
+					skipDepth++
+				}
+			case token.DataAtom == atom.Br:
+				out.WriteString("\n")
+			case token.DataAtom == atom.Li:
+				out.WriteString("\n- ")
+			case token.DataAtom == atom.Td || token.DataAtom == atom.Th:
+				out.WriteString(" ")
+			case token.DataAtom == atom.A:
+				links = append(links, openLink{href: attribute(token, "href"), start: out.Len()})
+			case token.DataAtom == atom.Img:
+				if alt := attribute(token, "alt"); alt != "" && skipDepth == 0 {
+					out.WriteString(alt)
+				}
+			case blockElements[token.DataAtom]:
+				out.WriteString("\n\n")
+				if token.DataAtom == atom.Pre {
+					preDepth++
+				}
+			}
+		case html.EndTagToken:
+			switch {
+			case skippedElements[token.DataAtom]:
+				if skipDepth > 0 {
+					skipDepth--
+				}
+			case token.DataAtom == atom.A:
+				if len(links) > 0 {
+					link := links[len(links)-1]
+					links = links[:len(links)-1]
+					target := strings.TrimPrefix(link.href, "mailto:")
+					text := strings.TrimSpace(out.String()[link.start:])
+					if isLinkTarget(link.href) && skipDepth == 0 && text != target {
+						out.WriteString(" (" + target + ")")
+					}
+				}
+			case blockElements[token.DataAtom]:
+				out.WriteString("\n\n")
+				if token.DataAtom == atom.Pre && preDepth > 0 {
+					preDepth--
+				}
+			}
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			if preDepth > 0 {
+				out.WriteString(token.Data)
+			} else {
+				out.WriteString(strings.ReplaceAll(token.Data, "\n", " "))
+			}
+		}
+	}
+
+	return normalizeText(out.String())
+}
+
+// openLink remembers where the text of an <a> element starts.
+type openLink struct {
+	href  string
+	start int
+}
+
+// normalizeText collapses runs of spaces, trims every line and limits blank lines to one.
+func normalizeText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(horizontalSpace.ReplaceAllString(line, " "))
+	}
+	text = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n"))
+}
+
+func attribute(token html.Token, key string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return strings.TrimSpace(attr.Val)
+		}
+	}
+	return ""
+}
+
+// isLinkTarget reports whether a link target is worth printing next to the link text.
+func isLinkTarget(href string) bool {
+	return href != "" && !strings.HasPrefix(href, "#") && !strings.HasPrefix(strings.ToLower(href), "javascript:")
+}
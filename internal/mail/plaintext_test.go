@@ -0,0 +1,77 @@
+package mail
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "paragraphs and line breaks",
+			html: "<p>Hello</p><p>first line<br>second line</p>",
+			want: "Hello\n\nfirst line\nsecond line",
+		},
+		{
+			name: "head, style and script are skipped",
+			html: "<html><head><title>T</title><style>p{color:red}</style></head><body><script>alert(1)</script><p>Body</p></body></html>",
+			want: "Body",
+		},
+		{
+			name: "links show their target",
+			html: `<p>Visit <a href="https://example.com/docs">the docs</a> or <a href="mailto:help@example.com">mail us</a>.</p>`,
+			want: "Visit the docs (https://example.com/docs) or mail us (help@example.com).",
+		},
+		{
+			name: "link text equal to target is not repeated",
+			html: `<a href="https://example.com">https://example.com</a>`,
+			want: "https://example.com",
+		},
+		{
+			name: "anchors are not printed",
+			html: `<a href="#top">Back to top</a>`,
+			want: "Back to top",
+		},
+		{
+			name: "lists",
+			html: "<ul><li>one</li><li>two</li></ul>",
+			want: "- one\n- two",
+		},
+		{
+			name: "entities and whitespace",
+			html: "<div>  caf&eacute;\n   &amp;   bar  </div>",
+			want: "café & bar",
+		},
+		{
+			name: "image alt text",
+			html: `<p><img src="cid:logo" alt="ACME"> Newsletter</p>`,
+			want: "ACME Newsletter",
+		},
+		{
+			name: "plain text input",
+			html: "just text",
+			want: "just text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLToText(tt.html); got != tt.want {
+				t.Errorf("HTMLToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMailAttributes_PlainText(t *testing.T) {
+	explicit := MailAttributes{HtmlContent: "<p>html</p>", TextContent: "explicit text"}
+	if got := explicit.PlainText(); got != "explicit text" {
+		t.Errorf("PlainText() = %q, want explicit text content", got)
+	}
+
+	generated := MailAttributes{HtmlContent: "<p>generated</p>"}
+	if got := generated.PlainText(); got != "generated" {
+		t.Errorf("PlainText() = %q, want text generated from HTML", got)
+	}
+}
@@ -0,0 +1,36 @@
+package postmark
+
+import "strings"
+
+// DefaultBaseURL is the public Postmark API.
+const DefaultBaseURL = "https://api.postmarkapp.com"
+
+// PostmarkConfig contains all attributes to initialize the Postmark mail service.
+type PostmarkConfig struct {
+	ServerToken string
+	// MessageStream selects the Postmark message stream, e.g. outbound or a broadcast stream.
+	MessageStream string
+	OriginAddress string
+	OriginName    string
+	// BaseURL overrides DefaultBaseURL when set.
+	BaseURL string
+}
+
+// NewPostmarkConfig creates a PostmarkConfig from the provided server token, message stream, sender identity and optional base URL.
+func NewPostmarkConfig(serverToken, messageStream, originAddress, originName, baseURL string) *PostmarkConfig {
+	return &PostmarkConfig{
+		ServerToken:   serverToken,
+		MessageStream: messageStream,
+		OriginAddress: originAddress,
+		OriginName:    originName,
+		BaseURL:       baseURL,
+	}
+}
+
+// baseURL returns the API base URL without a trailing slash.
+func (config *PostmarkConfig) baseURL() string {
+	if config.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(config.BaseURL, "/")
+}
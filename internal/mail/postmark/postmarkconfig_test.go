@@ -0,0 +1,45 @@
+package postmark
+
+import (
+	"testing"
+)
+
+func TestNewPostmarkConfig(t *testing.T) {
+	cfg := NewPostmarkConfig("server-token", "broadcast", "sender@example.com", "Sender Name", "http://localhost:8025")
+
+	if cfg.ServerToken != "server-token" {
+		t.Errorf("ServerToken = %q, want %q", cfg.ServerToken, "server-token")
+	}
+	if cfg.MessageStream != "broadcast" {
+		t.Errorf("MessageStream = %q, want %q", cfg.MessageStream, "broadcast")
+	}
+	if cfg.OriginAddress != "sender@example.com" {
+		t.Errorf("OriginAddress = %q, want %q", cfg.OriginAddress, "sender@example.com")
+	}
+	if cfg.OriginName != "Sender Name" {
+		t.Errorf("OriginName = %q, want %q", cfg.OriginName, "Sender Name")
+	}
+	if cfg.BaseURL != "http://localhost:8025" {
+		t.Errorf("BaseURL = %q, want %q", cfg.BaseURL, "http://localhost:8025")
+	}
+}
+
+func TestPostmarkConfig_baseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		want    string
+	}{
+		{name: "default", want: DefaultBaseURL},
+		{name: "override", baseURL: "http://localhost:8025", want: "http://localhost:8025"},
+		{name: "trailing slash", baseURL: "http://localhost:8025/", want: "http://localhost:8025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &PostmarkConfig{BaseURL: tt.baseURL}
+			if got := cfg.baseURL(); got != tt.want {
+				t.Errorf("baseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package postmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// providerName identifies Postmark in delivery records and errors
+const providerName = "postmark"
+
+// PostmarkService implements MailService
+type PostmarkService struct {
+	config *PostmarkConfig
+	client *http.Client
+}
+
+// NewPostmarkService creates a PostmarkService that sends its requests with client
+func NewPostmarkService(config *PostmarkConfig, client *http.Client) *PostmarkService {
+	return &PostmarkService{
+		config: config,
+		client: client,
+	}
+}
+
+// postmarkMessage represents a message in Postmark's email API format
+type postmarkMessage struct {
+	From          string               `json:"From"`
+	To            string               `json:"To"`
+	Cc            string               `json:"Cc,omitempty"`
+	Bcc           string               `json:"Bcc,omitempty"`
+	ReplyTo       string               `json:"ReplyTo,omitempty"`
+	Subject       string               `json:"Subject"`
+	HtmlBody      string               `json:"HtmlBody,omitempty"`
+	TextBody      string               `json:"TextBody,omitempty"`
+	MessageStream string               `json:"MessageStream,omitempty"`
+	Attachments   []postmarkAttachment `json:"Attachments,omitempty"`
+}
+
+// postmarkAttachment represents a regular or inline attachment
+type postmarkAttachment struct {
+	Name        string `json:"Name"`
+	Content     string `json:"Content"`
+	ContentType string `json:"ContentType"`
+	ContentID   string `json:"ContentID,omitempty"`
+}
+
+// postmarkResponse represents the Postmark API response, for both success and errors
+type postmarkResponse struct {
+	To        string `json:"To"`
+	MessageID string `json:"MessageID"`
+	ErrorCode int    `json:"ErrorCode"`
+	Message   string `json:"Message"`
+}
+
+func (service *PostmarkService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	slog.Info("postmark: preparing to send mail")
+
+	message := service.createMessage(attributes)
+	err := service.sendRequest(ctx, message)
+
+	if err != nil {
+		slog.Error("postmark: failed to send mail", "error", err)
+		return err
+	}
+
+	slog.Info("postmark: mail sent successfully")
+	return nil
+}
+
+// createMessage creates a Postmark message from mail attributes
+func (service *PostmarkService) createMessage(attributes mail.MailAttributes) postmarkMessage {
+	address, name := attributes.Sender(service.config.OriginAddress, service.config.OriginName)
+	from := address
+	if name != "" {
+		from = (&netmail.Address{Name: name, Address: address}).String()
+	}
+
+	message := postmarkMessage{
+		From:          from,
+		To:            strings.Join(attributes.ToAddresses(), ", "),
+		Cc:            strings.Join(attributes.Cc, ", "),
+		Bcc:           strings.Join(attributes.Bcc, ", "),
+		ReplyTo:       attributes.ReplyTo,
+		Subject:       attributes.Subject,
+		HtmlBody:      attributes.HtmlContent,
+		TextBody:      attributes.PlainText(),
+		MessageStream: service.config.MessageStream,
+	}
+
+	for _, attachment := range attributes.Attachments {
+		postmarkAttachment := postmarkAttachment{
+			Name:        attachment.Filename,
+			Content:     attachment.Content,
+			ContentType: attachment.ContentType,
+		}
+		if attachment.IsInline() {
+			postmarkAttachment.ContentID = "cid:" + attachment.ContentID
+		}
+		message.Attachments = append(message.Attachments, postmarkAttachment)
+	}
+
+	return message
+}
+
+func (service *PostmarkService) sendRequest(ctx context.Context, message postmarkMessage) error {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, service.config.baseURL()+"/email", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Postmark-Server-Token", service.config.ServerToken)
+
+	slog.Info("postmark: sending request to Postmark API")
+
+	resp, err := service.client.Do(req)
+	if err != nil {
+		return mail.NewNetworkError(providerName, fmt.Errorf("failed to send request: %w", err))
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return mail.NewNetworkError(providerName, fmt.Errorf("failed to read response body: %w", err))
+	}
+
+	slog.Info("postmark: received response", "status_code", resp.StatusCode)
+
+	var postmarkResp postmarkResponse
+	parseErr := json.Unmarshal(body, &postmarkResp)
+
+	if resp.StatusCode != http.StatusOK {
+		message := string(body)
+		if parseErr == nil && postmarkResp.Message != "" {
+			message = fmt.Sprintf("[%d] %s", postmarkResp.ErrorCode, postmarkResp.Message)
+		}
+		return mail.NewStatusError(providerName, resp.StatusCode, resp.Header.Get("Retry-After"),
+			fmt.Errorf("postmark API returned status %d: %s", resp.StatusCode, message))
+	}
+	if parseErr != nil {
+		return fmt.Errorf("failed to parse response: %w", parseErr)
+	}
+	if postmarkResp.ErrorCode != 0 {
+		return mail.NewStatusError(providerName, http.StatusUnprocessableEntity, "",
+			fmt.Errorf("postmark error: [%d] %s", postmarkResp.ErrorCode, postmarkResp.Message))
+	}
+
+	mail.RecordDelivery(ctx, providerName, postmarkResp.MessageID)
+	return nil
+}
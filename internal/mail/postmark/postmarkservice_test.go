@@ -0,0 +1,165 @@
+package postmark
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+func newTestService(serverURL string) *PostmarkService {
+	return NewPostmarkService(NewPostmarkConfig("server-token", "outbound", "sender@example.com", "Sender", serverURL), http.DefaultClient)
+}
+
+func TestPostmarkService_SendMail(t *testing.T) {
+	var gotPath, gotToken, gotAccept string
+	var gotMessage postmarkMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotToken = r.Header.Get("X-Postmark-Server-Token")
+		gotAccept = r.Header.Get("Accept")
+		if err := json.NewDecoder(r.Body).Decode(&gotMessage); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		_ = json.NewEncoder(w).Encode(postmarkResponse{To: "a@example.com", MessageID: "pm-message-id", Message: "OK"})
+	}))
+	defer server.Close()
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	err := newTestService(server.URL).SendMail(ctx, mail.MailAttributes{
+		To:          "a@example.com, b@example.com",
+		Cc:          []string{"c@example.com"},
+		Bcc:         []string{"d@example.com", "e@example.com"},
+		ReplyTo:     "support@example.com",
+		Subject:     "Test Subject",
+		HtmlContent: `<img src="cid:logo"><p>Test Content</p>`,
+		TextContent: "Test Content",
+		Attachments: []mail.Attachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Content: "JVBERi0xLjQK"},
+			{Filename: "logo.png", ContentType: "image/png", Content: "iVBORw0KGgo=", ContentID: "logo"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	if gotPath != "/email" {
+		t.Errorf("path = %q, want /email", gotPath)
+	}
+	if gotToken != "server-token" {
+		t.Errorf("X-Postmark-Server-Token = %q", gotToken)
+	}
+	if gotAccept != "application/json" {
+		t.Errorf("Accept = %q", gotAccept)
+	}
+	want := postmarkMessage{
+		From:          `"Sender" <sender@example.com>`,
+		To:            "a@example.com, b@example.com",
+		Cc:            "c@example.com",
+		Bcc:           "d@example.com, e@example.com",
+		ReplyTo:       "support@example.com",
+		Subject:       "Test Subject",
+		HtmlBody:      `<img src="cid:logo"><p>Test Content</p>`,
+		TextBody:      "Test Content",
+		MessageStream: "outbound",
+		Attachments: []postmarkAttachment{
+			{Name: "invoice.pdf", Content: "JVBERi0xLjQK", ContentType: "application/pdf"},
+			{Name: "logo.png", Content: "iVBORw0KGgo=", ContentType: "image/png", ContentID: "cid:logo"},
+		},
+	}
+	if !reflect.DeepEqual(gotMessage, want) {
+		t.Errorf("message = %+v, want %+v", gotMessage, want)
+	}
+	if want := []string{"pm-message-id"}; !reflect.DeepEqual(delivery.MessageIDs, want) {
+		t.Errorf("MessageIDs = %v, want %v", delivery.MessageIDs, want)
+	}
+}
+
+func TestPostmarkService_SendMail_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name           string
+		statusCode     int
+		retryAfter     string
+		body           string
+		wantTransient  bool
+		wantRetryAfter time.Duration
+		wantMessage    string
+	}{
+		{
+			name:        "inactive recipient",
+			statusCode:  http.StatusUnprocessableEntity,
+			body:        `{"ErrorCode":406,"Message":"You tried to send to a recipient that has been marked as inactive."}`,
+			wantMessage: "[406] You tried to send",
+		},
+		{
+			name:        "invalid token",
+			statusCode:  http.StatusUnauthorized,
+			body:        `{"ErrorCode":10,"Message":"No Account or Server API tokens were supplied."}`,
+			wantMessage: "[10] No Account",
+		},
+		{
+			name:           "rate limited",
+			statusCode:     http.StatusTooManyRequests,
+			retryAfter:     "10",
+			wantTransient:  true,
+			wantRetryAfter: 10 * time.Second,
+		},
+		{
+			name:          "server error",
+			statusCode:    http.StatusInternalServerError,
+			wantTransient: true,
+		},
+		{
+			name:        "error code in successful response",
+			statusCode:  http.StatusOK,
+			body:        `{"ErrorCode":300,"Message":"Invalid email request"}`,
+			wantMessage: "[300] Invalid email request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+			if err == nil {
+				t.Fatal("SendMail() expected error")
+			}
+			if mail.IsTransient(err) != tt.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v (error: %v)", mail.IsTransient(err), tt.wantTransient, err)
+			}
+			if mail.IsPermanent(err) == tt.wantTransient {
+				t.Errorf("IsPermanent() = %v, want %v", mail.IsPermanent(err), !tt.wantTransient)
+			}
+			if got := mail.RetryAfter(err); got != tt.wantRetryAfter {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.wantRetryAfter)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestPostmarkService_SendMail_NetworkErrorIsTransient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+	if !mail.IsTransient(err) {
+		t.Errorf("IsTransient() = false, want true for error %v", err)
+	}
+}
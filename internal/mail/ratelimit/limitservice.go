@@ -0,0 +1,176 @@
+// Package ratelimit keeps the mail sent through a provider within its quotas.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+
+	"golang.org/x/time/rate"
+)
+
+// window is the token bucket of one configured limit.
+type window struct {
+	name    string
+	limit   int
+	limiter *rate.Limiter
+}
+
+// LimitService implements MailService by holding mail back until every token
+// bucket of the provider has a token for each of its recipients, as providers
+// count their quotas in recipients. A mail with more recipients than a bucket
+// holds takes the whole bucket. A mail that would have to wait longer
+// than maxWait is not sent and fails with a transient error that wraps
+// mail.ErrRateLimited and asks to retry once the tokens are available.
+type LimitService struct {
+	provider string
+	service  mail.MailService
+	windows  []window
+	maxWait  time.Duration
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// NewLimitService wraps the service of provider with the configured limits.
+// With a maxWait of 0 mail never waits, e.g. because the queue postpones it instead.
+func NewLimitService(provider string, service mail.MailService, limits config.RateLimitConfig, maxWait time.Duration) *LimitService {
+	limited := &LimitService{
+		provider: provider,
+		service:  service,
+		maxWait:  maxWait,
+		now:      time.Now,
+		sleep:    sleep,
+	}
+	for _, w := range []struct {
+		name   string
+		limit  int
+		period time.Duration
+	}{
+		{"second", limits.PerSecond, time.Second},
+		{"minute", limits.PerMinute, time.Minute},
+		{"day", limits.PerDay, 24 * time.Hour},
+	} {
+		if w.limit > 0 {
+			limiter := rate.NewLimiter(rate.Every(w.period/time.Duration(w.limit)), w.limit)
+			limited.windows = append(limited.windows, window{name: w.name, limit: w.limit, limiter: limiter})
+		}
+	}
+	return limited
+}
+
+func (service *LimitService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	now := service.now()
+	r := service.reserve(now, recipients(attributes))
+	if r.delay > service.maxWait {
+		r.cancel(now)
+		return service.limitError(r)
+	}
+	if r.delay > 0 {
+		slog.Debug("ratelimit: waiting for provider quota", "provider", service.provider, "delay", r.delay)
+		if err := service.sleep(ctx, r.delay); err != nil {
+			r.cancel(now)
+			return err
+		}
+	}
+	return service.service.SendMail(ctx, attributes)
+}
+
+// SendBatch takes the tokens of each mail in turn. The mails that would have to
+// wait longer than maxWait fail, and the others are sent together once the
+// last of them may go.
+func (service *LimitService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	now := service.now()
+	results := make([]mail.BatchResult, len(mails))
+	var admitted []int
+	var reserved []reservation
+	var delay time.Duration
+	for i, attributes := range mails {
+		r := service.reserve(now, recipients(attributes))
+		if r.delay > service.maxWait {
+			r.cancel(now)
+			results[i].Err = service.limitError(r)
+			continue
+		}
+		admitted = append(admitted, i)
+		reserved = append(reserved, r)
+		delay = max(delay, r.delay)
+	}
+	if len(admitted) == 0 {
+		return results
+	}
+
+	if delay > 0 {
+		slog.Debug("ratelimit: waiting for provider quota", "provider", service.provider, "mails", len(admitted), "delay", delay)
+		if err := service.sleep(ctx, delay); err != nil {
+			for j, i := range admitted {
+				reserved[j].cancel(now)
+				results[i].Err = err
+			}
+			return results
+		}
+	}
+	mail.SendBatchSubset(ctx, service.service, mails, admitted, results)
+	return results
+}
+
+// reservation holds the tokens of every window for one mail.
+type reservation struct {
+	reservations []*rate.Reservation
+	// delay is how long the mail has to wait for the last of its tokens.
+	delay time.Duration
+	// longest is the window that makes it wait that long.
+	longest window
+}
+
+// reserve takes n tokens of every window, but at most the whole window, for a
+// mail sent at now.
+func (service *LimitService) reserve(now time.Time, n int) reservation {
+	r := reservation{reservations: make([]*rate.Reservation, 0, len(service.windows))}
+	for _, w := range service.windows {
+		reservation := w.limiter.ReserveN(now, min(n, w.limit))
+		r.reservations = append(r.reservations, reservation)
+		if d := reservation.DelayFrom(now); d > r.delay {
+			r.delay, r.longest = d, w
+		}
+	}
+	return r
+}
+
+// recipients returns the number of tokens a mail takes, at least one.
+func recipients(attributes mail.MailAttributes) int {
+	return max(len(attributes.ToAddresses())+len(attributes.Cc)+len(attributes.Bcc), 1)
+}
+
+// cancel returns the tokens of a mail that is not sent.
+func (r reservation) cancel(now time.Time) {
+	for _, reservation := range r.reservations {
+		reservation.CancelAt(now)
+	}
+}
+
+// limitError is returned for a mail that would have to wait longer than maxWait.
+func (service *LimitService) limitError(r reservation) error {
+	return &mail.ProviderError{
+		Provider:   service.provider,
+		Transient:  true,
+		RetryAfter: r.delay,
+		Err: fmt.Errorf("%w: %s allows %d recipients per %s, next mail in %s",
+			mail.ErrRateLimited, service.provider, r.longest.limit, r.longest.name, r.delay.Round(time.Millisecond)),
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// countingService counts the mails it sends.
+type countingService struct {
+	calls int
+}
+
+func (s *countingService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	s.calls++
+	return nil
+}
+
+// newTestLimitService returns a LimitService whose clock only moves when
+// advanced and whose waits advance the clock instead of sleeping.
+func newTestLimitService(limits config.RateLimitConfig, maxWait time.Duration) (*LimitService, *countingService, *time.Time, *[]time.Duration) {
+	svc := &countingService{}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var waits []time.Duration
+	service := NewLimitService("sendgrid", svc, limits, maxWait)
+	service.now = func() time.Time { return now }
+	service.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		now = now.Add(d)
+		return nil
+	}
+	return service, svc, &now, &waits
+}
+
+func TestLimitService_Unlimited(t *testing.T) {
+	service, svc, _, waits := newTestLimitService(config.RateLimitConfig{}, 0)
+	for range 100 {
+		if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+	}
+	if svc.calls != 100 || len(*waits) != 0 {
+		t.Errorf("sent %d mails with %d waits, want 100 without waiting", svc.calls, len(*waits))
+	}
+}
+
+func TestLimitService_WaitsUpToMaxWait(t *testing.T) {
+	service, svc, _, waits := newTestLimitService(config.RateLimitConfig{PerSecond: 2}, time.Second)
+
+	for range 4 {
+		if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+	}
+	// a burst of two, then one token every 500ms
+	want := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}
+	if svc.calls != 4 || len(*waits) != 2 || (*waits)[0] != want[0] || (*waits)[1] != want[1] {
+		t.Errorf("sent %d mails after waiting %v, want 4 after %v", svc.calls, *waits, want)
+	}
+}
+
+func TestLimitService_FailsBeyondMaxWait(t *testing.T) {
+	service, svc, now, _ := newTestLimitService(config.RateLimitConfig{PerSecond: 10, PerDay: 2}, time.Second)
+
+	for range 2 {
+		if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+	}
+	err := service.SendMail(context.Background(), mail.MailAttributes{})
+	if !errors.Is(err, mail.ErrRateLimited) || !mail.IsTransient(err) {
+		t.Fatalf("SendMail() error = %v, want a transient ErrRateLimited", err)
+	}
+	if !strings.Contains(err.Error(), "sendgrid allows 2 recipients per day") {
+		t.Errorf("error = %q, want it to name the daily limit", err)
+	}
+	if got := mail.RetryAfter(err); got != 12*time.Hour {
+		t.Errorf("RetryAfter = %v, want 12h", got)
+	}
+	if svc.calls != 2 {
+		t.Errorf("sent %d mails, want 2", svc.calls)
+	}
+
+	// the rejected mail did not use up a token of the per-second bucket
+	*now = now.Add(12 * time.Hour)
+	if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+		t.Fatalf("SendMail() after the delay error = %v", err)
+	}
+}
+
+func TestLimitService_CountsRecipients(t *testing.T) {
+	service, svc, _, waits := newTestLimitService(config.RateLimitConfig{PerSecond: 10}, 10*time.Second)
+
+	mailWithBcc := mail.MailAttributes{To: "a@example.com, b@example.com", Cc: []string{"c@example.com"}, Bcc: []string{"d@example.com"}}
+	for range 2 {
+		if err := service.SendMail(context.Background(), mailWithBcc); err != nil {
+			t.Fatalf("SendMail() error = %v", err)
+		}
+	}
+	// 8 of the 10 tokens are used up, the next 4 recipients wait for 2 tokens
+	if err := service.SendMail(context.Background(), mailWithBcc); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if svc.calls != 3 || len(*waits) != 1 || (*waits)[0] != 200*time.Millisecond {
+		t.Errorf("sent %d mails after waiting %v, want 3 after 200ms", svc.calls, *waits)
+	}
+
+	// more recipients than the bucket holds take the whole bucket instead of failing
+	bcc := make([]string, 50)
+	for i := range bcc {
+		bcc[i] = "bcc@example.com"
+	}
+	if err := service.SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Bcc: bcc}); err != nil {
+		t.Fatalf("SendMail() with 51 recipients error = %v", err)
+	}
+	if svc.calls != 4 || len(*waits) != 2 || (*waits)[1] != time.Second {
+		t.Errorf("sent %d mails after waiting %v, want 4 after another 1s", svc.calls, *waits)
+	}
+}
+
+func TestLimitService_QueueDoesNotWait(t *testing.T) {
+	service, svc, _, waits := newTestLimitService(config.RateLimitConfig{PerMinute: 1}, 0)
+
+	_ = service.SendMail(context.Background(), mail.MailAttributes{})
+	err := service.SendMail(context.Background(), mail.MailAttributes{})
+	if !errors.Is(err, mail.ErrRateLimited) || mail.RetryAfter(err) != time.Minute {
+		t.Errorf("SendMail() error = %v with RetryAfter %v, want ErrRateLimited after 1m", err, mail.RetryAfter(err))
+	}
+	if svc.calls != 1 || len(*waits) != 0 {
+		t.Errorf("sent %d mails with %d waits, want 1 without waiting", svc.calls, len(*waits))
+	}
+}
+
+func TestLimitService_CanceledWait(t *testing.T) {
+	svc := &countingService{}
+	service := NewLimitService("sendgrid", svc, config.RateLimitConfig{PerMinute: 1}, time.Minute)
+	_ = service.SendMail(context.Background(), mail.MailAttributes{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := service.SendMail(ctx, mail.MailAttributes{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("SendMail() error = %v, want context.Canceled", err)
+	}
+	if svc.calls != 1 {
+		t.Errorf("sent %d mails, want 1", svc.calls)
+	}
+}
+
+func TestLimitService_SendBatch(t *testing.T) {
+	service, svc, _, waits := newTestLimitService(config.RateLimitConfig{PerSecond: 2}, time.Second)
+
+	results := service.SendBatch(context.Background(), make([]mail.MailAttributes, 5))
+	// a burst of two, then one token every 500ms: the fifth mail would wait 1.5s
+	if svc.calls != 4 || len(*waits) != 1 || (*waits)[0] != time.Second {
+		t.Errorf("sent %d mails after waiting %v, want 4 after a single 1s wait", svc.calls, *waits)
+	}
+	for i, result := range results[:4] {
+		if result.Err != nil {
+			t.Errorf("results[%d] error = %v", i, result.Err)
+		}
+	}
+	if err := results[4].Err; !errors.Is(err, mail.ErrRateLimited) || mail.RetryAfter(err) != 1500*time.Millisecond {
+		t.Errorf("results[4] error = %v, want ErrRateLimited after 1.5s", err)
+	}
+}
@@ -0,0 +1,33 @@
+package resend
+
+import "strings"
+
+// DefaultBaseURL is the public Resend API.
+const DefaultBaseURL = "https://api.resend.com"
+
+// ResendConfig contains all attributes to initialize the Resend mail service.
+type ResendConfig struct {
+	APIKey        string
+	OriginAddress string
+	OriginName    string
+	// BaseURL overrides DefaultBaseURL when set.
+	BaseURL string
+}
+
+// NewResendConfig creates a ResendConfig from the provided API key, sender identity and optional base URL.
+func NewResendConfig(apiKey, originAddress, originName, baseURL string) *ResendConfig {
+	return &ResendConfig{
+		APIKey:        apiKey,
+		OriginAddress: originAddress,
+		OriginName:    originName,
+		BaseURL:       baseURL,
+	}
+}
+
+// baseURL returns the API base URL without a trailing slash.
+func (config *ResendConfig) baseURL() string {
+	if config.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(config.BaseURL, "/")
+}
@@ -0,0 +1,42 @@
+package resend
+
+import (
+	"testing"
+)
+
+func TestNewResendConfig(t *testing.T) {
+	cfg := NewResendConfig("re_key", "sender@example.com", "Sender Name", "http://localhost:8025")
+
+	if cfg.APIKey != "re_key" {
+		t.Errorf("APIKey = %q, want %q", cfg.APIKey, "re_key")
+	}
+	if cfg.OriginAddress != "sender@example.com" {
+		t.Errorf("OriginAddress = %q, want %q", cfg.OriginAddress, "sender@example.com")
+	}
+	if cfg.OriginName != "Sender Name" {
+		t.Errorf("OriginName = %q, want %q", cfg.OriginName, "Sender Name")
+	}
+	if cfg.BaseURL != "http://localhost:8025" {
+		t.Errorf("BaseURL = %q, want %q", cfg.BaseURL, "http://localhost:8025")
+	}
+}
+
+func TestResendConfig_baseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		want    string
+	}{
+		{name: "default", want: DefaultBaseURL},
+		{name: "override", baseURL: "http://localhost:8025", want: "http://localhost:8025"},
+		{name: "trailing slash", baseURL: "http://localhost:8025/", want: "http://localhost:8025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &ResendConfig{BaseURL: tt.baseURL}
+			if got := cfg.baseURL(); got != tt.want {
+				t.Errorf("baseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
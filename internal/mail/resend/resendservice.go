@@ -0,0 +1,161 @@
+package resend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	netmail "net/mail"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// providerName identifies Resend in delivery records and errors
+const providerName = "resend"
+
+// ResendService implements MailService
+type ResendService struct {
+	config *ResendConfig
+	client *http.Client
+}
+
+// NewResendService creates a ResendService that sends its requests with client
+func NewResendService(config *ResendConfig, client *http.Client) *ResendService {
+	return &ResendService{
+		config: config,
+		client: client,
+	}
+}
+
+// resendEmail represents a message in Resend's emails API format
+type resendEmail struct {
+	From        string             `json:"from"`
+	To          []string           `json:"to"`
+	Cc          []string           `json:"cc,omitempty"`
+	Bcc         []string           `json:"bcc,omitempty"`
+	ReplyTo     []string           `json:"reply_to,omitempty"`
+	Subject     string             `json:"subject"`
+	Html        string             `json:"html,omitempty"`
+	Text        string             `json:"text,omitempty"`
+	Attachments []resendAttachment `json:"attachments,omitempty"`
+}
+
+// resendAttachment represents a regular or inline attachment with base64 content
+type resendAttachment struct {
+	Filename    string `json:"filename"`
+	Content     string `json:"content"`
+	ContentType string `json:"content_type,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// resendResponse represents the Resend API response
+type resendResponse struct {
+	ID string `json:"id"`
+}
+
+// resendError represents the body of a failed request
+type resendError struct {
+	StatusCode int    `json:"statusCode"`
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+}
+
+func (service *ResendService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	slog.Info("resend: preparing to send mail")
+
+	email := service.createEmail(attributes)
+	err := service.sendRequest(ctx, email)
+
+	if err != nil {
+		slog.Error("resend: failed to send mail", "error", err)
+		return err
+	}
+
+	slog.Info("resend: mail sent successfully")
+	return nil
+}
+
+// createEmail creates a Resend email from mail attributes
+func (service *ResendService) createEmail(attributes mail.MailAttributes) resendEmail {
+	address, name := attributes.Sender(service.config.OriginAddress, service.config.OriginName)
+	from := address
+	if name != "" {
+		from = (&netmail.Address{Name: name, Address: address}).String()
+	}
+
+	email := resendEmail{
+		From:    from,
+		To:      attributes.ToAddresses(),
+		Cc:      attributes.Cc,
+		Bcc:     attributes.Bcc,
+		Subject: attributes.Subject,
+		Html:    attributes.HtmlContent,
+		Text:    attributes.PlainText(),
+	}
+	if attributes.ReplyTo != "" {
+		email.ReplyTo = []string{attributes.ReplyTo}
+	}
+
+	for _, attachment := range attributes.Attachments {
+		email.Attachments = append(email.Attachments, resendAttachment{
+			Filename:    attachment.Filename,
+			Content:     attachment.Content,
+			ContentType: attachment.ContentType,
+			ContentID:   attachment.ContentID,
+		})
+	}
+
+	return email
+}
+
+func (service *ResendService) sendRequest(ctx context.Context, email resendEmail) error {
+	jsonData, err := json.Marshal(email)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, service.config.baseURL()+"/emails", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+service.config.APIKey)
+
+	slog.Info("resend: sending request to Resend API")
+
+	resp, err := service.client.Do(req)
+	if err != nil {
+		return mail.NewNetworkError(providerName, fmt.Errorf("failed to send request: %w", err))
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return mail.NewNetworkError(providerName, fmt.Errorf("failed to read response body: %w", err))
+	}
+
+	slog.Info("resend: received response", "status_code", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK {
+		message := string(body)
+		var resendErr resendError
+		if err := json.Unmarshal(body, &resendErr); err == nil && resendErr.Message != "" {
+			message = fmt.Sprintf("[%s] %s", resendErr.Name, resendErr.Message)
+		}
+		return mail.NewStatusError(providerName, resp.StatusCode, resp.Header.Get("Retry-After"),
+			fmt.Errorf("resend API returned status %d: %s", resp.StatusCode, message))
+	}
+
+	var resendResp resendResponse
+	if err := json.Unmarshal(body, &resendResp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	mail.RecordDelivery(ctx, providerName, resendResp.ID)
+	return nil
+}
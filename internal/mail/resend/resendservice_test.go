@@ -0,0 +1,156 @@
+package resend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+func newTestService(serverURL string) *ResendService {
+	return NewResendService(NewResendConfig("re_key", "sender@example.com", "Sender", serverURL), http.DefaultClient)
+}
+
+func TestResendService_SendMail(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotEmail resendEmail
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&gotEmail); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		_ = json.NewEncoder(w).Encode(resendResponse{ID: "49a3999c-0ce1-4ea6-ab68-afcd6dc2e794"})
+	}))
+	defer server.Close()
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	err := newTestService(server.URL).SendMail(ctx, mail.MailAttributes{
+		To:          "a@example.com, b@example.com",
+		Cc:          []string{"c@example.com"},
+		Bcc:         []string{"d@example.com"},
+		ReplyTo:     "support@example.com",
+		Subject:     "Test Subject",
+		HtmlContent: `<img src="cid:logo"><p>Test Content</p>`,
+		TextContent: "Test Content",
+		Attachments: []mail.Attachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Content: "JVBERi0xLjQK"},
+			{Filename: "logo.png", ContentType: "image/png", Content: "iVBORw0KGgo=", ContentID: "logo"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	if gotPath != "/emails" {
+		t.Errorf("path = %q, want /emails", gotPath)
+	}
+	if gotAuth != "Bearer re_key" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	want := resendEmail{
+		From:    `"Sender" <sender@example.com>`,
+		To:      []string{"a@example.com", "b@example.com"},
+		Cc:      []string{"c@example.com"},
+		Bcc:     []string{"d@example.com"},
+		ReplyTo: []string{"support@example.com"},
+		Subject: "Test Subject",
+		Html:    `<img src="cid:logo"><p>Test Content</p>`,
+		Text:    "Test Content",
+		Attachments: []resendAttachment{
+			{Filename: "invoice.pdf", Content: "JVBERi0xLjQK", ContentType: "application/pdf"},
+			{Filename: "logo.png", Content: "iVBORw0KGgo=", ContentType: "image/png", ContentID: "logo"},
+		},
+	}
+	if !reflect.DeepEqual(gotEmail, want) {
+		t.Errorf("email = %+v, want %+v", gotEmail, want)
+	}
+	if want := []string{"49a3999c-0ce1-4ea6-ab68-afcd6dc2e794"}; !reflect.DeepEqual(delivery.MessageIDs, want) {
+		t.Errorf("MessageIDs = %v, want %v", delivery.MessageIDs, want)
+	}
+}
+
+func TestResendService_SendMail_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name           string
+		statusCode     int
+		retryAfter     string
+		body           string
+		wantTransient  bool
+		wantRetryAfter time.Duration
+		wantMessage    string
+	}{
+		{
+			name:        "validation error",
+			statusCode:  http.StatusUnprocessableEntity,
+			body:        `{"statusCode":422,"name":"validation_error","message":"Invalid ` + "`to`" + ` field."}`,
+			wantMessage: "[validation_error] Invalid `to` field.",
+		},
+		{
+			name:        "domain not verified",
+			statusCode:  http.StatusForbidden,
+			body:        `{"statusCode":403,"name":"validation_error","message":"The example.com domain is not verified."}`,
+			wantMessage: "domain is not verified",
+		},
+		{
+			name:           "rate limited",
+			statusCode:     http.StatusTooManyRequests,
+			retryAfter:     "1",
+			body:           `{"statusCode":429,"name":"rate_limit_exceeded","message":"Too many requests."}`,
+			wantTransient:  true,
+			wantRetryAfter: time.Second,
+			wantMessage:    "[rate_limit_exceeded]",
+		},
+		{
+			name:          "server error",
+			statusCode:    http.StatusInternalServerError,
+			wantTransient: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statusCode)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+			if err == nil {
+				t.Fatal("SendMail() expected error")
+			}
+			if mail.IsTransient(err) != tt.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v (error: %v)", mail.IsTransient(err), tt.wantTransient, err)
+			}
+			if mail.IsPermanent(err) == tt.wantTransient {
+				t.Errorf("IsPermanent() = %v, want %v", mail.IsPermanent(err), !tt.wantTransient)
+			}
+			if got := mail.RetryAfter(err); got != tt.wantRetryAfter {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.wantRetryAfter)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestResendService_SendMail_NetworkErrorIsTransient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+	if !mail.IsTransient(err) {
+		t.Errorf("IsTransient() = false, want true for error %v", err)
+	}
+}
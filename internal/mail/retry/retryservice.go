@@ -0,0 +1,129 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// RetryService implements MailService by retrying transient provider errors
+// of the wrapped service with jittered exponential backoff. Permanent and
+// unclassified errors are returned right away, and so are mails held back by
+// a rate limit, which already waited as long as allowed.
+type RetryService struct {
+	service mail.MailService
+	config  config.RetryConfig
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryService wraps service with retries as configured.
+func NewRetryService(service mail.MailService, config config.RetryConfig) *RetryService {
+	return &RetryService{
+		service: service,
+		config:  config,
+		sleep:   sleep,
+	}
+}
+
+func (service *RetryService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	for attempt := 1; ; attempt++ {
+		err := service.service.SendMail(ctx, attributes)
+		if !retryable(err) || attempt >= service.config.MaxAttempts {
+			return err
+		}
+
+		delay, ok := service.delay(attempt, err)
+		if !ok {
+			return err
+		}
+		slog.Warn("retry: transient provider error, retrying", "attempt", attempt, "delay", delay, "error", err)
+		if sleepErr := service.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// SendBatch sends the batch and then retries only the mails that failed with
+// a transient error, waiting as long as the most demanding of them asks for.
+func (service *RetryService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	results := mail.SendBatch(ctx, service.service, mails)
+	for attempt := 1; attempt < service.config.MaxAttempts; attempt++ {
+		var pending []int
+		var delay time.Duration
+		for i, result := range results {
+			if !retryable(result.Err) {
+				continue
+			}
+			d, ok := service.delay(attempt, result.Err)
+			if !ok {
+				continue
+			}
+			pending = append(pending, i)
+			delay = max(delay, d)
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		slog.Warn("retry: transient provider errors in batch, retrying", "attempt", attempt, "mails", len(pending), "delay", delay)
+		if service.sleep(ctx, delay) != nil {
+			break
+		}
+		mail.SendBatchSubset(ctx, service.service, mails, pending, results)
+	}
+	return results
+}
+
+// retryable reports whether err is worth another attempt. Mails held back by
+// a rate limit already waited as long as allowed.
+func retryable(err error) bool {
+	return mail.IsTransient(err) && !errors.Is(err, mail.ErrRateLimited)
+}
+
+// delay returns how long to wait after the given attempt failed with err. It
+// reports false if the provider asked to wait longer than the maximum backoff.
+func (service *RetryService) delay(attempt int, err error) (time.Duration, bool) {
+	delay := service.backoff(attempt)
+	if retryAfter := mail.RetryAfter(err); retryAfter > delay {
+		if retryAfter > service.config.MaxBackoff {
+			// leave longer waits to the caller, e.g. the outbox schedules a later attempt
+			slog.Warn("retry: provider asked to wait longer than allowed, giving up", "retry_after", retryAfter, "error", err)
+			return 0, false
+		}
+		delay = retryAfter
+	}
+	return delay, true
+}
+
+// backoff returns the delay after the given attempt: the initial backoff
+// doubled per attempt and capped at the maximum, of which a random half is
+// subtracted to spread out retries of concurrent requests.
+func (service *RetryService) backoff(attempt int) time.Duration {
+	delay := service.config.InitialBackoff
+	for i := 1; i < attempt && delay < service.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, service.config.MaxBackoff)
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,191 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// scriptedService returns the scripted errors in order, then succeeds.
+type scriptedService struct {
+	errs  []error
+	calls int
+}
+
+func (s *scriptedService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return s.errs[s.calls-1]
+	}
+	return nil
+}
+
+func newTestRetryService(svc mail.MailService, maxAttempts int) (*RetryService, *[]time.Duration) {
+	var delays []time.Duration
+	service := NewRetryService(svc, config.RetryConfig{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	})
+	service.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return service, &delays
+}
+
+func TestRetryService_SendMail(t *testing.T) {
+	transient := mail.NewStatusError("mailjet", 503, "", errors.New("unavailable"))
+	permanent := mail.NewStatusError("mailjet", 400, "", errors.New("invalid recipient"))
+	rateLimited := &mail.ProviderError{Provider: "mailjet", Transient: true, RetryAfter: 100 * time.Millisecond, Err: mail.ErrRateLimited}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", errs: nil, wantCalls: 1},
+		{name: "transient then success", errs: []error{transient, transient}, wantCalls: 3},
+		{name: "transient until attempts exhausted", errs: []error{transient, transient, transient, transient}, wantCalls: 3, wantErr: transient},
+		{name: "permanent is not retried", errs: []error{permanent}, wantCalls: 1, wantErr: permanent},
+		{name: "unclassified is not retried", errs: []error{errors.New("bug")}, wantCalls: 1},
+		{name: "rate limited is not retried", errs: []error{rateLimited}, wantCalls: 1, wantErr: rateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &scriptedService{errs: tt.errs}
+			service, _ := newTestRetryService(svc, 3)
+
+			err := service.SendMail(context.Background(), mail.MailAttributes{})
+			if svc.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", svc.calls, tt.wantCalls)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("SendMail() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantCalls == len(tt.errs)+1 && err != nil {
+				t.Errorf("SendMail() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestRetryService_BackoffIsJitteredAndGrows(t *testing.T) {
+	transient := mail.NewNetworkError("sendgrid", errors.New("connection reset"))
+	svc := &scriptedService{errs: []error{transient, transient, transient, transient, transient}}
+	service, delays := newTestRetryService(svc, 6)
+
+	if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	ceilings := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	if len(*delays) != len(ceilings) {
+		t.Fatalf("slept %d times, want %d", len(*delays), len(ceilings))
+	}
+	for i, delay := range *delays {
+		if delay < ceilings[i]/2 || delay > ceilings[i] {
+			t.Errorf("delay %d = %v, want between %v and %v", i+1, delay, ceilings[i]/2, ceilings[i])
+		}
+	}
+}
+
+func TestRetryService_HonorsRetryAfter(t *testing.T) {
+	rateLimited := mail.NewStatusError("sendgrid", 429, "1", errors.New("too many requests"))
+	svc := &scriptedService{errs: []error{rateLimited}}
+	service, delays := newTestRetryService(svc, 3)
+
+	if err := service.SendMail(context.Background(), mail.MailAttributes{}); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if len(*delays) != 1 || (*delays)[0] != time.Second {
+		t.Errorf("delays = %v, want [1s]", *delays)
+	}
+}
+
+func TestRetryService_RetryAfterAboveMaxBackoffGivesUp(t *testing.T) {
+	rateLimited := mail.NewStatusError("sendgrid", 429, "3600", errors.New("daily quota exceeded"))
+	svc := &scriptedService{errs: []error{rateLimited}}
+	service, delays := newTestRetryService(svc, 3)
+
+	err := service.SendMail(context.Background(), mail.MailAttributes{})
+	if !errors.Is(err, rateLimited) {
+		t.Errorf("SendMail() error = %v, want %v", err, rateLimited)
+	}
+	if svc.calls != 1 || len(*delays) != 0 {
+		t.Errorf("calls = %d, delays = %v, want a single attempt", svc.calls, *delays)
+	}
+}
+
+func TestRetryService_StopsWhenContextIsDone(t *testing.T) {
+	transient := mail.NewStatusError("mailjet", 503, "", errors.New("unavailable"))
+	svc := &scriptedService{errs: []error{transient, transient}}
+	service := NewRetryService(svc, config.RetryConfig{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := service.SendMail(ctx, mail.MailAttributes{})
+	if !errors.Is(err, transient) {
+		t.Errorf("SendMail() error = %v, want %v", err, transient)
+	}
+	if svc.calls != 1 {
+		t.Errorf("calls = %d, want 1", svc.calls)
+	}
+}
+
+// subjectService fails each mail with the errors scripted for its subject, in order.
+type subjectService struct {
+	errs map[string][]error
+	sent []string
+}
+
+func (s *subjectService) SendMail(_ context.Context, attributes mail.MailAttributes) error {
+	s.sent = append(s.sent, attributes.Subject)
+	errs := s.errs[attributes.Subject]
+	if len(errs) == 0 {
+		return nil
+	}
+	s.errs[attributes.Subject] = errs[1:]
+	return errs[0]
+}
+
+func TestRetryService_SendBatch(t *testing.T) {
+	transient := mail.NewStatusError("mailjet", 503, "", errors.New("unavailable"))
+	slow := mail.NewStatusError("mailjet", 429, "", errors.New("too many requests"))
+	slow.RetryAfter = 500 * time.Millisecond
+	permanent := mail.NewStatusError("mailjet", 400, "", errors.New("invalid recipient"))
+	svc := &subjectService{errs: map[string][]error{
+		"flaky":     {transient, slow},
+		"invalid":   {permanent},
+		"throttled": {slow, transient, transient},
+	}}
+	service, delays := newTestRetryService(svc, 3)
+
+	results := service.SendBatch(context.Background(), []mail.MailAttributes{
+		{Subject: "ok"}, {Subject: "flaky"}, {Subject: "invalid"}, {Subject: "throttled"},
+	})
+
+	want := []string{"ok", "flaky", "invalid", "throttled", "flaky", "throttled", "flaky", "throttled"}
+	if len(svc.sent) != len(want) {
+		t.Fatalf("sent %v, want %v", svc.sent, want)
+	}
+	for i := range want {
+		if svc.sent[i] != want[i] {
+			t.Fatalf("sent %v, want %v", svc.sent, want)
+		}
+	}
+	if results[0].Err != nil || results[1].Err != nil || !errors.Is(results[2].Err, permanent) || !errors.Is(results[3].Err, transient) {
+		t.Errorf("results = %+v", results)
+	}
+	// each round waits for the longest Retry-After of the mails it retries
+	if len(*delays) != 2 || (*delays)[0] != 500*time.Millisecond || (*delays)[1] != 500*time.Millisecond {
+		t.Errorf("delays = %v, want 500ms twice", *delays)
+	}
+}
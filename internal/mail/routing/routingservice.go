@@ -0,0 +1,259 @@
+// Package routing sends mail through different providers depending on its
+// recipients, sender, tags and API client.
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"slices"
+	"strings"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// DefaultRoute names the fallback service in logs and errors.
+const DefaultRoute = "default"
+
+// Route sends the recipients it matches to Service. A recipient matches when
+// every condition that is set matches; within a condition any entry may match.
+type Route struct {
+	Name string
+	// RecipientDomains match the domain of each recipient.
+	RecipientDomains []string
+	// Senders match the sender address, or its domain when written as "@example.com".
+	Senders []string
+	// Tags match any tag of the mail.
+	Tags []string
+	// Clients match the API client that submitted the mail.
+	Clients []string
+	Service mail.MailService
+}
+
+// RoutingService implements MailService by sending every recipient through
+// the first route that matches it, or through the fallback if none does.
+// A mail whose recipients match different routes is split into one mail per route.
+type RoutingService struct {
+	routes        []Route
+	fallback      mail.MailService
+	defaultSender string
+}
+
+// NewRoutingService creates a RoutingService. defaultSender is matched
+// against the sender conditions of mails that do not set From.
+func NewRoutingService(fallback mail.MailService, defaultSender string, routes ...Route) *RoutingService {
+	return &RoutingService{
+		routes:        routes,
+		fallback:      fallback,
+		defaultSender: defaultSender,
+	}
+}
+
+// part is the share of a mail that goes through one route.
+type part struct {
+	route   string
+	service mail.MailService
+	to      []string
+	cc      []string
+	bcc     []string
+}
+
+func (service *RoutingService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	parts := service.split(attributes)
+	if len(parts) == 1 {
+		slog.Debug("routing: mail routed", "route", parts[0].route)
+		return parts[0].service.SendMail(ctx, attributes)
+	}
+
+	var delivered, providers, messageIDs []string
+	var errs []error
+	for _, part := range parts {
+		slog.Info("routing: sending part of split mail", "route", part.route, "recipients", len(part.to)+len(part.cc)+len(part.bcc))
+		for _, partAttributes := range part.attributes(attributes) {
+			partCtx, delivery := mail.NewDeliveryContext(ctx)
+			if err := part.service.SendMail(partCtx, partAttributes); err != nil {
+				errs = append(errs, fmt.Errorf("route %s: %w", part.route, err))
+				continue
+			}
+			if !slices.Contains(delivered, part.route) {
+				delivered = append(delivered, part.route)
+			}
+			if delivery.Provider != "" && !slices.Contains(providers, delivery.Provider) {
+				providers = append(providers, delivery.Provider)
+			}
+			messageIDs = append(messageIDs, delivery.MessageIDs...)
+		}
+	}
+
+	if len(errs) == 0 {
+		mail.RecordDelivery(ctx, strings.Join(providers, ","), messageIDs...)
+		return nil
+	}
+	err := errors.Join(errs...)
+	if len(delivered) == 0 {
+		return err
+	}
+	// retrying the whole mail would deliver it twice to the recipients that already got it
+	return &mail.ProviderError{
+		Provider: strings.Join(providers, ","),
+		Err:      fmt.Errorf("mail was only delivered through routes %s: %w", strings.Join(delivered, ", "), err),
+	}
+}
+
+// SendBatch sends the mails of each route as one batch. Mails that have to
+// be split across routes are sent one by one.
+func (service *RoutingService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	results := make([]mail.BatchResult, len(mails))
+	// batch holds the mails that go entirely through one route
+	type batch struct {
+		route   string
+		service mail.MailService
+		indices []int
+	}
+	var batches []*batch
+	for i, attributes := range mails {
+		parts := service.split(attributes)
+		if len(parts) > 1 {
+			mailCtx, delivery := mail.NewDeliveryContext(ctx)
+			err := service.SendMail(mailCtx, attributes)
+			results[i] = mail.BatchResult{Delivery: *delivery, Err: err}
+			continue
+		}
+
+		index := slices.IndexFunc(batches, func(b *batch) bool { return b.route == parts[0].route })
+		if index < 0 {
+			index = len(batches)
+			batches = append(batches, &batch{route: parts[0].route, service: parts[0].service})
+		}
+		batches[index].indices = append(batches[index].indices, i)
+	}
+
+	for _, b := range batches {
+		slog.Debug("routing: batch routed", "route", b.route, "mails", len(b.indices))
+		mail.SendBatchSubset(ctx, b.service, mails, b.indices, results)
+	}
+	return results
+}
+
+// split groups the recipients of a mail by route, in order of their first recipient.
+func (service *RoutingService) split(attributes mail.MailAttributes) []*part {
+	sender, _ := attributes.Sender(service.defaultSender, "")
+	var parts []*part
+	partOf := func(recipient string) *part {
+		route, target := DefaultRoute, service.fallback
+		for _, candidate := range service.routes {
+			if candidate.matches(recipient, sender, attributes) {
+				route, target = candidate.Name, candidate.Service
+				break
+			}
+		}
+		for _, existing := range parts {
+			if existing.route == route {
+				return existing
+			}
+		}
+		created := &part{route: route, service: target}
+		parts = append(parts, created)
+		return created
+	}
+
+	for _, recipient := range attributes.ToAddresses() {
+		p := partOf(recipient)
+		p.to = append(p.to, recipient)
+	}
+	for _, recipient := range attributes.Cc {
+		p := partOf(recipient)
+		p.cc = append(p.cc, recipient)
+	}
+	for _, recipient := range attributes.Bcc {
+		p := partOf(recipient)
+		p.bcc = append(p.bcc, recipient)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, &part{route: DefaultRoute, service: service.fallback})
+	}
+	return parts
+}
+
+// attributes returns the mails that deliver the part. Providers need at least
+// one To recipient, so a part without one addresses its Cc recipients instead,
+// and a Bcc-only part becomes one mail per recipient so that they stay hidden
+// from each other.
+func (p *part) attributes(original mail.MailAttributes) []mail.MailAttributes {
+	attributes := original
+	attributes.To = strings.Join(p.to, ", ")
+	attributes.Cc = p.cc
+	attributes.Bcc = p.bcc
+	if len(p.to) > 0 {
+		return []mail.MailAttributes{attributes}
+	}
+	if len(p.cc) > 0 {
+		attributes.To = strings.Join(p.cc, ", ")
+		attributes.Cc = nil
+		return []mail.MailAttributes{attributes}
+	}
+
+	mails := make([]mail.MailAttributes, 0, len(p.bcc))
+	for _, recipient := range p.bcc {
+		single := attributes
+		single.To = recipient
+		single.Bcc = nil
+		mails = append(mails, single)
+	}
+	return mails
+}
+
+// matches reports whether the route applies to recipient of a mail from sender.
+func (route Route) matches(recipient, sender string, attributes mail.MailAttributes) bool {
+	if len(route.RecipientDomains) > 0 && !containsFold(route.RecipientDomains, domainOf(recipient)) {
+		return false
+	}
+	if len(route.Senders) > 0 && !matchesSender(route.Senders, sender) {
+		return false
+	}
+	if len(route.Tags) > 0 && !slices.ContainsFunc(attributes.Tags, func(tag string) bool { return containsFold(route.Tags, tag) }) {
+		return false
+	}
+	if len(route.Clients) > 0 && !slices.Contains(route.Clients, attributes.Client) {
+		return false
+	}
+	return true
+}
+
+// matchesSender reports whether sender is one of the addresses or, for
+// entries starting with "@", has one of the domains.
+func matchesSender(senders []string, sender string) bool {
+	address := bareAddress(sender)
+	for _, entry := range senders {
+		if domain, ok := strings.CutPrefix(entry, "@"); ok {
+			if strings.EqualFold(domain, domainOf(address)) {
+				return true
+			}
+		} else if strings.EqualFold(entry, address) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	return slices.ContainsFunc(list, func(entry string) bool { return strings.EqualFold(entry, value) })
+}
+
+// bareAddress strips the display name from an address such as "Jane <jane@example.com>".
+func bareAddress(address string) string {
+	if parsed, err := netmail.ParseAddress(address); err == nil {
+		return parsed.Address
+	}
+	return strings.TrimSpace(address)
+}
+
+func domainOf(address string) string {
+	address = bareAddress(address)
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return address[at+1:]
+	}
+	return ""
+}
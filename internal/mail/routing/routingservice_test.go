@@ -0,0 +1,287 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// recordingService records the mails it receives and fails with err.
+type recordingService struct {
+	name  string
+	err   error
+	mails []mail.MailAttributes
+}
+
+func (s *recordingService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	s.mails = append(s.mails, attributes)
+	if s.err != nil {
+		return s.err
+	}
+	mail.RecordDelivery(ctx, s.name, s.name+"-id")
+	return nil
+}
+
+func TestRoutingService_Matching(t *testing.T) {
+	tests := []struct {
+		name       string
+		route      Route
+		attributes mail.MailAttributes
+		wantRouted bool
+	}{
+		{
+			name:       "recipient domain",
+			route:      Route{RecipientDomains: []string{"corp.example.com"}},
+			attributes: mail.MailAttributes{To: "Jane <jane@CORP.example.com>"},
+			wantRouted: true,
+		},
+		{
+			name:       "other recipient domain",
+			route:      Route{RecipientDomains: []string{"corp.example.com"}},
+			attributes: mail.MailAttributes{To: "jane@example.org"},
+		},
+		{
+			name:       "sender address",
+			route:      Route{Senders: []string{"billing@example.com"}},
+			attributes: mail.MailAttributes{To: "a@example.org", From: "billing@example.com"},
+			wantRouted: true,
+		},
+		{
+			name:       "sender domain",
+			route:      Route{Senders: []string{"@example.com"}},
+			attributes: mail.MailAttributes{To: "a@example.org", From: "billing@example.com"},
+			wantRouted: true,
+		},
+		{
+			name:       "default sender",
+			route:      Route{Senders: []string{"noreply@example.com"}},
+			attributes: mail.MailAttributes{To: "a@example.org"},
+			wantRouted: true,
+		},
+		{
+			name:       "tag",
+			route:      Route{Tags: []string{"newsletter"}},
+			attributes: mail.MailAttributes{To: "a@example.org", Tags: []string{"marketing", "Newsletter"}},
+			wantRouted: true,
+		},
+		{
+			name:       "missing tag",
+			route:      Route{Tags: []string{"newsletter"}},
+			attributes: mail.MailAttributes{To: "a@example.org"},
+		},
+		{
+			name:       "client",
+			route:      Route{Clients: []string{"billing-api"}},
+			attributes: mail.MailAttributes{To: "a@example.org", Client: "billing-api"},
+			wantRouted: true,
+		},
+		{
+			name:       "all conditions must match",
+			route:      Route{RecipientDomains: []string{"example.org"}, Clients: []string{"billing-api"}},
+			attributes: mail.MailAttributes{To: "a@example.org", Client: "crm"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routed := &recordingService{name: "routed"}
+			fallback := &recordingService{name: "fallback"}
+			tt.route.Name = "route"
+			tt.route.Service = routed
+
+			service := NewRoutingService(fallback, "noreply@example.com", tt.route)
+			if err := service.SendMail(context.Background(), tt.attributes); err != nil {
+				t.Fatalf("SendMail() error = %v", err)
+			}
+			if got := len(routed.mails) == 1; got != tt.wantRouted {
+				t.Errorf("routed = %v, want %v (fallback got %d mails)", got, tt.wantRouted, len(fallback.mails))
+			}
+			if len(routed.mails)+len(fallback.mails) != 1 {
+				t.Errorf("mail sent %d times, want once", len(routed.mails)+len(fallback.mails))
+			}
+		})
+	}
+}
+
+func TestRoutingService_FirstMatchingRouteWins(t *testing.T) {
+	first := &recordingService{name: "first"}
+	second := &recordingService{name: "second"}
+	service := NewRoutingService(&recordingService{name: "fallback"}, "",
+		Route{Name: "first", Tags: []string{"invoice"}, Service: first},
+		Route{Name: "second", RecipientDomains: []string{"example.com"}, Service: second},
+	)
+
+	err := service.SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Tags: []string{"invoice"}})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if len(first.mails) != 1 || len(second.mails) != 0 {
+		t.Errorf("first got %d mails, second %d, want 1 and 0", len(first.mails), len(second.mails))
+	}
+}
+
+func TestRoutingService_SplitsRecipients(t *testing.T) {
+	relay := &recordingService{name: "smtp"}
+	fallback := &recordingService{name: "sendgrid"}
+	service := NewRoutingService(fallback, "noreply@example.com",
+		Route{Name: "internal", RecipientDomains: []string{"corp.example.com"}, Service: relay},
+	)
+
+	original := mail.MailAttributes{
+		To:      "a@corp.example.com, b@example.org",
+		Cc:      []string{"c@example.org"},
+		Bcc:     []string{"d@corp.example.com"},
+		Subject: "Hello",
+	}
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	if err := service.SendMail(ctx, original); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	if len(relay.mails) != 1 || len(fallback.mails) != 1 {
+		t.Fatalf("relay got %d mails, fallback %d, want 1 each", len(relay.mails), len(fallback.mails))
+	}
+	internal := relay.mails[0]
+	if internal.To != "a@corp.example.com" || internal.Cc != nil || !reflect.DeepEqual(internal.Bcc, []string{"d@corp.example.com"}) {
+		t.Errorf("internal part = to %q cc %v bcc %v", internal.To, internal.Cc, internal.Bcc)
+	}
+	external := fallback.mails[0]
+	if external.To != "b@example.org" || !reflect.DeepEqual(external.Cc, []string{"c@example.org"}) || external.Bcc != nil {
+		t.Errorf("external part = to %q cc %v bcc %v", external.To, external.Cc, external.Bcc)
+	}
+	if external.Subject != "Hello" {
+		t.Errorf("external part subject = %q, want Hello", external.Subject)
+	}
+
+	if delivery.Provider != "smtp,sendgrid" {
+		t.Errorf("Provider = %q, want smtp,sendgrid", delivery.Provider)
+	}
+	if want := []string{"smtp-id", "sendgrid-id"}; !reflect.DeepEqual(delivery.MessageIDs, want) {
+		t.Errorf("MessageIDs = %v, want %v", delivery.MessageIDs, want)
+	}
+}
+
+func TestRoutingService_SplitPartsWithoutTo(t *testing.T) {
+	relay := &recordingService{name: "smtp"}
+	fallback := &recordingService{name: "sendgrid"}
+	service := NewRoutingService(fallback, "",
+		Route{Name: "internal", RecipientDomains: []string{"corp.example.com"}, Service: relay},
+	)
+
+	err := service.SendMail(context.Background(), mail.MailAttributes{
+		To:  "a@example.org",
+		Cc:  []string{"b@corp.example.com"},
+		Bcc: []string{"c@corp.example.com", "d@corp.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	// the Cc recipient becomes the To recipient; the Bcc recipients keep being hidden
+	if len(relay.mails) != 1 || relay.mails[0].To != "b@corp.example.com" || relay.mails[0].Cc != nil || len(relay.mails[0].Bcc) != 2 {
+		t.Errorf("relay mails = %+v", relay.mails)
+	}
+
+	relay.mails, fallback.mails = nil, nil
+	err = service.SendMail(context.Background(), mail.MailAttributes{
+		To:  "a@example.org",
+		Bcc: []string{"c@corp.example.com", "d@corp.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if len(relay.mails) != 2 || relay.mails[0].To != "c@corp.example.com" || relay.mails[1].To != "d@corp.example.com" {
+		t.Fatalf("relay mails = %+v, want one per Bcc recipient", relay.mails)
+	}
+	for _, m := range relay.mails {
+		if m.Bcc != nil || m.Cc != nil {
+			t.Errorf("Bcc-only part leaks recipients: %+v", m)
+		}
+	}
+}
+
+func TestRoutingService_SplitFailures(t *testing.T) {
+	transient := mail.NewStatusError("smtp", 503, "", errors.New("try again later"))
+
+	tests := []struct {
+		name          string
+		relayErr      error
+		fallbackErr   error
+		wantTransient bool
+	}{
+		{name: "nothing delivered keeps the classification", relayErr: transient, fallbackErr: transient, wantTransient: true},
+		{name: "partial delivery is permanent", relayErr: transient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay := &recordingService{name: "smtp", err: tt.relayErr}
+			fallback := &recordingService{name: "sendgrid", err: tt.fallbackErr}
+			service := NewRoutingService(fallback, "",
+				Route{Name: "internal", RecipientDomains: []string{"corp.example.com"}, Service: relay},
+			)
+
+			err := service.SendMail(context.Background(), mail.MailAttributes{To: "a@corp.example.com, b@example.org"})
+			if err == nil {
+				t.Fatal("SendMail() expected error")
+			}
+			if len(relay.mails) != 1 || len(fallback.mails) != 1 {
+				t.Errorf("relay got %d mails, fallback %d, want both parts attempted", len(relay.mails), len(fallback.mails))
+			}
+			if mail.IsTransient(err) != tt.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v (error: %v)", mail.IsTransient(err), tt.wantTransient, err)
+			}
+		})
+	}
+}
+
+// batchingService records the subjects of each batch it receives.
+type batchingService struct {
+	recordingService
+	batches [][]string
+}
+
+func (s *batchingService) SendBatch(_ context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	var subjects []string
+	results := make([]mail.BatchResult, len(mails))
+	for i, attributes := range mails {
+		subjects = append(subjects, attributes.Subject)
+		results[i].Delivery = mail.Delivery{Provider: s.name}
+	}
+	s.batches = append(s.batches, subjects)
+	return results
+}
+
+func TestRoutingService_SendBatch(t *testing.T) {
+	relay := &batchingService{recordingService: recordingService{name: "smtp"}}
+	fallback := &batchingService{recordingService: recordingService{name: "sendgrid"}}
+	service := NewRoutingService(fallback, "noreply@example.com",
+		Route{Name: "internal", RecipientDomains: []string{"corp.example.com"}, Service: relay},
+	)
+
+	results := service.SendBatch(context.Background(), []mail.MailAttributes{
+		{To: "a@example.org", Subject: "external 1"},
+		{To: "b@corp.example.com", Subject: "internal"},
+		{To: "c@corp.example.com, d@example.org", Subject: "split"},
+		{To: "e@example.org", Subject: "external 2"},
+	})
+
+	if want := [][]string{{"external 1", "external 2"}}; !reflect.DeepEqual(fallback.batches, want) {
+		t.Errorf("fallback batches = %v, want %v", fallback.batches, want)
+	}
+	if want := [][]string{{"internal"}}; !reflect.DeepEqual(relay.batches, want) {
+		t.Errorf("relay batches = %v, want %v", relay.batches, want)
+	}
+	// the split mail is sent part by part
+	if len(relay.mails) != 1 || len(fallback.mails) != 1 || relay.mails[0].To != "c@corp.example.com" {
+		t.Errorf("relay got %v, fallback %v, want one part each", relay.mails, fallback.mails)
+	}
+	wantProviders := []string{"sendgrid", "smtp", "smtp,sendgrid", "sendgrid"}
+	for i, result := range results {
+		if result.Err != nil || result.Delivery.Provider != wantProviders[i] {
+			t.Errorf("results[%d] = %+v, want delivered by %s", i, result, wantProviders[i])
+		}
+	}
+}
@@ -1,17 +1,33 @@
 package sendgrid
 
+import "strings"
+
+// DefaultBaseURL is the public SendGrid API.
+const DefaultBaseURL = "https://api.sendgrid.com"
+
 // SendGridConfig contains all attributes to initialize the SendGrid mail service.
 type SendGridConfig struct {
 	APIKey        string
 	OriginAddress string
 	OriginName    string
+	// BaseURL overrides DefaultBaseURL when set.
+	BaseURL string
 }
 
 // NewSendGridConfig creates a SendGridConfig from the provided credentials and sender identity.
-func NewSendGridConfig(apiKey, originAddress, originName string) *SendGridConfig {
+func NewSendGridConfig(apiKey, originAddress, originName, baseURL string) *SendGridConfig {
 	return &SendGridConfig{
 		APIKey:        apiKey,
 		OriginAddress: originAddress,
 		OriginName:    originName,
+		BaseURL:       baseURL,
+	}
+}
+
+// baseURL returns the API base URL without a trailing slash.
+func (config *SendGridConfig) baseURL() string {
+	if config.BaseURL == "" {
+		return DefaultBaseURL
 	}
+	return strings.TrimSuffix(config.BaseURL, "/")
 }
@@ -5,7 +5,7 @@ import (
 )
 
 func TestNewSendGridConfig(t *testing.T) {
-	cfg := NewSendGridConfig("api-key", "sender@example.com", "Sender Name")
+	cfg := NewSendGridConfig("api-key", "sender@example.com", "Sender Name", "http://localhost:8082")
 
 	if cfg.APIKey != "api-key" {
 		t.Errorf("APIKey = %q, want %q", cfg.APIKey, "api-key")
@@ -16,4 +16,23 @@ func TestNewSendGridConfig(t *testing.T) {
 	if cfg.OriginName != "Sender Name" {
 		t.Errorf("OriginName = %q, want %q", cfg.OriginName, "Sender Name")
 	}
+	if cfg.BaseURL != "http://localhost:8082" {
+		t.Errorf("BaseURL = %q, want %q", cfg.BaseURL, "http://localhost:8082")
+	}
+}
+
+func TestSendGridConfig_baseURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{baseURL: "", want: DefaultBaseURL},
+		{baseURL: "http://localhost:8082/", want: "http://localhost:8082"},
+	}
+	for _, tt := range tests {
+		cfg := SendGridConfig{BaseURL: tt.baseURL}
+		if got := cfg.baseURL(); got != tt.want {
+			t.Errorf("baseURL() with BaseURL %q = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
 }
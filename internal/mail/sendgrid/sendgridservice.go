@@ -4,24 +4,35 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strings"
 
 	"github.com/jo-hoe/go-mail-service/internal/mail"
 
+	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
 	sgmail "github.com/sendgrid/sendgrid-go/helpers/mail"
 )
 
+// providerName identifies SendGrid in delivery records and errors
+const providerName = "sendgrid"
+
+// maxRecipients is the most recipients SendGrid accepts in one request,
+// counting to, cc and bcc of all its personalizations
+const maxRecipients = 1000
+
 // SendGridService implements MailService
 type SendGridService struct {
 	config   *SendGridConfig
+	client   *rest.Client
 	messages []*sgmail.SGMailV3
 }
 
-// NewSendGridService creates a SendGridService using an already initializes service
-func NewSendGridService(config *SendGridConfig) *SendGridService {
+// NewSendGridService creates a SendGridService that sends its requests with client
+func NewSendGridService(config *SendGridConfig, client *http.Client) *SendGridService {
 	return &SendGridService{
 		config:   config,
+		client:   &rest.Client{HTTPClient: client},
 		messages: make([]*sgmail.SGMailV3, 0),
 	}
 }
@@ -46,57 +57,158 @@ func (service *SendGridService) createMessage(attributes mail.MailAttributes) *s
 	// create new *SGMailV3
 	mailObject := sgmail.NewV3Mail()
 
-	from := sgmail.NewEmail(service.config.OriginName, service.config.OriginAddress)
-	content := sgmail.NewContent("text/html", attributes.HtmlContent)
-
+	address, name := attributes.Sender(service.config.OriginAddress, service.config.OriginName)
+	from := sgmail.NewEmail(name, address)
 	mailObject.SetFrom(from)
-	mailObject.AddContent(content)
+	// SendGrid requires text/plain to precede text/html
+	mailObject.AddContent(
+		sgmail.NewContent("text/plain", attributes.PlainText()),
+		sgmail.NewContent("text/html", attributes.HtmlContent),
+	)
 
 	// create new *Personalization
 	personalization := sgmail.NewPersonalization()
 
 	personalization.Subject = attributes.Subject
-	// populate `personalization` with data
-	emails := []*sgmail.Email{}
+	personalization.AddTos(toSendGridEmails(attributes.ToAddresses())...)
+	personalization.AddCCs(toSendGridEmails(attributes.Cc)...)
+	personalization.AddBCCs(toSendGridEmails(attributes.Bcc)...)
 
-	mailAddresses := strings.Split(attributes.To, ",")
-	for _, mailAddress := range mailAddresses {
-		mail, _ := sgmail.ParseEmail(mailAddress)
-		emails = append(emails, mail)
+	if attributes.ReplyTo != "" {
+		mailObject.SetReplyTo(sgmail.NewEmail("", attributes.ReplyTo))
 	}
 
-	personalization.AddTos(emails...)
+	for _, attachment := range attributes.Attachments {
+		mailObject.AddAttachment(toSendGridAttachment(attachment))
+	}
 
 	// add `personalization` to `m`
 	mailObject.AddPersonalizations(personalization)
 	return mailObject
 }
 
+// toSendGridEmails parses plain addresses into SendGrid emails, skipping blanks
+func toSendGridEmails(addresses []string) []*sgmail.Email {
+	emails := []*sgmail.Email{}
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		email, err := sgmail.ParseEmail(address)
+		if err != nil {
+			email = sgmail.NewEmail("", address)
+		}
+		emails = append(emails, email)
+	}
+	return emails
+}
+
+// toSendGridAttachment maps an attachment, marking it inline when it carries a content id
+func toSendGridAttachment(attachment mail.Attachment) *sgmail.Attachment {
+	sgAttachment := sgmail.NewAttachment().
+		SetContent(attachment.Content).
+		SetType(attachment.ContentType).
+		SetFilename(attachment.Filename)
+
+	if attachment.IsInline() {
+		return sgAttachment.SetDisposition("inline").SetContentID(attachment.ContentID)
+	}
+	return sgAttachment.SetDisposition("attachment")
+}
+
 func (service *SendGridService) sendRequest(ctx context.Context, mailObject *sgmail.SGMailV3) error {
+	messageIDs, err := service.post(ctx, mailObject)
+	if err != nil {
+		return err
+	}
+	mail.RecordDelivery(ctx, providerName, messageIDs...)
+	return nil
+}
+
+// SendBatch sends mails that differ only in their recipients and subject as
+// personalizations of a single request. SendGrid accepts or rejects a request
+// as a whole, so its outcome applies to every mail in it.
+func (service *SendGridService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	slog.Info("sendgrid: preparing to send batch", "mails", len(mails))
+
+	// request is a mail/send request, the indices of the mails it carries and
+	// the number of their recipients
+	type request struct {
+		mailObject *sgmail.SGMailV3
+		indices    []int
+		recipients int
+	}
+	var requests []*request
+	byContent := make(map[string]*request)
+	for i, attributes := range mails {
+		mailObject := service.createMessage(attributes)
+		personalizations := mailObject.Personalizations
+		mailObject.Personalizations = nil
+
+		recipients := 0
+		for _, p := range personalizations {
+			recipients += len(p.To) + len(p.CC) + len(p.BCC)
+		}
+
+		key := string(sgmail.GetRequestBody(mailObject))
+		r, ok := byContent[key]
+		if !ok || r.recipients+recipients > maxRecipients {
+			r = &request{mailObject: mailObject}
+			byContent[key] = r
+			requests = append(requests, r)
+		}
+		r.mailObject.AddPersonalizations(personalizations...)
+		r.indices = append(r.indices, i)
+		r.recipients += recipients
+	}
+
+	results := make([]mail.BatchResult, len(mails))
+	for _, r := range requests {
+		messageIDs, err := service.post(ctx, r.mailObject)
+		for _, i := range r.indices {
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			results[i].Delivery = mail.Delivery{Provider: providerName, MessageIDs: messageIDs}
+		}
+	}
+	return results
+}
+
+// post sends a mail/send request and returns the message IDs SendGrid assigned.
+func (service *SendGridService) post(ctx context.Context, mailObject *sgmail.SGMailV3) ([]string, error) {
 	request := sendgrid.GetRequest(
 		service.config.APIKey,
 		"/v3/mail/send",
-		"https://api.sendgrid.com",
+		service.config.baseURL(),
 	)
 
 	request.Method = "POST"
 	request.Body = sgmail.GetRequestBody(mailObject)
 
-	slog.Info("sendgrid: sending request to SendGrid API")
-	result, err := sendgrid.MakeRequestWithContext(ctx, request)
+	slog.Info("sendgrid: sending request to SendGrid API", "personalizations", len(mailObject.Personalizations))
+	result, err := service.client.SendWithContext(ctx, request)
 
 	if err != nil {
 		slog.Error("sendgrid: request error", "error", err)
-		return err
+		return nil, mail.NewNetworkError(providerName, err)
 	}
 
 	slog.Info("sendgrid: received response", "status_code", result.StatusCode)
 
 	if result.StatusCode != 202 {
 		slog.Error("sendgrid: API error", "status_code", result.StatusCode, "body", result.Body)
-		return fmt.Errorf("SendGrid could not send mail. [%d]: %s", result.StatusCode, result.Body)
+		return nil, mail.NewStatusError(providerName, result.StatusCode, firstHeader(result.Headers, "Retry-After"),
+			fmt.Errorf("SendGrid could not send mail. [%d]: %s", result.StatusCode, result.Body))
 	}
 
 	slog.Debug("sendgrid: response headers", "headers", result.Headers)
-	return nil
+	return result.Headers["X-Message-Id"], nil
+}
+
+// firstHeader returns the first value of a response header, matching the name case-insensitively.
+func firstHeader(headers map[string][]string, name string) string {
+	return http.Header(headers).Get(name)
 }
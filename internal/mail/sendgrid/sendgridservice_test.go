@@ -1,15 +1,22 @@
 package sendgrid
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/fakeesp"
 )
 
 func Test_Init(t *testing.T) {
 	config := getTestConfig()
 
-	sender := NewSendGridService(&config)
+	sender := NewSendGridService(&config, http.DefaultClient)
 
 	if sender == nil {
 		t.Errorf("Sendgrid not initialized")
@@ -19,7 +26,7 @@ func Test_Init(t *testing.T) {
 func Test_AddMessage(t *testing.T) {
 	config := getTestConfig()
 
-	sender := NewSendGridService(&config)
+	sender := NewSendGridService(&config, http.DefaultClient)
 	message := sender.createMessage(mail.MailAttributes{
 		To:          "test@test.com",
 		Subject:     "test",
@@ -38,3 +45,311 @@ func getTestConfig() SendGridConfig {
 		OriginName:    "testname",
 	}
 }
+
+func Test_AddMessage_CcBccReplyTo(t *testing.T) {
+	config := getTestConfig()
+
+	sender := NewSendGridService(&config, http.DefaultClient)
+	message := sender.createMessage(mail.MailAttributes{
+		To:          "a@test.com, b@test.com",
+		Cc:          []string{"manager@test.com"},
+		Bcc:         []string{"audit@test.com"},
+		ReplyTo:     "support@test.com",
+		Subject:     "test",
+		HtmlContent: "test content",
+	})
+
+	if len(message.Personalizations) != 1 {
+		t.Fatalf("Expected 1 personalization, got %d", len(message.Personalizations))
+	}
+	personalization := message.Personalizations[0]
+	if len(personalization.To) != 2 {
+		t.Errorf("Expected 2 recipients, got %d", len(personalization.To))
+	}
+	if len(personalization.CC) != 1 || personalization.CC[0].Address != "manager@test.com" {
+		t.Errorf("Expected cc manager@test.com, got %v", personalization.CC)
+	}
+	if len(personalization.BCC) != 1 || personalization.BCC[0].Address != "audit@test.com" {
+		t.Errorf("Expected bcc audit@test.com, got %v", personalization.BCC)
+	}
+	if message.ReplyTo == nil || message.ReplyTo.Address != "support@test.com" {
+		t.Errorf("Expected reply-to support@test.com, got %v", message.ReplyTo)
+	}
+}
+
+func Test_AddMessage_Attachments(t *testing.T) {
+	config := getTestConfig()
+
+	sender := NewSendGridService(&config, http.DefaultClient)
+	message := sender.createMessage(mail.MailAttributes{
+		To:          "test@test.com",
+		Subject:     "test",
+		HtmlContent: `<img src="cid:logo">`,
+		Attachments: []mail.Attachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Content: "JVBERi0xLjQ="},
+			{Filename: "logo.png", ContentType: "image/png", Content: "iVBORw0KGgo=", ContentID: "logo"},
+		},
+	})
+
+	if len(message.Attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(message.Attachments))
+	}
+	if message.Attachments[0].Disposition != "attachment" {
+		t.Errorf("Expected disposition attachment, got %s", message.Attachments[0].Disposition)
+	}
+	if message.Attachments[1].Disposition != "inline" || message.Attachments[1].ContentID != "logo" {
+		t.Errorf("Expected inline attachment with content id logo, got %+v", message.Attachments[1])
+	}
+}
+
+func Test_AddMessage_TextAndHTMLContent(t *testing.T) {
+	config := getTestConfig()
+
+	sender := NewSendGridService(&config, http.DefaultClient)
+	message := sender.createMessage(mail.MailAttributes{
+		To:          "test@test.com",
+		Subject:     "test",
+		HtmlContent: "<p>Hello</p>",
+		TextContent: "Hello text",
+	})
+
+	if len(message.Content) != 2 {
+		t.Fatalf("Expected 2 content parts, got %d", len(message.Content))
+	}
+	if message.Content[0].Type != "text/plain" || message.Content[0].Value != "Hello text" {
+		t.Errorf("Expected first content text/plain %q, got %+v", "Hello text", message.Content[0])
+	}
+	if message.Content[1].Type != "text/html" || message.Content[1].Value != "<p>Hello</p>" {
+		t.Errorf("Expected second content text/html, got %+v", message.Content[1])
+	}
+}
+
+func Test_AddMessage_Sender(t *testing.T) {
+	config := getTestConfig()
+	sender := NewSendGridService(&config, http.DefaultClient)
+
+	tests := []struct {
+		name        string
+		attributes  mail.MailAttributes
+		wantAddress string
+		wantName    string
+	}{
+		{
+			name:        "configured sender",
+			attributes:  mail.MailAttributes{To: "test@test.com"},
+			wantAddress: config.OriginAddress,
+			wantName:    config.OriginName,
+		},
+		{
+			name:        "per-request sender",
+			attributes:  mail.MailAttributes{To: "test@test.com", From: "billing@test.com", FromName: "Billing"},
+			wantAddress: "billing@test.com",
+			wantName:    "Billing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := sender.createMessage(tt.attributes)
+			if message.From.Address != tt.wantAddress || message.From.Name != tt.wantName {
+				t.Errorf("Expected from %s <%s>, got %s <%s>", tt.wantName, tt.wantAddress, message.From.Name, message.From.Address)
+			}
+		})
+	}
+}
+
+func TestSendGridService_SendMail_FakeServer(t *testing.T) {
+	server := fakeesp.NewSendGridServer("testkey")
+	defer server.Close()
+
+	config := getTestConfig()
+	config.OriginAddress = "sender@example.com"
+	config.BaseURL = server.URL()
+	service := NewSendGridService(&config, http.DefaultClient)
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	err := service.SendMail(ctx, mail.MailAttributes{
+		To:          "a@example.com",
+		Bcc:         []string{"b@example.com"},
+		ReplyTo:     "reply@example.com",
+		Subject:     "Hello",
+		HtmlContent: "<p>Hi</p>",
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("fake received %d mails, want 1", len(mails))
+	}
+	got := mails[0]
+	if got.From == nil || got.From.Email != "sender@example.com" || got.ReplyTo == nil || got.ReplyTo.Email != "reply@example.com" {
+		t.Errorf("From/ReplyTo = %+v/%+v", got.From, got.ReplyTo)
+	}
+	if len(got.Personalizations) != 1 || got.Personalizations[0].Subject != "Hello" || len(got.Personalizations[0].Bcc) != 1 {
+		t.Errorf("personalizations = %+v", got.Personalizations)
+	}
+	if len(got.Content) != 2 || got.Content[0].Type != "text/plain" || got.Content[1].Value != "<p>Hi</p>" {
+		t.Errorf("content = %+v", got.Content)
+	}
+	if delivery.Provider != "sendgrid" {
+		t.Errorf("Provider = %q, want sendgrid", delivery.Provider)
+	}
+	if want := []string{"sendgrid-1"}; !reflect.DeepEqual(delivery.MessageIDs, want) {
+		t.Errorf("MessageIDs = %v, want %v", delivery.MessageIDs, want)
+	}
+}
+
+func TestSendGridService_SendMail_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name           string
+		response       fakeesp.Response
+		wantTransient  bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:          "bad request",
+			response:      fakeesp.SendGridErrors(http.StatusBadRequest, "Does not contain a valid address."),
+			wantTransient: false,
+		},
+		{
+			name:           "rate limited",
+			response:       fakeesp.TooManyRequests("7"),
+			wantTransient:  true,
+			wantRetryAfter: 7 * time.Second,
+		},
+		{
+			name:          "server error",
+			response:      fakeesp.SendGridErrors(http.StatusBadGateway, "upstream unavailable"),
+			wantTransient: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeesp.NewSendGridServer("testkey")
+			defer server.Close()
+			server.FailNext(tt.response)
+
+			config := getTestConfig()
+			config.BaseURL = server.URL()
+			service := NewSendGridService(&config, http.DefaultClient)
+
+			err := service.SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+			if err == nil {
+				t.Fatal("SendMail() expected error")
+			}
+			if mail.IsTransient(err) != tt.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v (error: %v)", mail.IsTransient(err), tt.wantTransient, err)
+			}
+			if got := mail.RetryAfter(err); got != tt.wantRetryAfter {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestSendGridService_SendMail_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	config := getTestConfig()
+	config.BaseURL = server.URL
+	service := NewSendGridService(&config, &http.Client{Timeout: 50 * time.Millisecond})
+
+	err := service.SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+	if !mail.IsTransient(err) {
+		t.Errorf("IsTransient() = false, want true for error %v", err)
+	}
+}
+
+func TestSendGridService_SendBatch_FakeServer(t *testing.T) {
+	server := fakeesp.NewSendGridServer("testkey")
+	defer server.Close()
+
+	config := getTestConfig()
+	config.OriginAddress = "sender@example.com"
+	config.BaseURL = server.URL()
+	service := NewSendGridService(&config, http.DefaultClient)
+
+	results := service.SendBatch(context.Background(), []mail.MailAttributes{
+		{To: "a@example.com", Subject: "Invoice for A", HtmlContent: "<p>Your invoice</p>"},
+		{To: "b@example.com", Subject: "Reminder", HtmlContent: "<p>Please pay</p>"},
+		{To: "c@example.com", Subject: "Invoice for C", HtmlContent: "<p>Your invoice</p>"},
+	})
+
+	// the invoices share their content and go as personalizations of one request
+	mails := server.Mails()
+	if len(mails) != 2 {
+		t.Fatalf("fake received %d mails, want 2", len(mails))
+	}
+	if p := mails[0].Personalizations; len(p) != 2 || p[0].To[0].Email != "a@example.com" || p[1].Subject != "Invoice for C" {
+		t.Errorf("personalizations = %+v, want the invoices of a and c", p)
+	}
+	if p := mails[1].Personalizations; len(p) != 1 || p[0].Subject != "Reminder" {
+		t.Errorf("personalizations = %+v, want the reminder", p)
+	}
+
+	want := []string{"sendgrid-1", "sendgrid-2", "sendgrid-1"}
+	for i, result := range results {
+		if result.Err != nil || result.Delivery.Provider != "sendgrid" || !reflect.DeepEqual(result.Delivery.MessageIDs, want[i:i+1]) {
+			t.Errorf("results[%d] = %+v, want delivered as %s", i, result, want[i])
+		}
+	}
+}
+
+func TestSendGridService_SendBatch_RecipientLimit(t *testing.T) {
+	server := fakeesp.NewSendGridServer("testkey")
+	defer server.Close()
+
+	config := getTestConfig()
+	config.BaseURL = server.URL()
+	service := NewSendGridService(&config, http.DefaultClient)
+
+	// each mail has 400 recipients, so only two of them fit into a request
+	mails := make([]mail.MailAttributes, 3)
+	for i := range mails {
+		mails[i] = mail.MailAttributes{To: fmt.Sprintf("to%d@example.com", i), Subject: "s", HtmlContent: "c"}
+		for j := range 199 {
+			mails[i].Cc = append(mails[i].Cc, fmt.Sprintf("cc%d-%d@example.com", i, j))
+			mails[i].Bcc = append(mails[i].Bcc, fmt.Sprintf("bcc%d-%d@example.com", i, j))
+		}
+		mails[i].Bcc = append(mails[i].Bcc, fmt.Sprintf("bcc%d-199@example.com", i))
+	}
+
+	results := service.SendBatch(context.Background(), mails)
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("results[%d] error = %v, want delivered", i, result.Err)
+		}
+	}
+	got := server.Mails()
+	if len(got) != 2 || len(got[0].Personalizations) != 2 || len(got[1].Personalizations) != 1 {
+		t.Errorf("fake received %d requests, want the first two mails in one request and the third in another", len(got))
+	}
+}
+
+func TestSendGridService_SendBatch_RejectedRequest(t *testing.T) {
+	server := fakeesp.NewSendGridServer("testkey")
+	defer server.Close()
+	server.FailNext(fakeesp.TooManyRequests("5"))
+
+	config := getTestConfig()
+	config.BaseURL = server.URL()
+	service := NewSendGridService(&config, http.DefaultClient)
+
+	results := service.SendBatch(context.Background(), []mail.MailAttributes{
+		{To: "a@example.com", Subject: "s", HtmlContent: "c"},
+		{To: "b@example.com", Subject: "s", HtmlContent: "c"},
+	})
+	for i, result := range results {
+		if !mail.IsTransient(result.Err) || mail.RetryAfter(result.Err) != 5*time.Second {
+			t.Errorf("results[%d] error = %v, want the transient rate limit", i, result.Err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package ses
+
+import "github.com/jo-hoe/go-mail-service/internal/config"
+
+// SESConfig contains all attributes to initialize the SES mail service.
+type SESConfig struct {
+	Region string
+	// Endpoint is the base URL of the SES API, e.g. https://email.eu-west-1.amazonaws.com.
+	Endpoint         string
+	Credentials      Credentials
+	ConfigurationSet string
+	OriginAddress    string
+	OriginName       string
+}
+
+// NewSESConfig creates an SESConfig from the provider settings and sender identity.
+func NewSESConfig(provider config.SESProviderConfig, originAddress, originName string) *SESConfig {
+	return &SESConfig{
+		Region:   provider.Region,
+		Endpoint: provider.Endpoint,
+		Credentials: Credentials{
+			AccessKeyID:     provider.AccessKeyID,
+			SecretAccessKey: provider.SecretAccessKey,
+			SessionToken:    provider.SessionToken,
+		},
+		ConfigurationSet: provider.ConfigurationSet,
+		OriginAddress:    originAddress,
+		OriginName:       originName,
+	}
+}
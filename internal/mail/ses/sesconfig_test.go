@@ -0,0 +1,38 @@
+package ses
+
+import (
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+)
+
+func TestNewSESConfig(t *testing.T) {
+	cfg := NewSESConfig(config.SESProviderConfig{
+		Region:           "eu-west-1",
+		Endpoint:         "https://email.eu-west-1.amazonaws.com",
+		AccessKeyID:      "key-id",
+		SecretAccessKey:  "secret",
+		SessionToken:     "token",
+		ConfigurationSet: "tracking",
+	}, "sender@example.com", "Sender Name")
+
+	if cfg.Region != "eu-west-1" {
+		t.Errorf("Region = %q, want %q", cfg.Region, "eu-west-1")
+	}
+	if cfg.Endpoint != "https://email.eu-west-1.amazonaws.com" {
+		t.Errorf("Endpoint = %q", cfg.Endpoint)
+	}
+	want := Credentials{AccessKeyID: "key-id", SecretAccessKey: "secret", SessionToken: "token"}
+	if cfg.Credentials != want {
+		t.Errorf("Credentials = %+v, want %+v", cfg.Credentials, want)
+	}
+	if cfg.ConfigurationSet != "tracking" {
+		t.Errorf("ConfigurationSet = %q, want %q", cfg.ConfigurationSet, "tracking")
+	}
+	if cfg.OriginAddress != "sender@example.com" {
+		t.Errorf("OriginAddress = %q, want %q", cfg.OriginAddress, "sender@example.com")
+	}
+	if cfg.OriginName != "Sender Name" {
+		t.Errorf("OriginName = %q, want %q", cfg.OriginName, "Sender Name")
+	}
+}
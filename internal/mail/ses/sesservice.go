@@ -0,0 +1,226 @@
+package ses
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/mimemessage"
+)
+
+const (
+	// providerName identifies SES in delivery records and errors
+	providerName = "ses"
+	// signingName is the service name SES requests are signed for
+	signingName = "ses"
+	// sendEmailPath is the SES v2 SendEmail operation
+	sendEmailPath = "/v2/email/outbound-emails"
+	charset       = "UTF-8"
+)
+
+// SESService implements MailService using the Amazon SES v2 API
+type SESService struct {
+	config *SESConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSESService creates an SESService that sends its requests with client
+func NewSESService(config *SESConfig, client *http.Client) *SESService {
+	return &SESService{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// sesSendEmailRequest is the SendEmail request payload
+type sesSendEmailRequest struct {
+	FromEmailAddress     string         `json:"FromEmailAddress"`
+	Destination          sesDestination `json:"Destination"`
+	ReplyToAddresses     []string       `json:"ReplyToAddresses,omitempty"`
+	Content              sesContent     `json:"Content"`
+	ConfigurationSetName string         `json:"ConfigurationSetName,omitempty"`
+}
+
+// sesDestination lists the recipients of a mail
+type sesDestination struct {
+	ToAddresses  []string `json:"ToAddresses,omitempty"`
+	CcAddresses  []string `json:"CcAddresses,omitempty"`
+	BccAddresses []string `json:"BccAddresses,omitempty"`
+}
+
+// sesContent holds either a simple or a raw MIME message
+type sesContent struct {
+	Simple *sesSimpleMessage `json:"Simple,omitempty"`
+	Raw    *sesRawMessage    `json:"Raw,omitempty"`
+}
+
+// sesSimpleMessage is a mail that SES turns into MIME itself
+type sesSimpleMessage struct {
+	Subject sesText `json:"Subject"`
+	Body    sesBody `json:"Body"`
+}
+
+// sesBody holds the text and HTML parts of a simple message
+type sesBody struct {
+	Text *sesText `json:"Text,omitempty"`
+	Html *sesText `json:"Html,omitempty"`
+}
+
+// sesText is a text with its charset
+type sesText struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+// sesRawMessage is a complete MIME message; Data is base64 encoded by encoding/json
+type sesRawMessage struct {
+	Data []byte `json:"Data"`
+}
+
+// sesSendEmailResponse is the SendEmail response
+type sesSendEmailResponse struct {
+	MessageId string `json:"MessageId"`
+}
+
+// sesErrorResponse is the body of a failed request
+type sesErrorResponse struct {
+	Message string `json:"message"`
+}
+
+func (service *SESService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	slog.Info("ses: preparing to send mail")
+
+	request, err := service.createRequest(attributes)
+	if err != nil {
+		slog.Error("ses: failed to create message", "error", err)
+		return err
+	}
+	err = service.sendRequest(ctx, request)
+
+	if err != nil {
+		slog.Error("ses: failed to send mail", "error", err)
+		return err
+	}
+
+	slog.Info("ses: mail sent successfully")
+	return nil
+}
+
+// createRequest creates a SendEmail request. Mails with attachments are sent
+// as raw MIME because simple messages cannot carry inline images.
+func (service *SESService) createRequest(attributes mail.MailAttributes) (sesSendEmailRequest, error) {
+	address, name := attributes.Sender(service.config.OriginAddress, service.config.OriginName)
+	request := sesSendEmailRequest{
+		FromEmailAddress: fromAddress(address, name),
+		Destination: sesDestination{
+			ToAddresses:  attributes.ToAddresses(),
+			CcAddresses:  attributes.Cc,
+			BccAddresses: attributes.Bcc,
+		},
+		ConfigurationSetName: service.config.ConfigurationSet,
+	}
+	if attributes.ReplyTo != "" {
+		request.ReplyToAddresses = []string{attributes.ReplyTo}
+	}
+
+	if len(attributes.Attachments) == 0 {
+		request.Content.Simple = &sesSimpleMessage{
+			Subject: sesText{Data: attributes.Subject, Charset: charset},
+			Body: sesBody{
+				Text: &sesText{Data: attributes.PlainText(), Charset: charset},
+				Html: &sesText{Data: attributes.HtmlContent, Charset: charset},
+			},
+		}
+		return request, nil
+	}
+
+	raw, err := mimemessage.Build(attributes, mimemessage.Options{
+		FromAddress: address,
+		FromName:    name,
+		Date:        service.now(),
+	})
+	if err != nil {
+		// the mail itself is invalid, sending it again would fail the same way
+		return request, &mail.ProviderError{Provider: providerName, Err: err}
+	}
+	request.Content.Raw = &sesRawMessage{Data: raw}
+	return request, nil
+}
+
+func (service *SESService) sendRequest(ctx context.Context, request sesSendEmailRequest) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(service.config.Endpoint, "/") + sendEmailPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signRequest(req, jsonData, service.config.Credentials, service.config.Region, signingName, service.now())
+
+	slog.Info("ses: sending request to SES API")
+
+	resp, err := service.client.Do(req)
+	if err != nil {
+		return mail.NewNetworkError(providerName, fmt.Errorf("failed to send request: %w", err))
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return mail.NewNetworkError(providerName, fmt.Errorf("failed to read response body: %w", err))
+	}
+
+	slog.Info("ses: received response", "status_code", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK {
+		return mail.NewStatusError(providerName, resp.StatusCode, resp.Header.Get("Retry-After"),
+			fmt.Errorf("ses API returned status %d: %s", resp.StatusCode, errorMessage(resp.Header, body)))
+	}
+
+	var sesResp sesSendEmailResponse
+	if err := json.Unmarshal(body, &sesResp); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	mail.RecordDelivery(ctx, providerName, sesResp.MessageId)
+	return nil
+}
+
+// errorMessage combines the error type header and message of a failed SES response.
+func errorMessage(header http.Header, body []byte) string {
+	var sesErr sesErrorResponse
+	message := string(body)
+	if err := json.Unmarshal(body, &sesErr); err == nil && sesErr.Message != "" {
+		message = sesErr.Message
+	}
+	// the header may carry a namespace after a colon, e.g. "MessageRejected:http://..."
+	errorType, _, _ := strings.Cut(header.Get("X-Amzn-ErrorType"), ":")
+	if errorType == "" {
+		return message
+	}
+	return errorType + ": " + message
+}
+
+// fromAddress adds the display name to the sender address, RFC 2047 encoded if needed.
+func fromAddress(address, name string) string {
+	if name == "" {
+		return address
+	}
+	return (&netmail.Address{Name: name, Address: address}).String()
+}
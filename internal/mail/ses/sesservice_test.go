@@ -0,0 +1,220 @@
+package ses
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// sesStandIn is a local SES endpoint that checks the request signature and
+// records the decoded SendEmail payloads.
+type sesStandIn struct {
+	t        *testing.T
+	status   int
+	header   http.Header
+	body     string
+	requests []sesSendEmailRequest
+}
+
+func (s *sesStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != sendEmailPath {
+		s.t.Errorf("request = %s %s, want POST %s", r.Method, r.URL.Path, sendEmailPath)
+	}
+	payload, _ := io.ReadAll(r.Body)
+
+	// signing the received request again must yield the same signature
+	resigned := r.Clone(context.Background())
+	resigned.Header = r.Header.Clone()
+	resigned.Header.Del("Authorization")
+	resigned.Header.Del("X-Amz-Date")
+	signRequest(resigned, payload, testCredentials, "eu-west-1", signingName, testSigningTime)
+	if got, want := r.Header.Get("Authorization"), resigned.Header.Get("Authorization"); got != want {
+		s.t.Errorf("Authorization = %q, want %q", got, want)
+	}
+
+	var request sesSendEmailRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		s.t.Errorf("decoding payload: %v", err)
+	}
+	s.requests = append(s.requests, request)
+
+	for name, values := range s.header {
+		w.Header()[name] = values
+	}
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+	}
+	body := s.body
+	if body == "" {
+		body = `{"MessageId":"ses-message-id"}`
+	}
+	_, _ = io.WriteString(w, body)
+}
+
+func newTestService(endpoint string) *SESService {
+	return &SESService{
+		config: &SESConfig{
+			Region:           "eu-west-1",
+			Endpoint:         endpoint,
+			Credentials:      testCredentials,
+			ConfigurationSet: "tracking",
+			OriginAddress:    "noreply@example.com",
+			OriginName:       "Example",
+		},
+		client: http.DefaultClient,
+		now:    func() time.Time { return testSigningTime },
+	}
+}
+
+func TestSESService_SendMail_Simple(t *testing.T) {
+	standIn := &sesStandIn{t: t}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	err := newTestService(server.URL+"/").SendMail(ctx, mail.MailAttributes{
+		To:          "a@example.com, b@example.com",
+		Cc:          []string{"c@example.com"},
+		Bcc:         []string{"d@example.com"},
+		ReplyTo:     "support@example.com",
+		Subject:     "Hello",
+		HtmlContent: "<p>Hi</p>",
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	if len(standIn.requests) != 1 {
+		t.Fatalf("stand-in received %d requests, want 1", len(standIn.requests))
+	}
+	got := standIn.requests[0]
+	want := sesSendEmailRequest{
+		FromEmailAddress: `"Example" <noreply@example.com>`,
+		Destination: sesDestination{
+			ToAddresses:  []string{"a@example.com", "b@example.com"},
+			CcAddresses:  []string{"c@example.com"},
+			BccAddresses: []string{"d@example.com"},
+		},
+		ReplyToAddresses: []string{"support@example.com"},
+		Content: sesContent{Simple: &sesSimpleMessage{
+			Subject: sesText{Data: "Hello", Charset: charset},
+			Body: sesBody{
+				Text: &sesText{Data: "Hi", Charset: charset},
+				Html: &sesText{Data: "<p>Hi</p>", Charset: charset},
+			},
+		}},
+		ConfigurationSetName: "tracking",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request = %+v, want %+v", got, want)
+	}
+
+	if delivery.Provider != providerName || !reflect.DeepEqual(delivery.MessageIDs, []string{"ses-message-id"}) {
+		t.Errorf("delivery = %+v", delivery)
+	}
+}
+
+func TestSESService_SendMail_RawWithAttachments(t *testing.T) {
+	standIn := &sesStandIn{t: t}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{
+		To:          "a@example.com",
+		Subject:     "Invoice",
+		HtmlContent: `<img src="cid:logo">`,
+		Attachments: []mail.Attachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Content: base64.StdEncoding.EncodeToString([]byte("%PDF"))},
+			{Filename: "logo.png", ContentType: "image/png", Content: base64.StdEncoding.EncodeToString([]byte("png")), ContentID: "logo"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	content := standIn.requests[0].Content
+	if content.Simple != nil || content.Raw == nil {
+		t.Fatalf("content = %+v, want a raw message", content)
+	}
+	raw := content.Raw.Data
+	for _, want := range []string{"Subject: Invoice\r\n", "multipart/mixed", "multipart/related", "Content-Id: <logo>", "filename=invoice.pdf"} {
+		if !bytes.Contains(raw, []byte(want)) {
+			t.Errorf("raw message does not contain %q", want)
+		}
+	}
+}
+
+func TestSESService_SendMail_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		errorType     string
+		body          string
+		wantTransient bool
+		wantMessage   string
+	}{
+		{
+			name:        "message rejected",
+			status:      http.StatusBadRequest,
+			errorType:   "MessageRejected:http://internal.amazon.com/coral/com.amazonaws.sesv2/",
+			body:        `{"message":"Email address is not verified."}`,
+			wantMessage: "MessageRejected: Email address is not verified.",
+		},
+		{
+			name:          "throttled",
+			status:        http.StatusTooManyRequests,
+			errorType:     "TooManyRequestsException",
+			body:          `{"message":"Rate exceeded"}`,
+			wantTransient: true,
+			wantMessage:   "TooManyRequestsException: Rate exceeded",
+		},
+		{
+			name:          "service unavailable",
+			status:        http.StatusServiceUnavailable,
+			body:          "unavailable",
+			wantTransient: true,
+			wantMessage:   "status 503: unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn := &sesStandIn{t: t, status: tt.status, body: tt.body, header: http.Header{}}
+			if tt.errorType != "" {
+				standIn.header.Set("X-Amzn-ErrorType", tt.errorType)
+			}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+			if err == nil {
+				t.Fatal("SendMail() expected error")
+			}
+			if mail.IsTransient(err) != tt.wantTransient || mail.IsPermanent(err) == tt.wantTransient {
+				t.Errorf("SendMail() error = %v, transient = %v, want %v", err, mail.IsTransient(err), tt.wantTransient)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("SendMail() error = %q, want it to contain %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestSESService_SendMail_NetworkErrorIsTransient(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := newTestService(server.URL).SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "s", HtmlContent: "c"})
+	if !mail.IsTransient(err) {
+		t.Errorf("SendMail() error = %v, want a transient error", err)
+	}
+}
@@ -0,0 +1,139 @@
+package ses
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	amzDayFormat     = "20060102"
+)
+
+// Credentials are the AWS access keys used to sign requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only set for temporary credentials.
+	SessionToken string
+}
+
+// signRequest adds the AWS Signature Version 4 headers to req. The Host,
+// Content-Type and all X-Amz-* headers are signed along with the payload.
+func signRequest(req *http.Request, payload []byte, credentials Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		headers,
+		signedHeaders,
+		hashHex(payload),
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(amzDayFormat), region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		now.Format(amzDateFormat),
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), now.Format(amzDayFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", signingAlgorithm+
+		" Credential="+credentials.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+// canonicalHeaders returns the canonical header block and the signed header list.
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{"host": req.Host}
+	if values["host"] == "" {
+		values["host"] = req.URL.Host
+	}
+	for name, vals := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, 0, len(vals))
+		for _, v := range vals {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+func canonicalURI(u *url.URL) string {
+	if path := u.EscapedPath(); path != "" {
+		return path
+	}
+	return "/"
+}
+
+// canonicalQuery sorts the query parameters and encodes them as SigV4 requires.
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		vals := query[key]
+		sort.Strings(vals)
+		for _, v := range vals {
+			pairs = append(pairs, uriEncode(key)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything except the unreserved characters of RFC 3986.
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,82 @@
+package ses
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCredentials are the example credentials of the AWS Signature Version 4 test suite.
+var testCredentials = Credentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+var testSigningTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSignRequest_AWSTestVectors(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		region      string
+		service     string
+		want        string
+	}{
+		{
+			name:    "get-vanilla",
+			url:     "https://example.amazonaws.com/",
+			region:  "us-east-1",
+			service: "service",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, " +
+				"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:        "iam list users",
+			url:         "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			contentType: "application/x-www-form-urlencoded; charset=utf-8",
+			region:      "us-east-1",
+			service:     "iam",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+				"SignedHeaders=content-type;host;x-amz-date, " +
+				"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatalf("NewRequest() error = %v", err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			signRequest(req, nil, testCredentials, tt.region, tt.service, testSigningTime)
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", got)
+			}
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignRequest_SessionToken(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://email.eu-west-1.amazonaws.com/v2/email/outbound-emails", nil)
+	credentials := testCredentials
+	credentials.SessionToken = "session-token"
+
+	signRequest(req, []byte("{}"), credentials, "eu-west-1", "ses", testSigningTime)
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+	if got := req.Header.Get("Authorization"); !strings.Contains(got, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Authorization = %q, want the session token to be signed", got)
+	}
+}
@@ -0,0 +1,361 @@
+// Package smtprelay delivers mail through an ordinary SMTP server such as
+// Postfix, an Office 365 relay or an ISP smarthost.
+package smtprelay
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	netmail "net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	gosmtp "github.com/emersion/go-smtp"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"github.com/jo-hoe/go-mail-service/internal/mail/mimemessage"
+)
+
+// providerName identifies the SMTP relay in delivery records and errors
+const providerName = "smtp"
+
+// RecipientError is the rejection of a single recipient by the relay.
+type RecipientError struct {
+	Address string
+	Err     *gosmtp.SMTPError
+}
+
+func (e RecipientError) Error() string {
+	return fmt.Sprintf("recipient %s rejected: %d %s", e.Address, e.Err.Code, e.Err.Message)
+}
+
+// RecipientErrors lists every recipient the relay rejected. When it is
+// returned, the mail was not sent to any recipient.
+type RecipientErrors []RecipientError
+
+func (e RecipientErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, recipientErr := range e {
+		messages = append(messages, recipientErr.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// temporary reports whether every rejection may succeed when retried.
+func (e RecipientErrors) temporary() bool {
+	for _, recipientErr := range e {
+		if !recipientErr.Err.Temporary() {
+			return false
+		}
+	}
+	return true
+}
+
+// SMTPRelayService implements MailService by submitting mail to an SMTP relay.
+// Connections are kept open between mails, up to MaxConnections at a time.
+type SMTPRelayService struct {
+	config        config.SMTPRelayProviderConfig
+	originAddress string
+	originName    string
+	tlsConfig     *tls.Config
+	// slots limits the number of open connections.
+	slots chan struct{}
+	idle  chan *gosmtp.Client
+}
+
+// NewSMTPRelayService creates an SMTPRelayService for the relay in cfg. Mail
+// is sent as originAddress unless the request overrides the sender.
+func NewSMTPRelayService(cfg config.SMTPRelayProviderConfig, originAddress, originName string) (*SMTPRelayService, error) {
+	tlsConfig := &tls.Config{
+		ServerName: cfg.Host,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile) // #nosec G304 -- CA file path comes from operator-supplied config, not user input
+		if err != nil {
+			return nil, fmt.Errorf("smtp relay: reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("smtp relay: no certificates found in CA file %q", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &SMTPRelayService{
+		config:        cfg,
+		originAddress: originAddress,
+		originName:    originName,
+		tlsConfig:     tlsConfig,
+		slots:         make(chan struct{}, cfg.MaxConnections),
+		idle:          make(chan *gosmtp.Client, cfg.MaxConnections),
+	}, nil
+}
+
+func (service *SMTPRelayService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	slog.Info("smtp relay: preparing to send mail")
+
+	address, name := attributes.Sender(service.originAddress, service.originName)
+	messageID, err := mimemessage.NewMessageID(domainOf(address))
+	if err != nil {
+		return err
+	}
+	message, err := mimemessage.Build(attributes, mimemessage.Options{
+		FromAddress: address,
+		FromName:    name,
+		MessageID:   messageID,
+		Date:        time.Now(),
+	})
+	if err != nil {
+		// the mail itself is invalid, sending it again would fail the same way
+		return &mail.ProviderError{Provider: providerName, Err: err}
+	}
+
+	err = service.submit(ctx, address, envelopeRecipients(attributes), message)
+	if err != nil {
+		err = classify(err)
+		slog.Error("smtp relay: failed to send mail", "error", err)
+		return err
+	}
+
+	mail.RecordDelivery(ctx, providerName, messageID)
+	slog.Info("smtp relay: mail sent successfully", "messageId", messageID)
+	return nil
+}
+
+// submit runs one mail transaction on a pooled connection.
+func (service *SMTPRelayService) submit(ctx context.Context, from string, recipients []string, message []byte) error {
+	select {
+	case service.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-service.slots }()
+
+	client, err := service.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	// closing the connection aborts a transaction that outlives ctx
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+	err = transaction(client, from, recipients, message)
+	if !stop() {
+		if err == nil {
+			return nil
+		}
+		return errors.Join(ctx.Err(), err)
+	}
+
+	var recipientErrs RecipientErrors
+	if err == nil || errors.As(err, &recipientErrs) {
+		service.release(client)
+	} else {
+		_ = client.Close()
+	}
+	return err
+}
+
+// transaction sends the mail to every recipient or, if the relay rejects any
+// of them, to none, so that a retry does not deliver it twice.
+func transaction(client *gosmtp.Client, from string, recipients []string, message []byte) error {
+	if err := client.Mail(from, nil); err != nil {
+		return err
+	}
+
+	var rejected RecipientErrors
+	for _, recipient := range recipients {
+		err := client.Rcpt(recipient, nil)
+		var smtpErr *gosmtp.SMTPError
+		switch {
+		case err == nil:
+		case errors.As(err, &smtpErr):
+			rejected = append(rejected, RecipientError{Address: recipient, Err: smtpErr})
+		default:
+			return err
+		}
+	}
+	if len(rejected) > 0 {
+		if err := client.Reset(); err != nil {
+			return errors.Join(rejected, err)
+		}
+		return rejected
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(message); err != nil {
+		_ = data.Close()
+		return err
+	}
+	return data.Close()
+}
+
+// connection returns an idle connection that is still usable or dials a new one.
+func (service *SMTPRelayService) connection(ctx context.Context) (*gosmtp.Client, error) {
+	for {
+		select {
+		case client := <-service.idle:
+			if err := client.Reset(); err == nil {
+				return client, nil
+			}
+			_ = client.Close()
+		default:
+			return service.dial(ctx)
+		}
+	}
+}
+
+// release keeps a connection for the next mail.
+func (service *SMTPRelayService) release(client *gosmtp.Client) {
+	select {
+	case service.idle <- client:
+	default:
+		_ = client.Quit()
+	}
+}
+
+// dial opens and authenticates a new connection to the relay.
+func (service *SMTPRelayService) dial(ctx context.Context) (*gosmtp.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, service.config.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(service.config.Host, strconv.Itoa(service.config.Port))
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// the handshake below must not outlive the dial timeout either
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	client, err := service.handshake(conn)
+	if err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, errors.Join(ctx.Err(), err)
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// handshake greets the relay, upgrades the connection to TLS and authenticates.
+func (service *SMTPRelayService) handshake(conn net.Conn) (*gosmtp.Client, error) {
+	var client *gosmtp.Client
+	switch service.config.TLS {
+	case config.SMTPRelayTLSImplicit:
+		client = gosmtp.NewClient(tls.Client(conn, service.tlsConfig))
+	case config.SMTPRelayTLSNone:
+		client = gosmtp.NewClient(conn)
+	default:
+		var err error
+		client, err = gosmtp.NewClientStartTLS(conn, service.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client.CommandTimeout = service.config.Timeout
+
+	if err := client.Hello(service.heloName()); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	if service.config.Username != "" {
+		auth, err := service.auth(client)
+		if err == nil {
+			err = client.Auth(auth)
+		}
+		if err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// auth picks the configured mechanism or, if none is configured, PLAIN or LOGIN
+// as offered by the relay.
+func (service *SMTPRelayService) auth(client *gosmtp.Client) (sasl.Client, error) {
+	mechanism := strings.ToUpper(service.config.AuthMechanism)
+	if mechanism == "" {
+		for _, offered := range []string{sasl.Plain, sasl.Login} {
+			if client.SupportsAuth(offered) {
+				mechanism = offered
+				break
+			}
+		}
+	}
+
+	switch mechanism {
+	case sasl.Plain:
+		return sasl.NewPlainClient("", service.config.Username, service.config.Password), nil
+	case sasl.Login:
+		return sasl.NewLoginClient(service.config.Username, service.config.Password), nil
+	default:
+		return nil, errors.New("relay offers neither AUTH PLAIN nor AUTH LOGIN")
+	}
+}
+
+// heloName returns the configured EHLO name or the domain of the default sender.
+func (service *SMTPRelayService) heloName() string {
+	if service.config.HeloName != "" {
+		return service.config.HeloName
+	}
+	if domain := domainOf(service.originAddress); domain != "" {
+		return domain
+	}
+	return "localhost"
+}
+
+// classify turns an SMTP or network error into a ProviderError. 4xx replies
+// and network errors are transient, 5xx replies are permanent.
+func classify(err error) error {
+	var recipientErrs RecipientErrors
+	if errors.As(err, &recipientErrs) {
+		return &mail.ProviderError{Provider: providerName, Transient: recipientErrs.temporary(), Err: err}
+	}
+	var smtpErr *gosmtp.SMTPError
+	if errors.As(err, &smtpErr) {
+		return &mail.ProviderError{Provider: providerName, Transient: smtpErr.Temporary(), Err: err}
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return mail.NewNetworkError(providerName, err)
+}
+
+// envelopeRecipients returns the bare addresses of all To, Cc and Bcc recipients.
+func envelopeRecipients(attributes mail.MailAttributes) []string {
+	var recipients []string
+	for _, list := range [][]string{attributes.ToAddresses(), attributes.Cc, attributes.Bcc} {
+		for _, address := range list {
+			if parsed, err := netmail.ParseAddress(address); err == nil {
+				address = parsed.Address
+			}
+			recipients = append(recipients, address)
+		}
+	}
+	return recipients
+}
+
+func domainOf(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return strings.TrimSuffix(address[at+1:], ">")
+	}
+	return ""
+}
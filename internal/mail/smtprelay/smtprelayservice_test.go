@@ -0,0 +1,477 @@
+package smtprelay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	gosmtp "github.com/emersion/go-smtp"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+const (
+	testUsername = "relay-user"
+	testPassword = "relay-pass"
+)
+
+// receivedMail is a mail accepted by the test relay.
+type receivedMail struct {
+	from       string
+	recipients []string
+	data       string
+}
+
+// testRelay is an in-process SMTP server that records the mail it accepts.
+type testRelay struct {
+	mechanisms []string
+	// rejected maps recipients to the error their RCPT is answered with.
+	rejected map[string]*gosmtp.SMTPError
+
+	mu       sync.Mutex
+	sessions int
+	mechUsed []string
+	mails    []receivedMail
+}
+
+func (r *testRelay) NewSession(_ *gosmtp.Conn) (gosmtp.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions++
+	return &testSession{relay: r}, nil
+}
+
+func (r *testRelay) received() []receivedMail {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.mails)
+}
+
+func (r *testRelay) connections() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions
+}
+
+func (r *testRelay) authMechanisms() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.mechUsed)
+}
+
+func (r *testRelay) setRejected(rejected map[string]*gosmtp.SMTPError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected = rejected
+}
+
+type testSession struct {
+	relay      *testRelay
+	authed     bool
+	from       string
+	recipients []string
+}
+
+func (s *testSession) AuthMechanisms() []string {
+	return s.relay.mechanisms
+}
+
+func (s *testSession) Auth(mech string) (sasl.Server, error) {
+	s.relay.mu.Lock()
+	s.relay.mechUsed = append(s.relay.mechUsed, mech)
+	s.relay.mu.Unlock()
+
+	authenticate := func(username, password string) error {
+		if username != testUsername || password != testPassword {
+			return &gosmtp.SMTPError{Code: 535, EnhancedCode: gosmtp.EnhancedCode{5, 7, 8}, Message: "invalid credentials"}
+		}
+		s.authed = true
+		return nil
+	}
+	switch mech {
+	case sasl.Plain:
+		return sasl.NewPlainServer(func(_, username, password string) error {
+			return authenticate(username, password)
+		}), nil
+	case sasl.Login:
+		return &loginServer{authenticate: authenticate}, nil
+	}
+	return nil, gosmtp.ErrAuthUnknownMechanism
+}
+
+func (s *testSession) Mail(from string, _ *gosmtp.MailOptions) error {
+	if len(s.relay.mechanisms) > 0 && !s.authed {
+		return gosmtp.ErrAuthRequired
+	}
+	s.from = from
+	return nil
+}
+
+func (s *testSession) Rcpt(to string, _ *gosmtp.RcptOptions) error {
+	s.relay.mu.Lock()
+	defer s.relay.mu.Unlock()
+	if err, ok := s.relay.rejected[to]; ok {
+		return err
+	}
+	s.recipients = append(s.recipients, to)
+	return nil
+}
+
+func (s *testSession) Data(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.relay.mu.Lock()
+	defer s.relay.mu.Unlock()
+	s.relay.mails = append(s.relay.mails, receivedMail{from: s.from, recipients: s.recipients, data: string(data)})
+	return nil
+}
+
+func (s *testSession) Reset() {
+	s.from = ""
+	s.recipients = nil
+}
+
+func (s *testSession) Logout() error {
+	return nil
+}
+
+// loginServer implements the server side of AUTH LOGIN, which go-sasl only provides for clients.
+type loginServer struct {
+	username     string
+	step         int
+	authenticate func(username, password string) error
+}
+
+func (s *loginServer) Next(response []byte) ([]byte, bool, error) {
+	s.step++
+	switch s.step {
+	case 1:
+		if response == nil {
+			return []byte("Username:"), false, nil
+		}
+		s.username = string(response)
+		s.step++
+		return []byte("Password:"), false, nil
+	case 2:
+		s.username = string(response)
+		return []byte("Password:"), false, nil
+	default:
+		return nil, true, s.authenticate(s.username, string(response))
+	}
+}
+
+// startRelay serves relay on a local port and returns a matching provider config.
+// A non-nil tlsConfig enables STARTTLS, or implicit TLS when implicit is set.
+func startRelay(t *testing.T, relay *testRelay, tlsConfig *tls.Config, implicit bool) config.SMTPRelayProviderConfig {
+	t.Helper()
+	server := gosmtp.NewServer(relay)
+	server.Domain = "relay.test"
+	server.AllowInsecureAuth = tlsConfig == nil
+	server.TLSConfig = tlsConfig
+
+	var listener net.Listener
+	var err error
+	if implicit {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	cfg := config.SMTPRelayProviderConfig{
+		Enabled:        true,
+		Host:           host,
+		Port:           portNumber,
+		TLS:            config.SMTPRelayTLSNone,
+		MaxConnections: 2,
+		Timeout:        5 * time.Second,
+	}
+	if len(relay.mechanisms) > 0 {
+		cfg.Username = testUsername
+		cfg.Password = testPassword
+	}
+	return cfg
+}
+
+// selfSignedTLS returns a server TLS config for 127.0.0.1 and the path of its PEM certificate.
+func selfSignedTLS(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "relay.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(caFile, certPEM, 0600); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, caFile
+}
+
+func newTestService(t *testing.T, cfg config.SMTPRelayProviderConfig) *SMTPRelayService {
+	t.Helper()
+	service, err := NewSMTPRelayService(cfg, "noreply@example.com", "Example")
+	if err != nil {
+		t.Fatalf("NewSMTPRelayService() error = %v", err)
+	}
+	return service
+}
+
+var testAttributes = mail.MailAttributes{
+	To:          "Alice <alice@example.com>, bob@example.com",
+	Cc:          []string{"carol@example.com"},
+	Bcc:         []string{"dave@example.com"},
+	Subject:     "Relay test",
+	HtmlContent: "<p>Hello</p>",
+}
+
+func TestSMTPRelayService_SendMail_StartTLSAuthPlain(t *testing.T) {
+	tlsConfig, caFile := selfSignedTLS(t)
+	relay := &testRelay{mechanisms: []string{sasl.Plain, sasl.Login}}
+	cfg := startRelay(t, relay, tlsConfig, false)
+	cfg.TLS = config.SMTPRelayTLSStartTLS
+	cfg.CAFile = caFile
+	service := newTestService(t, cfg)
+
+	ctx, delivery := mail.NewDeliveryContext(context.Background())
+	if err := service.SendMail(ctx, testAttributes); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	mails := relay.received()
+	if len(mails) != 1 {
+		t.Fatalf("relay received %d mails, want 1", len(mails))
+	}
+	got := mails[0]
+	if got.from != "noreply@example.com" {
+		t.Errorf("MAIL FROM = %q", got.from)
+	}
+	wantRecipients := []string{"alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com"}
+	if !slices.Equal(got.recipients, wantRecipients) {
+		t.Errorf("RCPT TO = %v, want %v", got.recipients, wantRecipients)
+	}
+	if !strings.Contains(got.data, "Subject: Relay test\r\n") {
+		t.Errorf("message is missing the subject:\n%s", got.data)
+	}
+	if strings.Contains(got.data, "dave@example.com") {
+		t.Error("Bcc recipient must not appear in the message")
+	}
+	if !slices.Equal(relay.authMechanisms(), []string{sasl.Plain}) {
+		t.Errorf("auth mechanisms used = %v, want [PLAIN]", relay.authMechanisms())
+	}
+
+	if delivery.Provider != providerName || len(delivery.MessageIDs) != 1 {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+	if !strings.Contains(got.data, "Message-ID: <"+delivery.MessageIDs[0]+">\r\n") {
+		t.Errorf("recorded message ID %q is not the Message-ID header", delivery.MessageIDs[0])
+	}
+}
+
+func TestSMTPRelayService_SendMail_ImplicitTLS(t *testing.T) {
+	tlsConfig, caFile := selfSignedTLS(t)
+	relay := &testRelay{}
+	cfg := startRelay(t, relay, tlsConfig, true)
+	cfg.TLS = config.SMTPRelayTLSImplicit
+	cfg.CAFile = caFile
+	service := newTestService(t, cfg)
+
+	if err := service.SendMail(context.Background(), testAttributes); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if got := len(relay.received()); got != 1 {
+		t.Errorf("relay received %d mails, want 1", got)
+	}
+}
+
+func TestSMTPRelayService_SendMail_UntrustedCertificate(t *testing.T) {
+	tlsConfig, _ := selfSignedTLS(t)
+	cfg := startRelay(t, &testRelay{}, tlsConfig, false)
+	cfg.TLS = config.SMTPRelayTLSStartTLS
+	service := newTestService(t, cfg)
+
+	if err := service.SendMail(context.Background(), testAttributes); err == nil {
+		t.Fatal("SendMail() expected error for a certificate that is not trusted")
+	}
+}
+
+func TestSMTPRelayService_SendMail_AuthLogin(t *testing.T) {
+	tests := []struct {
+		name       string
+		mechanisms []string
+		configured string
+	}{
+		{name: "only offered mechanism", mechanisms: []string{sasl.Login}},
+		{name: "configured mechanism", mechanisms: []string{sasl.Plain, sasl.Login}, configured: "login"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay := &testRelay{mechanisms: tt.mechanisms}
+			cfg := startRelay(t, relay, nil, false)
+			cfg.AuthMechanism = tt.configured
+			service := newTestService(t, cfg)
+
+			if err := service.SendMail(context.Background(), testAttributes); err != nil {
+				t.Fatalf("SendMail() error = %v", err)
+			}
+			if !slices.Equal(relay.authMechanisms(), []string{sasl.Login}) {
+				t.Errorf("auth mechanisms used = %v, want [LOGIN]", relay.authMechanisms())
+			}
+		})
+	}
+}
+
+func TestSMTPRelayService_SendMail_AuthFailureIsPermanent(t *testing.T) {
+	relay := &testRelay{mechanisms: []string{sasl.Plain}}
+	cfg := startRelay(t, relay, nil, false)
+	cfg.Password = "wrong"
+	service := newTestService(t, cfg)
+
+	err := service.SendMail(context.Background(), testAttributes)
+	if !mail.IsPermanent(err) {
+		t.Errorf("SendMail() error = %v, want a permanent error", err)
+	}
+}
+
+func TestSMTPRelayService_SendMail_ReusesConnection(t *testing.T) {
+	relay := &testRelay{}
+	service := newTestService(t, startRelay(t, relay, nil, false))
+
+	for i := 0; i < 3; i++ {
+		if err := service.SendMail(context.Background(), testAttributes); err != nil {
+			t.Fatalf("SendMail() #%d error = %v", i, err)
+		}
+	}
+	if got := len(relay.received()); got != 3 {
+		t.Errorf("relay received %d mails, want 3", got)
+	}
+	if got := relay.connections(); got != 1 {
+		t.Errorf("relay saw %d connections, want 1", got)
+	}
+}
+
+func TestSMTPRelayService_SendMail_RejectedRecipients(t *testing.T) {
+	mailboxUnavailable := &gosmtp.SMTPError{Code: 550, EnhancedCode: gosmtp.EnhancedCode{5, 1, 1}, Message: "no such user"}
+	mailboxBusy := &gosmtp.SMTPError{Code: 450, EnhancedCode: gosmtp.EnhancedCode{4, 2, 1}, Message: "mailbox busy"}
+
+	tests := []struct {
+		name          string
+		rejected      map[string]*gosmtp.SMTPError
+		wantTransient bool
+	}{
+		{
+			name:     "permanent rejection",
+			rejected: map[string]*gosmtp.SMTPError{"bob@example.com": mailboxUnavailable, "dave@example.com": mailboxBusy},
+		},
+		{
+			name:          "temporary rejections only",
+			rejected:      map[string]*gosmtp.SMTPError{"carol@example.com": mailboxBusy},
+			wantTransient: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay := &testRelay{rejected: tt.rejected}
+			service := newTestService(t, startRelay(t, relay, nil, false))
+
+			err := service.SendMail(context.Background(), testAttributes)
+			if err == nil {
+				t.Fatal("SendMail() expected error")
+			}
+			if mail.IsTransient(err) != tt.wantTransient {
+				t.Errorf("IsTransient() = %v, want %v", mail.IsTransient(err), tt.wantTransient)
+			}
+
+			var recipientErrs RecipientErrors
+			if !errors.As(err, &recipientErrs) {
+				t.Fatalf("SendMail() error = %v, want RecipientErrors", err)
+			}
+			var addresses []string
+			for _, recipientErr := range recipientErrs {
+				addresses = append(addresses, recipientErr.Address)
+				if want := tt.rejected[recipientErr.Address]; recipientErr.Err.Code != want.Code {
+					t.Errorf("%s rejected with %d, want %d", recipientErr.Address, recipientErr.Err.Code, want.Code)
+				}
+			}
+			if len(addresses) != len(tt.rejected) {
+				t.Errorf("rejected recipients = %v, want %d", addresses, len(tt.rejected))
+			}
+			if got := len(relay.received()); got != 0 {
+				t.Errorf("relay received %d mails, want none after a rejection", got)
+			}
+
+			// the connection stays usable after the aborted transaction
+			relay.setRejected(nil)
+			if err := service.SendMail(context.Background(), testAttributes); err != nil {
+				t.Fatalf("SendMail() after rejection error = %v", err)
+			}
+			if got := relay.connections(); got != 1 {
+				t.Errorf("relay saw %d connections, want 1", got)
+			}
+		})
+	}
+}
+
+func TestSMTPRelayService_SendMail_UnreachableIsTransient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	_ = listener.Close()
+
+	service := newTestService(t, config.SMTPRelayProviderConfig{
+		Host:           "127.0.0.1",
+		Port:           addr.Port,
+		TLS:            config.SMTPRelayTLSNone,
+		MaxConnections: 1,
+		Timeout:        time.Second,
+	})
+	if err := service.SendMail(context.Background(), testAttributes); !mail.IsTransient(err) {
+		t.Errorf("SendMail() error = %v, want a transient error", err)
+	}
+}
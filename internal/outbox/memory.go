@@ -0,0 +1,97 @@
+package outbox
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps messages in memory. It is used to record
+// the status of synchronously sent mail when the persistent queue is disabled.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	messages map[string]Message
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{messages: make(map[string]Message)}
+}
+
+// Save inserts or replaces a message.
+func (s *MemoryStore) Save(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[msg.ID] = msg
+	return nil
+}
+
+// Get returns the message with the given id.
+func (s *MemoryStore) Get(id string) (Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	msg, ok := s.messages[id]
+	if !ok {
+		return Message{}, ErrNotFound
+	}
+	return msg, nil
+}
+
+// Due returns up to limit accepted messages whose next attempt is due, the
+// longest due first.
+func (s *MemoryStore) Due(now time.Time, limit int) ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var due []Message
+	for _, msg := range s.messages {
+		if msg.Status == StatusAccepted && !msg.NextAttemptAt.After(now) {
+			due = append(due, msg)
+		}
+	}
+	slices.SortFunc(due, func(a, b Message) int {
+		return cmp.Or(a.NextAttemptAt.Compare(b.NextAttemptAt), strings.Compare(a.ID, b.ID))
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// List returns the messages matching filter, newest first, without their content.
+func (s *MemoryStore) List(filter Filter) ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matching []Message
+	for _, msg := range s.messages {
+		if filter.matches(msg) {
+			matching = append(matching, msg.metadata())
+		}
+	}
+	return newestFirst(matching, filter.Limit), nil
+}
+
+// Recover is a no-op, nothing survives a restart of a MemoryStore.
+func (s *MemoryStore) Recover() (int, error) {
+	return 0, nil
+}
+
+// Prune deletes sent and failed messages last updated before the given time.
+func (s *MemoryStore) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pruned := 0
+	for id, msg := range s.messages {
+		if msg.isFinal() && msg.UpdatedAt.Before(before) {
+			delete(s.messages, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// Close is a no-op.
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,164 @@
+package outbox
+
+import (
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// Status describes where a message is in its delivery lifecycle.
+type Status string
+
+const (
+	// StatusAccepted means the message is stored and waiting for (another) delivery attempt.
+	StatusAccepted Status = "accepted"
+	// StatusSending means a worker is currently handing the message to the provider.
+	StatusSending Status = "sending"
+	// StatusSent means the provider accepted the message.
+	StatusSent Status = "sent"
+	// StatusFailed means delivery was given up.
+	StatusFailed Status = "failed"
+)
+
+// ParseStatus returns the Status named by s.
+func ParseStatus(s string) (Status, error) {
+	switch status := Status(s); status {
+	case StatusAccepted, StatusSending, StatusSent, StatusFailed:
+		return status, nil
+	default:
+		return "", fmt.Errorf("unknown message status %q", s)
+	}
+}
+
+// Message is a mail stored in the outbox together with its delivery state.
+// Once the message is sent or failed, its content, Bcc recipients and
+// attachments are dropped, only the metadata is kept until the retention ends.
+type Message struct {
+	ID         string              `json:"id"`
+	Status     Status              `json:"status"`
+	Attributes mail.MailAttributes `json:"attributes"`
+	// BatchID is shared by the messages enqueued together with EnqueueBatch,
+	// which are delivered together where the providers support it.
+	BatchID string `json:"batchId,omitempty"`
+	// Recipients is the number of To, Cc and Bcc recipients.
+	Recipients    int       `json:"recipients"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	// Provider is the provider that accepted the mail, ProviderMessageIDs the ids it assigned.
+	Provider           string   `json:"provider,omitempty"`
+	ProviderMessageIDs []string `json:"providerMessageIds,omitempty"`
+	// History lists every status change in order.
+	History []Event `json:"history"`
+}
+
+// Event is one status change of a message.
+type Event struct {
+	Status Status    `json:"status"`
+	Time   time.Time `json:"time"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// isPending reports whether the message still needs a delivery attempt.
+func (m Message) isPending() bool {
+	return m.Status == StatusAccepted || m.Status == StatusSending
+}
+
+// isFinal reports whether the message reached a state it will not leave again.
+func (m Message) isFinal() bool {
+	return m.Status == StatusSent || m.Status == StatusFailed
+}
+
+// transition moves the message to status and records the change in its history.
+// A final status drops what is only needed for delivery, see dropContent.
+func (m *Message) transition(status Status, at time.Time, errMsg string) {
+	m.Status = status
+	m.UpdatedAt = at
+	m.LastError = errMsg
+	m.History = append(m.History, Event{Status: status, Time: at, Error: errMsg})
+	if m.isFinal() {
+		m.dropContent()
+	}
+}
+
+// dropContent keeps the metadata of the mail and drops its content, Bcc
+// recipients and attachments, so that they are not kept until the retention ends.
+func (m *Message) dropContent() {
+	a := m.Attributes
+	m.Attributes = mail.MailAttributes{
+		To:       a.To,
+		Cc:       a.Cc,
+		ReplyTo:  a.ReplyTo,
+		Subject:  a.Subject,
+		From:     a.From,
+		FromName: a.FromName,
+		Tags:     a.Tags,
+		Client:   a.Client,
+	}
+}
+
+// metadata returns a copy of the message without its content, see dropContent.
+func (m Message) metadata() Message {
+	m.dropContent()
+	return m
+}
+
+// Filter selects messages in Store.List. Zero fields match everything.
+type Filter struct {
+	Status Status
+	// Recipient matches messages sent to this address in To or Cc, ignoring case.
+	// Bcc recipients are not matched, as they are not shown either.
+	Recipient string
+	// Since and Until bound the creation time, Since inclusive and Until exclusive.
+	Since time.Time
+	Until time.Time
+	// Limit caps the number of returned messages.
+	Limit int
+}
+
+func (f Filter) matches(m Message) bool {
+	if f.Status != "" && m.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && m.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !m.CreatedAt.Before(f.Until) {
+		return false
+	}
+	if f.Recipient != "" {
+		recipients := slices.Concat(m.Attributes.ToAddresses(), m.Attributes.Cc)
+		return slices.ContainsFunc(recipients, func(address string) bool {
+			return strings.EqualFold(strings.TrimSpace(address), strings.TrimSpace(f.Recipient))
+		})
+	}
+	return true
+}
+
+// newestFirst sorts messages by creation time, newest first, and applies the filter limit.
+func newestFirst(messages []Message, limit int) []Message {
+	slices.SortFunc(messages, func(a, b Message) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages
+}
+
+// newID returns a random RFC 4122 version 4 UUID.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating message id: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
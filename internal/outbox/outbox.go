@@ -0,0 +1,428 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// maxRetryBackoff caps the exponential delay between two delivery attempts.
+const maxRetryBackoff = time.Hour
+
+// pruneInterval is how often expired messages are removed from the store.
+const pruneInterval = time.Hour
+
+// maxJobSize is the most messages of a batch that a worker delivers together.
+const maxJobSize = 100
+
+// Outbox records every mail and its delivery status in a Store. With the
+// queue enabled, mail is delivered in the background through the wrapped
+// MailService, retrying failed attempts with exponential backoff. Without
+// it, mail is delivered right away and only its outcome is recorded.
+//
+// Outbox itself implements mail.MailService, so ingestion paths that do not
+// care about the message ID can use it as a drop-in.
+type Outbox struct {
+	store     Store
+	svc       mail.MailService
+	cfg       config.QueueConfig
+	retention time.Duration
+	now       func() time.Time
+
+	wake   chan struct{}
+	jobs   chan []Message
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates an outbox that records messages in store and delivers them through svc.
+// Call Start to begin background delivery and pruning.
+func New(store Store, svc mail.MailService, cfg *config.Config) *Outbox {
+	return &Outbox{
+		store:     store,
+		svc:       svc,
+		cfg:       cfg.Queue,
+		retention: cfg.Messages.Retention,
+		now:       func() time.Time { return time.Now().UTC() },
+		wake:      make(chan struct{}, 1),
+		jobs:      make(chan []Message),
+	}
+}
+
+// Queued reports whether mail is delivered in the background.
+func (o *Outbox) Queued() bool {
+	return o.cfg.Enabled
+}
+
+// SendMail enqueues the mail if the queue is enabled, otherwise it delivers it right away.
+func (o *Outbox) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	if o.Queued() {
+		_, err := o.Enqueue(attributes)
+		return err
+	}
+	_, err := o.Send(ctx, attributes)
+	return err
+}
+
+// Enqueue persists the mail and returns the stored message with its ID.
+// Once Enqueue returns without error the mail survives a restart.
+func (o *Outbox) Enqueue(attributes mail.MailAttributes) (Message, error) {
+	msg, err := o.accept(attributes)
+	if err != nil {
+		return Message{}, err
+	}
+
+	slog.Debug("outbox: message accepted", "id", msg.ID)
+	o.notify()
+	return msg, nil
+}
+
+// EnqueueBatch persists the mails like Enqueue and returns the stored message
+// and the error of each mail. The messages share a batch ID, so that the
+// workers deliver them together where the providers support it.
+func (o *Outbox) EnqueueBatch(mails []mail.MailAttributes) ([]Message, []error) {
+	msgs := make([]Message, len(mails))
+	errs := make([]error, len(mails))
+	batchID, err := newID()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return msgs, errs
+	}
+
+	for i, attributes := range mails {
+		msgs[i], errs[i] = o.acceptBatch(attributes, batchID)
+	}
+	slog.Debug("outbox: batch accepted", "batch_id", batchID, "messages", len(mails))
+	o.notify()
+	return msgs, errs
+}
+
+// Send delivers the mail right away and returns the recorded message.
+// If delivery fails, the message is recorded as failed and the error returned.
+func (o *Outbox) Send(ctx context.Context, attributes mail.MailAttributes) (Message, error) {
+	msg, err := o.accept(attributes)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg.transition(StatusSending, o.now(), "")
+	msg.Attempts++
+	ctx, delivery := mail.NewDeliveryContext(ctx)
+	sendErr := o.svc.SendMail(ctx, attributes)
+	o.finish(&msg, *delivery, sendErr)
+	return msg, sendErr
+}
+
+// SendBatch delivers the mails right away, as a single batch where the
+// providers support it, and returns the recorded message and the error of
+// each mail. A mail that could not be recorded is not sent.
+func (o *Outbox) SendBatch(ctx context.Context, mails []mail.MailAttributes) ([]Message, []error) {
+	msgs := make([]Message, len(mails))
+	errs := make([]error, len(mails))
+	var accepted []int
+	for i, attributes := range mails {
+		msg, err := o.accept(attributes)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		msg.transition(StatusSending, o.now(), "")
+		msg.Attempts++
+		msgs[i] = msg
+		accepted = append(accepted, i)
+	}
+
+	results := make([]mail.BatchResult, len(mails))
+	mail.SendBatchSubset(ctx, o.svc, mails, accepted, results)
+	for _, i := range accepted {
+		o.finish(&msgs[i], results[i].Delivery, results[i].Err)
+		errs[i] = results[i].Err
+	}
+	return msgs, errs
+}
+
+// finish records the outcome of delivering msg right away.
+func (o *Outbox) finish(msg *Message, delivery mail.Delivery, sendErr error) {
+	if sendErr != nil {
+		msg.transition(StatusFailed, o.now(), sendErr.Error())
+	} else {
+		msg.transition(StatusSent, o.now(), "")
+		msg.Provider = delivery.Provider
+		msg.ProviderMessageIDs = delivery.MessageIDs
+	}
+
+	if err := o.store.Save(*msg); err != nil {
+		slog.Error("outbox: failed to update message", "id", msg.ID, "status", msg.Status, "error", err)
+	}
+}
+
+// Get returns the stored message with the given id.
+func (o *Outbox) Get(id string) (Message, error) {
+	return o.store.Get(id)
+}
+
+// List returns the stored messages matching filter, newest first.
+func (o *Outbox) List(filter Filter) ([]Message, error) {
+	return o.store.List(filter)
+}
+
+func (o *Outbox) accept(attributes mail.MailAttributes) (Message, error) {
+	return o.acceptBatch(attributes, "")
+}
+
+// acceptBatch stores the mail as an accepted message of the given batch.
+func (o *Outbox) acceptBatch(attributes mail.MailAttributes, batchID string) (Message, error) {
+	id, err := newID()
+	if err != nil {
+		return Message{}, err
+	}
+
+	now := o.now()
+	msg := Message{
+		ID:            id,
+		Attributes:    attributes,
+		BatchID:       batchID,
+		Recipients:    len(attributes.ToAddresses()) + len(attributes.Cc) + len(attributes.Bcc),
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+	msg.transition(StatusAccepted, now, "")
+	if err := o.store.Save(msg); err != nil {
+		return Message{}, fmt.Errorf("storing message: %w", err)
+	}
+	return msg, nil
+}
+
+// Start recovers messages interrupted by a previous shutdown and starts the
+// background goroutines. They run until ctx is cancelled or Shutdown is called.
+func (o *Outbox) Start(ctx context.Context) error {
+	recovered, err := o.store.Recover()
+	if err != nil {
+		return fmt.Errorf("recovering outbox: %w", err)
+	}
+	if recovered > 0 {
+		slog.Info("outbox: re-queued interrupted messages", "count", recovered)
+	}
+
+	ctx, o.cancel = context.WithCancel(ctx)
+
+	o.wg.Add(1)
+	go o.prune(ctx)
+
+	if !o.Queued() {
+		return nil
+	}
+
+	o.wg.Add(o.cfg.Workers)
+	for range o.cfg.Workers {
+		go o.work()
+	}
+	go o.dispatch(ctx)
+
+	slog.Info("outbox: started", "workers", o.cfg.Workers)
+	return nil
+}
+
+// Shutdown stops picking up new messages and waits for in-flight deliveries
+// to finish or ctx to expire. Messages still being delivered when ctx expires
+// are retried after the next start.
+func (o *Outbox) Shutdown(ctx context.Context) error {
+	if o.cancel != nil {
+		o.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("outbox shutdown: %w", ctx.Err())
+	}
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// prune periodically removes sent and failed messages older than the retention.
+func (o *Outbox) prune(ctx context.Context) {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := o.store.Prune(o.now().Add(-o.retention))
+		if err != nil {
+			slog.Error("outbox: failed to prune messages", "error", err)
+		} else if pruned > 0 {
+			slog.Info("outbox: pruned expired messages", "count", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch polls the store for due messages, marks them as sending and hands them to the workers.
+func (o *Outbox) dispatch(ctx context.Context) {
+	defer close(o.jobs)
+
+	ticker := time.NewTicker(o.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if ctx.Err() == nil && o.dispatchDue(ctx) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// dispatchDue hands the due messages to the workers, those of a batch
+// together, and reports whether the limit was reached, i.e. more messages
+// may be waiting.
+func (o *Outbox) dispatchDue(ctx context.Context) bool {
+	limit := o.cfg.Workers * maxJobSize
+	due, err := o.store.Due(o.now(), limit)
+	if err != nil {
+		slog.Error("outbox: failed to load due messages", "error", err)
+		return false
+	}
+
+	for _, job := range groupJobs(due) {
+		for i := range job {
+			job[i].transition(StatusSending, o.now(), "")
+			if err := o.store.Save(job[i]); err != nil {
+				slog.Error("outbox: failed to update message", "id", job[i].ID, "error", err)
+				return false
+			}
+		}
+
+		select {
+		case o.jobs <- job:
+		case <-ctx.Done():
+			// left in the sending state, Recover re-queues it on the next start
+			return false
+		}
+	}
+	return len(due) == limit
+}
+
+// groupJobs splits messages into the jobs of the workers: the messages of a
+// batch in jobs of up to maxJobSize, every other message on its own.
+func groupJobs(msgs []Message) [][]Message {
+	var jobs [][]Message
+	open := make(map[string]int)
+	for _, msg := range msgs {
+		if msg.BatchID == "" {
+			jobs = append(jobs, []Message{msg})
+			continue
+		}
+		if i, ok := open[msg.BatchID]; ok && len(jobs[i]) < maxJobSize {
+			jobs[i] = append(jobs[i], msg)
+			continue
+		}
+		open[msg.BatchID] = len(jobs)
+		jobs = append(jobs, []Message{msg})
+	}
+	return jobs
+}
+
+func (o *Outbox) work() {
+	defer o.wg.Done()
+	for job := range o.jobs {
+		o.deliver(job)
+	}
+}
+
+// deliver makes one delivery attempt for the messages of a job, as a single
+// batch if there are several, and stores the outcome of each. In-flight
+// attempts are not cancelled on shutdown so the provider call is not cut off halfway.
+func (o *Outbox) deliver(job []Message) {
+	if len(job) == 1 {
+		ctx, delivery := mail.NewDeliveryContext(context.Background())
+		err := o.svc.SendMail(ctx, job[0].Attributes)
+		o.complete(job[0], *delivery, err)
+		return
+	}
+
+	mails := make([]mail.MailAttributes, len(job))
+	for i, msg := range job {
+		mails[i] = msg.Attributes
+	}
+	for i, result := range mail.SendBatch(context.Background(), o.svc, mails) {
+		o.complete(job[i], result.Delivery, result.Err)
+	}
+}
+
+// complete stores the outcome of a delivery attempt of msg.
+func (o *Outbox) complete(msg Message, delivery mail.Delivery, err error) {
+	msg.Attempts++
+	now := o.now()
+	switch {
+	case err == nil:
+		msg.transition(StatusSent, now, "")
+		msg.Provider = delivery.Provider
+		msg.ProviderMessageIDs = delivery.MessageIDs
+		slog.Info("outbox: message sent", "id", msg.ID, "attempts", msg.Attempts, "provider", msg.Provider)
+	case mail.IsPermanent(err):
+		msg.transition(StatusFailed, now, err.Error())
+		slog.Error("outbox: provider rejected message", "id", msg.ID, "attempts", msg.Attempts, "error", err)
+	case errors.Is(err, mail.ErrRateLimited):
+		// the mail was held back before reaching a provider, so it was no attempt
+		msg.Attempts--
+		msg.transition(StatusAccepted, now, err.Error())
+		msg.NextAttemptAt = now.Add(max(mail.RetryAfter(err), o.cfg.PollInterval))
+		slog.Info("outbox: delivery postponed by rate limit", "id", msg.ID, "next_attempt_at", msg.NextAttemptAt)
+	case msg.Attempts >= o.cfg.MaxAttempts:
+		msg.transition(StatusFailed, now, err.Error())
+		slog.Error("outbox: giving up on message", "id", msg.ID, "attempts", msg.Attempts, "error", err)
+	default:
+		msg.transition(StatusAccepted, now, err.Error())
+		msg.NextAttemptAt = now.Add(max(retryBackoff(o.cfg.RetryBackoff, msg.Attempts), mail.RetryAfter(err)))
+		slog.Warn("outbox: delivery failed, will retry", "id", msg.ID, "attempts", msg.Attempts, "next_attempt_at", msg.NextAttemptAt, "error", err)
+	}
+
+	if err := o.store.Save(msg); err != nil {
+		slog.Error("outbox: failed to update message", "id", msg.ID, "status", msg.Status, "error", err)
+	}
+}
+
+// retryBackoff returns the delay after the given number of failed attempts:
+// base, 2*base, 4*base, ... capped at maxRetryBackoff.
+func retryBackoff(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return min(delay, maxRetryBackoff)
+}
@@ -0,0 +1,486 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/config"
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// flakyService fails the first failures calls and records every successful delivery.
+type flakyService struct {
+	mu        sync.Mutex
+	failures  int
+	calls     int
+	delivered []mail.MailAttributes
+}
+
+func (s *flakyService) SendMail(ctx context.Context, attributes mail.MailAttributes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return errors.New("provider unavailable")
+	}
+	s.delivered = append(s.delivered, attributes)
+	mail.RecordDelivery(ctx, "flaky", fmt.Sprintf("provider-id-%d", s.calls))
+	return nil
+}
+
+// batchService records the size of each batch it sends.
+type batchService struct {
+	flakyService
+	batches []int
+}
+
+func (s *batchService) SendBatch(ctx context.Context, mails []mail.MailAttributes) []mail.BatchResult {
+	s.mu.Lock()
+	s.batches = append(s.batches, len(mails))
+	s.mu.Unlock()
+
+	results := make([]mail.BatchResult, len(mails))
+	for i, attributes := range mails {
+		results[i].Err = s.SendMail(ctx, attributes)
+		results[i].Delivery = mail.Delivery{Provider: "batch"}
+	}
+	return results
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Queue: config.QueueConfig{
+			Enabled:      true,
+			Workers:      2,
+			MaxAttempts:  3,
+			RetryBackoff: time.Millisecond,
+			PollInterval: 5 * time.Millisecond,
+		},
+		Messages: config.MessagesConfig{Retention: time.Hour},
+	}
+}
+
+func startOutbox(t *testing.T, store Store, svc mail.MailService, cfg *config.Config) *Outbox {
+	t.Helper()
+	o := New(store, svc, cfg)
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := o.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+	})
+	return o
+}
+
+func waitForStatus(t *testing.T, o *Outbox, id string, want Status) Message {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		msg, err := o.Get(id)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if msg.Status == want {
+			return msg
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message %s has status %q, want %q", id, msg.Status, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOutbox_DeliversMessage(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+	svc := &flakyService{}
+	o := startOutbox(t, store, svc, testConfig())
+
+	msg, err := o.Enqueue(mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "body"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if msg.ID == "" || msg.Status != StatusAccepted {
+		t.Errorf("Enqueue() = %+v, want accepted message with id", msg)
+	}
+
+	sent := waitForStatus(t, o, msg.ID, StatusSent)
+	if sent.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", sent.Attempts)
+	}
+	if sent.Provider != "flaky" || !reflect.DeepEqual(sent.ProviderMessageIDs, []string{"provider-id-1"}) {
+		t.Errorf("provider = %q, providerMessageIds = %v", sent.Provider, sent.ProviderMessageIDs)
+	}
+	if got := historyStatuses(sent); !reflect.DeepEqual(got, []Status{StatusAccepted, StatusSending, StatusSent}) {
+		t.Errorf("history = %v", got)
+	}
+}
+
+func TestOutbox_RetriesUntilSent(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+	svc := &flakyService{failures: 2}
+	o := startOutbox(t, store, svc, testConfig())
+
+	msg, err := o.Enqueue(mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "body"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	sent := waitForStatus(t, o, msg.ID, StatusSent)
+	if sent.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", sent.Attempts)
+	}
+	if sent.LastError != "" {
+		t.Errorf("lastError = %q, want empty after success", sent.LastError)
+	}
+}
+
+func TestOutbox_FailsAfterMaxAttempts(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+	svc := &flakyService{failures: 100}
+	o := startOutbox(t, store, svc, testConfig())
+
+	msg, err := o.Enqueue(mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "body"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	failed := waitForStatus(t, o, msg.ID, StatusFailed)
+	if failed.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", failed.Attempts)
+	}
+	if failed.LastError != "provider unavailable" {
+		t.Errorf("lastError = %q", failed.LastError)
+	}
+}
+
+func TestOutbox_DeliversAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+
+	// accept without starting the workers, as if the pod stopped right after
+	store := openTestStore(t, path)
+	msg, err := New(store, &flakyService{}, testConfig()).Enqueue(mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "body"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	store = openTestStore(t, path)
+	defer func() { _ = store.Close() }()
+	svc := &flakyService{}
+	o := startOutbox(t, store, svc, testConfig())
+
+	waitForStatus(t, o, msg.ID, StatusSent)
+	if len(svc.delivered) != 1 || svc.delivered[0].To != "a@example.com" {
+		t.Errorf("delivered = %v", svc.delivered)
+	}
+}
+
+func TestOutbox_DeliversBatchTogether(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+	svc := &batchService{}
+	o := New(store, svc, testConfig())
+
+	mails := make([]mail.MailAttributes, 5)
+	for i := range mails {
+		mails[i] = mail.MailAttributes{To: fmt.Sprintf("%d@example.com", i), Subject: "subject", HtmlContent: "body"}
+	}
+	msgs, errs := o.EnqueueBatch(mails)
+	if _, err := o.Enqueue(mail.MailAttributes{To: "single@example.com", Subject: "subject", HtmlContent: "body"}); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() { _ = o.Shutdown(context.Background()) }()
+
+	for i, msg := range msgs {
+		if errs[i] != nil || msg.BatchID == "" || msg.BatchID != msgs[0].BatchID {
+			t.Fatalf("EnqueueBatch()[%d] = %+v, %v, want a message of the batch", i, msg, errs[i])
+		}
+		if sent := waitForStatus(t, o, msg.ID, StatusSent); sent.Provider != "batch" || sent.Attempts != 1 {
+			t.Errorf("message %d = %+v, want sent once through the batch", i, sent)
+		}
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if !reflect.DeepEqual(svc.batches, []int{5}) {
+		t.Errorf("batches = %v, want a single batch of 5", svc.batches)
+	}
+}
+
+func Test_groupJobs(t *testing.T) {
+	var msgs []Message
+	for i := range maxJobSize + 1 {
+		msgs = append(msgs, Message{ID: fmt.Sprintf("a%d", i), BatchID: "a"})
+	}
+	msgs = append(msgs, Message{ID: "single"}, Message{ID: "b0", BatchID: "b"})
+
+	var sizes []int
+	for _, job := range groupJobs(msgs) {
+		sizes = append(sizes, len(job))
+	}
+	if want := []int{maxJobSize, 1, 1, 1}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("groupJobs() sizes = %v, want %v", sizes, want)
+	}
+}
+
+func Test_retryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 20, want: maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(30*time.Second, tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(30s, %d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func Test_newID(t *testing.T) {
+	a, err := newID()
+	if err != nil {
+		t.Fatalf("newID() error = %v", err)
+	}
+	b, _ := newID()
+	if len(a) != 36 || a == b {
+		t.Errorf("newID() = %q, %q", a, b)
+	}
+}
+
+func historyStatuses(msg Message) []Status {
+	statuses := make([]Status, 0, len(msg.History))
+	for _, event := range msg.History {
+		statuses = append(statuses, event.Status)
+	}
+	return statuses
+}
+
+func TestOutbox_SendRecordsOutcome(t *testing.T) {
+	cfg := testConfig()
+	cfg.Queue.Enabled = false
+	svc := &flakyService{failures: 1}
+	o := New(NewMemoryStore(), svc, cfg)
+	attrs := mail.MailAttributes{
+		To:          "a@example.com",
+		Bcc:         []string{"audit@example.com"},
+		Subject:     "subject",
+		HtmlContent: "body",
+		Attachments: []mail.Attachment{{Filename: "a.txt", Content: "YQ=="}},
+	}
+
+	failed, err := o.Send(context.Background(), attrs)
+	if err == nil {
+		t.Fatal("Send() expected error from failing provider")
+	}
+	if failed.Status != StatusFailed || failed.LastError != "provider unavailable" {
+		t.Errorf("failed message = %+v", failed)
+	}
+
+	sent, err := o.Send(context.Background(), attrs)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	stored, err := o.Get(sent.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if stored.Status != StatusSent || stored.Provider != "flaky" || stored.Attempts != 1 {
+		t.Errorf("stored message = %+v", stored)
+	}
+	if got := historyStatuses(stored); !reflect.DeepEqual(got, []Status{StatusAccepted, StatusSending, StatusSent}) {
+		t.Errorf("history = %v", got)
+	}
+	want := mail.MailAttributes{To: "a@example.com", Subject: "subject"}
+	if !reflect.DeepEqual(stored.Attributes, want) || stored.Recipients != 2 {
+		t.Errorf("stored attributes = %+v with %d recipients, want %+v with 2", stored.Attributes, stored.Recipients, want)
+	}
+	if !reflect.DeepEqual(failed.Attributes, want) {
+		t.Errorf("failed attributes = %+v, want %+v", failed.Attributes, want)
+	}
+	if len(svc.delivered) != 1 || svc.delivered[0].HtmlContent != "body" || len(svc.delivered[0].Bcc) != 1 {
+		t.Errorf("delivered = %+v, want the complete mail", svc.delivered)
+	}
+}
+
+func TestOutbox_SendBatchRecordsEachOutcome(t *testing.T) {
+	cfg := testConfig()
+	cfg.Queue.Enabled = false
+	svc := &flakyService{failures: 1}
+	o := New(NewMemoryStore(), svc, cfg)
+
+	msgs, errs := o.SendBatch(context.Background(), []mail.MailAttributes{
+		{To: "a@example.com", Subject: "first", HtmlContent: "body"},
+		{To: "b@example.com", Subject: "second", HtmlContent: "body"},
+	})
+	if len(msgs) != 2 || len(errs) != 2 {
+		t.Fatalf("SendBatch() returned %d messages and %d errors, want 2 each", len(msgs), len(errs))
+	}
+	if errs[0] == nil || msgs[0].Status != StatusFailed {
+		t.Errorf("first message = %+v with error %v, want failed", msgs[0], errs[0])
+	}
+	if errs[1] != nil || msgs[1].ID == msgs[0].ID {
+		t.Errorf("second message = %+v with error %v, want sent with its own ID", msgs[1], errs[1])
+	}
+	stored, err := o.Get(msgs[1].ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if stored.Status != StatusSent || stored.Provider != "flaky" || !reflect.DeepEqual(stored.ProviderMessageIDs, []string{"provider-id-2"}) {
+		t.Errorf("stored message = %+v", stored)
+	}
+}
+
+func TestOutbox_SendMailWithoutQueueDeliversImmediately(t *testing.T) {
+	cfg := testConfig()
+	cfg.Queue.Enabled = false
+	svc := &flakyService{}
+	o := New(NewMemoryStore(), svc, cfg)
+
+	if err := o.SendMail(context.Background(), mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "body"}); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+	if len(svc.delivered) != 1 {
+		t.Errorf("delivered %d mails, want 1", len(svc.delivered))
+	}
+	messages, _ := o.List(Filter{Status: StatusSent})
+	if len(messages) != 1 {
+		t.Errorf("List(sent) returned %d messages, want 1", len(messages))
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	if got, err := ParseStatus("sent"); err != nil || got != StatusSent {
+		t.Errorf("ParseStatus(sent) = %q, %v", got, err)
+	}
+	if _, err := ParseStatus("bounced"); err == nil {
+		t.Error("ParseStatus(bounced) expected error")
+	}
+}
+
+// rejectingService fails every mail with a permanent provider error.
+type rejectingService struct {
+	calls int
+}
+
+func (s *rejectingService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	s.calls++
+	return mail.NewStatusError("mailjet", 400, "", errors.New("invalid recipient"))
+}
+
+func TestOutbox_PermanentErrorFailsImmediately(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+	o := startOutbox(t, store, &rejectingService{}, testConfig())
+
+	msg, err := o.Enqueue(mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "body"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	failed := waitForStatus(t, o, msg.ID, StatusFailed)
+	if failed.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", failed.Attempts)
+	}
+}
+
+// rateLimitedService asks to retry after an hour.
+type rateLimitedService struct{}
+
+func (rateLimitedService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	return mail.NewStatusError("sendgrid", 429, "3600", errors.New("quota exceeded"))
+}
+
+func TestOutbox_HonorsRetryAfter(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+	o := startOutbox(t, store, rateLimitedService{}, testConfig())
+
+	msg, err := o.Enqueue(mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "body"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stored, err := o.Get(msg.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if stored.Attempts == 1 && stored.Status == StatusAccepted {
+			if wait := stored.NextAttemptAt.Sub(stored.UpdatedAt); wait < 59*time.Minute {
+				t.Errorf("next attempt in %v, want about an hour", wait)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message = %+v, want one failed attempt", stored)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// heldBackService holds every mail back as if a rate limit was exhausted for a minute.
+type heldBackService struct{}
+
+func (heldBackService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	return &mail.ProviderError{
+		Provider:   "sendgrid",
+		Transient:  true,
+		RetryAfter: time.Minute,
+		Err:        fmt.Errorf("%w: sendgrid allows 1 recipients per minute", mail.ErrRateLimited),
+	}
+}
+
+func TestOutbox_RateLimitPostponesWithoutAttempt(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+	o := startOutbox(t, store, heldBackService{}, testConfig())
+
+	msg, err := o.Enqueue(mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "body"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stored, err := o.Get(msg.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if stored.Status == StatusAccepted && stored.LastError != "" {
+			if stored.Attempts != 0 {
+				t.Errorf("attempts = %d, want 0", stored.Attempts)
+			}
+			if wait := stored.NextAttemptAt.Sub(stored.UpdatedAt); wait < 59*time.Second {
+				t.Errorf("next attempt in %v, want about a minute", wait)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message = %+v, want it postponed", stored)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
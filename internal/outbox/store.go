@@ -0,0 +1,296 @@
+package outbox
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned when no message exists for the requested id.
+var ErrNotFound = errors.New("message not found")
+
+var (
+	messagesBucket = []byte("messages")
+	contentsBucket = []byte("contents")
+	pendingBucket  = []byte("pending")
+)
+
+// Store persists outbox messages.
+type Store interface {
+	// Save inserts or replaces a message.
+	Save(msg Message) error
+	// Get returns the message with the given id or ErrNotFound.
+	Get(id string) (Message, error)
+	// Due returns up to limit accepted messages whose next attempt is due at
+	// now, the longest due first.
+	Due(now time.Time, limit int) ([]Message, error)
+	// List returns the messages matching filter, newest first, without their
+	// content, Bcc recipients and attachments.
+	List(filter Filter) ([]Message, error)
+	// Recover returns messages left in the sending state, e.g. after a crash, to the accepted state.
+	Recover() (int, error)
+	// Prune deletes sent and failed messages last updated before the given time.
+	Prune(before time.Time) (int, error)
+	// Close releases the underlying resources.
+	Close() error
+}
+
+// BoltStore is a Store backed by a bbolt database file.
+// The metadata of messages is kept as JSON in one bucket and the attributes of
+// undelivered messages, with their content and attachments, in a second one,
+// so that polling and listing do not decode them. Undelivered messages are
+// indexed by their next attempt in a third bucket, so that polling does not
+// scan delivered mail and finds the messages in the order they became due.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens or creates the bbolt database at path.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening outbox database %q: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{messagesBucket, contentsBucket, pendingBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initializing outbox database %q: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Save inserts or replaces a message and keeps the pending index in sync.
+func (s *BoltStore) Save(msg Message) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putMessage(tx, msg)
+	})
+}
+
+// Get returns the message with the given id.
+func (s *BoltStore) Get(id string) (Message, error) {
+	var msg Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		msg, err = getMetadata(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+		return getContent(tx, &msg)
+	})
+	return msg, err
+}
+
+// Due returns up to limit accepted messages whose next attempt is due, the
+// longest due first. Only the content of the due messages is decoded.
+func (s *BoltStore) Due(now time.Time, limit int) ([]Message, error) {
+	var due []Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(pendingBucket).Cursor()
+		for key, id := cursor.First(); key != nil && len(due) < limit; key, id = cursor.Next() {
+			if pendingTime(key).After(now) {
+				break
+			}
+			msg, err := getMetadata(tx, id)
+			if err != nil {
+				return err
+			}
+			// messages being sent stay in the index until they are done
+			if msg.Status != StatusAccepted {
+				continue
+			}
+			if err := getContent(tx, &msg); err != nil {
+				return err
+			}
+			due = append(due, msg)
+		}
+		return nil
+	})
+	return due, err
+}
+
+// List returns the messages matching filter, newest first, without their content.
+func (s *BoltStore) List(filter Filter) ([]Message, error) {
+	var matching []Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(messagesBucket).ForEach(func(id, data []byte) error {
+			var msg Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				return fmt.Errorf("decoding message %s: %w", id, err)
+			}
+			if filter.matches(msg) {
+				matching = append(matching, msg.metadata())
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newestFirst(matching, filter.Limit), nil
+}
+
+// Prune deletes sent and failed messages last updated before the given time.
+func (s *BoltStore) Prune(before time.Time) (int, error) {
+	pruned := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		messages := tx.Bucket(messagesBucket)
+		var expired [][]byte
+		err := messages.ForEach(func(id, data []byte) error {
+			var msg Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				return fmt.Errorf("decoding message %s: %w", id, err)
+			}
+			if msg.isFinal() && msg.UpdatedAt.Before(before) {
+				expired = append(expired, slices.Clone(id))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// keys must not be deleted while iterating with ForEach
+		for _, id := range expired {
+			if err := messages.Delete(id); err != nil {
+				return err
+			}
+			if err := tx.Bucket(contentsBucket).Delete(id); err != nil {
+				return err
+			}
+		}
+		pruned = len(expired)
+		return nil
+	})
+	return pruned, err
+}
+
+// Recover resets every message in the sending state back to accepted.
+func (s *BoltStore) Recover() (int, error) {
+	recovered := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(pendingBucket).Cursor()
+		var stuck []Message
+		for key, id := cursor.First(); key != nil; key, id = cursor.Next() {
+			msg, err := getMetadata(tx, id)
+			if err != nil {
+				return err
+			}
+			if msg.Status != StatusSending {
+				continue
+			}
+			if err := getContent(tx, &msg); err != nil {
+				return err
+			}
+			stuck = append(stuck, msg)
+		}
+
+		for _, msg := range stuck {
+			msg.transition(StatusAccepted, time.Now().UTC(), "interrupted by shutdown")
+			if err := putMessage(tx, msg); err != nil {
+				return err
+			}
+		}
+		recovered = len(stuck)
+		return nil
+	})
+	return recovered, err
+}
+
+// Close closes the database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// putMessage stores the metadata of msg and keeps the pending index in sync.
+// The attributes of a pending message are stored apart, those of a sent or
+// failed message are deleted.
+func putMessage(tx *bolt.Tx, msg Message) error {
+	id := []byte(msg.ID)
+	contents := tx.Bucket(contentsBucket)
+	pending := tx.Bucket(pendingBucket)
+
+	// the index key changes with the next attempt
+	previous, err := getMetadata(tx, id)
+	switch {
+	case err == nil && previous.isPending():
+		if err := pending.Delete(pendingKey(previous)); err != nil {
+			return err
+		}
+	case err != nil && !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	meta, err := json.Marshal(msg.metadata())
+	if err != nil {
+		return fmt.Errorf("encoding message %s: %w", msg.ID, err)
+	}
+	if err := tx.Bucket(messagesBucket).Put(id, meta); err != nil {
+		return err
+	}
+
+	if !msg.isPending() {
+		return contents.Delete(id)
+	}
+	content, err := json.Marshal(msg.Attributes)
+	if err != nil {
+		return fmt.Errorf("encoding content of message %s: %w", msg.ID, err)
+	}
+	if err := contents.Put(id, content); err != nil {
+		return err
+	}
+	return pending.Put(pendingKey(msg), id)
+}
+
+// pendingKey is the key of msg in the pending index: its next attempt as
+// big-endian Unix nanoseconds followed by its id, so that the keys sort by the
+// time the messages are due. The index maps the key to the id.
+func pendingKey(msg Message) []byte {
+	key := make([]byte, 8, 8+len(msg.ID))
+	binary.BigEndian.PutUint64(key, uint64(msg.NextAttemptAt.UnixNano()))
+	return append(key, msg.ID...)
+}
+
+// pendingTime returns the next attempt encoded in a key of the pending index.
+func pendingTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
+
+// getMetadata decodes the message with the given id without its content.
+func getMetadata(tx *bolt.Tx, id []byte) (Message, error) {
+	data := tx.Bucket(messagesBucket).Get(id)
+	if data == nil {
+		return Message{}, ErrNotFound
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return Message{}, fmt.Errorf("decoding message %s: %w", id, err)
+	}
+	return msg, nil
+}
+
+// getContent fills in the attributes of msg. Only pending messages have them,
+// those of sent and failed messages are deleted.
+func getContent(tx *bolt.Tx, msg *Message) error {
+	data := tx.Bucket(contentsBucket).Get([]byte(msg.ID))
+	if data == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &msg.Attributes); err != nil {
+		return fmt.Errorf("decoding content of message %s: %w", msg.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package outbox
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestStore(t *testing.T, path string) *BoltStore {
+	t.Helper()
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("OpenBoltStore() error = %v", err)
+	}
+	return store
+}
+
+func testMessage(id string, status Status, next time.Time) Message {
+	return Message{
+		ID:            id,
+		Status:        status,
+		Attributes:    mail.MailAttributes{To: "a@example.com", Subject: "subject", HtmlContent: "<p>body</p>"},
+		CreatedAt:     next,
+		UpdatedAt:     next,
+		NextAttemptAt: next,
+	}
+}
+
+func TestBoltStore_SaveAndGet(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := store.Save(testMessage("1", StatusAccepted, now)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	got, err := store.Get("1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Status != StatusAccepted || got.Attributes.To != "a@example.com" || !got.NextAttemptAt.Equal(now) {
+		t.Errorf("Get() = %+v", got)
+	}
+
+	if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestBoltStore_Due(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+
+	now := time.Now().UTC()
+	for _, msg := range []Message{
+		testMessage("due", StatusAccepted, now.Add(-time.Second)),
+		testMessage("later", StatusAccepted, now.Add(time.Minute)),
+		testMessage("sending", StatusSending, now.Add(-time.Second)),
+		testMessage("sent", StatusSent, now.Add(-time.Second)),
+		testMessage("failed", StatusFailed, now.Add(-time.Second)),
+	} {
+		if err := store.Save(msg); err != nil {
+			t.Fatalf("Save(%s) error = %v", msg.ID, err)
+		}
+	}
+
+	due, err := store.Due(now, 10)
+	if err != nil {
+		t.Fatalf("Due() error = %v", err)
+	}
+	if len(due) != 1 || due[0].ID != "due" {
+		t.Errorf("Due() = %v, want only message \"due\"", due)
+	}
+}
+
+func TestBoltStore_DueLimit(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+
+	now := time.Now().UTC()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.Save(testMessage(id, StatusAccepted, now)); err != nil {
+			t.Fatalf("Save(%s) error = %v", id, err)
+		}
+	}
+
+	due, err := store.Due(now, 2)
+	if err != nil {
+		t.Fatalf("Due() error = %v", err)
+	}
+	if len(due) != 2 {
+		t.Errorf("Due() returned %d messages, want 2", len(due))
+	}
+}
+
+func TestBoltStore_DueInOrder(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+
+	now := time.Now().UTC()
+	for i, id := range []string{"c", "a", "b"} {
+		if err := store.Save(testMessage(id, StatusAccepted, now.Add(-time.Duration(3-i)*time.Second))); err != nil {
+			t.Fatalf("Save(%s) error = %v", id, err)
+		}
+	}
+	// a postponed message moves to its new place in the index
+	postponed := testMessage("c", StatusAccepted, now.Add(time.Minute))
+	if err := store.Save(postponed); err != nil {
+		t.Fatalf("Save(c) error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		now  time.Time
+		want []string
+	}{
+		{now: now, want: []string{"a", "b"}},
+		{now: now.Add(time.Minute), want: []string{"a", "b", "c"}},
+	} {
+		due, err := store.Due(tt.now, 10)
+		if err != nil {
+			t.Fatalf("Due() error = %v", err)
+		}
+		var ids []string
+		for _, msg := range due {
+			ids = append(ids, msg.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("Due(%v) = %v, want %v", tt.now, ids, tt.want)
+		}
+	}
+}
+
+func TestBoltStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	now := time.Now().UTC()
+
+	store := openTestStore(t, path)
+	if err := store.Save(testMessage("queued", StatusAccepted, now)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save(testMessage("interrupted", StatusSending, now)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	store = openTestStore(t, path)
+	defer func() { _ = store.Close() }()
+
+	recovered, err := store.Recover()
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if recovered != 1 {
+		t.Errorf("Recover() = %d, want 1", recovered)
+	}
+
+	due, err := store.Due(now, 10)
+	if err != nil {
+		t.Fatalf("Due() error = %v", err)
+	}
+	if len(due) != 2 {
+		t.Errorf("Due() after reopen returned %d messages, want 2", len(due))
+	}
+	for _, msg := range due {
+		if msg.Attributes.HtmlContent != "<p>body</p>" {
+			t.Errorf("Due() after reopen returned %s without its content", msg.ID)
+		}
+	}
+}
+
+func TestBoltStore_ContentKeptApart(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer func() { _ = store.Close() }()
+
+	now := time.Now().UTC()
+	msg := testMessage("1", StatusAccepted, now)
+	msg.Attributes.Bcc = []string{"hidden@example.com"}
+	msg.Attributes.Attachments = []mail.Attachment{{Filename: "a.txt", ContentType: "text/plain", Content: "aGVsbG8="}}
+	if err := store.Save(msg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	listed, err := store.List(Filter{})
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("List() = %v, %v, want the message", listed, err)
+	}
+	if a := listed[0].Attributes; a.HtmlContent != "" || len(a.Bcc) != 0 || len(a.Attachments) != 0 || a.Subject != "subject" {
+		t.Errorf("List() attributes = %+v, want the metadata only", a)
+	}
+
+	due, err := store.Due(now, 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("Due() = %v, %v, want the message", due, err)
+	}
+	if a := due[0].Attributes; a.HtmlContent != "<p>body</p>" || len(a.Bcc) != 1 || len(a.Attachments) != 1 {
+		t.Errorf("Due() attributes = %+v, want the full content", a)
+	}
+
+	msg.transition(StatusSent, now, "")
+	if err := store.Save(msg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	err = store.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(contentsBucket).Get([]byte("1")) != nil {
+			t.Error("content of the sent message is still stored")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View() error = %v", err)
+	}
+}
+
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	bolt := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	t.Cleanup(func() { _ = bolt.Close() })
+	return map[string]Store{"bolt": bolt, "memory": NewMemoryStore()}
+}
+
+func TestStore_List(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	older := testMessage("older", StatusSent, now.Add(-time.Hour))
+	newer := testMessage("newer", StatusFailed, now)
+	other := testMessage("other", StatusSent, now.Add(-time.Minute))
+	other.Attributes.To = "b@example.com"
+	other.Attributes.Cc = []string{"C@example.com"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all, newest first", filter: Filter{}, want: []string{"newer", "other", "older"}},
+		{name: "by status", filter: Filter{Status: StatusSent}, want: []string{"other", "older"}},
+		{name: "by to recipient", filter: Filter{Recipient: "a@example.com"}, want: []string{"newer", "older"}},
+		{name: "by cc recipient ignoring case", filter: Filter{Recipient: "c@EXAMPLE.com"}, want: []string{"other"}},
+		{name: "since", filter: Filter{Since: now.Add(-time.Minute)}, want: []string{"newer", "other"}},
+		{name: "until", filter: Filter{Until: now.Add(-time.Minute)}, want: []string{"older"}},
+		{name: "limit", filter: Filter{Limit: 1}, want: []string{"newer"}},
+	}
+
+	for storeName, store := range testStores(t) {
+		for _, msg := range []Message{older, newer, other} {
+			if err := store.Save(msg); err != nil {
+				t.Fatalf("%s: Save(%s) error = %v", storeName, msg.ID, err)
+			}
+		}
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				messages, err := store.List(tt.filter)
+				if err != nil {
+					t.Fatalf("List() error = %v", err)
+				}
+				var got []string
+				for _, msg := range messages {
+					got = append(got, msg.ID)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("List() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	now := time.Now().UTC()
+	for storeName, store := range testStores(t) {
+		t.Run(storeName, func(t *testing.T) {
+			for _, msg := range []Message{
+				testMessage("old-sent", StatusSent, now.Add(-2*time.Hour)),
+				testMessage("old-failed", StatusFailed, now.Add(-2*time.Hour)),
+				testMessage("old-pending", StatusAccepted, now.Add(-2*time.Hour)),
+				testMessage("recent-sent", StatusSent, now),
+			} {
+				if err := store.Save(msg); err != nil {
+					t.Fatalf("Save(%s) error = %v", msg.ID, err)
+				}
+			}
+
+			pruned, err := store.Prune(now.Add(-time.Hour))
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if pruned != 2 {
+				t.Errorf("Prune() = %d, want 2", pruned)
+			}
+			for _, id := range []string{"old-pending", "recent-sent"} {
+				if _, err := store.Get(id); err != nil {
+					t.Errorf("Get(%s) error = %v, want message kept", id, err)
+				}
+			}
+			if _, err := store.Get("old-sent"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(old-sent) error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
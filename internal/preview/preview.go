@@ -0,0 +1,126 @@
+// Package preview finds problems in a rendered mail that do not stop it from
+// being sent but are likely to break it in the recipient's mail client.
+package preview
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Warning codes reported by the render endpoint.
+const (
+	CodeMissingVariable    = "missingVariable"
+	CodeMissingTranslation = "missingTranslation"
+	CodeInvalidMail        = "invalidMail"
+	CodeBrokenLink         = "brokenLink"
+	CodeBodyTooLarge       = "bodyTooLarge"
+)
+
+// MaxHTMLBytes is the size of the HTML part above which Gmail clips a mail
+// and hides the rest behind a "View entire message" link.
+const MaxHTMLBytes = 102 * 1024
+
+// unsafeURL is what html/template writes instead of a URL it considers unsafe.
+const unsafeURL = "ZgotmplZ"
+
+// Warning is a problem found in a mail.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Check returns the problems found in the HTML part of a mail: links that
+// cannot work in a mail client and a body that is too large. Links are
+// checked for their form only, they are not requested.
+func Check(attributes mail.MailAttributes) []Warning {
+	warnings := checkLinks(attributes)
+	if size := len(attributes.HtmlContent); size > MaxHTMLBytes {
+		warnings = append(warnings, Warning{
+			Code:    CodeBodyTooLarge,
+			Message: fmt.Sprintf("the HTML part has %d bytes, Gmail clips mails above %d bytes", size, MaxHTMLBytes),
+		})
+	}
+	return warnings
+}
+
+// linkAttributes are the attributes that hold a link, by element.
+var linkAttributes = map[atom.Atom]string{
+	atom.A:    "href",
+	atom.Area: "href",
+	atom.Img:  "src",
+}
+
+func checkLinks(attributes mail.MailAttributes) []Warning {
+	contentIDs := map[string]bool{}
+	for _, attachment := range attributes.Attachments {
+		if attachment.IsInline() {
+			contentIDs[attachment.ContentID] = true
+		}
+	}
+
+	var warnings []Warning
+	tokenizer := html.NewTokenizer(strings.NewReader(attributes.HtmlContent))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return warnings
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		key, ok := linkAttributes[token.DataAtom]
+		if !ok {
+			continue
+		}
+		for _, attr := range token.Attr {
+			if attr.Key != key {
+				continue
+			}
+			if problem := linkProblem(strings.TrimSpace(attr.Val), contentIDs); problem != "" {
+				warnings = append(warnings, Warning{
+					Code:    CodeBrokenLink,
+					Message: fmt.Sprintf("<%s %s=%q> %s", token.Data, key, attr.Val, problem),
+				})
+			}
+		}
+	}
+}
+
+// linkProblem describes why target cannot work in a mail, or returns "" if it can.
+func linkProblem(target string, contentIDs map[string]bool) string {
+	switch {
+	case target == "" || target == "#":
+		return "has no target"
+	case strings.Contains(target, unsafeURL):
+		return "was replaced because the template data is not a safe URL"
+	case strings.HasPrefix(target, "#"):
+		return ""
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "is not a valid URL"
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "":
+		return "is relative and does not resolve in a mail client"
+	case "http", "https":
+		if parsed.Host == "" {
+			return "has no host"
+		}
+	case "mailto", "tel", "data":
+	case "cid":
+		if !contentIDs[parsed.Opaque] {
+			return "refers to no inline attachment"
+		}
+	default:
+		return fmt.Sprintf("uses the unsupported scheme %q", parsed.Scheme)
+	}
+	return ""
+}
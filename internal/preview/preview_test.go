@@ -0,0 +1,57 @@
+package preview
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+func TestCheck_Links(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "absolute link", html: `<a href="https://example.com/a?b=c">a</a>`},
+		{name: "mailto", html: `<a href="mailto:support@example.com">mail</a>`},
+		{name: "anchor", html: `<a href="#top">top</a>`},
+		{name: "inline image", html: `<img src="cid:logo">`},
+		{name: "empty href", html: `<a href="">a</a>`, want: "has no target"},
+		{name: "placeholder href", html: `<a href="#">a</a>`, want: "has no target"},
+		{name: "relative link", html: `<a href="/account">a</a>`, want: "is relative"},
+		{name: "missing host", html: `<a href="https:///account">a</a>`, want: "has no host"},
+		{name: "unsafe url", html: `<a href="#ZgotmplZ">a</a>`, want: "not a safe URL"},
+		{name: "javascript", html: `<a href="javascript:alert(1)">a</a>`, want: "unsupported scheme"},
+		{name: "unknown inline image", html: `<img src="cid:banner">`, want: "no inline attachment"},
+		{name: "invalid url", html: `<img src="https://exa mple.com/%zz">`, want: "not a valid URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := Check(mail.MailAttributes{
+				HtmlContent: tt.html,
+				Attachments: []mail.Attachment{{Filename: "logo.png", ContentID: "logo"}},
+			})
+			if tt.want == "" {
+				if len(warnings) != 0 {
+					t.Errorf("Check() = %v, want no warnings", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || warnings[0].Code != CodeBrokenLink || !strings.Contains(warnings[0].Message, tt.want) {
+				t.Errorf("Check() = %v, want a broken link that %s", warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck_BodyTooLarge(t *testing.T) {
+	if warnings := Check(mail.MailAttributes{HtmlContent: strings.Repeat("a", MaxHTMLBytes)}); len(warnings) != 0 {
+		t.Errorf("Check() at the limit = %v, want no warnings", warnings)
+	}
+	warnings := Check(mail.MailAttributes{HtmlContent: strings.Repeat("a", MaxHTMLBytes+1)})
+	if len(warnings) != 1 || warnings[0].Code != CodeBodyTooLarge {
+		t.Errorf("Check() above the limit = %v, want bodyTooLarge", warnings)
+	}
+}
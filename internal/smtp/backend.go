@@ -2,6 +2,7 @@ package smtp
 
 import (
 	gosmtp "github.com/emersion/go-smtp"
+	"github.com/jo-hoe/go-mail-service/internal/compose"
 	"github.com/jo-hoe/go-mail-service/internal/config"
 	"github.com/jo-hoe/go-mail-service/internal/mail"
 )
@@ -10,18 +11,22 @@ import (
 // It creates a new session for each incoming connection.
 type SMTPBackend struct {
 	mailService mail.MailService
-	auth        config.SMTPAuthConfig
+	cfg         *config.Config
+	composer    *compose.Composer
 }
 
-// NewSMTPBackend creates an SMTPBackend using the provided mail service and auth config.
-func NewSMTPBackend(svc mail.MailService, auth config.SMTPAuthConfig) *SMTPBackend {
+// NewSMTPBackend creates an SMTPBackend using the provided mail service and config.
+// Sessions read the auth, sender and attachment settings from cfg and render
+// Markdown bodies with composer.
+func NewSMTPBackend(svc mail.MailService, cfg *config.Config, composer *compose.Composer) *SMTPBackend {
 	return &SMTPBackend{
 		mailService: svc,
-		auth:        auth,
+		cfg:         cfg,
+		composer:    composer,
 	}
 }
 
 // NewSession creates a fresh session for an incoming SMTP connection.
 func (b *SMTPBackend) NewSession(_ *gosmtp.Conn) (gosmtp.Session, error) {
-	return newSMTPSession(b.mailService, b.auth), nil
+	return newSMTPSession(b.mailService, b.cfg, b.composer), nil
 }
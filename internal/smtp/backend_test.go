@@ -9,9 +9,9 @@ import (
 
 func TestNewSMTPBackend_CreatesSession(t *testing.T) {
 	svc := noop.NewNoopService()
-	auth := config.SMTPAuthConfig{Required: false}
+	cfg := &config.Config{SMTP: config.SMTPConfig{Auth: config.SMTPAuthConfig{Required: false}}}
 
-	backend := NewSMTPBackend(svc, auth)
+	backend := NewSMTPBackend(svc, cfg, newTestComposer(cfg))
 	session, err := backend.NewSession(nil)
 	if err != nil {
 		t.Fatalf("NewSession() error: %v", err)
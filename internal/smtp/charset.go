@@ -0,0 +1,92 @@
+package smtp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// headerDecoder decodes RFC 2047 encoded words in any charset known to htmlindex.
+var headerDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// addressParser parses address headers whose display names use any charset known to htmlindex.
+var addressParser = &netmail.AddressParser{WordDecoder: headerDecoder}
+
+// decodeHeader decodes RFC 2047 encoded words such as "=?iso-8859-1?Q?Gr=FC=DFe?=".
+// Undecodable input is returned unchanged.
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// decodeTransferEncoding undoes a base64 or quoted-printable Content-Transfer-Encoding.
+// mime/multipart already decodes quoted-printable parts and removes the header,
+// so parts are never decoded twice. 7bit, 8bit and binary are passed through.
+func decodeTransferEncoding(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &lineBreakStripper{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// readText decodes the transfer encoding of a text part and converts its charset to UTF-8.
+func readText(header mimeHeader, body io.Reader, charset string) (string, error) {
+	decoded := decodeTransferEncoding(body, header.Get("Content-Transfer-Encoding"))
+	utf8Reader, err := charsetReader(charset, decoded)
+	if err != nil {
+		// Unknown charsets are passed through rather than rejecting the whole message.
+		utf8Reader = decoded
+	}
+	return readAll(utf8Reader)
+}
+
+// charsetReader converts input in the given charset to UTF-8.
+// An empty charset is treated as US-ASCII, which is a subset of UTF-8.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	}
+
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q: %w", charset, err)
+	}
+	return encoding.NewDecoder().Reader(input), nil
+}
+
+// lineBreakStripper drops CR, LF and other whitespace, which base64 bodies are wrapped with.
+type lineBreakStripper struct {
+	r io.Reader
+}
+
+func (s *lineBreakStripper) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		kept := bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, p[:n])
+		copy(p, kept)
+		if len(kept) > 0 || err != nil {
+			return len(kept), err
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package smtp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "Hello", want: "Hello"},
+		{name: "utf-8 base64", value: "=?UTF-8?B?UsOpc3Vtw6k=?=", want: "Résumé"},
+		{name: "iso-8859-1 quoted-printable", value: "=?iso-8859-1?Q?Gr=FC=DFe?=", want: "Grüße"},
+		{name: "windows-1252", value: "=?windows-1252?Q?=80_price?=", want: "€ price"},
+		{name: "mixed words", value: "Re: =?utf-8?Q?caf=C3=A9?= meeting", want: "Re: café meeting"},
+		{name: "unknown charset kept", value: "=?x-unknown?Q?abc?=", want: "=?x-unknown?Q?abc?="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeHeader(tt.value); got != tt.want {
+				t.Errorf("decodeHeader(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMessage_QuotedPrintableBody(t *testing.T) {
+	raw := "Subject: QP\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"<p style=3D\"color:red\">caf=C3=A9 and a long line that was soft=\r\n" +
+		" wrapped</p>"
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	want := `<p style="color:red">café and a long line that was soft wrapped</p>`
+	if msg.body != want {
+		t.Errorf("body = %q, want %q", msg.body, want)
+	}
+}
+
+func TestParseMessage_Base64Body(t *testing.T) {
+	raw := "Subject: B64\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		"PHA+SGVs\r\nbG88L3A+\r\n"
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if msg.body != "<p>Hello</p>" {
+		t.Errorf("body = %q, want %q", msg.body, "<p>Hello</p>")
+	}
+}
+
+func TestParseMessage_Latin1MultipartPart(t *testing.T) {
+	raw := "Subject: Latin1\r\nContent-Type: multipart/alternative; boundary=\"b\"\r\n\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain; charset=ISO-8859-1\r\n" +
+		"Content-Transfer-Encoding: 8bit\r\n\r\n" +
+		"Gr\xfc\xdfe\r\n" +
+		"--b--\r\n"
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if msg.body != "<pre>Grüße</pre>" {
+		t.Errorf("body = %q, want %q", msg.body, "<pre>Grüße</pre>")
+	}
+}
+
+func TestParseMessage_UnknownCharsetPassesThrough(t *testing.T) {
+	raw := "Subject: X\r\nContent-Type: text/html; charset=x-made-up\r\n\r\n<p>ok</p>"
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if msg.body != "<p>ok</p>" {
+		t.Errorf("body = %q, want %q", msg.body, "<p>ok</p>")
+	}
+}
+
+func TestParseMessage_ClientFixtures(t *testing.T) {
+	tests := []struct {
+		fixture        string
+		subject        string
+		bodyContains   []string
+		attachmentName string
+		contentID      string
+	}{
+		{
+			fixture:        "outlook.eml",
+			subject:        "Grüße aus München",
+			bodyContains:   []string{"<p>anbei die Übersicht für nächste Woche.</p>", "Grüße<br>Jürgen"},
+			attachmentName: "Übersicht.pdf",
+		},
+		{
+			fixture:        "thunderbird.eml",
+			subject:        "Résumé de la réunion",
+			bodyContains:   []string{"<pre>Bonjour à tous,", "Renée"},
+			attachmentName: "résumé.pdf",
+		},
+		{
+			fixture:        "applemail.eml",
+			subject:        "Weekly update 📈",
+			bodyContains:   []string{"<div>Hello team 👋</div>", `<img src="cid:8A1F2C3D-4E5F-4A6B-9C7D-112233445566"`, "Zoë"},
+			attachmentName: "chart.png",
+			contentID:      "8A1F2C3D-4E5F-4A6B-9C7D-112233445566",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatalf("opening fixture: %v", err)
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+
+			msg, err := parseMessage(f)
+			if err != nil {
+				t.Fatalf("parseMessage() error: %v", err)
+			}
+			if msg.subject != tt.subject {
+				t.Errorf("subject = %q, want %q", msg.subject, tt.subject)
+			}
+			for _, want := range tt.bodyContains {
+				if !strings.Contains(msg.body, want) {
+					t.Errorf("body = %q, expected to contain %q", msg.body, want)
+				}
+			}
+			if len(msg.attachments) != 1 {
+				t.Fatalf("attachments = %d, want 1", len(msg.attachments))
+			}
+			if msg.attachments[0].Filename != tt.attachmentName {
+				t.Errorf("attachment filename = %q, want %q", msg.attachments[0].Filename, tt.attachmentName)
+			}
+			if msg.attachments[0].ContentID != tt.contentID {
+				t.Errorf("attachment content id = %q, want %q", msg.attachments[0].ContentID, tt.contentID)
+			}
+			if _, err := msg.attachments[0].Size(); err != nil {
+				t.Errorf("attachment content is not valid base64: %v", err)
+			}
+		})
+	}
+}
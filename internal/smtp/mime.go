@@ -1,102 +1,234 @@
 package smtp
 
 import (
+	"encoding/base64"
+	"fmt"
+	"html"
 	"io"
 	"mime"
 	"mime/multipart"
 	netmail "net/mail"
 	"strings"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
 )
 
-// parsedMessage holds the extracted subject and HTML body from a raw SMTP DATA payload.
+// parsedMessage holds the extracted headers, HTML body and attachments from a raw SMTP DATA payload.
+// addressed reports whether the message carries a To or Cc header, even one without addresses
+// such as "To: undisclosed-recipients:;".
 type parsedMessage struct {
-	subject string
-	body    string
+	subject     string
+	body        string
+	text        string
+	contentType string
+	to          []string
+	cc          []string
+	addressed   bool
+	replyTo     string
+	from        string
+	fromName    string
+	attachments []mail.Attachment
+}
+
+// mimeHeader is implemented by both the top-level message header and multipart part headers.
+type mimeHeader interface {
+	Get(key string) string
 }
 
-// parseMessage reads the raw message, extracts the Subject header and HTML body.
+// mimeParts collects the bodies and attachments found while walking the MIME tree.
+type mimeParts struct {
+	html        string
+	plain       string
+	markdown    string
+	hasHTML     bool
+	hasPlain    bool
+	hasMarkdown bool
+	attachments []mail.Attachment
+}
+
+// parseMessage reads the raw message, extracts the addressing headers, Subject, HTML body and attachments.
 func parseMessage(r io.Reader) (parsedMessage, error) {
 	msg, err := netmail.ReadMessage(r)
 	if err != nil {
 		return parsedMessage{}, err
 	}
 
-	subject := msg.Header.Get("Subject")
-	body, err := extractBody(msg)
-	if err != nil {
+	parts := &mimeParts{}
+	if err := parts.walk(msg.Header, msg.Body); err != nil {
 		return parsedMessage{}, err
 	}
 
-	return parsedMessage{subject: subject, body: body}, nil
+	parsed := parsedMessage{
+		subject:     decodeHeader(msg.Header.Get("Subject")),
+		body:        parts.body(),
+		text:        parts.plain,
+		contentType: parts.contentType(),
+		to:          headerAddresses(msg.Header, "To"),
+		cc:          headerAddresses(msg.Header, "Cc"),
+		addressed:   msg.Header.Get("To") != "" || msg.Header.Get("Cc") != "",
+		attachments: parts.attachments,
+	}
+	if replyTo := headerAddresses(msg.Header, "Reply-To"); len(replyTo) > 0 {
+		parsed.replyTo = replyTo[0]
+	}
+	if from, err := addressParser.Parse(msg.Header.Get("From")); err == nil {
+		parsed.from, parsed.fromName = from.Address, from.Name
+	}
+
+	return parsed, nil
 }
 
-// extractBody returns the HTML body of the message if present,
-// otherwise returns the plain text body wrapped in <pre> tags.
-func extractBody(msg *netmail.Message) (string, error) {
-	contentType := msg.Header.Get("Content-Type")
-	if contentType == "" {
-		return readPlain(msg.Body)
+// headerAddresses returns the bare addresses listed in the given header.
+// Malformed headers are treated as empty rather than failing the whole message.
+func headerAddresses(header netmail.Header, key string) []string {
+	if header.Get(key) == "" {
+		return nil
+	}
+	list, err := addressParser.ParseList(header.Get(key))
+	if err != nil {
+		return nil
 	}
+	addresses := make([]string, 0, len(list))
+	for _, address := range list {
+		addresses = append(addresses, address.Address)
+	}
+	return addresses
+}
 
-	mediaType, params, err := mime.ParseMediaType(contentType)
+// body returns the HTML body of the message if present, otherwise the
+// Markdown body, which is rendered to HTML later, or the escaped plain text
+// body wrapped in <pre> tags. The plain text part is always kept separately
+// as the text alternative.
+func (p *mimeParts) body() string {
+	if p.hasHTML {
+		return p.html
+	}
+	if p.hasMarkdown {
+		return p.markdown
+	}
+	if p.hasPlain {
+		return "<pre>" + html.EscapeString(p.plain) + "</pre>"
+	}
+	return ""
+}
+
+// contentType returns the content type of the body, see body.
+func (p *mimeParts) contentType() string {
+	if !p.hasHTML && p.hasMarkdown {
+		return mail.ContentTypeMarkdown
+	}
+	return ""
+}
+
+// walk descends into nested multipart entities. The first text/html, text/markdown
+// and text/plain parts that are not marked as attachments become the body, every
+// other leaf part is kept as an attachment. Parts with a Content-ID are kept as inline attachments
+// unless they are explicitly marked as attachments.
+func (p *mimeParts) walk(header mimeHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
 	if err != nil {
-		return readPlain(msg.Body)
+		mediaType = "text/plain"
 	}
 
 	if strings.HasPrefix(mediaType, "multipart/") {
-		return extractMultipart(msg.Body, params["boundary"])
+		return p.walkMultipart(body, params["boundary"])
 	}
 
-	if mediaType == "text/html" {
-		return readAll(msg.Body)
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	isAttachment := disposition == "attachment"
+
+	switch {
+	case mediaType == "text/html" && !isAttachment && !p.hasHTML:
+		htmlBody, err := readText(header, body, params["charset"])
+		if err != nil {
+			return err
+		}
+		p.html, p.hasHTML = htmlBody, true
+		return nil
+	case mediaType == "text/plain" && !isAttachment && !p.hasPlain:
+		plain, err := readText(header, body, params["charset"])
+		if err != nil {
+			return err
+		}
+		p.plain, p.hasPlain = plain, true
+		return nil
+	case (mediaType == "text/markdown" || mediaType == "text/x-markdown") && !isAttachment && !p.hasMarkdown:
+		markdown, err := readText(header, body, params["charset"])
+		if err != nil {
+			return err
+		}
+		p.markdown, p.hasMarkdown = markdown, true
+		return nil
 	}
 
-	return readPlain(msg.Body)
+	attachment, err := readAttachment(header, body, mediaType, params, dispositionParams, isAttachment, len(p.attachments)+1)
+	if err != nil {
+		return err
+	}
+	p.attachments = append(p.attachments, attachment)
+	return nil
 }
 
-func extractMultipart(r io.Reader, boundary string) (string, error) {
-	mr := multipart.NewReader(r, boundary)
-	var plain string
+func (p *mimeParts) walkMultipart(r io.Reader, boundary string) error {
+	if boundary == "" {
+		return fmt.Errorf("multipart entity without boundary")
+	}
 
+	mr := multipart.NewReader(r, boundary)
 	for {
 		part, err := mr.NextPart()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		ct := part.Header.Get("Content-Type")
-		mediaType, _, parseErr := mime.ParseMediaType(ct)
-		if parseErr != nil {
-			continue
+		if err := p.walk(part.Header, part); err != nil {
+			return err
 		}
+	}
+}
 
-		if mediaType == "text/html" {
-			return readAll(part)
-		}
-		if mediaType == "text/plain" && plain == "" {
-			var readErr error
-			plain, readErr = readAll(part)
-			if readErr != nil {
-				return "", readErr
-			}
-		}
+// readAttachment reads a leaf part into a base64 encoded attachment.
+// The Content-ID is dropped for parts with an attachment disposition, since clients
+// like Outlook add one to ordinary attachments and they must not be sent as inline.
+func readAttachment(header mimeHeader, body io.Reader, mediaType string, params, dispositionParams map[string]string, isAttachment bool, index int) (mail.Attachment, error) {
+	data, err := io.ReadAll(decodeTransferEncoding(body, header.Get("Content-Transfer-Encoding")))
+	if err != nil {
+		return mail.Attachment{}, fmt.Errorf("reading attachment: %w", err)
 	}
 
-	if plain != "" {
-		return "<pre>" + plain + "</pre>", nil
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	filename = decodeHeader(filename)
+	if filename == "" {
+		filename = defaultFilename(mediaType, index)
 	}
-	return "", nil
+
+	attachment := mail.Attachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		Content:     base64.StdEncoding.EncodeToString(data),
+	}
+	if !isAttachment {
+		attachment.ContentID = strings.Trim(strings.TrimSpace(header.Get("Content-ID")), "<>")
+	}
+	return attachment, nil
 }
 
-func readPlain(r io.Reader) (string, error) {
-	text, err := readAll(r)
-	if err != nil {
-		return "", err
+// defaultFilename names attachments that carry neither a filename nor a name parameter.
+func defaultFilename(mediaType string, index int) string {
+	name := fmt.Sprintf("attachment-%d", index)
+	if mediaType == "message/rfc822" {
+		return name + ".eml"
+	}
+	if extensions, err := mime.ExtensionsByType(mediaType); err == nil && len(extensions) > 0 {
+		return name + extensions[0]
 	}
-	return "<pre>" + text + "</pre>", nil
+	return name
 }
 
 func readAll(r io.Reader) (string, error) {
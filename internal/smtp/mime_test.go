@@ -3,6 +3,8 @@ package smtp
 import (
 	"strings"
 	"testing"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
 )
 
 func TestParseMessage_PlainText(t *testing.T) {
@@ -53,6 +55,45 @@ func TestParseMessage_MultipartPrefersHTML(t *testing.T) {
 	if msg.body != "<p>HTML</p>" {
 		t.Errorf("body = %q, expected HTML part", msg.body)
 	}
+	if msg.text != "Plain text" {
+		t.Errorf("text = %q, expected plain part to be kept", msg.text)
+	}
+}
+
+func TestParseMessage_Markdown(t *testing.T) {
+	raw := "Subject: Deploy\r\nContent-Type: multipart/alternative; boundary=\"b\"\r\n\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n\r\n" +
+		"Deploy done\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/markdown; charset=utf-8\r\n\r\n" +
+		"# Deploy *done*\r\n" +
+		"--b--\r\n"
+
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if msg.body != "# Deploy *done*" || msg.contentType != mail.ContentTypeMarkdown {
+		t.Errorf("body = %q with content type %q, want the markdown source", msg.body, msg.contentType)
+	}
+	if msg.text != "Deploy done" {
+		t.Errorf("text = %q, expected plain part to be kept", msg.text)
+	}
+}
+
+func TestParseMessage_PlainTextIsEscaped(t *testing.T) {
+	raw := "Subject: Escape\r\nContent-Type: text/plain\r\n\r\nif a < b && c > d"
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if msg.body != "<pre>if a &lt; b &amp;&amp; c &gt; d</pre>" {
+		t.Errorf("body = %q, expected escaped plain text in <pre>", msg.body)
+	}
+	if msg.text != "if a < b && c > d" {
+		t.Errorf("text = %q, expected unescaped plain text", msg.text)
+	}
 }
 
 func TestParseMessage_MultipartPlainFallback(t *testing.T) {
@@ -84,3 +125,149 @@ func TestParseMessage_NoContentType(t *testing.T) {
 		t.Errorf("body = %q, expected to contain raw text", msg.body)
 	}
 }
+
+func TestParseMessage_AddressHeaders(t *testing.T) {
+	raw := "To: A <a@example.com>, b@example.com\r\n" +
+		"Cc: \"Manager, Team\" <manager@example.com>\r\n" +
+		"Reply-To: support@example.com\r\n" +
+		"Subject: Headers\r\n\r\nBody"
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if len(msg.to) != 2 || msg.to[0] != "a@example.com" || msg.to[1] != "b@example.com" {
+		t.Errorf("to = %v, want [a@example.com b@example.com]", msg.to)
+	}
+	if len(msg.cc) != 1 || msg.cc[0] != "manager@example.com" {
+		t.Errorf("cc = %v, want [manager@example.com]", msg.cc)
+	}
+	if msg.replyTo != "support@example.com" {
+		t.Errorf("replyTo = %q, want %q", msg.replyTo, "support@example.com")
+	}
+}
+
+func TestParseMessage_NestedMixedRelatedAlternative(t *testing.T) {
+	raw := "Subject: Report\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"mixed\"\r\n\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: multipart/related; boundary=\"related\"\r\n\r\n" +
+		"--related\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"alt\"\r\n\r\n" +
+		"--alt\r\n" +
+		"Content-Type: text/plain\r\n\r\n" +
+		"Plain report\r\n" +
+		"--alt\r\n" +
+		"Content-Type: text/html\r\n\r\n" +
+		"<p>Report</p><img src=\"cid:chart@example\">\r\n" +
+		"--alt--\r\n" +
+		"--related\r\n" +
+		"Content-Type: image/png; name=\"chart.png\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-ID: <chart@example>\r\n" +
+		"Content-Disposition: inline\r\n\r\n" +
+		"iVBORw0K\r\nGgo=\r\n" +
+		"--related--\r\n" +
+		"--mixed\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Disposition: attachment; filename=\"report.pdf\"\r\n\r\n" +
+		"JVBERi0xLjQK\r\n" +
+		"--mixed--\r\n"
+
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if msg.body != "<p>Report</p><img src=\"cid:chart@example\">" {
+		t.Errorf("body = %q, expected HTML part", msg.body)
+	}
+	if len(msg.attachments) != 2 {
+		t.Fatalf("attachments = %d, want 2", len(msg.attachments))
+	}
+
+	inline := msg.attachments[0]
+	if inline.Filename != "chart.png" || inline.ContentType != "image/png" || inline.ContentID != "chart@example" {
+		t.Errorf("inline attachment = %+v, want chart.png image/png with content id chart@example", inline)
+	}
+	if inline.Content != "iVBORw0KGgo=" {
+		t.Errorf("inline content = %q, want re-encoded base64 %q", inline.Content, "iVBORw0KGgo=")
+	}
+
+	pdf := msg.attachments[1]
+	if pdf.Filename != "report.pdf" || pdf.ContentType != "application/pdf" || pdf.IsInline() {
+		t.Errorf("attachment = %+v, want regular report.pdf application/pdf", pdf)
+	}
+	if pdf.Content != "JVBERi0xLjQK" {
+		t.Errorf("attachment content = %q, want %q", pdf.Content, "JVBERi0xLjQK")
+	}
+}
+
+func TestParseMessage_TextAttachmentIsNotBody(t *testing.T) {
+	raw := "Subject: Log\r\nContent-Type: multipart/mixed; boundary=\"b\"\r\n\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n\r\n" +
+		"See the log\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Disposition: attachment; filename=\"app.log\"\r\n\r\n" +
+		"line 1\r\n" +
+		"--b--\r\n"
+
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if msg.body != "<pre>See the log</pre>" {
+		t.Errorf("body = %q, want %q", msg.body, "<pre>See the log</pre>")
+	}
+	if len(msg.attachments) != 1 || msg.attachments[0].Filename != "app.log" {
+		t.Fatalf("attachments = %+v, want app.log", msg.attachments)
+	}
+	if msg.attachments[0].Content != "bGluZSAx" {
+		t.Errorf("attachment content = %q, want base64 of %q", msg.attachments[0].Content, "line 1")
+	}
+}
+
+func TestParseMessage_AttachmentWithContentIDIsNotInline(t *testing.T) {
+	raw := "Subject: Invoice\r\nContent-Type: multipart/mixed; boundary=\"b\"\r\n\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/html\r\n\r\n" +
+		"<p>Invoice attached</p>\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-ID: <invoice@example>\r\n" +
+		"Content-Disposition: attachment; filename=\"invoice.pdf\"\r\n\r\n" +
+		"JVBERi0xLjQK\r\n" +
+		"--b--\r\n"
+
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if len(msg.attachments) != 1 || msg.attachments[0].Filename != "invoice.pdf" {
+		t.Fatalf("attachments = %+v, want invoice.pdf", msg.attachments)
+	}
+	if msg.attachments[0].IsInline() {
+		t.Errorf("attachment = %+v, want regular attachment without content id", msg.attachments[0])
+	}
+}
+
+func TestParseMessage_AttachmentWithoutFilename(t *testing.T) {
+	raw := "Subject: Fwd\r\nContent-Type: multipart/mixed; boundary=\"b\"\r\n\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/html\r\n\r\n" +
+		"<p>Forwarded</p>\r\n" +
+		"--b\r\n" +
+		"Content-Type: message/rfc822\r\n\r\n" +
+		"Subject: Original\r\n\r\nOriginal body\r\n" +
+		"--b--\r\n"
+
+	msg, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage() error: %v", err)
+	}
+	if len(msg.attachments) != 1 || msg.attachments[0].Filename != "attachment-1.eml" {
+		t.Fatalf("attachments = %+v, want attachment-1.eml", msg.attachments)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 
 	gosmtp "github.com/emersion/go-smtp"
+	"github.com/jo-hoe/go-mail-service/internal/compose"
 	"github.com/jo-hoe/go-mail-service/internal/config"
 	"github.com/jo-hoe/go-mail-service/internal/mail"
 )
@@ -18,18 +19,19 @@ type SMTPServer struct {
 	server *gosmtp.Server
 }
 
-// NewSMTPServer creates an SMTPServer configured from cfg, using svc for mail dispatch.
-func NewSMTPServer(cfg *config.SMTPConfig, svc mail.MailService) (*SMTPServer, error) {
-	backend := NewSMTPBackend(svc, cfg.Auth)
+// NewSMTPServer creates an SMTPServer configured from cfg, using svc for mail
+// dispatch and composer to render the content of received mails.
+func NewSMTPServer(cfg *config.Config, svc mail.MailService, composer *compose.Composer) (*SMTPServer, error) {
+	backend := NewSMTPBackend(svc, cfg, composer)
 
 	s := gosmtp.NewServer(backend)
-	s.Domain = cfg.Domain
-	s.Addr = fmt.Sprintf(":%d", cfg.Port)
+	s.Domain = cfg.SMTP.Domain
+	s.Addr = fmt.Sprintf(":%d", cfg.SMTP.Port)
 	s.MaxMessageBytes = maxMessageBytes
-	s.AllowInsecureAuth = !cfg.Auth.Required
+	s.AllowInsecureAuth = !cfg.SMTP.Auth.Required
 
-	if cfg.TLS.Enabled {
-		tlsCfg, err := loadTLS(cfg.TLS)
+	if cfg.SMTP.TLS.Enabled {
+		tlsCfg, err := loadTLS(cfg.SMTP.TLS)
 		if err != nil {
 			return nil, fmt.Errorf("smtp: loading TLS config: %w", err)
 		}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"slices"
 	"strings"
 
 	gosmtp "github.com/emersion/go-smtp"
+	"github.com/jo-hoe/go-mail-service/internal/compose"
 	"github.com/jo-hoe/go-mail-service/internal/config"
 	"github.com/jo-hoe/go-mail-service/internal/mail"
 )
@@ -15,28 +17,39 @@ import (
 // SMTPSession holds per-connection envelope state for one SMTP transaction.
 type SMTPSession struct {
 	mailService mail.MailService
-	auth        config.SMTPAuthConfig
+	cfg         *config.Config
+	composer    *compose.Composer
 	from        string
 	recipients  []string
 }
 
-func newSMTPSession(svc mail.MailService, auth config.SMTPAuthConfig) *SMTPSession {
+func newSMTPSession(svc mail.MailService, cfg *config.Config, composer *compose.Composer) *SMTPSession {
 	return &SMTPSession{
 		mailService: svc,
-		auth:        auth,
+		cfg:         cfg,
+		composer:    composer,
 	}
 }
 
 // AuthPlain validates AUTH PLAIN/LOGIN credentials.
 func (s *SMTPSession) AuthPlain(username, password string) error {
-	if username != s.auth.Username || password != s.auth.Password {
+	if username != s.cfg.SMTP.Auth.Username || password != s.cfg.SMTP.Auth.Password {
 		return errors.New("invalid credentials")
 	}
 	return nil
 }
 
-// Mail records the envelope sender.
+// Mail records the envelope sender. Senders outside the configured allowlist are rejected;
+// a null reverse-path falls back to the default sender.
 func (s *SMTPSession) Mail(from string, _ *gosmtp.MailOptions) error {
+	if from != "" && !s.cfg.Sender.IsAllowed(from) {
+		slog.Error("smtp: rejected sender address", "from", from)
+		return &gosmtp.SMTPError{
+			Code:         550,
+			EnhancedCode: gosmtp.EnhancedCode{5, 7, 1},
+			Message:      "sender address not allowed",
+		}
+	}
 	s.from = from
 	return nil
 }
@@ -59,15 +72,47 @@ func (s *SMTPSession) Data(r io.Reader) error {
 		return err
 	}
 
+	if err := mail.ValidateAttachmentSize(parsed.attachments, s.cfg.Attachments.MaxTotalBytes); err != nil {
+		slog.Error("smtp: rejecting message", "error", err)
+		return &gosmtp.SMTPError{
+			Code:         552,
+			EnhancedCode: gosmtp.EnhancedCode{5, 3, 4},
+			Message:      err.Error(),
+		}
+	}
+
+	to, cc, bcc := splitRecipients(s.recipients, parsed, s.cfg.Sender.Address)
 	attrs := mail.MailAttributes{
-		To:          strings.Join(s.recipients, ","),
+		To:          strings.Join(to, ","),
+		Cc:          cc,
+		Bcc:         bcc,
+		ReplyTo:     parsed.replyTo,
 		Subject:     parsed.subject,
 		HtmlContent: parsed.body,
+		TextContent: parsed.text,
+		ContentType: parsed.contentType,
+		Attachments: parsed.attachments,
 		From:        s.from,
+		Client:      config.ClientSMTP,
+	}
+	if s.from != "" && strings.EqualFold(parsed.from, s.from) {
+		attrs.FromName = parsed.fromName
+	}
+	if err := s.composer.Compose(&attrs); err != nil {
+		slog.Error("smtp: failed to compose message", "error", err)
+		return err
 	}
 
 	if err := s.mailService.SendMail(context.Background(), attrs); err != nil {
 		slog.Error("smtp: mail service failed", "error", err)
+		if errors.Is(err, mail.ErrRateLimited) {
+			// ask the sending server to try again later
+			return &gosmtp.SMTPError{
+				Code:         451,
+				EnhancedCode: gosmtp.EnhancedCode{4, 7, 0},
+				Message:      err.Error(),
+			}
+		}
 		return err
 	}
 
@@ -75,6 +120,55 @@ func (s *SMTPSession) Data(r io.Reader) error {
 	return nil
 }
 
+// splitRecipients sorts the envelope recipients into To, Cc and Bcc using the message headers.
+// Recipients listed in the Cc header become Cc, recipients missing from both the To and Cc
+// headers were blind-copied. Each address is used once, as providers such as SendGrid reject
+// duplicates. Messages without To and Cc headers carry nothing to sort by, so all envelope
+// recipients become To. Providers need at least one To recipient: if none of the envelope
+// recipients appear in the To header, the Cc recipients are moved to To, and without those
+// the configured default sender becomes the To recipient. Bcc recipients are never moved,
+// they would see each other.
+func splitRecipients(envelope []string, parsed parsedMessage, defaultSender string) (to, cc, bcc []string) {
+	inTo := addressSet(parsed.to)
+	inCc := addressSet(parsed.cc)
+	seen := make(map[string]bool, len(envelope))
+
+	for _, recipient := range envelope {
+		key := strings.ToLower(recipient)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		switch {
+		case inTo[key], !parsed.addressed:
+			to = append(to, recipient)
+		case inCc[key]:
+			cc = append(cc, recipient)
+		default:
+			bcc = append(bcc, recipient)
+		}
+	}
+
+	switch {
+	case len(to) > 0:
+	case len(cc) > 0:
+		to, cc = cc, nil
+	default:
+		// e.g. "To: undisclosed-recipients:;" with every recipient blind-copied
+		to = []string{defaultSender}
+		bcc = slices.DeleteFunc(bcc, func(recipient string) bool { return strings.EqualFold(recipient, defaultSender) })
+	}
+	return to, cc, bcc
+}
+
+func addressSet(addresses []string) map[string]bool {
+	set := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		set[strings.ToLower(address)] = true
+	}
+	return set
+}
+
 // Reset clears envelope state. Auth state lives on the connection (managed by go-smtp).
 func (s *SMTPSession) Reset() {
 	s.from = ""
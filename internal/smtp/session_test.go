@@ -2,10 +2,13 @@ package smtp
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"strings"
 	"testing"
 
 	gosmtp "github.com/emersion/go-smtp"
+	"github.com/jo-hoe/go-mail-service/internal/compose"
 	"github.com/jo-hoe/go-mail-service/internal/config"
 	"github.com/jo-hoe/go-mail-service/internal/mail"
 )
@@ -22,12 +25,34 @@ func (c *captureService) SendMail(_ context.Context, attrs mail.MailAttributes)
 
 func newTestSession(authRequired bool, username, password string) (*SMTPSession, *captureService) {
 	svc := &captureService{}
-	auth := config.SMTPAuthConfig{
-		Required: authRequired,
-		Username: username,
-		Password: password,
+	cfg := testConfig(authRequired, username, password)
+	return newSMTPSession(svc, cfg, newTestComposer(cfg)), svc
+}
+
+// newTestComposer creates a composer with the built-in layout, which always parses.
+func newTestComposer(cfg *config.Config) *compose.Composer {
+	composer, err := compose.NewComposer(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return composer
+}
+
+func testConfig(authRequired bool, username, password string) *config.Config {
+	return &config.Config{
+		Sender: config.SenderConfig{
+			Address:        "noreply@example.com",
+			AllowedDomains: []string{"example.com"},
+		},
+		SMTP: config.SMTPConfig{
+			Auth: config.SMTPAuthConfig{
+				Required: authRequired,
+				Username: username,
+				Password: password,
+			},
+		},
+		Attachments: config.AttachmentsConfig{MaxTotalBytes: config.DefaultMaxAttachmentBytes},
 	}
-	return newSMTPSession(svc, auth), svc
 }
 
 func TestSMTPSession_AuthPlain_Valid(t *testing.T) {
@@ -81,7 +106,7 @@ func TestSMTPSession_Data_DispatchesMail(t *testing.T) {
 	_ = s.Mail("sender@example.com", &gosmtp.MailOptions{})
 	_ = s.Rcpt("to@example.com", &gosmtp.RcptOptions{})
 
-	raw := "Subject: Hello\r\nContent-Type: text/plain\r\n\r\nTest body"
+	raw := "To: to@example.com\r\nSubject: Hello\r\nContent-Type: text/plain\r\n\r\nTest body"
 	if err := s.Data(strings.NewReader(raw)); err != nil {
 		t.Fatalf("Data() error: %v", err)
 	}
@@ -95,6 +120,12 @@ func TestSMTPSession_Data_DispatchesMail(t *testing.T) {
 	if svc.last.From != "sender@example.com" {
 		t.Errorf("from = %q, want %q", svc.last.From, "sender@example.com")
 	}
+	if svc.last.TextContent != "Test body" {
+		t.Errorf("textContent = %q, want %q", svc.last.TextContent, "Test body")
+	}
+	if svc.last.Client != config.ClientSMTP {
+		t.Errorf("client = %q, want %q", svc.last.Client, config.ClientSMTP)
+	}
 }
 
 func TestSMTPSession_Data_MultipleRecipients(t *testing.T) {
@@ -113,6 +144,63 @@ func TestSMTPSession_Data_MultipleRecipients(t *testing.T) {
 	}
 }
 
+func TestSMTPSession_Data_UndisclosedRecipients(t *testing.T) {
+	s, svc := newTestSession(false, "", "")
+	_ = s.Mail("sender@example.com", &gosmtp.MailOptions{})
+	for _, rcpt := range []string{"a@example.com", "b@example.com", "A@example.com", "sender@example.com"} {
+		_ = s.Rcpt(rcpt, &gosmtp.RcptOptions{})
+	}
+
+	raw := "To: undisclosed-recipients:;\r\nSubject: News\r\nContent-Type: text/plain\r\n\r\nBody"
+	if err := s.Data(strings.NewReader(raw)); err != nil {
+		t.Fatalf("Data() error: %v", err)
+	}
+
+	// the envelope sender must not receive an extra copy
+	if svc.last.To != "noreply@example.com" || len(svc.last.Cc) != 0 {
+		t.Errorf("to = %q, cc = %v, want only the default sender visible", svc.last.To, svc.last.Cc)
+	}
+	if !slices.Equal(svc.last.Bcc, []string{"a@example.com", "b@example.com", "sender@example.com"}) {
+		t.Errorf("bcc = %v, want each envelope recipient once", svc.last.Bcc)
+	}
+}
+
+func TestSMTPSession_Data_DeduplicatesRecipients(t *testing.T) {
+	s, svc := newTestSession(false, "", "")
+	for _, rcpt := range []string{"to@example.com", "cc@example.com", "TO@example.com", "cc@example.com"} {
+		_ = s.Rcpt(rcpt, &gosmtp.RcptOptions{})
+	}
+
+	raw := "To: to@example.com\r\nCc: cc@example.com\r\nSubject: Dup\r\nContent-Type: text/plain\r\n\r\nBody"
+	if err := s.Data(strings.NewReader(raw)); err != nil {
+		t.Fatalf("Data() error: %v", err)
+	}
+
+	if svc.last.To != "to@example.com" || !slices.Equal(svc.last.Cc, []string{"cc@example.com"}) || len(svc.last.Bcc) != 0 {
+		t.Errorf("to = %q, cc = %v, bcc = %v, want each address once", svc.last.To, svc.last.Cc, svc.last.Bcc)
+	}
+}
+
+// rateLimitedService fails like a provider whose quota is used up.
+type rateLimitedService struct{}
+
+func (rateLimitedService) SendMail(_ context.Context, _ mail.MailAttributes) error {
+	return &mail.ProviderError{Provider: "sendgrid", Transient: true, Err: mail.ErrRateLimited}
+}
+
+func TestSMTPSession_Data_RateLimitedIsTemporary(t *testing.T) {
+	cfg := testConfig(false, "", "")
+	s := newSMTPSession(rateLimitedService{}, cfg, newTestComposer(cfg))
+	_ = s.Mail("sender@example.com", &gosmtp.MailOptions{})
+	_ = s.Rcpt("to@example.com", &gosmtp.RcptOptions{})
+
+	err := s.Data(strings.NewReader("Subject: X\r\nContent-Type: text/plain\r\n\r\nBody"))
+	var smtpErr *gosmtp.SMTPError
+	if !errors.As(err, &smtpErr) || smtpErr.Code != 451 {
+		t.Errorf("Data() error = %v, want a 451 reply", err)
+	}
+}
+
 func TestSMTPSession_Data_NoRecipients(t *testing.T) {
 	s, _ := newTestSession(false, "", "")
 	raw := "Subject: X\r\n\r\nBody"
@@ -127,3 +215,170 @@ func TestSMTPSession_Logout(t *testing.T) {
 		t.Errorf("Logout() error: %v", err)
 	}
 }
+
+func TestSMTPSession_Data_SplitsCcAndBcc(t *testing.T) {
+	s, svc := newTestSession(false, "", "")
+	_ = s.Mail("sender@example.com", &gosmtp.MailOptions{})
+	_ = s.Rcpt("to@example.com", &gosmtp.RcptOptions{})
+	_ = s.Rcpt("Manager@example.com", &gosmtp.RcptOptions{})
+	_ = s.Rcpt("hidden@example.com", &gosmtp.RcptOptions{})
+
+	raw := "To: To <to@example.com>\r\n" +
+		"Cc: manager@example.com\r\n" +
+		"Reply-To: Support <support@example.com>\r\n" +
+		"Subject: Split\r\nContent-Type: text/plain\r\n\r\nBody"
+	if err := s.Data(strings.NewReader(raw)); err != nil {
+		t.Fatalf("Data() error: %v", err)
+	}
+
+	if svc.last.To != "to@example.com" {
+		t.Errorf("to = %q, want %q", svc.last.To, "to@example.com")
+	}
+	if len(svc.last.Cc) != 1 || svc.last.Cc[0] != "Manager@example.com" {
+		t.Errorf("cc = %v, want [Manager@example.com]", svc.last.Cc)
+	}
+	if len(svc.last.Bcc) != 1 || svc.last.Bcc[0] != "hidden@example.com" {
+		t.Errorf("bcc = %v, want [hidden@example.com]", svc.last.Bcc)
+	}
+	if svc.last.ReplyTo != "support@example.com" {
+		t.Errorf("replyTo = %q, want %q", svc.last.ReplyTo, "support@example.com")
+	}
+}
+
+func TestSMTPSession_Data_OnlyCcHeaderPromotesToTo(t *testing.T) {
+	s, svc := newTestSession(false, "", "")
+	_ = s.Rcpt("cc@example.com", &gosmtp.RcptOptions{})
+
+	raw := "Cc: cc@example.com\r\nSubject: Cc only\r\n\r\nBody"
+	if err := s.Data(strings.NewReader(raw)); err != nil {
+		t.Fatalf("Data() error: %v", err)
+	}
+
+	if svc.last.To != "cc@example.com" {
+		t.Errorf("to = %q, want %q", svc.last.To, "cc@example.com")
+	}
+	if len(svc.last.Cc) != 0 {
+		t.Errorf("cc = %v, want empty", svc.last.Cc)
+	}
+}
+
+func TestSMTPSession_Data_ForwardsAttachments(t *testing.T) {
+	s, svc := newTestSession(false, "", "")
+	_ = s.Rcpt("to@example.com", &gosmtp.RcptOptions{})
+
+	raw := "Subject: Invoice\r\nContent-Type: multipart/mixed; boundary=\"b\"\r\n\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/html\r\n\r\n" +
+		"<p>Invoice</p>\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Disposition: attachment; filename=\"invoice.pdf\"\r\n\r\n" +
+		"JVBERi0xLjQK\r\n" +
+		"--b--\r\n"
+	if err := s.Data(strings.NewReader(raw)); err != nil {
+		t.Fatalf("Data() error: %v", err)
+	}
+
+	if len(svc.last.Attachments) != 1 || svc.last.Attachments[0].Filename != "invoice.pdf" {
+		t.Errorf("attachments = %+v, want invoice.pdf", svc.last.Attachments)
+	}
+}
+
+func TestSMTPSession_Data_RendersMarkdown(t *testing.T) {
+	s, svc := newTestSession(false, "", "")
+	_ = s.Rcpt("to@example.com", &gosmtp.RcptOptions{})
+
+	raw := "Subject: Deploy\r\nContent-Type: text/markdown\r\n\r\n**api** is live"
+	if err := s.Data(strings.NewReader(raw)); err != nil {
+		t.Fatalf("Data() error: %v", err)
+	}
+	if !strings.Contains(svc.last.HtmlContent, "<p><strong>api</strong> is live</p>") {
+		t.Errorf("content = %q, want rendered markdown", svc.last.HtmlContent)
+	}
+	if svc.last.TextContent != "**api** is live" {
+		t.Errorf("textContent = %q, want the markdown source", svc.last.TextContent)
+	}
+}
+
+func TestSMTPSession_Data_InlinesCSS(t *testing.T) {
+	svc := &captureService{}
+	cfg := testConfig(false, "", "")
+	cfg.HTML = config.HTMLConfig{Enabled: true, Preheader: "Status update"}
+	s := newSMTPSession(svc, cfg, newTestComposer(cfg))
+	_ = s.Rcpt("to@example.com", &gosmtp.RcptOptions{})
+
+	raw := "Subject: Status\r\nContent-Type: text/html\r\n\r\n" +
+		"<style>p { color: gray; }</style><p>All good</p><script>alert(1)</script>"
+	if err := s.Data(strings.NewReader(raw)); err != nil {
+		t.Fatalf("Data() error: %v", err)
+	}
+	if !strings.Contains(svc.last.HtmlContent, `<p style="color:gray;">All good</p>`) {
+		t.Errorf("content = %q, want inlined CSS", svc.last.HtmlContent)
+	}
+	if !strings.Contains(svc.last.HtmlContent, ">Status update</div>") || strings.Contains(svc.last.HtmlContent, "<script") {
+		t.Errorf("content = %q, want a preheader and no script", svc.last.HtmlContent)
+	}
+}
+
+func TestSMTPSession_Data_RejectsOversizedAttachments(t *testing.T) {
+	svc := &captureService{}
+	cfg := testConfig(false, "", "")
+	cfg.Attachments.MaxTotalBytes = 4
+	s := newSMTPSession(svc, cfg, newTestComposer(cfg))
+	_ = s.Rcpt("to@example.com", &gosmtp.RcptOptions{})
+
+	raw := "Subject: Big\r\nContent-Type: multipart/mixed; boundary=\"b\"\r\n\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"Content-Disposition: attachment; filename=\"big.bin\"\r\n\r\n" +
+		"0123456789\r\n" +
+		"--b--\r\n"
+	err := s.Data(strings.NewReader(raw))
+
+	var smtpErr *gosmtp.SMTPError
+	if !errors.As(err, &smtpErr) || smtpErr.Code != 552 {
+		t.Fatalf("Data() error = %v, want SMTP 552", err)
+	}
+	if svc.last.Subject != "" {
+		t.Error("oversized message should not be dispatched")
+	}
+}
+
+func TestSMTPSession_Mail_RejectsSenderOutsideAllowlist(t *testing.T) {
+	s, _ := newTestSession(false, "", "")
+	err := s.Mail("ceo@evil.com", &gosmtp.MailOptions{})
+
+	var smtpErr *gosmtp.SMTPError
+	if !errors.As(err, &smtpErr) || smtpErr.Code != 550 {
+		t.Fatalf("Mail() error = %v, want SMTP 550", err)
+	}
+	if s.from != "" {
+		t.Errorf("from = %q, want empty after rejection", s.from)
+	}
+}
+
+func TestSMTPSession_Mail_AllowsNullSender(t *testing.T) {
+	s, _ := newTestSession(false, "", "")
+	if err := s.Mail("", &gosmtp.MailOptions{}); err != nil {
+		t.Errorf("Mail() error for null reverse-path: %v", err)
+	}
+}
+
+func TestSMTPSession_Data_TakesFromNameFromHeader(t *testing.T) {
+	s, svc := newTestSession(false, "", "")
+	_ = s.Mail("billing@example.com", &gosmtp.MailOptions{})
+	_ = s.Rcpt("to@example.com", &gosmtp.RcptOptions{})
+
+	raw := "From: =?utf-8?Q?Abrechnung_M=C3=BCller?= <Billing@example.com>\r\nSubject: Invoice\r\n\r\nBody"
+	if err := s.Data(strings.NewReader(raw)); err != nil {
+		t.Fatalf("Data() error: %v", err)
+	}
+
+	if svc.last.From != "billing@example.com" {
+		t.Errorf("from = %q, want %q", svc.last.From, "billing@example.com")
+	}
+	if svc.last.FromName != "Abrechnung Müller" {
+		t.Errorf("fromName = %q, want %q", svc.last.FromName, "Abrechnung Müller")
+	}
+}
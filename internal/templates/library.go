@@ -0,0 +1,175 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+// ErrExists is returned when creating a template whose id is already taken.
+var ErrExists = errors.New("template already exists")
+
+// validID restricts template ids to names that are safe in URLs, e.g. "welcome" or "invoice.v2".
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Library manages the stored templates. Every change of a template's content
+// adds a version, earlier versions stay available for rendering.
+type Library struct {
+	store Store
+	now   func() time.Time
+	// mu serializes changes, which read a template and save it again.
+	mu sync.Mutex
+}
+
+// NewLibrary creates a library of the templates in store.
+func NewLibrary(store Store) *Library {
+	return &Library{
+		store: store,
+		now:   func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Create stores a new template with def as its first version.
+func (l *Library) Create(id string, def Definition) (Template, error) {
+	if !validID.MatchString(id) {
+		return Template{}, fmt.Errorf("%w: id %q must be 1 to 64 letters, digits, '.', '_' or '-'", ErrInvalid, id)
+	}
+	if err := validate(def.Content); err != nil {
+		return Template{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.store.Get(id); err == nil {
+		return Template{}, fmt.Errorf("%w: %s", ErrExists, id)
+	} else if !errors.Is(err, ErrNotFound) {
+		return Template{}, err
+	}
+
+	now := l.now()
+	t := Template{
+		ID:          id,
+		Name:        def.Name,
+		Description: def.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Versions:    []Version{{Number: 1, Content: def.Content, CreatedAt: now}},
+	}
+	if err := l.store.Save(t); err != nil {
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// Update adds def as a new version of the template. Name and description are
+// kept if left empty. If the content equals the latest version, no version is added.
+func (l *Library) Update(id string, def Definition) (Template, error) {
+	if err := validate(def.Content); err != nil {
+		return Template{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t, err := l.store.Get(id)
+	if err != nil {
+		return Template{}, err
+	}
+
+	now := l.now()
+	if def.Name != "" {
+		t.Name = def.Name
+	}
+	if def.Description != "" {
+		t.Description = def.Description
+	}
+	if !def.Content.Equal(t.Latest().Content) {
+		t.Versions = append(t.Versions, Version{Number: len(t.Versions) + 1, Content: def.Content, CreatedAt: now})
+	}
+	t.UpdatedAt = now
+	if err := l.store.Save(t); err != nil {
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// Get returns the template with the given id.
+func (l *Library) Get(id string) (Template, error) {
+	return l.store.Get(id)
+}
+
+// List returns all templates ordered by id.
+func (l *Library) List() ([]Template, error) {
+	return l.store.List()
+}
+
+// Delete removes the template with all of its versions.
+func (l *Library) Delete(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.store.Delete(id)
+}
+
+// Rendered is a template rendered by the library.
+type Rendered struct {
+	Content
+	// Version is the number of the version used.
+	Version int
+	// Locale is the key of the translation used, empty for the default content.
+	Locale string
+	// Missing lists the variables missing from the data, set by Preview only.
+	Missing []string
+}
+
+// Render renders the given version of a template, or its latest version for 0,
+// in the translation for locale with data, see Content.Localize and Render.
+func (l *Library) Render(id string, version int, locale string, data map[string]any) (Rendered, error) {
+	return l.render(id, version, locale, func(content Content) (Content, []string, error) {
+		rendered, err := Render(content, data)
+		return rendered, nil, err
+	})
+}
+
+// Preview renders a template like Render, but reports variables missing from
+// data instead of failing, see Preview.
+func (l *Library) Preview(id string, version int, locale string, data map[string]any) (Rendered, error) {
+	return l.render(id, version, locale, func(content Content) (Content, []string, error) {
+		return Preview(content, data)
+	})
+}
+
+// render looks up the version and translation of a template and renders it with execute.
+func (l *Library) render(id string, version int, locale string, execute func(Content) (Content, []string, error)) (Rendered, error) {
+	t, err := l.store.Get(id)
+	if err != nil {
+		return Rendered{}, err
+	}
+	v, err := t.Version(version)
+	if err != nil {
+		return Rendered{}, err
+	}
+	content, key := v.Content.Localize(locale)
+	rendered, missing, err := execute(content)
+	if err != nil {
+		return Rendered{}, fmt.Errorf("template %q version %d: %w", id, v.Number, err)
+	}
+	return Rendered{Content: rendered, Version: v.Number, Locale: key, Missing: missing}, nil
+}
+
+// validate checks that content and each of its translations have a subject
+// and an HTML part and that all parts compile.
+func validate(content Content) error {
+	if content.Subject == "" || content.HtmlContent == "" {
+		return fmt.Errorf("%w: subject and content are required", ErrInvalid)
+	}
+	if content.ContentType != "" && content.ContentType != mail.ContentTypeHTML && content.ContentType != mail.ContentTypeMarkdown {
+		return fmt.Errorf("%w: contentType must be %q or %q", ErrInvalid, mail.ContentTypeHTML, mail.ContentTypeMarkdown)
+	}
+	if _, err := compile(content, missingKeyError); err != nil {
+		return err
+	}
+	return validateLocales(content)
+}
@@ -0,0 +1,124 @@
+package templates
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLibrary() *Library {
+	library := NewLibrary(NewMemoryStore())
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	library.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	return library
+}
+
+var welcome = Definition{
+	Name:    "Welcome",
+	Content: Content{Subject: "Welcome {{.name}}", HtmlContent: "<p>Hi {{.name}}</p>"},
+}
+
+func TestLibrary_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		def     Definition
+		wantErr error
+	}{
+		{name: "valid", id: "welcome", def: welcome},
+		{name: "taken id", id: "taken", def: welcome, wantErr: ErrExists},
+		{name: "invalid id", id: "../welcome", def: welcome, wantErr: ErrInvalid},
+		{name: "missing subject", id: "welcome", def: Definition{Content: Content{HtmlContent: "<p>Hi</p>"}}, wantErr: ErrInvalid},
+		{name: "syntax error", id: "welcome", def: Definition{Content: Content{Subject: "{{if}}", HtmlContent: "<p>Hi</p>"}}, wantErr: ErrInvalid},
+		{name: "markdown", id: "welcome", def: Definition{Content: Content{Subject: "Hi", HtmlContent: "**{{.name}}**", ContentType: "markdown"}}},
+		{name: "unknown content type", id: "welcome", def: Definition{Content: Content{Subject: "Hi", HtmlContent: "<p>Hi</p>", ContentType: "text"}}, wantErr: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			library := newTestLibrary()
+			if _, err := library.Create("taken", welcome); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			got, err := library.Create(tt.id, tt.def)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.ID != tt.id || len(got.Versions) != 1 || got.Latest().Number != 1) {
+				t.Errorf("Create() = %+v, want version 1 of %s", got, tt.id)
+			}
+		})
+	}
+}
+
+func TestLibrary_UpdateAddsVersions(t *testing.T) {
+	library := newTestLibrary()
+	created, _ := library.Create("welcome", welcome)
+
+	def := welcome
+	def.Subject = "Welcome aboard {{.name}}"
+	updated, err := library.Update("welcome", def)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if len(updated.Versions) != 2 || updated.Latest().Subject != def.Subject || updated.Name != "Welcome" {
+		t.Errorf("Update() = %+v, want a second version", updated)
+	}
+	if !updated.UpdatedAt.After(created.UpdatedAt) || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Update() times = %v, %v", updated.CreatedAt, updated.UpdatedAt)
+	}
+
+	// unchanged content only updates the description
+	def.Description = "sent after sign-up"
+	updated, _ = library.Update("welcome", def)
+	if len(updated.Versions) != 2 || updated.Description != def.Description {
+		t.Errorf("Update() with the same content = %+v, want no new version", updated)
+	}
+
+	if _, err := library.Update("missing", def); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLibrary_Render(t *testing.T) {
+	library := newTestLibrary()
+	_, _ = library.Create("welcome", welcome)
+	def := welcome
+	def.Subject = "Hello {{.name}}"
+	_, _ = library.Update("welcome", def)
+	data := map[string]any{"name": "Ann"}
+
+	tests := []struct {
+		name        string
+		id          string
+		version     int
+		wantSubject string
+		wantVersion int
+		wantErr     error
+	}{
+		{name: "latest", id: "welcome", wantSubject: "Hello Ann", wantVersion: 2},
+		{name: "pinned version", id: "welcome", version: 1, wantSubject: "Welcome Ann", wantVersion: 1},
+		{name: "unknown version", id: "welcome", version: 3, wantErr: ErrNotFound},
+		{name: "unknown template", id: "missing", wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := library.Render(tt.id, tt.version, "", data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Render() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Subject != tt.wantSubject || got.Version != tt.wantVersion {
+				t.Errorf("Render() = %q from version %d, want %q from version %d", got.Subject, got.Version, tt.wantSubject, tt.wantVersion)
+			}
+		})
+	}
+
+	if _, err := library.Render("welcome", 0, "", nil); !errors.Is(err, ErrRender) {
+		t.Errorf("Render() without data error = %v, want ErrRender", err)
+	}
+}
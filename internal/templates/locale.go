@@ -0,0 +1,65 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// localeTag matches language tags such as "de", "de-AT" or "zh-Hant-TW".
+var localeTag = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// Localize returns the variant of content for locale together with its key.
+// The locale falls back to less specific tags, e.g. "de-AT" to "de", and
+// finally to the default content, for which the key is empty. Tags are
+// matched case-insensitively and "_" is accepted for "-", e.g. "de_at".
+// Variants have the ContentType of c.
+func (c Content) Localize(locale string) (Content, string) {
+	tag := strings.ReplaceAll(locale, "_", "-")
+	for tag != "" {
+		// keys differ in more than case, see validateLocales
+		for key, variant := range c.Locales {
+			if strings.EqualFold(key, tag) {
+				variant.ContentType = c.ContentType
+				return variant, key
+			}
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	c.Locales = nil
+	return c, ""
+}
+
+// validateLocales checks the keys of the locale variants and that every
+// variant is complete, so that the subject is never left untranslated.
+func validateLocales(content Content) error {
+	seen := make(map[string]string, len(content.Locales))
+	for key, variant := range content.Locales {
+		if !localeTag.MatchString(key) {
+			return fmt.Errorf("%w: locale %q is not a language tag such as \"de\" or \"de-AT\"", ErrInvalid, key)
+		}
+		if other, ok := seen[strings.ToLower(key)]; ok {
+			return fmt.Errorf("%w: locales %q and %q differ only in case", ErrInvalid, other, key)
+		}
+		seen[strings.ToLower(key)] = key
+
+		if len(variant.Locales) > 0 {
+			return fmt.Errorf("%w: locale %q must not have locales of its own", ErrInvalid, key)
+		}
+		if variant.Subject == "" || variant.HtmlContent == "" {
+			return fmt.Errorf("%w: locale %q: subject and content are required", ErrInvalid, key)
+		}
+		if variant.ContentType != "" && variant.ContentType != content.ContentType {
+			return fmt.Errorf("%w: locale %q: contentType must be that of the template", ErrInvalid, key)
+		}
+		variant.ContentType = content.ContentType
+		if _, err := compile(variant, missingKeyError); err != nil {
+			return fmt.Errorf("locale %q: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package templates
+
+import (
+	"errors"
+	"testing"
+)
+
+var localized = Content{
+	Subject:     "Welcome {{.name}}",
+	HtmlContent: "<p>Hi {{.name}}</p>",
+	Locales: map[string]Content{
+		"de":    {Subject: "Willkommen {{.name}}", HtmlContent: "<p>Hallo {{.name}}</p>"},
+		"de-AT": {Subject: "Servus {{.name}}", HtmlContent: "<p>Servus {{.name}}</p>"},
+		"fr":    {Subject: "Bienvenue {{.name}}", HtmlContent: "<p>Bonjour {{.name}}</p>"},
+	},
+}
+
+func TestContent_Localize(t *testing.T) {
+	tests := []struct {
+		locale      string
+		wantKey     string
+		wantSubject string
+	}{
+		{locale: "", wantKey: "", wantSubject: "Welcome {{.name}}"},
+		{locale: "de", wantKey: "de", wantSubject: "Willkommen {{.name}}"},
+		{locale: "de-AT", wantKey: "de-AT", wantSubject: "Servus {{.name}}"},
+		{locale: "de_at", wantKey: "de-AT", wantSubject: "Servus {{.name}}"},
+		{locale: "de-CH", wantKey: "de", wantSubject: "Willkommen {{.name}}"},
+		{locale: "FR-ca", wantKey: "fr", wantSubject: "Bienvenue {{.name}}"},
+		{locale: "es-MX", wantKey: "", wantSubject: "Welcome {{.name}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			got, key := localized.Localize(tt.locale)
+			if key != tt.wantKey || got.Subject != tt.wantSubject {
+				t.Errorf("Localize(%q) = %q from %q, want %q from %q", tt.locale, got.Subject, key, tt.wantSubject, tt.wantKey)
+			}
+			if got.Locales != nil {
+				t.Errorf("Localize(%q) kept the locales", tt.locale)
+			}
+		})
+	}
+}
+
+func TestValidate_Locales(t *testing.T) {
+	variant := Content{Subject: "Hallo", HtmlContent: "<p>Hallo</p>"}
+	tests := []struct {
+		name    string
+		locales map[string]Content
+		wantErr error
+	}{
+		{name: "valid", locales: map[string]Content{"de": variant, "de-AT": variant}},
+		{name: "invalid tag", locales: map[string]Content{"german": variant, "de/AT": variant}, wantErr: ErrInvalid},
+		{name: "duplicate in case", locales: map[string]Content{"de-AT": variant, "de-at": variant}, wantErr: ErrInvalid},
+		{name: "missing subject", locales: map[string]Content{"de": {HtmlContent: "<p>Hallo</p>"}}, wantErr: ErrInvalid},
+		{name: "syntax error", locales: map[string]Content{"de": {Subject: "{{.name", HtmlContent: "<p>Hallo</p>"}}, wantErr: ErrInvalid},
+		{name: "nested locales", locales: map[string]Content{"de": {Subject: "Hallo", HtmlContent: "<p>Hallo</p>", Locales: map[string]Content{"at": variant}}}, wantErr: ErrInvalid},
+		{name: "other content type", locales: map[string]Content{"de": {Subject: "Hallo", HtmlContent: "**Hallo**", ContentType: "markdown"}}, wantErr: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := Content{Subject: "Hi", HtmlContent: "<p>Hi</p>", Locales: tt.locales}
+			if err := validate(content); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLibrary_RenderLocale(t *testing.T) {
+	library := newTestLibrary()
+	if _, err := library.Create("welcome", Definition{Content: localized}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := library.Render("welcome", 0, "de-CH", map[string]any{"name": "Ann"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got.Subject != "Willkommen Ann" || got.HtmlContent != "<p>Hallo Ann</p>" || got.Locale != "de" || got.Version != 1 {
+		t.Errorf("Render() = %+v", got)
+	}
+
+	// translations are written in the content type of the template
+	markdown := Content{Subject: "Hi", HtmlContent: "**{{.name}}**", ContentType: "markdown", Locales: map[string]Content{"de": {Subject: "Hallo", HtmlContent: "_{{.name}}_"}}}
+	if _, err := library.Create("markdown", Definition{Content: markdown}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	got, err = library.Render("markdown", 0, "de", map[string]any{"name": "A*nn"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got.HtmlContent != "_A\\*nn_" || got.TextContent != "_A*nn_" || got.ContentType != "markdown" {
+		t.Errorf("Render() of a markdown translation = %+v", got)
+	}
+
+	// a changed translation adds a version
+	def := Definition{Content: localized}
+	def.Locales = map[string]Content{"de": {Subject: "Hallo {{.name}}", HtmlContent: "<p>Hallo</p>"}}
+	updated, err := library.Update("welcome", def)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if len(updated.Versions) != 2 {
+		t.Errorf("Update() with a changed translation has %d versions, want 2", len(updated.Versions))
+	}
+}
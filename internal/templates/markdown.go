@@ -0,0 +1,80 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// markdownEscaper is the function appended to every action of Markdown content.
+const markdownEscaper = "_markdown_escaper"
+
+// markdownPunctuation holds the ASCII punctuation characters, all of which
+// CommonMark allows to be escaped with a backslash.
+const markdownPunctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+var markdownFuncs = texttemplate.FuncMap{markdownEscaper: escapeMarkdown}
+
+// escapeMarkdown prints value as literal text in Markdown, so that data cannot
+// add links, images, HTML or other markup. Line breaks become spaces, as they
+// could start a new block.
+func escapeMarkdown(value any) string {
+	if value == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range fmt.Sprint(value) {
+		switch {
+		case r == '\r' || r == '\n':
+			b.WriteByte(' ')
+		case strings.ContainsRune(markdownPunctuation, r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// escapeActions pipes the output of every action in t, including the
+// templates it defines, through escapeMarkdown, like html/template does for
+// HTML. Actions that only declare a variable print nothing and are left as is.
+func escapeActions(t *texttemplate.Template) {
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			escapeNode(tmpl.Tree, tmpl.Tree.Root)
+		}
+	}
+}
+
+func escapeNode(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeNode(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(markdownEscaper).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeNode(tree, n.List)
+		escapeNode(tree, n.ElseList)
+	}
+}
@@ -0,0 +1,67 @@
+package templates
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps templates in memory, so they are lost on restart.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	templates map[string]Template
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{templates: make(map[string]Template)}
+}
+
+// Save inserts or replaces a template.
+func (s *MemoryStore) Save(t Template) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[t.ID] = t
+	return nil
+}
+
+// Get returns the template with the given id.
+func (s *MemoryStore) Get(id string) (Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.templates[id]
+	if !ok {
+		return Template{}, ErrNotFound
+	}
+	return t, nil
+}
+
+// List returns all templates ordered by id.
+func (s *MemoryStore) List() ([]Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := make([]Template, 0, len(s.templates))
+	for _, t := range s.templates {
+		all = append(all, t)
+	}
+	slices.SortFunc(all, func(a, b Template) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return all, nil
+}
+
+// Delete removes the template with the given id.
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.templates[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.templates, id)
+	return nil
+}
+
+// Close is a no-op.
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/jo-hoe/go-mail-service/internal/mail"
+)
+
+var (
+	// ErrInvalid is returned for a template definition that cannot be stored,
+	// e.g. because of a syntax error.
+	ErrInvalid = errors.New("invalid template")
+	// ErrRender is returned when a template fails for the given data, e.g.
+	// because a variable is missing.
+	ErrRender = errors.New("cannot render template")
+)
+
+// missingKeyError makes a variable missing from the data fail the rendering
+// instead of printing "<no value>" into the mail. Previews use missingKeyDefault.
+const (
+	missingKeyError   = "missingkey=error"
+	missingKeyDefault = "missingkey=default"
+)
+
+// parsedContent holds the compiled parts of a Content. text is nil if the
+// version has no text part, the mail then derives it from the HTML.
+type parsedContent struct {
+	subject *texttemplate.Template
+	html    *htmltemplate.Template
+	// markdown replaces html for content written in Markdown. It escapes the
+	// data for Markdown, the HTML is escaped when the Markdown is rendered.
+	markdown *texttemplate.Template
+	// markdownText renders the same Markdown without escaping the data. It
+	// becomes the text part if the version has none.
+	markdownText *texttemplate.Template
+	text         *texttemplate.Template
+}
+
+// compile compiles the parts of content with the given missingkey option.
+// The content is compiled as Markdown or HTML depending on its ContentType.
+func compile(content Content, missingKey string) (parsedContent, error) {
+	var parsed parsedContent
+	var err error
+	if parsed.subject, err = texttemplate.New("subject").Option(missingKey).Parse(content.Subject); err != nil {
+		return parsed, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if content.ContentType == mail.ContentTypeMarkdown {
+		// parsed twice, as escaping rewrites the parse tree that a clone shares
+		parsed.markdownText, err = texttemplate.New("content").Option(missingKey).Parse(content.HtmlContent)
+		if err == nil {
+			parsed.markdown, err = texttemplate.New("content").Funcs(markdownFuncs).Option(missingKey).Parse(content.HtmlContent)
+		}
+		if err == nil {
+			escapeActions(parsed.markdown)
+		}
+	} else {
+		parsed.html, err = htmltemplate.New("content").Option(missingKey).Parse(content.HtmlContent)
+	}
+	if err != nil {
+		return parsed, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if content.TextContent == "" {
+		return parsed, nil
+	}
+	if parsed.text, err = texttemplate.New("textContent").Option(missingKey).Parse(content.TextContent); err != nil {
+		return parsed, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	return parsed, nil
+}
+
+// Render executes content with data and returns the subject and body of the mail.
+// Line breaks in the rendered subject are replaced by spaces. Markdown content
+// gets the data escaped for Markdown, so that it renders as plain text, and
+// without a text part of its own the Markdown rendered with the data as it is.
+func Render(content Content, data map[string]any) (Content, error) {
+	parsed, err := compile(content, missingKeyError)
+	if err != nil {
+		return Content{}, err
+	}
+	return parsed.execute(data)
+}
+
+// Preview renders content like Render, but does not fail for variables
+// missing from data. They are rendered empty, or as "<no value>" in the
+// subject and text part, and returned as missing, e.g. ".user.name".
+func Preview(content Content, data map[string]any) (rendered Content, missing []string, err error) {
+	parsed, err := compile(content, missingKeyDefault)
+	if err != nil {
+		return Content{}, nil, err
+	}
+	// the variables are collected before executing, which rewrites the HTML template
+	missing = parsed.missingVariables(data)
+	rendered, err = parsed.execute(data)
+	if err != nil {
+		return Content{}, nil, err
+	}
+	return rendered, missing, nil
+}
+
+// execute renders the parts with data.
+func (parsed parsedContent) execute(data map[string]any) (Content, error) {
+	var rendered Content
+	if parsed.markdown != nil {
+		rendered.ContentType = mail.ContentTypeMarkdown
+	}
+	var b strings.Builder
+	if err := parsed.subject.Execute(&b, data); err != nil {
+		return Content{}, fmt.Errorf("%w: %w", ErrRender, err)
+	}
+	rendered.Subject = strings.Join(strings.Fields(b.String()), " ")
+
+	b.Reset()
+	var err error
+	if parsed.markdown != nil {
+		err = parsed.markdown.Execute(&b, data)
+	} else {
+		err = parsed.html.Execute(&b, data)
+	}
+	if err != nil {
+		return Content{}, fmt.Errorf("%w: %w", ErrRender, err)
+	}
+	rendered.HtmlContent = b.String()
+
+	text := parsed.text
+	if text == nil {
+		text = parsed.markdownText
+	}
+	if text != nil {
+		b.Reset()
+		if err := text.Execute(&b, data); err != nil {
+			return Content{}, fmt.Errorf("%w: %w", ErrRender, err)
+		}
+		rendered.TextContent = b.String()
+	}
+	return rendered, nil
+}
@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		content Content
+		data    map[string]any
+		want    Content
+		wantErr error
+	}{
+		{
+			name:    "all parts",
+			content: Content{Subject: "Hello {{.name}}", HtmlContent: "<p>Hi {{.name}}</p>", TextContent: "Hi {{.name}}"},
+			data:    map[string]any{"name": "Ann"},
+			want:    Content{Subject: "Hello Ann", HtmlContent: "<p>Hi Ann</p>", TextContent: "Hi Ann"},
+		},
+		{
+			name:    "html is escaped, the text part is not",
+			content: Content{Subject: "{{.name}}", HtmlContent: "<p>{{.name}}</p>", TextContent: "{{.name}}"},
+			data:    map[string]any{"name": "<b>Ann</b>"},
+			want:    Content{Subject: "<b>Ann</b>", HtmlContent: "<p>&lt;b&gt;Ann&lt;/b&gt;</p>", TextContent: "<b>Ann</b>"},
+		},
+		{
+			name:    "markdown escapes the data for markdown, the text part does not",
+			content: Content{Subject: "Hi {{.name}}", HtmlContent: "**{{.name}}** & {{.company}}", ContentType: "markdown"},
+			data:    map[string]any{"name": "O'Brien", "company": "Smith & Sons"},
+			want:    Content{Subject: "Hi O'Brien", HtmlContent: "**O\\'Brien** & Smith \\& Sons", TextContent: "**O'Brien** & Smith & Sons", ContentType: "markdown"},
+		},
+		{
+			name:    "markdown data cannot add markup",
+			content: Content{Subject: "s", HtmlContent: "{{range .items}}- {{.}}\n{{end}}{{with .note}}{{.}}{{end}}", TextContent: "plain", ContentType: "markdown"},
+			data:    map[string]any{"items": []any{"[x](https://evil.example)"}, "note": "![img](x.png)\n# <b>hi</b>"},
+			want: Content{
+				Subject:     "s",
+				HtmlContent: "- \\[x\\]\\(https\\:\\/\\/evil\\.example\\)\n\\!\\[img\\]\\(x\\.png\\) \\# \\<b\\>hi\\<\\/b\\>",
+				TextContent: "plain",
+				ContentType: "markdown",
+			},
+		},
+		{
+			name:    "subject is a single line",
+			content: Content{Subject: "Order\n{{.id}}\r\n", HtmlContent: "<p>ok</p>"},
+			data:    map[string]any{"id": "42\nBcc: x@example.com"},
+			want:    Content{Subject: "Order 42 Bcc: x@example.com", HtmlContent: "<p>ok</p>"},
+		},
+		{
+			name:    "nested data and range",
+			content: Content{Subject: "Order", HtmlContent: "{{range .items}}<li>{{.title}}</li>{{end}}"},
+			data:    map[string]any{"items": []any{map[string]any{"title": "a"}, map[string]any{"title": "b"}}},
+			want:    Content{Subject: "Order", HtmlContent: "<li>a</li><li>b</li>"},
+		},
+		{
+			name:    "missing variable",
+			content: Content{Subject: "Hello {{.name}}", HtmlContent: "<p>Hi</p>"},
+			data:    map[string]any{},
+			wantErr: ErrRender,
+		},
+		{
+			name:    "syntax error",
+			content: Content{Subject: "Hello", HtmlContent: "<p>{{.name</p>"},
+			wantErr: ErrInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.content, tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Render() error = %v, want %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Render() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreview(t *testing.T) {
+	content := Content{
+		Subject:     "Order {{.order.id}} for {{.name}}",
+		HtmlContent: `<p>Hi {{.name}}</p>{{if .vip}}<b>VIP</b>{{end}}{{range .items}}<li>{{.title}} {{$.currency}}</li>{{end}}{{with .address}}{{.street}}{{end}}`,
+		TextContent: "Hi {{.name}}, {{.order.total}}",
+	}
+
+	rendered, missing, err := Preview(content, map[string]any{
+		"order": map[string]any{"id": 7},
+		"items": []any{map[string]any{"title": "a"}},
+	})
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+	// like a send, which fails for them, optional blocks need their variable too
+	want := []string{".name", ".vip", ".currency", ".address", ".order.total"}
+	if !slices.Equal(missing, want) {
+		t.Errorf("Preview() missing = %v, want %v", missing, want)
+	}
+	if rendered.HtmlContent != "<p>Hi </p><li>a </li>" || rendered.Subject != "Order 7 for <no value>" {
+		t.Errorf("Preview() = %+v", rendered)
+	}
+
+	if _, missing, _ := Preview(content, map[string]any{
+		"name": "Ann", "vip": true, "currency": "EUR", "items": []any{}, "address": nil, "order": map[string]any{"id": 7, "total": 3},
+	}); len(missing) != 0 {
+		t.Errorf("Preview() with all data missing = %v", missing)
+	}
+
+	if _, missing, _ := Preview(Content{Subject: "s", HtmlContent: "**{{.name}}**", ContentType: "markdown"}, nil); !slices.Equal(missing, []string{".name"}) {
+		t.Errorf("Preview() of markdown missing = %v, want [.name]", missing)
+	}
+}
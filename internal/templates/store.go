@@ -0,0 +1,111 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned when no template or version exists for the requested id.
+var ErrNotFound = errors.New("template not found")
+
+var templatesBucket = []byte("templates")
+
+// Store persists templates.
+type Store interface {
+	// Save inserts or replaces a template.
+	Save(t Template) error
+	// Get returns the template with the given id or ErrNotFound.
+	Get(id string) (Template, error)
+	// List returns all templates ordered by id.
+	List() ([]Template, error)
+	// Delete removes the template with the given id or returns ErrNotFound.
+	Delete(id string) error
+	// Close releases the underlying resources.
+	Close() error
+}
+
+// BoltStore is a Store backed by a bbolt database file. Each template is
+// kept as JSON together with all of its versions.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens or creates the bbolt database at path.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening template database %q: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(templatesBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initializing template database %q: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Save inserts or replaces a template.
+func (s *BoltStore) Save(t Template) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("encoding template %s: %w", t.ID, err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(templatesBucket).Put([]byte(t.ID), data)
+	})
+}
+
+// Get returns the template with the given id.
+func (s *BoltStore) Get(id string) (Template, error) {
+	var t Template
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(templatesBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &t)
+	})
+	return t, err
+}
+
+// List returns all templates ordered by id, the key order of the bucket.
+func (s *BoltStore) List() ([]Template, error) {
+	var all []Template
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(templatesBucket).ForEach(func(id, data []byte) error {
+			var t Template
+			if err := json.Unmarshal(data, &t); err != nil {
+				return fmt.Errorf("decoding template %s: %w", id, err)
+			}
+			all = append(all, t)
+			return nil
+		})
+	})
+	return all, err
+}
+
+// Delete removes the template with the given id.
+func (s *BoltStore) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(templatesBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// Close closes the database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,88 @@
+package templates
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testTemplate(id string) Template {
+	now := time.Now().UTC().Truncate(time.Second)
+	return Template{
+		ID:        id,
+		Name:      "Welcome",
+		CreatedAt: now,
+		UpdatedAt: now,
+		Versions:  []Version{{Number: 1, Content: Content{Subject: "Hi {{.name}}", HtmlContent: "<p>Hi</p>"}, CreatedAt: now}},
+	}
+}
+
+func TestStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.db")
+	bolt, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("OpenBoltStore() error = %v", err)
+	}
+	defer func() { _ = bolt.Close() }()
+
+	for name, store := range map[string]Store{"bolt": bolt, "memory": NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) {
+			for _, id := range []string{"welcome", "invoice"} {
+				if err := store.Save(testTemplate(id)); err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+			}
+
+			got, err := store.Get("welcome")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Name != "Welcome" || got.Latest().Subject != "Hi {{.name}}" {
+				t.Errorf("Get() = %+v", got)
+			}
+			if _, err := store.Get("missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+			}
+
+			all, err := store.List()
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(all) != 2 || all[0].ID != "invoice" || all[1].ID != "welcome" {
+				t.Errorf("List() = %+v, want invoice and welcome", all)
+			}
+
+			if err := store.Delete("invoice"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if err := store.Delete("invoice"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Delete() twice error = %v, want ErrNotFound", err)
+			}
+			if all, _ := store.List(); len(all) != 1 {
+				t.Errorf("List() after Delete() = %+v", all)
+			}
+		})
+	}
+}
+
+func TestBoltStore_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.db")
+	store, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("OpenBoltStore() error = %v", err)
+	}
+	if err := store.Save(testTemplate("welcome")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	_ = store.Close()
+
+	store, err = OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("OpenBoltStore() error = %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	if _, err := store.Get("welcome"); err != nil {
+		t.Errorf("Get() after reopening error = %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package templates
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+// Content is the subject and body of a template version. All three are Go
+// templates: Subject and TextContent use text/template, HtmlContent uses
+// html/template, which escapes the data for its context in the markup.
+// Markdown content uses text/template and escapes the data for Markdown.
+type Content struct {
+	Subject     string `json:"subject"`
+	HtmlContent string `json:"content"`
+	TextContent string `json:"textContent,omitempty"`
+	// ContentType tells how HtmlContent is written, mail.ContentTypeHTML if
+	// empty. It applies to the translations as well.
+	ContentType string `json:"contentType,omitempty"`
+	// Locales holds translations keyed by language tag, e.g. "de" or "de-AT".
+	// The fields above are the default for locales without a translation.
+	Locales map[string]Content `json:"locales,omitempty"`
+}
+
+// Equal reports whether both contents, including their locales, are the same.
+func (c Content) Equal(other Content) bool {
+	return c.Subject == other.Subject &&
+		c.HtmlContent == other.HtmlContent &&
+		c.TextContent == other.TextContent &&
+		c.ContentType == other.ContentType &&
+		maps.EqualFunc(c.Locales, other.Locales, Content.Equal)
+}
+
+// Definition is what a client submits to create a template or a new version of it.
+type Definition struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Content
+}
+
+// Version is one immutable revision of a template.
+type Version struct {
+	Number int `json:"version"`
+	Content
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Template is a stored template with all of its versions.
+type Template struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	// Versions holds every version, oldest first. Updates add a version and
+	// keep the earlier ones, so that a mail can pin the version it was tested with.
+	Versions []Version `json:"versions"`
+}
+
+// Latest returns the newest version of the template.
+func (t Template) Latest() Version {
+	return t.Versions[len(t.Versions)-1]
+}
+
+// Version returns the version with the given number, or the latest one for 0.
+func (t Template) Version(number int) (Version, error) {
+	if number == 0 {
+		return t.Latest(), nil
+	}
+	if number < 0 || number > len(t.Versions) {
+		return Version{}, fmt.Errorf("template %q has no version %d: %w", t.ID, number, ErrNotFound)
+	}
+	return t.Versions[number-1], nil
+}
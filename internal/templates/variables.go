@@ -0,0 +1,99 @@
+package templates
+
+import (
+	"slices"
+	"strings"
+	"text/template/parse"
+)
+
+// missingVariables returns the variables the parts refer to that data lacks,
+// in the order they first appear. Only references to the data itself, such as
+// {{.name}} or {{$.user.name}}, are checked; inside range and with blocks dot
+// is another value whose fields are not known in advance.
+func (parsed parsedContent) missingVariables(data map[string]any) []string {
+	var missing []string
+	trees := []*parse.Tree{parsed.subject.Tree}
+	if parsed.markdown != nil {
+		trees = append(trees, parsed.markdown.Tree)
+	} else {
+		trees = append(trees, parsed.html.Tree)
+	}
+	if parsed.text != nil {
+		trees = append(trees, parsed.text.Tree)
+	}
+	for _, tree := range trees {
+		if tree == nil {
+			continue
+		}
+		walkNode(tree.Root, true, func(path []string) {
+			if variable := missingPath(data, path); variable != "" && !slices.Contains(missing, variable) {
+				missing = append(missing, variable)
+			}
+		})
+	}
+	return missing
+}
+
+// walkNode calls check with the field path of every reference to the data
+// below node. dotIsData tells whether dot still is the data at node.
+func walkNode(node parse.Node, dotIsData bool, check func(path []string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkNode(child, dotIsData, check)
+		}
+	case *parse.ActionNode:
+		walkNode(n.Pipe, dotIsData, check)
+	case *parse.IfNode:
+		walkNode(n.Pipe, dotIsData, check)
+		walkNode(n.List, dotIsData, check)
+		walkNode(n.ElseList, dotIsData, check)
+	case *parse.RangeNode:
+		walkNode(n.Pipe, dotIsData, check)
+		walkNode(n.List, false, check)
+		walkNode(n.ElseList, dotIsData, check)
+	case *parse.WithNode:
+		walkNode(n.Pipe, dotIsData, check)
+		walkNode(n.List, false, check)
+		walkNode(n.ElseList, dotIsData, check)
+	case *parse.TemplateNode:
+		walkNode(n.Pipe, dotIsData, check)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				walkNode(arg, dotIsData, check)
+			}
+		}
+	case *parse.FieldNode:
+		if dotIsData {
+			check(n.Ident)
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			check(n.Ident[1:])
+		}
+	}
+}
+
+// missingPath returns the shortest prefix of path that is missing from data,
+// e.g. ".user.name" if data has a user without a name. Values other than maps
+// are not inspected.
+func missingPath(data map[string]any, path []string) string {
+	var current any = data
+	for i, key := range path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return ""
+		}
+		if current, ok = m[key]; !ok {
+			return "." + strings.Join(path[:i+1], ".")
+		}
+	}
+	return ""
+}
@@ -3,34 +3,178 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 )
 
+// ClientIDHeader is the header that identifies the API client to the service.
+// The service can route mail by client.
+const ClientIDHeader = "X-Client-ID"
+
+// Content types of MailRequest.ContentType
+const (
+	ContentTypeHTML     = "html"
+	ContentTypeMarkdown = "markdown"
+)
+
 // Client represents a mail service client
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	clientID   string
 }
 
 // MailRequest represents the structure for sending mail
 type MailRequest struct {
-	To          string `json:"to"`
-	Subject     string `json:"subject"`
-	HtmlContent string `json:"content"`
-	From        string `json:"from,omitempty"`
-	FromName    string `json:"fromName,omitempty"`
+	To          string       `json:"to"`
+	Cc          []string     `json:"cc,omitempty"`
+	Bcc         []string     `json:"bcc,omitempty"`
+	ReplyTo     string       `json:"replyTo,omitempty"`
+	Subject     string       `json:"subject"`
+	HtmlContent string       `json:"content"`
+	TextContent string       `json:"textContent,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	From        string       `json:"from,omitempty"`
+	FromName    string       `json:"fromName,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	// ContentType is ContentTypeMarkdown if HtmlContent is written in Markdown
+	ContentType string `json:"contentType,omitempty"`
+	// TemplateID names a template stored in the service. Subject, HtmlContent,
+	// TextContent and ContentType are then left empty and taken from the
+	// template rendered with Data.
+	TemplateID string `json:"templateId,omitempty"`
+	// TemplateVersion pins a version of the template, 0 selects the latest
+	TemplateVersion int `json:"templateVersion,omitempty"`
+	// Locale selects the translation of the template, e.g. "de-AT", which
+	// falls back to "de" and then to the template's default content
+	Locale string         `json:"locale,omitempty"`
+	Data   map[string]any `json:"data,omitempty"`
+}
+
+// MailResponse represents the response from the mail service.
+// It has the fields of MailRequest, so that a request converts to a response.
+// The service echoes the mail without its Bcc recipients and attachments.
+type MailResponse MailRequest
+
+// SendMailResult represents the response of SendMailWithResult.
+// ID identifies the message for GetMessage. Status is MessageStatusSent,
+// or MessageStatusAccepted if the service queued the mail for asynchronous delivery.
+type SendMailResult struct {
+	MailResponse
+	ID     string        `json:"id"`
+	Status MessageStatus `json:"status"`
+}
+
+// MessageStatus describes where a message is in its delivery lifecycle
+type MessageStatus string
+
+const (
+	// MessageStatusAccepted means the message is waiting for (another) delivery attempt
+	MessageStatusAccepted MessageStatus = "accepted"
+	// MessageStatusSending means the message is currently handed to the provider
+	MessageStatusSending MessageStatus = "sending"
+	// MessageStatusSent means the provider accepted the message
+	MessageStatusSent MessageStatus = "sent"
+	// MessageStatusFailed means the service gave up delivering the message
+	MessageStatusFailed MessageStatus = "failed"
+)
+
+// Message represents the delivery status of a mail as reported by the service
+type Message struct {
+	ID                 string         `json:"id"`
+	Status             MessageStatus  `json:"status"`
+	To                 string         `json:"to"`
+	Cc                 []string       `json:"cc,omitempty"`
+	Subject            string         `json:"subject"`
+	From               string         `json:"from,omitempty"`
+	Recipients         int            `json:"recipients"`
+	Attempts           int            `json:"attempts"`
+	LastError          string         `json:"lastError,omitempty"`
+	Provider           string         `json:"provider,omitempty"`
+	ProviderMessageIDs []string       `json:"providerMessageIds,omitempty"`
+	CreatedAt          time.Time      `json:"createdAt"`
+	UpdatedAt          time.Time      `json:"updatedAt"`
+	NextAttemptAt      *time.Time     `json:"nextAttemptAt,omitempty"`
+	History            []MessageEvent `json:"history"`
 }
 
-// MailResponse represents the response from the mail service
-type MailResponse struct {
-	To          string `json:"to"`
-	Subject     string `json:"subject"`
-	HtmlContent string `json:"content"`
-	From        string `json:"from,omitempty"`
-	FromName    string `json:"fromName,omitempty"`
+// MessageEvent is a single status change of a message
+type MessageEvent struct {
+	Status MessageStatus `json:"status"`
+	Time   time.Time     `json:"time"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// ListMessagesOptions filters the messages returned by ListMessages. Zero values are ignored.
+type ListMessagesOptions struct {
+	Status    MessageStatus
+	Recipient string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// BatchResult is the outcome of one mail of a batch, in the order of the request.
+// Code is the HTTP status SendMail would have received for the mail: 200 if it
+// was sent, 202 if it was queued, and an error status such as 400 otherwise.
+// ID is empty if the mail was rejected before it was recorded.
+type BatchResult struct {
+	ID     string        `json:"id,omitempty"`
+	Status MessageStatus `json:"status,omitempty"`
+	Code   int           `json:"code"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// OK reports whether the mail was sent or queued
+func (r BatchResult) OK() bool {
+	return r.Code == http.StatusOK || r.Code == http.StatusAccepted
+}
+
+// batchRequest is the envelope of the batch send endpoint
+type batchRequest struct {
+	Messages []MailRequest `json:"messages"`
+}
+
+// batchResponse holds one result per mail of a batch
+type batchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// listMessagesResponse is the envelope of the message list endpoint
+type listMessagesResponse struct {
+	Messages []Message `json:"messages"`
+}
+
+// Attachment represents a file sent along with the mail.
+// Content holds the base64 encoded file data. Set ContentID to embed the
+// attachment inline and reference it from the HTML body via "cid:<ContentID>".
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+	ContentID   string `json:"contentId,omitempty"`
+}
+
+// NewAttachment creates an attachment from raw file data
+func NewAttachment(filename, contentType string, data []byte) Attachment {
+	return Attachment{
+		Filename:    filename,
+		ContentType: contentType,
+		Content:     base64.StdEncoding.EncodeToString(data),
+	}
+}
+
+// NewInlineAttachment creates an inline attachment, e.g. an image referenced as "cid:<contentID>"
+func NewInlineAttachment(filename, contentType, contentID string, data []byte) Attachment {
+	attachment := NewAttachment(filename, contentType, data)
+	attachment.ContentID = contentID
+	return attachment
 }
 
 // ErrorResponse represents an error response from the service
@@ -69,6 +213,13 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// WithClientID identifies the client to the service on every sent mail
+func WithClientID(clientID string) ClientOption {
+	return func(c *Client) {
+		c.clientID = clientID
+	}
+}
+
 // WithHTTPClient sets a custom HTTP client
 func WithHTTPClient(httpClient *http.Client) ClientOption {
 	return func(c *Client) {
@@ -76,61 +227,154 @@ func WithHTTPClient(httpClient *http.Client) ClientOption {
 	}
 }
 
-// SendMail sends an email using the mail service
+// SendMail sends an email using the mail service.
+// If the service runs with a queue, the mail is only accepted for delivery.
+// Use SendMailWithResult to also get the message ID.
 func (c *Client) SendMail(ctx context.Context, request MailRequest) (*MailResponse, error) {
+	result, err := c.SendMailWithResult(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return &result.MailResponse, nil
+}
+
+// SendMailWithResult sends an email like SendMail and also returns the ID and
+// status of the message the service recorded for it.
+func (c *Client) SendMailWithResult(ctx context.Context, request MailRequest) (*SendMailResult, error) {
 	// Validate required fields
 	if request.To == "" {
 		return nil, fmt.Errorf("'to' field is required")
 	}
-	if request.Subject == "" {
+	if request.TemplateID == "" && request.Subject == "" {
 		return nil, fmt.Errorf("'subject' field is required")
 	}
-	if request.HtmlContent == "" {
+	if request.TemplateID == "" && request.HtmlContent == "" {
 		return nil, fmt.Errorf("'content' field is required")
 	}
-	// Note: From and FromName are optional - the service will use defaults if not provided
+	// Note: Cc, Bcc, ReplyTo, From and FromName are optional - the service will use defaults if not provided
 
-	// Prepare request body
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	var result SendMailResult
+	if err := c.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/v1/sendmail", c.baseURL), request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SendBatch sends several mails with a single request. The service checks
+// each mail on its own and hands the valid ones to the provider together, so
+// one invalid mail does not fail the others. The results are in the order of
+// requests; the error is only set if the batch as a whole failed.
+func (c *Client) SendBatch(ctx context.Context, requests []MailRequest) ([]BatchResult, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("at least one mail is required")
+	}
+
+	var batchResp batchResponse
+	if err := c.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/v1/sendmail/batch", c.baseURL), batchRequest{Messages: requests}, &batchResp); err != nil {
+		return nil, err
+	}
+	if len(batchResp.Results) != len(requests) {
+		return nil, fmt.Errorf("received %d results for %d mails", len(batchResp.Results), len(requests))
+	}
+	return batchResp.Results, nil
+}
+
+// doJSON sends body, if any, as JSON and decodes a successful response into
+// target, if any. Any 2xx status is a success, e.g. the 202 of a service with a queue.
+func (c *Client) doJSON(ctx context.Context, method, endpoint string, body any, target any) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
 	}
 
 	// Create HTTP request
-	url := fmt.Sprintf("%s/v1/sendmail", c.baseURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.clientID != "" {
+		req.Header.Set(ClientIDHeader, c.clientID)
+	}
 
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
 	// Handle error responses
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		var errorResp ErrorResponse
 		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err != nil {
-			return nil, fmt.Errorf("received HTTP %d but failed to decode error response: %w", resp.StatusCode, err)
+			return fmt.Errorf("received HTTP %d but failed to decode error response: %w", resp.StatusCode, err)
 		}
 		errorResp.Code = resp.StatusCode
-		return nil, errorResp
+		return errorResp
 	}
 
 	// Parse successful response
-	var mailResp MailResponse
-	if err := json.NewDecoder(resp.Body).Decode(&mailResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if target == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// GetMessage returns the delivery status of the message with the given ID
+func (c *Client) GetMessage(ctx context.Context, id string) (*Message, error) {
+	if id == "" {
+		return nil, fmt.Errorf("message id is required")
 	}
 
-	return &mailResp, nil
+	var message Message
+	if err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s/v1/messages/%s", c.baseURL, url.PathEscape(id)), nil, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ListMessages returns the messages matching the options, newest first
+func (c *Client) ListMessages(ctx context.Context, options ListMessagesOptions) ([]Message, error) {
+	query := url.Values{}
+	if options.Status != "" {
+		query.Set("status", string(options.Status))
+	}
+	if options.Recipient != "" {
+		query.Set("recipient", options.Recipient)
+	}
+	if !options.Since.IsZero() {
+		query.Set("since", options.Since.Format(time.RFC3339))
+	}
+	if !options.Until.IsZero() {
+		query.Set("until", options.Until.Format(time.RFC3339))
+	}
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/messages", c.baseURL)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var list listMessagesResponse
+	if err := c.doJSON(ctx, http.MethodGet, endpoint, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Messages, nil
 }
 
 // HealthCheck performs a health check against the service
@@ -153,8 +153,8 @@ func TestSendMail_OptionalFromFields(t *testing.T) {
 			To:          request.To,
 			Subject:     request.Subject,
 			HtmlContent: request.HtmlContent,
-			From:        "default@example.com",  // Service would populate this from env
-			FromName:    "Default Sender",       // Service would populate this from env
+			From:        "default@example.com", // Service would populate this from env
+			FromName:    "Default Sender",      // Service would populate this from env
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -190,6 +190,137 @@ func TestSendMail_OptionalFromFields(t *testing.T) {
 	}
 }
 
+func TestSendMail_CcBccReplyTo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request MailRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		if len(request.Cc) != 1 || request.Cc[0] != "manager@example.com" {
+			t.Errorf("Expected Cc [manager@example.com], got %v", request.Cc)
+		}
+		if len(request.Bcc) != 1 || request.Bcc[0] != "audit@example.com" {
+			t.Errorf("Expected Bcc [audit@example.com], got %v", request.Bcc)
+		}
+		if request.ReplyTo != "support@example.com" {
+			t.Errorf("Expected ReplyTo support@example.com, got %s", request.ReplyTo)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(MailResponse(request))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	response, err := client.SendMail(context.Background(), MailRequest{
+		To:          "test@example.com",
+		Cc:          []string{"manager@example.com"},
+		Bcc:         []string{"audit@example.com"},
+		ReplyTo:     "support@example.com",
+		Subject:     "Test Subject",
+		HtmlContent: "Test Body",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if response.ReplyTo != "support@example.com" {
+		t.Errorf("Expected ReplyTo support@example.com, got %s", response.ReplyTo)
+	}
+}
+
+func TestSendMail_TagsAndClientID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(ClientIDHeader); got != "billing" {
+			t.Errorf("Expected %s billing, got %q", ClientIDHeader, got)
+		}
+		var request MailRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(MailResponse(request))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithClientID("billing"))
+
+	response, err := client.SendMail(context.Background(), MailRequest{
+		To:          "test@example.com",
+		Subject:     "Test Subject",
+		HtmlContent: "Test Body",
+		Tags:        []string{"invoice"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(response.Tags) != 1 || response.Tags[0] != "invoice" {
+		t.Errorf("Expected Tags [invoice], got %v", response.Tags)
+	}
+}
+
+func TestSendMail_Markdown(t *testing.T) {
+	mockServer := NewMockMailServer()
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL())
+	_, err := client.SendMail(context.Background(), MailRequest{
+		To:          "test@example.com",
+		Subject:     "Deploy finished",
+		HtmlContent: "# Deploy finished",
+		ContentType: ContentTypeMarkdown,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if sent := mockServer.GetLastSentMail(); sent == nil || sent.ContentType != ContentTypeMarkdown {
+		t.Errorf("Expected the markdown content type to be sent, got %+v", sent)
+	}
+}
+
+func TestSendMail_Attachments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request MailRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		if len(request.Attachments) != 2 {
+			t.Fatalf("Expected 2 attachments, got %d", len(request.Attachments))
+		}
+		if request.Attachments[0].Content != "JVBERi0xLjQ=" {
+			t.Errorf("Expected base64 content JVBERi0xLjQ=, got %s", request.Attachments[0].Content)
+		}
+		if request.Attachments[1].ContentID != "logo" {
+			t.Errorf("Expected content id logo, got %s", request.Attachments[1].ContentID)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(MailResponse(request))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	_, err := client.SendMail(context.Background(), MailRequest{
+		To:          "test@example.com",
+		Subject:     "Invoice",
+		HtmlContent: `<p>Invoice attached</p><img src="cid:logo">`,
+		Attachments: []Attachment{
+			NewAttachment("invoice.pdf", "application/pdf", []byte("%PDF-1.4")),
+			NewInlineAttachment("logo.png", "image/png", "logo", []byte{0x89, 'P', 'N', 'G'}),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
 func TestSendMail_HTTPError(t *testing.T) {
 	// Create a test server that returns an error
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -270,3 +401,166 @@ func TestWithHTTPClient(t *testing.T) {
 		t.Errorf("Expected custom HTTP client to be set")
 	}
 }
+
+func TestClient_GetMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET request, got %s", r.Method)
+		}
+		if r.URL.Path != "/v1/messages/msg-1" {
+			t.Errorf("Expected path /v1/messages/msg-1, got %s", r.URL.Path)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg-1","status":"sent","to":"test@example.com","subject":"Hi","attempts":1,` +
+			`"provider":"mailjet","providerMessageIds":["uuid-1"],"createdAt":"2024-01-01T10:00:00Z","updatedAt":"2024-01-01T10:00:01Z",` +
+			`"history":[{"status":"accepted","time":"2024-01-01T10:00:00Z"},{"status":"sent","time":"2024-01-01T10:00:01Z"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	message, err := client.GetMessage(context.Background(), "msg-1")
+	if err != nil {
+		t.Fatalf("GetMessage failed: %v", err)
+	}
+
+	if message.Status != MessageStatusSent {
+		t.Errorf("Expected status sent, got %s", message.Status)
+	}
+	if message.Provider != "mailjet" || len(message.ProviderMessageIDs) != 1 || message.ProviderMessageIDs[0] != "uuid-1" {
+		t.Errorf("Unexpected provider details: %s %v", message.Provider, message.ProviderMessageIDs)
+	}
+	if len(message.History) != 2 {
+		t.Errorf("Expected 2 history events, got %d", len(message.History))
+	}
+}
+
+func TestClient_GetMessage_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message":"message not found"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, err := client.GetMessage(context.Background(), "unknown")
+
+	errorResp, ok := err.(ErrorResponse)
+	if !ok {
+		t.Fatalf("Expected ErrorResponse, got %T", err)
+	}
+	if errorResp.Code != http.StatusNotFound {
+		t.Errorf("Expected code 404, got %d", errorResp.Code)
+	}
+}
+
+func TestClient_GetMessage_EmptyID(t *testing.T) {
+	client := NewClient("http://localhost")
+	if _, err := client.GetMessage(context.Background(), ""); err == nil {
+		t.Error("Expected error for empty message id")
+	}
+}
+
+func TestClient_ListMessages(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("Expected path /v1/messages, got %s", r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("status") != "failed" {
+			t.Errorf("Expected status=failed, got %s", query.Get("status"))
+		}
+		if query.Get("recipient") != "test@example.com" {
+			t.Errorf("Expected recipient=test@example.com, got %s", query.Get("recipient"))
+		}
+		if query.Get("since") != "2024-01-01T00:00:00Z" {
+			t.Errorf("Expected since=2024-01-01T00:00:00Z, got %s", query.Get("since"))
+		}
+		if query.Get("limit") != "10" {
+			t.Errorf("Expected limit=10, got %s", query.Get("limit"))
+		}
+		if query.Has("until") {
+			t.Error("Expected no until parameter")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"messages":[{"id":"msg-2","status":"failed","lastError":"rejected"},{"id":"msg-1","status":"failed"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	messages, err := client.ListMessages(context.Background(), ListMessagesOptions{
+		Status:    MessageStatusFailed,
+		Recipient: "test@example.com",
+		Since:     since,
+		Limit:     10,
+	})
+	if err != nil {
+		t.Fatalf("ListMessages failed: %v", err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].ID != "msg-2" || messages[0].LastError != "rejected" {
+		t.Errorf("Unexpected first message: %+v", messages[0])
+	}
+}
+
+func TestClient_SendBatch(t *testing.T) {
+	mockServer := NewMockMailServer()
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL(), WithClientID("newsletter"))
+	requests := []MailRequest{
+		{To: "first@example.com", Subject: "First", HtmlContent: "Content"},
+		{To: "second@example.com", Subject: "Second", HtmlContent: "Content"},
+	}
+
+	results, err := client.SendBatch(context.Background(), requests)
+	if err != nil {
+		t.Fatalf("Failed to send batch: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if !result.OK() || result.ID == "" || result.Status != MessageStatusSent {
+			t.Errorf("Expected result %d to be sent, got %+v", i, result)
+		}
+	}
+	if mockServer.SentMailCount() != 2 {
+		t.Errorf("Expected 2 sent mails, got %d", mockServer.SentMailCount())
+	}
+
+	// A failing mail fails its own result only, not the batch
+	mockServer.SetSendMailStatus(http.StatusInternalServerError, "provider unavailable")
+	results, err = client.SendBatch(context.Background(), requests[:1])
+	if err != nil {
+		t.Fatalf("Failed to send batch: %v", err)
+	}
+	if results[0].OK() || results[0].Code != http.StatusInternalServerError || results[0].Error != "provider unavailable" {
+		t.Errorf("Expected a failed result, got %+v", results[0])
+	}
+}
+
+func TestClient_SendBatch_Errors(t *testing.T) {
+	client := NewClient("http://localhost:8080")
+	if _, err := client.SendBatch(context.Background(), nil); err == nil {
+		t.Error("Expected error for an empty batch")
+	}
+
+	// The service must answer with one result per mail
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(batchResponse{Results: []BatchResult{{Code: http.StatusOK}}})
+	}))
+	defer server.Close()
+
+	client = NewClient(server.URL)
+	requests := []MailRequest{{To: "a@example.com"}, {To: "b@example.com"}}
+	if _, err := client.SendBatch(context.Background(), requests); err == nil {
+		t.Error("Expected error for a missing result")
+	}
+}
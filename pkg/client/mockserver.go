@@ -2,15 +2,23 @@ package client
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 // MockMailServer is a mock implementation of the mail service for testing
 type MockMailServer struct {
 	server       *httptest.Server
 	sentMails    []MailRequest
+	messages     []Message
+	templates    map[string][]TemplateVersion
+	templateInfo map[string]Template
 	mu           sync.RWMutex
 	healthStatus int
 	sendStatus   int
@@ -21,6 +29,8 @@ type MockMailServer struct {
 func NewMockMailServer() *MockMailServer {
 	mock := &MockMailServer{
 		sentMails:    make([]MailRequest, 0),
+		templates:    make(map[string][]TemplateVersion),
+		templateInfo: make(map[string]Template),
 		healthStatus: http.StatusOK,
 		sendStatus:   http.StatusOK,
 	}
@@ -65,31 +75,369 @@ func NewMockMailServer() *MockMailServer {
 			return
 		}
 
+		// Mails naming a template are recorded without rendering it
+		if request.TemplateID != "" && !mock.hasTemplate(request.TemplateID) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"message": "template not found: " + request.TemplateID,
+			})
+			return
+		}
+
 		// Store the mail
 		mock.mu.Lock()
 		mock.sentMails = append(mock.sentMails, request)
+		id := fmt.Sprintf("mock-%d", len(mock.sentMails))
 		mock.mu.Unlock()
 
 		// Return configured status
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(sendStatus)
 
-		if sendStatus != http.StatusOK {
+		if sendStatus != http.StatusOK && sendStatus != http.StatusAccepted {
 			_ = json.NewEncoder(w).Encode(map[string]string{
 				"message": errorMsg,
 			})
 			return
 		}
 
-		// Return the mail request as response
-		response := MailResponse(request)
+		// Record the message for status lookups; it is queued if the service answered 202
+		status := MessageStatusSent
+		if sendStatus == http.StatusAccepted {
+			status = MessageStatusAccepted
+		}
+		mock.addMessage(id, request, status)
+
+		// Return the mail request as response, without what the service leaves out
+		response := SendMailResult{MailResponse: MailResponse(request), ID: id, Status: status}
+		response.Bcc, response.Attachments = nil, nil
 		_ = json.NewEncoder(w).Encode(response)
 	})
 
+	// Batch send endpoint
+	mux.HandleFunc("/v1/sendmail/batch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		mock.mu.RLock()
+		sendStatus := mock.sendStatus
+		errorMsg := mock.errorMessage
+		mock.mu.RUnlock()
+
+		var request batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Messages) == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"message": "Invalid request body",
+			})
+			return
+		}
+
+		// Like the service, a failing mail only fails its own result
+		status := MessageStatusSent
+		responseStatus := http.StatusOK
+		if sendStatus == http.StatusAccepted {
+			status = MessageStatusAccepted
+			responseStatus = http.StatusAccepted
+		}
+		results := make([]BatchResult, len(request.Messages))
+		for i, mail := range request.Messages {
+			mock.mu.Lock()
+			mock.sentMails = append(mock.sentMails, mail)
+			id := fmt.Sprintf("mock-%d", len(mock.sentMails))
+			mock.mu.Unlock()
+
+			if sendStatus != http.StatusOK && sendStatus != http.StatusAccepted {
+				results[i] = BatchResult{Code: sendStatus, Error: errorMsg}
+				continue
+			}
+			mock.addMessage(id, mail, status)
+			results[i] = BatchResult{ID: id, Status: status, Code: sendStatus}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(responseStatus)
+		_ = json.NewEncoder(w).Encode(batchResponse{Results: results})
+	})
+
+	// Message status endpoints
+	mux.HandleFunc("/v1/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		message, ok := mock.message(r.PathValue("id"))
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"message": "message not found",
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(message)
+	})
+
+	mux.HandleFunc("/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		limit, _ := strconv.Atoi(query.Get("limit"))
+
+		// Newest first, like the service
+		mock.mu.RLock()
+		messages := make([]Message, 0, len(mock.messages))
+		for i := len(mock.messages) - 1; i >= 0; i-- {
+			message := mock.messages[i]
+			if status := query.Get("status"); status != "" && string(message.Status) != status {
+				continue
+			}
+			if recipient := query.Get("recipient"); recipient != "" && !hasRecipient(message, recipient) {
+				continue
+			}
+			if limit > 0 && len(messages) == limit {
+				break
+			}
+			messages = append(messages, message)
+		}
+		mock.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(listMessagesResponse{Messages: messages})
+	})
+
+	// Render endpoint; templates are returned unrendered in the translation for
+	// the locale, and only missing translations are reported
+	mux.HandleFunc("/v1/render", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var request MailRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+			return
+		}
+
+		result := RenderResult{
+			Subject:     request.Subject,
+			HtmlContent: request.HtmlContent,
+			TextContent: request.TextContent,
+			TemplateID:  request.TemplateID,
+			Warnings:    []Warning{},
+		}
+		if request.TemplateID != "" {
+			if !mock.hasTemplate(request.TemplateID) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"message": "template not found: " + request.TemplateID})
+				return
+			}
+			mock.mu.RLock()
+			template := mock.template(request.TemplateID)
+			mock.mu.RUnlock()
+			result.Subject, result.HtmlContent, result.TextContent = template.Subject, template.HtmlContent, template.TextContent
+			result.TemplateVersion = template.Version
+			if locale, ok := localize(template.Locales, request.Locale); ok {
+				translation := template.Locales[locale]
+				result.Subject, result.HtmlContent, result.TextContent = translation.Subject, translation.HtmlContent, translation.TextContent
+				result.Locale = locale
+			} else if request.Locale != "" {
+				result.Warnings = append(result.Warnings, Warning{Code: WarningMissingTranslation, Message: "no translation for " + request.Locale})
+			}
+		}
+		result.Headers = []HeaderField{{Name: "To", Value: request.To}, {Name: "Subject", Value: result.Subject}}
+		writeJSON(w, http.StatusOK, result)
+	})
+
+	mock.registerTemplateHandlers(mux)
+
 	mock.server = httptest.NewServer(mux)
 	return mock
 }
 
+// addMessage records a sent mail for status lookups
+func (m *MockMailServer) addMessage(id string, request MailRequest, status MessageStatus) {
+	now := time.Now().UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, Message{
+		ID:         id,
+		Status:     status,
+		To:         request.To,
+		Cc:         request.Cc,
+		Subject:    request.Subject,
+		From:       request.From,
+		Recipients: len(strings.Split(request.To, ",")) + len(request.Cc) + len(request.Bcc),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		History:    []MessageEvent{{Status: status, Time: now}},
+	})
+}
+
+// registerTemplateHandlers adds the template endpoints. Like the service, every
+// update adds a version, but the mock does not check the template syntax.
+func (m *MockMailServer) registerTemplateHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/templates", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			m.mu.RLock()
+			templates := make([]Template, 0, len(m.templateInfo))
+			for id := range m.templateInfo {
+				templates = append(templates, m.template(id))
+			}
+			m.mu.RUnlock()
+			slices.SortFunc(templates, func(a, b Template) int {
+				return strings.Compare(a.ID, b.ID)
+			})
+			writeJSON(w, http.StatusOK, listTemplatesResponse{Templates: templates})
+		case http.MethodPost:
+			var request createTemplateRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ID == "" || request.Subject == "" || request.HtmlContent == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+				return
+			}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if _, ok := m.templateInfo[request.ID]; ok {
+				writeJSON(w, http.StatusConflict, map[string]string{"message": "template already exists: " + request.ID})
+				return
+			}
+			now := time.Now().UTC()
+			m.templateInfo[request.ID] = Template{ID: request.ID, CreatedAt: now}
+			m.addTemplateVersion(request.ID, request.TemplateDefinition, now)
+			writeJSON(w, http.StatusCreated, m.template(request.ID))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/v1/templates/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.templateInfo[id]; !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "template not found"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, m.template(id))
+		case http.MethodPut:
+			var definition TemplateDefinition
+			if err := json.NewDecoder(r.Body).Decode(&definition); err != nil || definition.Subject == "" || definition.HtmlContent == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Invalid request body"})
+				return
+			}
+			m.addTemplateVersion(id, definition, time.Now().UTC())
+			writeJSON(w, http.StatusOK, m.template(id))
+		case http.MethodDelete:
+			delete(m.templateInfo, id)
+			delete(m.templates, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/v1/templates/{id}/versions/{version}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		version, _ := strconv.Atoi(r.PathValue("version"))
+		m.mu.RLock()
+		versions := m.templates[r.PathValue("id")]
+		m.mu.RUnlock()
+		if version < 1 || version > len(versions) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "template not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, versions[version-1])
+	})
+}
+
+// addTemplateVersion appends a version to a template; the caller holds the lock
+func (m *MockMailServer) addTemplateVersion(id string, definition TemplateDefinition, now time.Time) {
+	info := m.templateInfo[id]
+	if definition.Name != "" {
+		info.Name = definition.Name
+	}
+	if definition.Description != "" {
+		info.Description = definition.Description
+	}
+	info.UpdatedAt = now
+	m.templateInfo[id] = info
+	m.templates[id] = append(m.templates[id], TemplateVersion{
+		Version:     len(m.templates[id]) + 1,
+		Subject:     definition.Subject,
+		HtmlContent: definition.HtmlContent,
+		TextContent: definition.TextContent,
+		ContentType: definition.ContentType,
+		Locales:     definition.Locales,
+		CreatedAt:   now,
+	})
+}
+
+// template returns a template with its latest version; the caller holds the lock
+func (m *MockMailServer) template(id string) Template {
+	template := m.templateInfo[id]
+	versions := m.templates[id]
+	latest := versions[len(versions)-1]
+	template.Version = latest.Version
+	template.Subject = latest.Subject
+	template.HtmlContent = latest.HtmlContent
+	template.TextContent = latest.TextContent
+	template.ContentType = latest.ContentType
+	template.Locales = latest.Locales
+	template.Versions = make([]TemplateVersionInfo, 0, len(versions))
+	for _, version := range versions {
+		template.Versions = append(template.Versions, TemplateVersionInfo{Version: version.Version, CreatedAt: version.CreatedAt})
+	}
+	return template
+}
+
+func (m *MockMailServer) hasTemplate(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.templateInfo[id]
+	return ok
+}
+
+// localize returns the key of the translation for locale, falling back to
+// less specific tags like the service, e.g. from "de-AT" to "de"
+func localize(locales map[string]TemplateLocale, locale string) (string, bool) {
+	tag := strings.ReplaceAll(locale, "_", "-")
+	for tag != "" {
+		for key := range locales {
+			if strings.EqualFold(key, tag) {
+				return key, true
+			}
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return "", false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
 // URL returns the base URL of the mock server
 func (m *MockMailServer) URL() string {
 	return m.server.URL
@@ -129,6 +477,9 @@ func (m *MockMailServer) Reset() {
 	defer m.mu.Unlock()
 
 	m.sentMails = make([]MailRequest, 0)
+	m.messages = nil
+	m.templates = make(map[string][]TemplateVersion)
+	m.templateInfo = make(map[string]Template)
 	m.healthStatus = http.StatusOK
 	m.sendStatus = http.StatusOK
 	m.errorMessage = ""
@@ -141,7 +492,8 @@ func (m *MockMailServer) SetHealthStatus(status int) {
 	m.healthStatus = status
 }
 
-// SetSendMailStatus configures the HTTP status code and error message returned by the sendmail endpoint
+// SetSendMailStatus configures the HTTP status code and error message returned by the sendmail endpoint.
+// Use http.StatusAccepted to simulate a service that queues mail; the response then carries a message ID.
 func (m *MockMailServer) SetSendMailStatus(status int, errorMessage string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -149,6 +501,44 @@ func (m *MockMailServer) SetSendMailStatus(status int, errorMessage string) {
 	m.errorMessage = errorMessage
 }
 
+// SetMessageStatus changes the status of a recorded message, e.g. to simulate
+// background delivery of a queued mail. It reports whether the message exists.
+func (m *MockMailServer) SetMessageStatus(id string, status MessageStatus) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.messages {
+		if m.messages[i].ID == id {
+			now := time.Now().UTC()
+			m.messages[i].Status = status
+			m.messages[i].UpdatedAt = now
+			m.messages[i].History = append(m.messages[i].History, MessageEvent{Status: status, Time: now})
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockMailServer) message(id string) (Message, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, message := range m.messages {
+		if message.ID == id {
+			return message, true
+		}
+	}
+	return Message{}, false
+}
+
+func hasRecipient(message Message, recipient string) bool {
+	addresses := append(strings.Split(message.To, ","), message.Cc...)
+	for _, address := range addresses {
+		if strings.EqualFold(strings.TrimSpace(address), recipient) {
+			return true
+		}
+	}
+	return false
+}
+
 // SentMailCount returns the number of mails sent to the mock server
 func (m *MockMailServer) SentMailCount() int {
 	m.mu.RLock()
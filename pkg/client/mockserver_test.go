@@ -226,6 +226,63 @@ func TestMockMailServer_ErrorResponse(t *testing.T) {
 	}
 }
 
+func TestMockMailServer_Accepted(t *testing.T) {
+	mockServer := NewMockMailServer()
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL())
+
+	// Simulate a service running with a queue
+	mockServer.SetSendMailStatus(http.StatusAccepted, "")
+
+	request := MailRequest{
+		To:          "test@example.com",
+		Subject:     "Test",
+		HtmlContent: "Content",
+	}
+
+	response, err := client.SendMailWithResult(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Failed to send mail: %v", err)
+	}
+
+	if response.ID == "" {
+		t.Error("Expected a message ID for an accepted mail")
+	}
+	if response.Status != "accepted" {
+		t.Errorf("Expected status 'accepted', got '%s'", response.Status)
+	}
+	if response.To != request.To {
+		t.Errorf("Expected To: %s, got %s", request.To, response.To)
+	}
+}
+
+func TestMockMailServer_BatchAccepted(t *testing.T) {
+	mockServer := NewMockMailServer()
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL())
+	mockServer.SetSendMailStatus(http.StatusAccepted, "")
+
+	results, err := client.SendBatch(context.Background(), []MailRequest{
+		{To: "test@example.com", Subject: "Test", HtmlContent: "Content"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to send batch: %v", err)
+	}
+	if results[0].Code != http.StatusAccepted || results[0].Status != MessageStatusAccepted {
+		t.Errorf("Expected an accepted result, got %+v", results[0])
+	}
+
+	message, err := client.GetMessage(context.Background(), results[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if message.To != "test@example.com" || message.Status != MessageStatusAccepted {
+		t.Errorf("Unexpected message: %+v", message)
+	}
+}
+
 func TestMockMailServer_ConcurrentRequests(t *testing.T) {
 	mockServer := NewMockMailServer()
 	defer mockServer.Close()
@@ -325,3 +382,45 @@ func ExampleMockMailServer_errorScenario() {
 		println("Mail sent successfully after reset")
 	}
 }
+
+func TestMockMailServer_MessageStatus(t *testing.T) {
+	mockServer := NewMockMailServer()
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL())
+	mockServer.SetSendMailStatus(http.StatusAccepted, "")
+
+	response, err := client.SendMailWithResult(context.Background(), MailRequest{
+		To:          "test@example.com",
+		Subject:     "Test",
+		HtmlContent: "Content",
+	})
+	if err != nil {
+		t.Fatalf("Failed to send mail: %v", err)
+	}
+
+	message, err := client.GetMessage(context.Background(), response.ID)
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if message.Status != MessageStatusAccepted {
+		t.Errorf("Expected status accepted, got %s", message.Status)
+	}
+
+	// Simulate background delivery
+	if !mockServer.SetMessageStatus(response.ID, MessageStatusSent) {
+		t.Fatal("Expected message to exist")
+	}
+
+	sent, err := client.ListMessages(context.Background(), ListMessagesOptions{Status: MessageStatusSent})
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if len(sent) != 1 || sent[0].ID != response.ID {
+		t.Errorf("Expected the sent message to be listed, got %+v", sent)
+	}
+
+	if _, err := client.GetMessage(context.Background(), "unknown"); err == nil {
+		t.Error("Expected error for unknown message")
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Warning codes of a RenderResult
+const (
+	// WarningMissingVariable marks a template variable missing from Data; sending would fail
+	WarningMissingVariable = "missingVariable"
+	// WarningMissingTranslation marks a template without a translation for Locale
+	WarningMissingTranslation = "missingTranslation"
+	// WarningInvalidMail marks a mail the service would reject, e.g. without recipient
+	WarningInvalidMail = "invalidMail"
+	// WarningBrokenLink marks a link or image that does not resolve in a mail client
+	WarningBrokenLink = "brokenLink"
+	// WarningBodyTooLarge marks an HTML part that mail clients clip
+	WarningBodyTooLarge = "bodyTooLarge"
+)
+
+// RenderResult is a mail as the service would hand it to the provider
+type RenderResult struct {
+	Subject     string `json:"subject"`
+	HtmlContent string `json:"content"`
+	// TextContent is the text part, generated from the HTML if the mail has none
+	TextContent     string        `json:"textContent"`
+	Headers         []HeaderField `json:"headers"`
+	TemplateID      string        `json:"templateId,omitempty"`
+	TemplateVersion int           `json:"templateVersion,omitempty"`
+	// Locale is the translation of the template used, empty for its default content
+	Locale   string    `json:"locale,omitempty"`
+	Warnings []Warning `json:"warnings"`
+}
+
+// HeaderField is a header of the rendered mail
+type HeaderField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Warning is a problem found while rendering a mail
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Render renders a mail without sending it, e.g. to preview a template with
+// data. Problems of the mail are returned as warnings of the result; the error
+// is only set if the mail cannot be rendered at all, e.g. for an unknown template.
+func (c *Client) Render(ctx context.Context, request MailRequest) (*RenderResult, error) {
+	var result RenderResult
+	if err := c.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/v1/render", c.baseURL), request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClient_Render(t *testing.T) {
+	mockServer := NewMockMailServer()
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL())
+	ctx := context.Background()
+
+	result, err := client.Render(ctx, MailRequest{To: "a@example.com", Subject: "Hi", HtmlContent: "<p>Hi</p>"})
+	if err != nil {
+		t.Fatalf("Failed to render mail: %v", err)
+	}
+	if result.Subject != "Hi" || result.HtmlContent != "<p>Hi</p>" || len(result.Headers) == 0 || len(result.Warnings) != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if _, err := client.CreateTemplate(ctx, "welcome", TemplateDefinition{Subject: "Welcome {{.name}}", HtmlContent: "<p>Hi</p>"}); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	result, err = client.Render(ctx, MailRequest{To: "a@example.com", TemplateID: "welcome"})
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if result.TemplateVersion != 1 || result.Subject != "Welcome {{.name}}" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if _, err := client.UpdateTemplate(ctx, "welcome", TemplateDefinition{
+		Subject:     "Welcome {{.name}}",
+		HtmlContent: "<p>Hi</p>",
+		Locales:     map[string]TemplateLocale{"de": {Subject: "Willkommen {{.name}}", HtmlContent: "<p>Hallo</p>"}},
+	}); err != nil {
+		t.Fatalf("Failed to update template: %v", err)
+	}
+	result, err = client.Render(ctx, MailRequest{To: "a@example.com", TemplateID: "welcome", Locale: "de-AT"})
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if result.Locale != "de" || result.Subject != "Willkommen {{.name}}" || len(result.Warnings) != 0 {
+		t.Errorf("Unexpected localized result: %+v", result)
+	}
+	result, err = client.Render(ctx, MailRequest{To: "a@example.com", TemplateID: "welcome", Locale: "fr"})
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if result.Locale != "" || len(result.Warnings) != 1 || result.Warnings[0].Code != WarningMissingTranslation {
+		t.Errorf("Expected a missing translation warning, got %+v", result)
+	}
+
+	var errorResp ErrorResponse
+	if _, err := client.Render(ctx, MailRequest{TemplateID: "missing"}); !errors.As(err, &errorResp) || errorResp.Code != http.StatusBadRequest {
+		t.Errorf("Expected a 400 error for an unknown template, got %v", err)
+	}
+	if mockServer.SentMailCount() != 0 {
+		t.Errorf("Expected no mail to be sent, got %d", mockServer.SentMailCount())
+	}
+}
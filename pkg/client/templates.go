@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TemplateDefinition is the content of a template version. Subject and
+// TextContent are Go text/template templates, HtmlContent an html/template
+// template, e.g. "Welcome {{.name}}".
+type TemplateDefinition struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Subject     string `json:"subject"`
+	HtmlContent string `json:"content"`
+	TextContent string `json:"textContent,omitempty"`
+	// ContentType is ContentTypeMarkdown if HtmlContent is written in Markdown,
+	// the data is then escaped for Markdown. It applies to the locales as well
+	ContentType string `json:"contentType,omitempty"`
+	// Locales holds translations keyed by language tag, e.g. "de" or "de-AT"
+	Locales map[string]TemplateLocale `json:"locales,omitempty"`
+}
+
+// TemplateLocale is the translation of a template into one locale. Subject
+// and HtmlContent are required, so that no part is left untranslated.
+type TemplateLocale struct {
+	Subject     string `json:"subject"`
+	HtmlContent string `json:"content"`
+	TextContent string `json:"textContent,omitempty"`
+}
+
+// Template is a stored template with the content of its latest version
+type Template struct {
+	ID          string                    `json:"id"`
+	Name        string                    `json:"name,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Version     int                       `json:"version"`
+	Subject     string                    `json:"subject"`
+	HtmlContent string                    `json:"content"`
+	TextContent string                    `json:"textContent,omitempty"`
+	ContentType string                    `json:"contentType,omitempty"`
+	Locales     map[string]TemplateLocale `json:"locales,omitempty"`
+	CreatedAt   time.Time                 `json:"createdAt"`
+	UpdatedAt   time.Time                 `json:"updatedAt"`
+	Versions    []TemplateVersionInfo     `json:"versions"`
+}
+
+// TemplateVersionInfo lists a version of a template without its content
+type TemplateVersionInfo struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TemplateVersion is one immutable version of a template
+type TemplateVersion struct {
+	Version     int                       `json:"version"`
+	Subject     string                    `json:"subject"`
+	HtmlContent string                    `json:"content"`
+	TextContent string                    `json:"textContent,omitempty"`
+	ContentType string                    `json:"contentType,omitempty"`
+	Locales     map[string]TemplateLocale `json:"locales,omitempty"`
+	CreatedAt   time.Time                 `json:"createdAt"`
+}
+
+// createTemplateRequest is the body of the template create endpoint
+type createTemplateRequest struct {
+	ID string `json:"id"`
+	TemplateDefinition
+}
+
+// listTemplatesResponse is the envelope of the template list endpoint
+type listTemplatesResponse struct {
+	Templates []Template `json:"templates"`
+}
+
+// CreateTemplate stores a new template under id as its version 1.
+// An id that is already taken returns an ErrorResponse with code 409.
+func (c *Client) CreateTemplate(ctx context.Context, id string, definition TemplateDefinition) (*Template, error) {
+	if id == "" {
+		return nil, fmt.Errorf("template id is required")
+	}
+
+	var template Template
+	request := createTemplateRequest{ID: id, TemplateDefinition: definition}
+	if err := c.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/v1/templates", c.baseURL), request, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// UpdateTemplate stores definition as a new version of the template. Earlier
+// versions stay available, so mails that pin a version are not affected.
+func (c *Client) UpdateTemplate(ctx context.Context, id string, definition TemplateDefinition) (*Template, error) {
+	if id == "" {
+		return nil, fmt.Errorf("template id is required")
+	}
+
+	var template Template
+	if err := c.doJSON(ctx, http.MethodPut, c.templateURL(id), definition, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetTemplate returns the template with the content of its latest version
+func (c *Client) GetTemplate(ctx context.Context, id string) (*Template, error) {
+	if id == "" {
+		return nil, fmt.Errorf("template id is required")
+	}
+
+	var template Template
+	if err := c.doJSON(ctx, http.MethodGet, c.templateURL(id), nil, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetTemplateVersion returns the given version of a template
+func (c *Client) GetTemplateVersion(ctx context.Context, id string, version int) (*TemplateVersion, error) {
+	if id == "" {
+		return nil, fmt.Errorf("template id is required")
+	}
+
+	var templateVersion TemplateVersion
+	endpoint := fmt.Sprintf("%s/versions/%d", c.templateURL(id), version)
+	if err := c.doJSON(ctx, http.MethodGet, endpoint, nil, &templateVersion); err != nil {
+		return nil, err
+	}
+	return &templateVersion, nil
+}
+
+// ListTemplates returns all templates ordered by id
+func (c *Client) ListTemplates(ctx context.Context) ([]Template, error) {
+	var list listTemplatesResponse
+	if err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s/v1/templates", c.baseURL), nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Templates, nil
+}
+
+// DeleteTemplate deletes the template with all of its versions
+func (c *Client) DeleteTemplate(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("template id is required")
+	}
+	return c.doJSON(ctx, http.MethodDelete, c.templateURL(id), nil, nil)
+}
+
+func (c *Client) templateURL(id string) string {
+	return fmt.Sprintf("%s/v1/templates/%s", c.baseURL, url.PathEscape(id))
+}
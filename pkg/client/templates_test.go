@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClient_Templates(t *testing.T) {
+	mockServer := NewMockMailServer()
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL())
+	ctx := context.Background()
+	welcome := TemplateDefinition{Name: "Welcome", Subject: "Welcome {{.name}}", HtmlContent: "<p>Hi {{.name}}</p>"}
+
+	created, err := client.CreateTemplate(ctx, "welcome", welcome)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	if created.ID != "welcome" || created.Version != 1 || created.Subject != welcome.Subject {
+		t.Errorf("Unexpected template: %+v", created)
+	}
+
+	var errorResp ErrorResponse
+	if _, err := client.CreateTemplate(ctx, "welcome", welcome); !errors.As(err, &errorResp) || errorResp.Code != http.StatusConflict {
+		t.Errorf("Expected a 409 error for a taken id, got %v", err)
+	}
+
+	updated, err := client.UpdateTemplate(ctx, "welcome", TemplateDefinition{Subject: "Hello {{.name}}", HtmlContent: "<p>Hello</p>"})
+	if err != nil {
+		t.Fatalf("Failed to update template: %v", err)
+	}
+	if updated.Version != 2 || updated.Name != "Welcome" || len(updated.Versions) != 2 {
+		t.Errorf("Expected version 2, got %+v", updated)
+	}
+
+	first, err := client.GetTemplateVersion(ctx, "welcome", 1)
+	if err != nil {
+		t.Fatalf("Failed to get template version: %v", err)
+	}
+	if first.Version != 1 || first.Subject != welcome.Subject {
+		t.Errorf("Unexpected version: %+v", first)
+	}
+
+	templates, err := client.ListTemplates(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list templates: %v", err)
+	}
+	if len(templates) != 1 || templates[0].Subject != "Hello {{.name}}" {
+		t.Errorf("Unexpected templates: %+v", templates)
+	}
+
+	if err := client.DeleteTemplate(ctx, "welcome"); err != nil {
+		t.Fatalf("Failed to delete template: %v", err)
+	}
+	if _, err := client.GetTemplate(ctx, "welcome"); !errors.As(err, &errorResp) || errorResp.Code != http.StatusNotFound {
+		t.Errorf("Expected a 404 error after deleting, got %v", err)
+	}
+}
+
+func TestSendMail_Template(t *testing.T) {
+	mockServer := NewMockMailServer()
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL())
+	ctx := context.Background()
+	if _, err := client.CreateTemplate(ctx, "welcome", TemplateDefinition{Subject: "Welcome {{.name}}", HtmlContent: "<p>Hi {{.name}}</p>"}); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	// Subject and content come from the template
+	request := MailRequest{To: "test@example.com", TemplateID: "welcome", Data: map[string]any{"name": "Ann"}}
+	if _, err := client.SendMail(ctx, request); err != nil {
+		t.Fatalf("Failed to send mail: %v", err)
+	}
+	sent := mockServer.GetLastSentMail()
+	if sent.TemplateID != "welcome" || sent.Data["name"] != "Ann" {
+		t.Errorf("Unexpected sent mail: %+v", sent)
+	}
+
+	request.TemplateID = "missing"
+	var errorResp ErrorResponse
+	if _, err := client.SendMail(ctx, request); !errors.As(err, &errorResp) || errorResp.Code != http.StatusBadRequest {
+		t.Errorf("Expected a 400 error for an unknown template, got %v", err)
+	}
+}